@@ -0,0 +1,85 @@
+package api
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/validate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBodyBytes limits request bodies if Handlers.MaxBodyBytes is 0.
+const defaultMaxBodyBytes = 10 << 20
+
+// decode reads the JSON body of r into v and checks the rules declared on
+// v. Bodies that are too large, malformed or have fields v does not know
+// yield 413 or 400 errors; bodies failing the rules yield a 422 error
+// listing every failed field.
+func (h *Handlers) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err, limit)
+	}
+	if dec.More() {
+		return apperr.Invalid("invalid_payload", "Invalid request payload: unexpected data after the JSON value")
+	}
+
+	if fields := validate.Struct(v); len(fields) > 0 {
+		return apperr.Invalid("validation_failed", "Request failed validation", fields...).
+			WithStatus(http.StatusUnprocessableEntity)
+	}
+	return nil
+}
+
+// decodeError converts an error decoding a request body of at most limit
+// bytes.
+func decodeError(err error, limit int64) error {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return apperr.Wrap(err, apperr.Validation, "body_too_large", fmt.Sprintf("Request body exceeds %d bytes", limit)).
+			WithStatus(http.StatusRequestEntityTooLarge)
+	case errors.As(err, &typeErr):
+		e := invalidPayload(err)
+		e.Fields = []apperr.FieldError{{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type)}}
+		return e
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The decoder has no error type for unknown fields.
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		e := apperr.Wrap(err, apperr.Validation, "unknown_field", "Invalid request payload: unknown field "+field)
+		e.Fields = []apperr.FieldError{{Field: field, Message: "is not allowed"}}
+		return e
+	default:
+		return invalidPayload(err)
+	}
+}
+
+// jsonType describes the JSON values decoded into t.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
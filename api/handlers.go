@@ -1,54 +1,214 @@
 package api
 
 import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/chunking"
 	"cognivaultServer/collections"
-	"cognivaultServer/database"
+	"cognivaultServer/fetch"
+	"cognivaultServer/files"
+	"cognivaultServer/ingest"
+	"cognivaultServer/jobs"
+	"cognivaultServer/search"
 	"cognivaultServer/utils"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/oklog/ulid/v2"
 )
 
+// Handlers holds the dependencies shared by the HTTP handlers.
+type Handlers struct {
+	Store  collections.Store
+	Search *search.Service
+	Ingest *ingest.Ingester
+	Jobs   *jobs.Queue
+	// MaxBodyBytes limits the size of request bodies, defaultMaxBodyBytes
+	// if 0.
+	MaxBodyBytes int64
+	// TrashRetention is how long deleted records are kept in the trash
+	// before they are purged, 0 if they are kept until restored.
+	TrashRetention time.Duration
+	// FeedInterval is the time between polls of feeds subscribed to without
+	// an interval.
+	FeedInterval time.Duration
+}
+
 // CreateCollectionRequest represents the request body for creating a new collection.
 type CreateCollectionRequest struct {
-	Name string `json:"name"`
-	URL  string `json:"url,omitempty"`
+	Name string `json:"name" validate:"required,max=200,pattern=name"`
+	URL  string `json:"url,omitempty" validate:"max=2048"`
 	Text string `json:"text,omitempty"`
-	File string `json:"file,omitempty"`
-	Tag  string `json:"tag,omitempty"`
+	File string `json:"file,omitempty" validate:"max=4096"`
+	// Dir imports every file below a directory, tagged by subdirectory
+	// below Tag, which defaults to the name of the directory.
+	Dir string `json:"dir,omitempty" validate:"max=4096"`
+	Tag string `json:"tag,omitempty" validate:"max=200,pattern=path"`
+	// Include and Exclude filter the files of Dir with .gitignore-style
+	// globs.
+	Include []string `json:"include,omitempty" validate:"max=100"`
+	Exclude []string `json:"exclude,omitempty" validate:"max=100"`
+	// Chunking controls how long texts are split into data points. It
+	// defaults to chunking.DefaultOptions.
+	Chunking *chunking.Options `json:"chunking,omitempty"`
+	// KeepHTML keeps the raw HTML of a fetched page or HTML file as an
+	// attachment of the document next to the extracted text.
+	KeepHTML bool `json:"keep_html,omitempty"`
+	// Dedupe handles content that is already stored in the collection. It
+	// defaults to the dedupe policy of the collection.
+	Dedupe collections.DedupePolicy `json:"dedupe,omitempty" validate:"enum=reject|skip|merge|revision"`
+	// RecrawlInterval is the time between crawls of a fetched URL, such as
+	// "6h". It defaults to the configured interval; "0s" only crawls the
+	// URL on demand.
+	RecrawlInterval *collections.Duration `json:"recrawl_interval,omitempty"`
+}
+
+// minFetchInterval is the shortest time allowed between crawls of a source
+// or polls of a feed.
+const minFetchInterval = time.Minute
+
+// fetchIntervalError returns the error for an invalid interval between
+// crawls of a source or polls of a feed, or nil if d is valid.
+func fetchIntervalError(field string, d collections.Duration) error {
+	if d != 0 && time.Duration(d) < minFetchInterval {
+		return invalidField(field, "must be 0s or at least "+minFetchInterval.String())
+	}
+	return nil
 }
 
 // CreateCollectionResponse represents the response body for creating a new collection.
 type CreateCollectionResponse struct {
 	ID string `json:"id"`
+	// DocumentID is set if the text was split into several data points.
+	DocumentID   string   `json:"document_id,omitempty"`
+	DataPointIDs []string `json:"data_point_ids"`
+	// Metadata is the metadata stored on the data points, such as the title
+	// of an extracted page.
+	Metadata    map[string]interface{}   `json:"metadata,omitempty"`
+	Attachments []collections.Attachment `json:"attachments,omitempty"`
+	// Duplicates lists the data points whose content was already stored,
+	// which are included in DataPointIDs.
+	Duplicates []ingest.Duplicate `json:"duplicates,omitempty"`
+	// SourceID is set if a fetched URL is tracked for crawling.
+	SourceID string `json:"source_id,omitempty"`
+	// Files lists the files stored by a directory import and Failures
+	// those that were skipped.
+	Files    []ImportedFile   `json:"files,omitempty"`
+	Failures []ingest.Failure `json:"failures,omitempty"`
+}
+
+// ingestRequest returns the ingestion request for req.
+func (req CreateCollectionRequest) ingestRequest() ingest.Request {
+	return ingest.Request{
+		Collection: req.Name,
+		Tag:        req.Tag,
+		URL:        req.URL,
+		File:       req.File,
+		Dir:        req.Dir,
+		Text:       req.Text,
+		Include:    req.Include,
+		Exclude:    req.Exclude,
+		KeepHTML:   req.KeepHTML,
+		Chunking:   req.Chunking,
+		Dedupe:     req.Dedupe,
+
+		RecrawlInterval: req.RecrawlInterval,
+	}
+}
+
+// ImportedFile describes a file stored by a directory import.
+type ImportedFile struct {
+	Path         string             `json:"path"`
+	Tag          string             `json:"tag"`
+	DocumentID   string             `json:"document_id,omitempty"`
+	DataPointIDs []string           `json:"data_point_ids"`
+	Duplicates   []ingest.Duplicate `json:"duplicates,omitempty"`
+}
+
+// GetDocumentResponse represents the response body for getting a chunked document.
+type GetDocumentResponse struct {
+	Document    collections.Document     `json:"document"`
+	Chunks      []collections.DataPoint  `json:"chunks"`
+	Attachments []collections.Attachment `json:"attachments"`
 }
 
 // GetCollectionRequest represents the request parameters for getting data points from a collection.
 type GetCollectionRequest struct {
 	CollectionName string `json:"collection_name"`
 	Query          string `json:"query"`
+	// Tags restricts the data points to those carrying any of the tags, or
+	// all of them if Match is "all".
+	Tags  []string `json:"tags"`
+	Match string   `json:"match"`
+	// Filter selects data points by their metadata, such as
+	// meta.author = "Ada" AND meta.year > 2020.
+	Filter string `json:"filter"`
 }
 
 // GetCollectionResponse represents the response body for getting data points from a collection.
 type GetCollectionResponse struct {
 	DataPoints []collections.DataPoint `json:"data_points"`
+	Page       PageInfo                `json:"page"`
+}
+
+// SearchCollectionResponse represents the response body for searching a collection.
+type SearchCollectionResponse struct {
+	Query   string                     `json:"query"`
+	Mode    search.Mode                `json:"mode"`
+	Results []collections.SearchResult `json:"results"`
+}
+
+// SearchSettingsRequest represents the request body for updating the hybrid search settings of a collection.
+type SearchSettingsRequest struct {
+	KeywordWeight  float64 `json:"keyword_weight" validate:"min=0"`
+	SemanticWeight float64 `json:"semantic_weight" validate:"min=0"`
+	RRFK           int     `json:"rrf_k" validate:"min=1"`
+}
+
+// SearchSettingsResponse represents the hybrid search settings of a collection.
+type SearchSettingsResponse struct {
+	KeywordWeight  float64 `json:"keyword_weight"`
+	SemanticWeight float64 `json:"semantic_weight"`
+	RRFK           int     `json:"rrf_k"`
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// CreateTagRequest represents the request body for creating a tag.
+// Parent, a tag given by ID, name or slug, puts the tag below it.
+type CreateTagRequest struct {
+	Name   string `json:"name" validate:"required,max=200,pattern=path"`
+	Parent string `json:"parent,omitempty" validate:"max=200"`
+}
+
+// MoveTagRequest represents the request body for moving a tag below another one.
+// An empty Parent moves the tag to the top level.
+type MoveTagRequest struct {
+	Parent string `json:"parent" validate:"max=200"`
 }
 
 // UpdateTagRequest represents the request body for updating a tag.
 type UpdateTagRequest struct {
 	CollectionName string `json:"collection_name"`
 	TagID          string `json:"tag_id"`
-	NewTag         string `json:"new_tag"`
+	NewTag         string `json:"new_tag" validate:"required,max=200,pattern=path"`
 }
 
 // UpdateCollectionRequest represents the request body for updating a collection.
 type UpdateCollectionRequest struct {
 	CollectionName string `json:"collection_name"`
-	NewName        string `json:"new_name"`
+	NewName        string `json:"new_name" validate:"required,max=200,pattern=name"`
 }
 
 // DeleteTagRequest represents the request parameters for deleting a tag.
@@ -62,6 +222,12 @@ type DeleteCollectionRequest struct {
 	CollectionName string `json:"collection_name"`
 }
 
+// ListCollectionsResponse represents the response body for listing collections.
+type ListCollectionsResponse struct {
+	Collections []collections.CollectionSummary `json:"collections"`
+	Page        PageInfo                        `json:"page"`
+}
+
 // GetTagsRequest represents the request parameters for getting tags under a collection.
 type GetTagsRequest struct {
 	CollectionName string `json:"collection_name"`
@@ -70,6 +236,7 @@ type GetTagsRequest struct {
 // GetTagsResponse represents the response body for getting tags under a collection.
 type GetTagsResponse struct {
 	Tags []collections.Tag `json:"tags"`
+	Page PageInfo          `json:"page"`
 }
 
 // GetDataPointsRequest represents the request parameters for getting data points under a tag.
@@ -81,242 +248,2082 @@ type GetDataPointsRequest struct {
 // GetDataPointsResponse represents the response body for getting data points under a tag.
 type GetDataPointsResponse struct {
 	DataPoints []collections.DataPoint `json:"data_points"`
+	Page       PageInfo                `json:"page"`
+}
+
+// GetRevisionsResponse represents the response body for listing the revisions of a data point.
+type GetRevisionsResponse struct {
+	Revisions []collections.Revision `json:"revisions"`
+	Page      PageInfo               `json:"page"`
+}
+
+// DiffRevisionsResponse represents the response body for comparing two revisions of a data point.
+type DiffRevisionsResponse struct {
+	From int                       `json:"from"`
+	To   int                       `json:"to"`
+	Diff *collections.RevisionDiff `json:"diff"`
+}
+
+// DataPointRequest represents the request body for creating or replacing a data point.
+type DataPointRequest struct {
+	Value    string                 `json:"value" validate:"required"`
+	Metadata map[string]interface{} `json:"metadata,omitempty" validate:"max=100"`
+}
+
+// CreateDataPointRequest represents the request body for adding a data point.
+type CreateDataPointRequest struct {
+	Value    string                 `json:"value" validate:"required"`
+	Metadata map[string]interface{} `json:"metadata,omitempty" validate:"max=100"`
+	// Dedupe handles a value that is already stored in the collection. It
+	// defaults to the dedupe policy of the collection.
+	Dedupe collections.DedupePolicy `json:"dedupe,omitempty" validate:"enum=reject|skip|merge|revision"`
+}
+
+// PatchDataPointRequest represents the request body for partially updating a data point.
+// Metadata is merged into the stored metadata; keys set to null are removed.
+type PatchDataPointRequest struct {
+	Value    *string                `json:"value" validate:"notblank"`
+	Metadata map[string]interface{} `json:"metadata" validate:"max=100"`
+}
+
+// DataPointTagsRequest represents the request body for adding tags to a data point.
+// Tags the collection does not have yet are created.
+type DataPointTagsRequest struct {
+	Tags []string `json:"tags" validate:"required,max=100,each,required,max=200,pattern=path"`
+}
+
+// DataPointTagsResponse represents the response body listing the tags of a data point.
+type DataPointTagsResponse struct {
+	Tags []collections.Tag `json:"tags"`
+}
+
+// DedupeSettingsRequest represents the request body for updating the dedupe settings of a collection.
+type DedupeSettingsRequest struct {
+	Unique bool                     `json:"unique"`
+	Policy collections.DedupePolicy `json:"policy,omitempty" validate:"enum=reject|skip|merge|revision"`
+}
+
+// DedupeSettingsResponse represents the response body for the dedupe settings of a collection.
+type DedupeSettingsResponse struct {
+	Unique bool                     `json:"unique"`
+	Policy collections.DedupePolicy `json:"policy,omitempty"`
+}
+
+// ListDuplicatesResponse represents the response body for listing the duplicate content of a collection.
+type ListDuplicatesResponse struct {
+	Duplicates []collections.DuplicateGroup `json:"duplicates"`
+	Page       PageInfo                     `json:"page"`
+}
+
+// ListJobsResponse represents the response body for listing ingestion jobs.
+type ListJobsResponse struct {
+	Jobs []collections.Job `json:"jobs"`
+	Page PageInfo          `json:"page"`
+}
+
+// TrashItemResponse represents a collection, tag or data point in the trash.
+type TrashItemResponse struct {
+	collections.TrashItem
+	// PurgeAt is when the record is deleted for good, unset if the trash
+	// is not purged.
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// UpdateSourceRequest represents the request body for updating a source.
+type UpdateSourceRequest struct {
+	// Interval is the time between crawls, such as "6h"; "0s" only crawls
+	// the source on demand. It is required; 0s does not count as missing.
+	Interval *collections.Duration `json:"interval"`
+}
+
+// ListSourcesResponse represents the response body for listing sources.
+type ListSourcesResponse struct {
+	Sources []collections.Source `json:"sources"`
+	Page    PageInfo             `json:"page"`
+}
+
+// FetchSourceResponse represents the response body for crawling a source on demand.
+type FetchSourceResponse struct {
+	Source *collections.Source      `json:"source"`
+	Fetch  *collections.SourceFetch `json:"fetch"`
+}
+
+// ListSourceFetchesResponse represents the response body for listing the fetch history of a source.
+type ListSourceFetchesResponse struct {
+	Fetches []collections.SourceFetch `json:"fetches"`
+	Page    PageInfo                  `json:"page"`
+}
+
+// CreateFeedRequest represents the request body for subscribing to a feed.
+type CreateFeedRequest struct {
+	Collection string `json:"collection" validate:"required,max=200"`
+	// Tag is created if it does not exist yet.
+	Tag string `json:"tag" validate:"required,max=200,pattern=path"`
+	URL string `json:"url" validate:"required,max=2048"`
+	// FullText stores the text of the page each entry links to.
+	FullText bool `json:"full_text,omitempty"`
+	// Interval is the time between polls, such as "1h". It defaults to the
+	// configured interval; "0s" only polls the feed on demand.
+	Interval *collections.Duration `json:"interval,omitempty"`
+}
+
+// UpdateFeedRequest represents the request body for updating a feed. Fields left out are kept.
+type UpdateFeedRequest struct {
+	FullText *bool                 `json:"full_text,omitempty"`
+	Interval *collections.Duration `json:"interval,omitempty"`
+}
+
+// FeedPollResponse represents the response body for subscribing to a feed or polling it on demand.
+type FeedPollResponse struct {
+	Feed *collections.Feed `json:"feed"`
+	Poll *ingest.FeedPoll  `json:"poll"`
+}
+
+// ListFeedsResponse represents the response body for listing feeds.
+type ListFeedsResponse struct {
+	Feeds []collections.Feed `json:"feeds"`
+	Page  PageInfo           `json:"page"`
+}
+
+// ListFeedEntriesResponse represents the response body for listing the stored entries of a feed.
+type ListFeedEntriesResponse struct {
+	Entries []collections.FeedEntry `json:"entries"`
+	Page    PageInfo                `json:"page"`
+}
+
+// ListTrashResponse represents the response body for listing the trash.
+type ListTrashResponse struct {
+	Items []TrashItemResponse `json:"items"`
+	Page  PageInfo            `json:"page"`
 }
 
 // CreateCollectionHandler handles the HTTP request for creating a new collection.
-func CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateCollectionRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := h.decode(w, r, &req)
 	if err != nil {
-		utils.SendResponse(w, http.StatusBadRequest, "Invalid request payload")
+		sendError(w, r, err)
 		return
 	}
 
-	var data string
-	switch {
-	case req.URL != "":
-		data, err = utils.FetchURL(req.URL)
-		if err != nil {
-			utils.SendResponse(w, http.StatusBadRequest, "Failed to fetch URL")
+	// Slow sources can be ingested in the background instead.
+	if r.URL.Query().Get("async") == "true" {
+		h.submitJob(w, r, req)
+		return
+	}
+
+	if req.RecrawlInterval != nil {
+		if err := fetchIntervalError("recrawl_interval", *req.RecrawlInterval); err != nil {
+			sendError(w, r, err)
 			return
 		}
-	case req.File != "":
-		data, err = utils.ReadFile(req.File)
-		if err != nil {
-			utils.SendResponse(w, http.StatusBadRequest, "Failed to read file")
-			return
+	}
+
+	report, err := h.Ingest.Run(r.Context(), req.ingestRequest(), nil)
+	if err != nil {
+		sendError(w, r, ingestError(err))
+		return
+	}
+
+	resp := CreateCollectionResponse{
+		ID:           report.Collection.ID,
+		DataPointIDs: dataPointIDs(report.DataPoints()),
+		Duplicates:   report.Duplicates(),
+		Failures:     report.Failures,
+	}
+	if req.Dir != "" {
+		resp.Files = make([]ImportedFile, 0, len(report.Results))
+		for _, res := range report.Results {
+			file := ImportedFile{
+				Path:         fmt.Sprint(res.Metadata["path"]),
+				Tag:          res.Tag.Name,
+				DataPointIDs: dataPointIDs(res.DataPoints),
+				Duplicates:   res.Duplicates,
+			}
+			if res.Document != nil {
+				file.DocumentID = res.Document.ID
+			}
+			resp.Files = append(resp.Files, file)
 		}
-	case req.Text != "":
-		data = req.Text
+	} else {
+		result := report.Results[0]
+		resp.Metadata = result.Metadata
+		resp.Attachments = result.Attachments
+		if result.Document != nil {
+			resp.DocumentID = result.Document.ID
+		}
+		if result.Source != nil {
+			resp.SourceID = result.Source.ID
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+// ingestError converts a failed ingestion into an error with a status
+// matching the cause.
+func ingestError(err error) error {
+	switch {
+	case fetch.KindOf(err) != "":
+		return fetchError(err)
+	case files.KindOf(err) != "":
+		return fileError(err)
+	case errors.Is(err, collections.ErrDuplicateContent):
+		return duplicateError(err)
+	case errors.Is(err, ingest.ErrNoSource), errors.Is(err, ingest.ErrEmpty):
+		return err
 	default:
-		utils.SendResponse(w, http.StatusBadRequest, "Missing data source")
+		return internalError(err, "Failed to create collection")
+	}
+}
+
+// GetDocumentHandler handles the HTTP request for getting a chunked document with its chunks in order.
+func (h *Handlers) GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
 		return
 	}
 
-	tag := req.Tag
-	if tag == "" {
-		tag = req.URL
+	documentID := chi.URLParam(r, "documentID")
+	document, err := h.Store.GetDocument(r.Context(), documentID)
+	if err == nil && document.CollectionID != collection.ID {
+		err = fmt.Errorf("document %s: %w", documentID, collections.ErrNotFound)
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "document_not_found", "Document not found"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get document"))
+		return
 	}
 
-	collection := collections.Collection{
-		ID:   ulid.Make().String(),
-		Name: req.Name,
+	chunks, err := h.Store.ListDocumentDataPoints(r.Context(), document.ID)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get document"))
+		return
 	}
-	db, err := database.ConnectDB()
+	attachments, err := h.Store.ListAttachments(r.Context(), document.ID)
 	if err != nil {
-		log.Fatal(err)
+		sendError(w, r, internalError(err, "Failed to get document"))
+		return
+	}
+
+	render.JSON(w, r, GetDocumentResponse{Document: *document, Chunks: chunks, Attachments: attachments})
+}
+
+// GetAttachmentHandler handles the HTTP request for downloading an attachment of a document.
+func (h *Handlers) GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	documentID := chi.URLParam(r, "documentID")
+	attachmentID := chi.URLParam(r, "attachmentID")
+	attachment, err := h.Store.GetAttachment(r.Context(), attachmentID)
+	var document *collections.Document
+	if err == nil {
+		document, err = h.Store.GetDocument(r.Context(), attachment.DocumentID)
+	}
+	if err == nil && (attachment.DocumentID != documentID || document.CollectionID != collection.ID) {
+		err = fmt.Errorf("attachment %s: %w", attachmentID, collections.ErrNotFound)
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "attachment_not_found", "Attachment not found"))
+		return
 	}
-	defer db.Close()
-	err = collection.Create(db)
 	if err != nil {
-		utils.SendResponse(w, http.StatusInternalServerError, "Failed to create collection")
+		sendError(w, r, internalError(err, "Failed to get attachment"))
 		return
 	}
 
-	tagObj := collections.Tag{
-		ID:           ulid.Make().String(),
-		Name:         tag,
-		CollectionID: collection.ID,
+	// Attachments hold untrusted content such as fetched HTML, which must
+	// never be rendered on the API's origin.
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(attachment.Data)
+}
+
+// GetCollectionHandler handles the HTTP request for getting data points from a collection.
+// When a query is given the data points are searched by content and ranked by relevance.
+func (h *Handlers) GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req := GetCollectionRequest{
+		CollectionName: chi.URLParam(r, "collectionName"),
+		Query:          r.URL.Query().Get("query"),
+		Tags:           r.URL.Query()["tag"],
+		Match:          r.URL.Query().Get("match"),
+		Filter:         r.URL.Query().Get("filter"),
+	}
+	if req.Match != "" && req.Match != "any" && req.Match != "all" {
+		sendError(w, r, invalidField("match", "must be any or all"))
+		return
+	}
+	// Searches are not restricted by tag; rather than drop the tags, the
+	// combination is refused.
+	if req.Query != "" && (len(req.Tags) > 0 || req.Match != "") {
+		field := "tag"
+		if len(req.Tags) == 0 {
+			field = "match"
+		}
+		sendError(w, r, apperr.Invalid("invalid_parameters", "Invalid parameters: "+field+" cannot be combined with query",
+			apperr.FieldError{Field: field, Message: "cannot be combined with query"}).WithStatus(http.StatusUnprocessableEntity))
+		return
 	}
-	err = tagObj.CreateTag(db)
+	filter, err := metadataFilter(req.Filter)
 	if err != nil {
-		utils.SendResponse(w, http.StatusInternalServerError, "Failed to create tag")
+		sendError(w, r, err)
 		return
 	}
 
-	dataPoint := collections.DataPoint{
-		TagID: tagObj.ID,
-		Value: data,
+	collection, ok := h.collection(w, r, req.CollectionName)
+	if !ok {
+		return
+	}
+
+	if req.Query != "" {
+		limit, err := searchLimit(r)
+		if err != nil {
+			sendError(w, r, err)
+			return
+		}
+
+		results, err := h.Store.SearchDataPoints(r.Context(), collection.ID, req.Query, filter, limit)
+		if err != nil {
+			sendError(w, r, internalError(err, "Failed to search data points"))
+			return
+		}
+
+		render.JSON(w, r, SearchCollectionResponse{Query: req.Query, Mode: search.ModeKeyword, Results: results})
+		return
 	}
-	err = dataPoint.Create()
+
+	page, err := pageRequest(r, "")
 	if err != nil {
-		utils.SendResponse(w, http.StatusInternalServerError, "Failed to create data point")
+		sendError(w, r, err)
 		return
 	}
 
-	resp := CreateCollectionResponse{
-		ID: collection.ID,
+	var dataPoints []collections.DataPoint
+	if len(req.Tags) > 0 {
+		tagIDs := make([]string, len(req.Tags))
+		for i, name := range req.Tags {
+			tag, ok := h.namedTag(w, r, collection, name)
+			if !ok {
+				return
+			}
+			tagIDs[i] = tag.ID
+		}
+		dataPoints, err = h.Store.ListDataPointsByTags(r.Context(), tagIDs, req.Match == "all", filter, page)
+	} else {
+		dataPoints, err = h.Store.ListCollectionDataPoints(r.Context(), collection.ID, filter, page)
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get data points"))
+		return
 	}
+
+	resp := GetCollectionResponse{}
+	resp.DataPoints, resp.Page = nextPage(w, r, dataPoints, page, "", dataPointKey)
 	render.JSON(w, r, resp)
 }
 
-// GetCollectionHandler handles the HTTP request for getting data points from a collection.
-func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
-	var req GetCollectionRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+// SearchCollectionHandler handles the HTTP request for searching the data points of a collection
+// by keyword (full-text) or by meaning (embedding similarity).
+func (h *Handlers) SearchCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		sendError(w, r, apperr.Invalid("missing_parameter", "Missing query parameter q",
+			apperr.FieldError{Field: "q", Message: "is required"}))
+		return
+	}
+	mode, err := search.ParseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		sendError(w, r, invalidField("mode", err.Error()))
+		return
+	}
+	limit, err := searchLimit(r)
 	if err != nil {
-		utils.SendResponse(w, http.StatusBadRequest, "Invalid request payload")
+		sendError(w, r, err)
+		return
+	}
+	filter, err := metadataFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		sendError(w, r, err)
 		return
 	}
 
-	collection := collections.Collection{
-		Name: req.CollectionName,
+	fusion, err := h.Search.Settings(r.Context(), collection.ID)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get search settings"))
+		return
 	}
-	err = collection.Get()
+	fusion, err = fusionOverrides(r, fusion)
 	if err != nil {
-		utils.SendResponse(w, http.StatusNotFound, "Collection not found")
+		sendError(w, r, err)
 		return
 	}
 
-	dataPoints, err := collection.GetDataPoints(req.Query)
+	results, err := h.Search.Search(r.Context(), collection.ID, query, filter, mode, limit, fusion)
 	if err != nil {
-		utils.SendResponse(w, http.StatusInternalServerError, "Failed to get data points")
+		sendError(w, r, internalError(err, "Failed to search collection"))
+		return
+	}
+
+	render.JSON(w, r, SearchCollectionResponse{Query: query, Mode: mode, Results: results})
+}
+
+// GetSearchSettingsHandler handles the HTTP request for getting the hybrid search settings of a collection.
+func (h *Handlers) GetSearchSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
 		return
 	}
 
-	resp := GetCollectionResponse{
-		DataPoints: dataPoints,
+	fusion, err := h.Search.Settings(r.Context(), collection.ID)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get search settings"))
+		return
 	}
-	render.JSON(w, r, resp)
+
+	render.JSON(w, r, SearchSettingsResponse{
+		KeywordWeight:  fusion.KeywordWeight,
+		SemanticWeight: fusion.SemanticWeight,
+		RRFK:           fusion.K,
+	})
 }
 
-// UpdateTagHandler handles the HTTP request for updating a tag.
-func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
-	var req UpdateTagRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+// UpdateSearchSettingsHandler handles the HTTP request for updating the hybrid search settings of a collection.
+func (h *Handlers) UpdateSearchSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	req := SearchSettingsRequest{
+		KeywordWeight:  search.DefaultFusion.KeywordWeight,
+		SemanticWeight: search.DefaultFusion.SemanticWeight,
+		RRFK:           search.DefaultFusion.K,
+	}
+	err := h.decode(w, r, &req)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		sendError(w, r, err)
 		return
 	}
 
-	tag := collections.Tag{
-		ID:           req.TagID,
-		CollectionID: collections.GetCollectionID(req.CollectionName),
-		Name:         req.NewTag,
+	fusion := search.Fusion{KeywordWeight: req.KeywordWeight, SemanticWeight: req.SemanticWeight, K: req.RRFK}
+	if err := fusion.Validate(); err != nil {
+		sendError(w, r, apperr.Wrap(err, apperr.Validation, "invalid_search_settings", err.Error()).WithStatus(http.StatusUnprocessableEntity))
+		return
 	}
-	err = tag.Update()
+
+	settings, err := h.Search.SaveSettings(r.Context(), collection.ID, fusion)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update tag")
+		sendError(w, r, internalError(err, "Failed to update search settings"))
 		return
 	}
 
-	utils.RespondWithMessage(w, http.StatusOK, "Tag updated successfully")
+	render.JSON(w, r, SearchSettingsResponse{
+		KeywordWeight:  settings.KeywordWeight,
+		SemanticWeight: settings.SemanticWeight,
+		RRFK:           settings.RRFK,
+	})
 }
 
-// UpdateCollectionHandler handles the HTTP request for updating a collection.
-func UpdateCollectionHandler(w http.ResponseWriter, r *http.Request) {
-	var req UpdateCollectionRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+// GetDedupeSettingsHandler handles the HTTP request for getting the dedupe settings of a collection.
+func (h *Handlers) GetDedupeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
 		return
 	}
 
-	collection := collections.Collection{
-		Name:    req.CollectionName,
-		NewName: req.NewName,
+	settings, err := h.Store.GetDedupeSettings(r.Context(), collection.ID)
+	if errors.Is(err, collections.ErrNotFound) {
+		settings, err = &collections.DedupeSettings{}, nil
 	}
-	err = collection.Update()
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update collection")
+		sendError(w, r, internalError(err, "Failed to get dedupe settings"))
 		return
 	}
 
-	utils.RespondWithMessage(w, http.StatusOK, "Collection updated successfully")
+	render.JSON(w, r, DedupeSettingsResponse{Unique: settings.Unique, Policy: settings.Policy})
 }
 
-// DeleteTagHandler handles the HTTP request for deleting a tag.
-func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
-	tagID := chi.URLParam(r, "tagID")
-	collectionName := chi.URLParam(r, "collectionName")
+// UpdateDedupeSettingsHandler handles the HTTP request for updating the dedupe settings of a collection.
+func (h *Handlers) UpdateDedupeSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	var req DedupeSettingsRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
 
-	tag := collections.Tag{
-		ID:           tagID,
-		CollectionID: collections.GetCollectionID(collectionName),
+	settings := collections.DedupeSettings{CollectionID: collection.ID, Unique: req.Unique, Policy: req.Policy}
+	err = h.Store.SaveDedupeSettings(r.Context(), &settings)
+	if errors.Is(err, collections.ErrDuplicatesExist) {
+		sendError(w, r, err)
+		return
 	}
-	err := tag.Delete()
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete tag")
+		sendError(w, r, internalError(err, "Failed to update dedupe settings"))
 		return
 	}
 
-	utils.RespondWithMessage(w, http.StatusOK, "Tag deleted successfully")
+	render.JSON(w, r, DedupeSettingsResponse{Unique: settings.Unique, Policy: settings.Policy})
 }
 
-// DeleteCollectionHandler handles the HTTP request for deleting a collection.
-func DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
-	collectionName := chi.URLParam(r, "collectionName")
+// ListDuplicatesHandler handles the HTTP request for listing the groups of data points of a collection with the same content.
+func (h *Handlers) ListDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
 
-	collection := collections.Collection{
-		Name: collectionName,
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
 	}
-	err := collection.Delete()
+
+	duplicates, err := h.Store.ListDuplicates(r.Context(), collection.ID, page)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete collection")
+		sendError(w, r, internalError(err, "Failed to list duplicates"))
 		return
 	}
 
-	utils.RespondWithMessage(w, http.StatusOK, "Collection deleted successfully")
+	resp := ListDuplicatesResponse{}
+	resp.Duplicates, resp.Page = nextPage(w, r, duplicates, page, "", func(g collections.DuplicateGroup) (string, string) { return g.ContentHash, "" })
+	render.JSON(w, r, resp)
+}
+
+// fusionOverrides applies the keyword_weight, semantic_weight and rrf_k query
+// parameters of a search request on top of the collection's settings.
+func fusionOverrides(r *http.Request, f search.Fusion) (search.Fusion, error) {
+	q := r.URL.Query()
+	for name, target := range map[string]*float64{
+		"keyword_weight":  &f.KeywordWeight,
+		"semantic_weight": &f.SemanticWeight,
+	} {
+		if raw := q.Get(name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return f, invalidField(name, "must be a number")
+			}
+			*target = v
+		}
+	}
+	if raw := q.Get("rrf_k"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return f, invalidField("rrf_k", "must be an integer")
+		}
+		f.K = v
+	}
+
+	if err := f.Validate(); err != nil {
+		return f, apperr.Wrap(err, apperr.Validation, "invalid_search_settings", err.Error())
+	}
+	return f, nil
 }
 
-// GetTagsHandler handles the HTTP request for getting tags under a collection.
-func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
-	collectionName := chi.URLParam(r, "collectionName")
+// searchLimit reads the optional limit query parameter of a search request.
+func searchLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultSearchLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		return 0, invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxSearchLimit))
+	}
+
+	return limit, nil
+}
 
-	collection := collections.Collection{
-		Name: collectionName,
+// metadataFilter parses the optional filter query parameter of a data point
+// listing or search. It returns nil if there is none.
+func metadataFilter(raw string) (*collections.MetadataFilter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
 	}
-	err := collection.Get()
+
+	filter, err := collections.ParseMetadataFilter(raw)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, "Collection not found")
+		return nil, invalidField("filter", err.Error())
+	}
+
+	return filter, nil
+}
+
+// ListCollectionsHandler handles the HTTP request for listing collections with their tag and data point counts.
+// They are sorted by ?sort=name|created|updated, name by default, in ?order=asc|desc.
+func (h *Handlers) ListCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	order := collections.OrderByName
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "":
+	case string(collections.OrderByName), string(collections.OrderByCreated), string(collections.OrderByUpdated):
+		order = collections.CollectionOrder(sort)
+	default:
+		sendError(w, r, invalidField("sort", "must be name, created or updated"))
+		return
+	}
+	var desc bool
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		sendError(w, r, invalidField("order", "must be asc or desc"))
 		return
 	}
 
-	tags, err := collection.GetTags()
+	// Cursors are only valid for the ordering they were issued for.
+	sortKey := string(order)
+	if desc {
+		sortKey += ":desc"
+	}
+	page, err := pageRequest(r, sortKey)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get tags")
+		sendError(w, r, err)
 		return
 	}
 
-	resp := GetTagsResponse{
-		Tags: tags,
+	summaries, err := h.Store.ListCollectionSummaries(r.Context(), order, desc, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list collections"))
+		return
 	}
+
+	resp := ListCollectionsResponse{}
+	resp.Collections, resp.Page = nextPage(w, r, summaries, page, sortKey, func(s collections.CollectionSummary) (string, string) {
+		switch order {
+		case collections.OrderByCreated:
+			return s.ID, s.CreatedAt.Format(time.RFC3339Nano)
+		case collections.OrderByUpdated:
+			return s.ID, s.UpdatedAt.Format(time.RFC3339Nano)
+		}
+		return s.ID, s.Name
+	})
 	render.JSON(w, r, resp)
 }
 
-// GetDataPointsHandler handles the HTTP request for getting data points under a tag.
-func GetDataPointsHandler(w http.ResponseWriter, r *http.Request) {
-	tagID := chi.URLParam(r, "tagID")
-	collectionName := chi.URLParam(r, "collectionName")
+// GetCollectionInfoHandler handles the HTTP request for getting a collection with its tag and data point counts.
+func (h *Handlers) GetCollectionInfoHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	summary, err := h.Store.GetCollectionSummary(r.Context(), collection.ID)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "collection_not_found", "Collection not found"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get collection"))
+		return
+	}
+
+	render.JSON(w, r, summary)
+}
+
+// UpdateTagHandler handles the HTTP request for updating a tag.
+func (h *Handlers) UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateTagRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
 
-	tag := collections.Tag{
-		ID:           tagID,
-		CollectionID: collections.GetCollectionID(collectionName),
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return
 	}
-	err := tag.Get()
+
+	tag.Name = req.NewTag
+	err = h.Store.UpdateTag(r.Context(), tag)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		sendError(w, r, tagUpdateError(err))
 		return
 	}
 
-	dataPoints, err := tag.GetDataPoints()
+	utils.SendResponse(w, http.StatusOK, "Tag updated successfully")
+}
+
+// CreateTagHandler handles the HTTP request for creating a tag, along with
+// any missing ancestors.
+func (h *Handlers) CreateTagHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateTagRequest
+	err := h.decode(w, r, &req)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get data points")
+		sendError(w, r, err)
 		return
 	}
 
-	resp := GetDataPointsResponse{
-		DataPoints: dataPoints,
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
 	}
-	render.JSON(w, r, resp)
+	tag := collections.Tag{CollectionID: collection.ID, Name: req.Name}
+	if req.Parent != "" {
+		parent, ok := h.namedTag(w, r, collection, req.Parent)
+		if !ok {
+			return
+		}
+		tag.Name = parent.Name + "/" + req.Name
+	}
+
+	err = h.Store.CreateTag(r.Context(), &tag)
+	if errors.Is(err, collections.ErrConflict) {
+		sendError(w, r, apperr.Wrap(err, apperr.Conflict, "tag_name_taken", "A tag with this name already exists in the collection"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to create tag"))
+		return
+	}
+
+	w.Header().Set("Location", "/collections/"+url.PathEscape(collection.Slug)+"/tags/"+url.PathEscape(tag.Slug))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, tag)
+}
+
+// MoveTagHandler handles the HTTP request for moving a tag, with its descendants,
+// below another tag of the collection.
+func (h *Handlers) MoveTagHandler(w http.ResponseWriter, r *http.Request) {
+	var req MoveTagRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return
+	}
+
+	_, leaf := path.Split(tag.Name)
+	tag.Name = leaf
+	if req.Parent != "" {
+		parent, ok := h.namedTag(w, r, collection, req.Parent)
+		if !ok {
+			return
+		}
+		tag.Name = parent.Name + "/" + leaf
+	}
+
+	err = h.Store.UpdateTag(r.Context(), tag)
+	if err != nil {
+		sendError(w, r, tagUpdateError(err))
+		return
+	}
+
+	render.JSON(w, r, tag)
+}
+
+// tagUpdateError converts an error renaming or moving a tag.
+func tagUpdateError(err error) error {
+	switch {
+	case errors.Is(err, collections.ErrCycle):
+		return apperr.Wrap(err, apperr.Conflict, "tag_cycle", "A tag cannot be moved below itself")
+	case errors.Is(err, collections.ErrConflict):
+		return apperr.Wrap(err, apperr.Conflict, "tag_name_taken", "A tag with this name already exists in the collection")
+	default:
+		return internalError(err, "Failed to update tag")
+	}
+}
+
+// UpdateCollectionHandler handles the HTTP request for updating a collection.
+func (h *Handlers) UpdateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateCollectionRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	collection.Name = req.NewName
+	err = h.Store.UpdateCollection(r.Context(), collection)
+	if errors.Is(err, collections.ErrConflict) {
+		sendError(w, r, apperr.Wrap(err, apperr.Conflict, "collection_name_taken", "A collection with this name already exists"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to update collection"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Collection updated successfully")
+}
+
+// DeleteTagHandler handles the HTTP request for deleting a tag.
+func (h *Handlers) DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return
+	}
+
+	// The data points moved to the trash stay in the search index until
+	// they are purged; searches leave them out meanwhile.
+	if err := h.Store.DeleteTag(r.Context(), tag.ID); err != nil {
+		sendError(w, r, internalError(err, "Failed to delete tag"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Tag moved to trash")
+}
+
+// DeleteCollectionHandler handles the HTTP request for deleting a collection.
+func (h *Handlers) DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteCollection(r.Context(), collection.ID); err != nil {
+		sendError(w, r, internalError(err, "Failed to delete collection"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Collection moved to trash")
+}
+
+// GetTagsHandler handles the HTTP request for getting tags under a collection.
+func (h *Handlers) GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	var tags []collections.Tag
+	if r.URL.Query().Has("parent") {
+		// An empty parent lists the top-level tags.
+		var parentID string
+		if ref := r.URL.Query().Get("parent"); ref != "" {
+			parent, ok := h.namedTag(w, r, collection, ref)
+			if !ok {
+				return
+			}
+			parentID = parent.ID
+		}
+		tags, err = h.Store.ListChildTags(r.Context(), collection.ID, parentID, page)
+	} else {
+		tags, err = h.Store.ListTags(r.Context(), collection.ID, page)
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get tags"))
+		return
+	}
+
+	resp := GetTagsResponse{}
+	resp.Tags, resp.Page = nextPage(w, r, tags, page, "", func(t collections.Tag) (string, string) { return t.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// GetDataPointsByTagHandler handles the HTTP request for getting data points under a tag.
+func (h *Handlers) GetDataPointsByTagHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return
+	}
+
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	filter, err := metadataFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	var dataPoints []collections.DataPoint
+	if r.URL.Query().Get("descendants") == "true" {
+		var subtree []collections.Tag
+		subtree, err = h.Store.ListTagSubtree(r.Context(), tag.ID)
+		if err == nil {
+			dataPoints, err = h.Store.ListDataPointsByTags(r.Context(), tagIDs(subtree), false, filter, page)
+		}
+	} else {
+		dataPoints, err = h.Store.ListDataPoints(r.Context(), tag.ID, filter, page)
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get data points"))
+		return
+	}
+
+	resp := GetDataPointsResponse{}
+	resp.DataPoints, resp.Page = nextPage(w, r, dataPoints, page, "", dataPointKey)
+	render.JSON(w, r, resp)
+}
+
+// CreateDataPointHandler handles the HTTP request for adding a data point to a tag.
+// The ID is taken from the path if given there, and must not be in use yet.
+// A value already stored in the collection is handled by the dedupe policy;
+// unless it is rejected, the stored data point is returned with 200 OK.
+func (h *Handlers) CreateDataPointHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateDataPointRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	id := chi.URLParam(r, "dataPointID")
+	if id != "" {
+		if _, err := ulid.ParseStrict(id); err != nil {
+			sendError(w, r, apperr.Wrap(err, apperr.Validation, "invalid_id", "Invalid data point ID"))
+			return
+		}
+	}
+
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return
+	}
+
+	dp := collections.DataPoint{ID: id, TagID: tag.ID, Value: req.Value, Metadata: req.Metadata}
+	var duplicate *ingest.Duplicate
+	err = h.Store.WithTx(r.Context(), func(store collections.Store) error {
+		policy, err := ingest.DedupePolicy(r.Context(), store, collection.ID, req.Dedupe)
+		if err != nil {
+			return err
+		}
+		duplicate, err = ingest.Deduplicate(r.Context(), store, collection.ID, &dp, policy)
+		if err != nil || duplicate != nil {
+			return err
+		}
+		return store.CreateDataPoint(r.Context(), &dp)
+	})
+	if errors.Is(err, collections.ErrDuplicateContent) {
+		sendError(w, r, duplicateError(err))
+		return
+	}
+	if errors.Is(err, collections.ErrConflict) {
+		sendError(w, r, apperr.Wrap(err, apperr.Conflict, "data_point_exists", "Data point already exists"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to create data point"))
+		return
+	}
+	if duplicate == nil || duplicate.Policy != collections.DedupeSkip {
+		if err := h.Search.Index(r.Context(), dp); err != nil {
+			log.Println(err)
+		}
+	}
+	if duplicate != nil {
+		render.JSON(w, r, dp)
+		return
+	}
+
+	w.Header().Set("Location", "/collections/"+url.PathEscape(collection.Slug)+"/tags/"+url.PathEscape(tag.Slug)+"/datapoints/"+dp.ID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dp)
+}
+
+// GetDataPointHandler handles the HTTP request for getting a data point of a tag.
+func (h *Handlers) GetDataPointHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, dp)
+}
+
+// ReplaceDataPointHandler handles the HTTP request for replacing the value and metadata of a data point.
+func (h *Handlers) ReplaceDataPointHandler(w http.ResponseWriter, r *http.Request) {
+	var req DataPointRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	dp.Value = req.Value
+	dp.Metadata = req.Metadata
+	h.saveDataPoint(w, r, dp)
+}
+
+// PatchDataPointHandler handles the HTTP request for updating some fields of a data point.
+func (h *Handlers) PatchDataPointHandler(w http.ResponseWriter, r *http.Request) {
+	var req PatchDataPointRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	if req.Value != nil {
+		dp.Value = *req.Value
+	}
+	if len(req.Metadata) > 0 && dp.Metadata == nil {
+		dp.Metadata = map[string]interface{}{}
+	}
+	for k, v := range req.Metadata {
+		if v == nil {
+			delete(dp.Metadata, k)
+		} else {
+			dp.Metadata[k] = v
+		}
+	}
+	h.saveDataPoint(w, r, dp)
+}
+
+// DeleteDataPointHandler handles the HTTP request for deleting a data point.
+func (h *Handlers) DeleteDataPointHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Store.DeleteDataPoint(r.Context(), dp.ID)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "data_point_not_found", "Data point not found"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to delete data point"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Data point moved to trash")
+}
+
+// GetDataPointTagsHandler handles the HTTP request for listing the tags of a data point.
+func (h *Handlers) GetDataPointTagsHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	h.sendDataPointTags(w, r, dp)
+}
+
+// AddDataPointTagsHandler handles the HTTP request for adding tags to a data point.
+func (h *Handlers) AddDataPointTagsHandler(w http.ResponseWriter, r *http.Request) {
+	var req DataPointTagsRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.Store.WithTx(r.Context(), func(store collections.Store) error {
+		for _, name := range req.Tags {
+			tag, _, err := store.ResolveTag(r.Context(), collection.ID, name)
+			if errors.Is(err, collections.ErrNotFound) {
+				tag = &collections.Tag{CollectionID: collection.ID, Name: name}
+				err = store.CreateTag(r.Context(), tag)
+			}
+			if err == nil {
+				err = store.AddDataPointTag(r.Context(), dp.ID, tag.ID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sendError(w, r, dataPointTagError(err))
+		return
+	}
+
+	h.sendDataPointTags(w, r, dp)
+}
+
+// dataPointTagError converts an error adding tags to a data point.
+func dataPointTagError(err error) error {
+	switch {
+	case apperr.As(err) != nil && apperr.KindOf(err) == apperr.Validation:
+		return err
+	case errors.Is(err, collections.ErrCycle):
+		return apperr.Wrap(err, apperr.Conflict, "tag_cycle", "A tag cannot be moved below itself")
+	case errors.Is(err, collections.ErrConflict):
+		return apperr.Wrap(err, apperr.Conflict, "tag_name_taken", "A tag with this name already exists in the collection")
+	case errors.Is(err, collections.ErrNotFound):
+		return apperr.Wrap(err, apperr.NotFound, "tag_not_found", "Tag not found")
+	default:
+		return internalError(err, "Failed to tag data point")
+	}
+}
+
+// RemoveDataPointTagHandler handles the HTTP request for removing a tag from a data point.
+// The tag the data point is filed under cannot be removed.
+func (h *Handlers) RemoveDataPointTagHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return
+	}
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+	tag, ok := h.namedTag(w, r, collection, chi.URLParam(r, "tagRef"))
+	if !ok {
+		return
+	}
+
+	err := h.Store.RemoveDataPointTag(r.Context(), dp.ID, tag.ID)
+	if errors.Is(err, collections.ErrConflict) {
+		sendError(w, r, apperr.Wrap(err, apperr.Conflict, "data_point_filed_under_tag",
+			"The tag a data point is filed under cannot be removed"))
+		return
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "tag_not_found", "Data point does not carry the tag"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to untag data point"))
+		return
+	}
+
+	h.sendDataPointTags(w, r, dp)
+}
+
+// sendDataPointTags writes the tags of dp.
+func (h *Handlers) sendDataPointTags(w http.ResponseWriter, r *http.Request, dp *collections.DataPoint) {
+	tags, err := h.Store.ListDataPointTags(r.Context(), dp.ID)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get data point tags"))
+		return
+	}
+
+	render.JSON(w, r, DataPointTagsResponse{Tags: tags})
+}
+
+// saveDataPoint stores the changes to dp, reindexes it and responds with
+// the stored data point.
+func (h *Handlers) saveDataPoint(w http.ResponseWriter, r *http.Request, dp *collections.DataPoint) {
+	err := h.Store.UpdateDataPoint(r.Context(), dp)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "data_point_not_found", "Data point not found"))
+		return
+	}
+	if errors.Is(err, collections.ErrDuplicateContent) {
+		sendError(w, r, duplicateError(err))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to update data point"))
+		return
+	}
+	if err := h.Search.Index(r.Context(), *dp); err != nil {
+		log.Println(err)
+	}
+
+	render.JSON(w, r, dp)
+}
+
+// ListRevisionsHandler handles the HTTP request for listing the revisions of a data point, newest first.
+func (h *Handlers) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	revisions, err := h.Store.ListRevisions(r.Context(), dp.ID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get revisions"))
+		return
+	}
+
+	resp := GetRevisionsResponse{}
+	resp.Revisions, resp.Page = nextPage(w, r, revisions, page, "", func(rev collections.Revision) (string, string) { return rev.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// GetRevisionHandler handles the HTTP request for getting a revision of a data point by its number.
+func (h *Handlers) GetRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := h.revision(w, r, dp, chi.URLParam(r, "revision"))
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, revision)
+}
+
+// DiffRevisionsHandler handles the HTTP request for comparing the revisions ?from= and ?to= of a data point.
+// The latest revision is compared if ?to= is left out.
+func (h *Handlers) DiffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if !query.Has("from") {
+		sendError(w, r, apperr.Invalid("missing_parameter", "Missing query parameter from",
+			apperr.FieldError{Field: "from", Message: "is required"}))
+		return
+	}
+	for _, param := range []string{"from", "to"} {
+		if _, ok := revisionNumber(query.Get(param)); query.Has(param) && !ok {
+			sendError(w, r, invalidField(param, "must be a revision number"))
+			return
+		}
+	}
+
+	from, ok := h.revision(w, r, dp, query.Get("from"))
+	if !ok {
+		return
+	}
+	var to *collections.Revision
+	if query.Has("to") {
+		if to, ok = h.revision(w, r, dp, query.Get("to")); !ok {
+			return
+		}
+	} else {
+		latest, err := h.Store.ListRevisions(r.Context(), dp.ID, collections.Page{Limit: 1})
+		if err != nil {
+			sendError(w, r, internalError(err, "Failed to get revisions"))
+			return
+		}
+		if len(latest) == 0 {
+			sendError(w, r, apperr.New(apperr.NotFound, "revision_not_found", "Revision not found"))
+			return
+		}
+		to = &latest[0]
+	}
+
+	render.JSON(w, r, DiffRevisionsResponse{From: from.Number, To: to.Number, Diff: collections.DiffRevisions(from, to)})
+}
+
+// RestoreRevisionHandler handles the HTTP request for setting a data point back to one of its revisions.
+// The restore is recorded as a new revision.
+func (h *Handlers) RestoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	dp, ok := h.dataPoint(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := h.revision(w, r, dp, chi.URLParam(r, "revision"))
+	if !ok {
+		return
+	}
+
+	restored, err := h.Store.RestoreRevision(r.Context(), dp.ID, revision.Number)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "revision_not_found", "Revision not found"))
+		return
+	}
+	if errors.Is(err, collections.ErrDuplicateContent) {
+		sendError(w, r, duplicateError(err))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to restore revision"))
+		return
+	}
+	if err := h.Search.Index(r.Context(), *restored); err != nil {
+		log.Println(err)
+	}
+
+	render.JSON(w, r, restored)
+}
+
+// revision looks up a revision of dp by its number, writing an error
+// response and returning false if it cannot be found.
+func (h *Handlers) revision(w http.ResponseWriter, r *http.Request, dp *collections.DataPoint, raw string) (*collections.Revision, bool) {
+	number, ok := revisionNumber(raw)
+	if !ok {
+		sendError(w, r, apperr.New(apperr.NotFound, "revision_not_found", "Revision not found"))
+		return nil, false
+	}
+
+	revision, err := h.Store.GetRevision(r.Context(), dp.ID, number)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "revision_not_found", "Revision not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get revision"))
+		return nil, false
+	}
+
+	return revision, true
+}
+
+// revisionNumber parses the number of a revision.
+func revisionNumber(raw string) (int, bool) {
+	number, err := strconv.Atoi(raw)
+	return number, err == nil && number > 0
+}
+
+// SubmitJobHandler handles the HTTP request for ingesting a source in the background.
+func (h *Handlers) SubmitJobHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateCollectionRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	h.submitJob(w, r, req)
+}
+
+// submitJob queues req as an ingestion job and responds with the job.
+func (h *Handlers) submitJob(w http.ResponseWriter, r *http.Request, req CreateCollectionRequest) {
+	if req.RecrawlInterval != nil {
+		if err := fetchIntervalError("recrawl_interval", *req.RecrawlInterval); err != nil {
+			sendError(w, r, err)
+			return
+		}
+	}
+
+	job, err := h.Jobs.Submit(r.Context(), req.ingestRequest())
+	if errors.Is(err, ingest.ErrNoSource) {
+		sendError(w, r, ingestError(err))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to submit job"))
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, job)
+}
+
+// ListJobsHandler handles the HTTP request for listing ingestion jobs, optionally filtered by ?status=.
+func (h *Handlers) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	status := collections.JobStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", collections.JobQueued, collections.JobRunning, collections.JobSucceeded, collections.JobFailed, collections.JobCanceled:
+	default:
+		sendError(w, r, invalidField("status", "must be queued, running, succeeded, failed or canceled"))
+		return
+	}
+
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	jobs, err := h.Store.ListJobs(r.Context(), status, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list jobs"))
+		return
+	}
+
+	resp := ListJobsResponse{}
+	resp.Jobs, resp.Page = nextPage(w, r, jobs, page, "", func(j collections.Job) (string, string) { return j.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// GetJobHandler handles the HTTP request for getting an ingestion job with its progress.
+func (h *Handlers) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := h.Store.GetJob(r.Context(), chi.URLParam(r, "jobID"))
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "job_not_found", "Job not found"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get job"))
+		return
+	}
+
+	render.JSON(w, r, job)
+}
+
+// CancelJobHandler handles the HTTP request for canceling an ingestion job.
+func (h *Handlers) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := h.Jobs.Cancel(r.Context(), chi.URLParam(r, "jobID"))
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "job_not_found", "Job not found"))
+		return
+	}
+	if errors.Is(err, jobs.ErrFinished) {
+		sendError(w, r, err)
+		return
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to cancel job"))
+		return
+	}
+
+	// A running job stops in the background.
+	if job.Status == collections.JobRunning {
+		render.Status(r, http.StatusAccepted)
+	}
+	render.JSON(w, r, job)
+}
+
+// ListSourcesHandler handles the HTTP request for listing the fetched URLs tracked for crawling,
+// optionally only those of a ?collection=.
+func (h *Handlers) ListSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := h.collectionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	sources, err := h.Store.ListSources(r.Context(), collectionID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list sources"))
+		return
+	}
+
+	resp := ListSourcesResponse{}
+	resp.Sources, resp.Page = nextPage(w, r, sources, page, "", func(src collections.Source) (string, string) { return src.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// GetSourceHandler handles the HTTP request for getting a source with the outcome of its last crawl.
+func (h *Handlers) GetSourceHandler(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, src)
+}
+
+// UpdateSourceHandler handles the HTTP request for changing the interval between crawls of a source,
+// which schedules its next crawl an interval after its last one.
+func (h *Handlers) UpdateSourceHandler(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateSourceRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	if req.Interval == nil {
+		sendError(w, r, invalidField("interval", "is required"))
+		return
+	}
+	if err := fetchIntervalError("interval", *req.Interval); err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	src.Interval = *req.Interval
+	last := src.CreatedAt
+	if src.LastFetchedAt != nil {
+		last = *src.LastFetchedAt
+	}
+	src.Schedule(last)
+	if err := h.Store.UpdateSource(r.Context(), src); err != nil {
+		sendError(w, r, internalError(err, "Failed to update source"))
+		return
+	}
+
+	render.JSON(w, r, src)
+}
+
+// DeleteSourceHandler handles the HTTP request for no longer crawling a source. Its data points are kept.
+func (h *Handlers) DeleteSourceHandler(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteSource(r.Context(), src.ID); err != nil {
+		sendError(w, r, internalError(err, "Failed to delete source"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Source deleted")
+}
+
+// FetchSourceHandler handles the HTTP request for crawling a source right away. Failed crawls are
+// reported in the fetch of the response.
+func (h *Handlers) FetchSourceHandler(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+
+	f, err := h.Ingest.Recrawl(r.Context(), src)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to crawl source"))
+		return
+	}
+
+	render.JSON(w, r, FetchSourceResponse{Source: src, Fetch: f})
+}
+
+// ListSourceFetchesHandler handles the HTTP request for listing the crawls of a source, newest first.
+func (h *Handlers) ListSourceFetchesHandler(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	fetches, err := h.Store.ListSourceFetches(r.Context(), src.ID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list fetches"))
+		return
+	}
+
+	resp := ListSourceFetchesResponse{}
+	resp.Fetches, resp.Page = nextPage(w, r, fetches, page, "", func(f collections.SourceFetch) (string, string) { return f.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// CreateFeedHandler handles the HTTP request for subscribing a tag to an RSS or Atom feed. The feed is
+// polled right away, storing its current entries.
+func (h *Handlers) CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateFeedRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	interval := collections.Duration(h.FeedInterval)
+	if req.Interval != nil {
+		if err := fetchIntervalError("interval", *req.Interval); err != nil {
+			sendError(w, r, err)
+			return
+		}
+		interval = *req.Interval
+	}
+	collection, ok := h.namedCollection(w, r, req.Collection)
+	if !ok {
+		return
+	}
+
+	f := &collections.Feed{CollectionID: collection.ID, URL: req.URL, FullText: req.FullText, Interval: interval}
+	poll, err := h.Ingest.Subscribe(r.Context(), f, req.Tag)
+	if err != nil {
+		sendError(w, r, feedError(err))
+		return
+	}
+
+	w.Header().Set("Location", "/feeds/"+f.ID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, FeedPollResponse{Feed: f, Poll: poll})
+}
+
+// feedError converts a failed subscription to a feed into an error with a
+// status matching the cause.
+func feedError(err error) error {
+	switch {
+	case fetch.KindOf(err) != "":
+		return fetchError(err)
+	case errors.Is(err, collections.ErrConflict):
+		return apperr.Wrap(err, apperr.Conflict, "feed_exists", "The tag is already subscribed to this feed")
+	case apperr.As(err) != nil && apperr.KindOf(err) == apperr.Validation:
+		return err
+	default:
+		return internalError(err, "Failed to subscribe to feed")
+	}
+}
+
+// ListFeedsHandler handles the HTTP request for listing the feeds, optionally only those of a ?collection=.
+func (h *Handlers) ListFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := h.collectionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	feeds, err := h.Store.ListFeeds(r.Context(), collectionID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list feeds"))
+		return
+	}
+
+	resp := ListFeedsResponse{}
+	resp.Feeds, resp.Page = nextPage(w, r, feeds, page, "", func(f collections.Feed) (string, string) { return f.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// GetFeedHandler handles the HTTP request for getting a feed with the outcome of its last poll.
+func (h *Handlers) GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.feed(w, r)
+	if !ok {
+		return
+	}
+
+	render.JSON(w, r, f)
+}
+
+// UpdateFeedHandler handles the HTTP request for changing the interval between polls of a feed, which
+// schedules its next poll an interval after its last one, or whether it stores the full text of entries.
+func (h *Handlers) UpdateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.feed(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateFeedRequest
+	err := h.decode(w, r, &req)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	if req.FullText != nil {
+		f.FullText = *req.FullText
+	}
+	if req.Interval != nil {
+		if err := fetchIntervalError("interval", *req.Interval); err != nil {
+			sendError(w, r, err)
+			return
+		}
+		f.Interval = *req.Interval
+		last := f.CreatedAt
+		if f.LastFetchedAt != nil {
+			last = *f.LastFetchedAt
+		}
+		f.Schedule(last)
+	}
+	if err := h.Store.UpdateFeed(r.Context(), f); err != nil {
+		sendError(w, r, internalError(err, "Failed to update feed"))
+		return
+	}
+
+	render.JSON(w, r, f)
+}
+
+// DeleteFeedHandler handles the HTTP request for unsubscribing from a feed. The data points of its entries
+// are kept.
+func (h *Handlers) DeleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.feed(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteFeed(r.Context(), f.ID); err != nil {
+		sendError(w, r, internalError(err, "Failed to delete feed"))
+		return
+	}
+
+	utils.SendResponse(w, http.StatusOK, "Feed deleted")
+}
+
+// PollFeedHandler handles the HTTP request for polling a feed right away. Failed polls are reported in the
+// poll of the response.
+func (h *Handlers) PollFeedHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.feed(w, r)
+	if !ok {
+		return
+	}
+
+	poll, err := h.Ingest.Poll(r.Context(), f)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to poll feed"))
+		return
+	}
+
+	render.JSON(w, r, FeedPollResponse{Feed: f, Poll: poll})
+}
+
+// ListFeedEntriesHandler handles the HTTP request for listing the stored entries of a feed, newest first.
+func (h *Handlers) ListFeedEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.feed(w, r)
+	if !ok {
+		return
+	}
+	page, err := pageRequest(r, "")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	entries, err := h.Store.ListFeedEntries(r.Context(), f.ID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list feed entries"))
+		return
+	}
+
+	resp := ListFeedEntriesResponse{}
+	resp.Entries, resp.Page = nextPage(w, r, entries, page, "", func(e collections.FeedEntry) (string, string) { return e.ID, "" })
+	render.JSON(w, r, resp)
+}
+
+// ListTrashHandler handles the HTTP request for listing the deleted collections, tags and data points,
+// optionally only those of a ?kind= or a ?collection=.
+func (h *Handlers) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	kind := collections.TrashKind(r.URL.Query().Get("kind"))
+	switch kind {
+	case "", collections.TrashCollection, collections.TrashTag, collections.TrashDataPoint:
+	default:
+		sendError(w, r, invalidField("kind", "must be collection, tag or data_point"))
+		return
+	}
+
+	// Collections in the trash can only be named by ID.
+	collectionID := r.URL.Query().Get("collection")
+	if collectionID != "" {
+		collection, _, err := h.Store.ResolveCollection(r.Context(), collectionID)
+		if err != nil && !errors.Is(err, collections.ErrNotFound) {
+			sendError(w, r, internalError(err, "Failed to get collection"))
+			return
+		}
+		if err == nil {
+			collectionID = collection.ID
+		}
+	}
+
+	page, err := pageRequest(r, "deleted")
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	items, err := h.Store.ListTrash(r.Context(), kind, collectionID, page)
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to list trash"))
+		return
+	}
+
+	items, info := nextPage(w, r, items, page, "deleted", func(item collections.TrashItem) (string, string) {
+		return item.ID, item.DeletedAt.Format(time.RFC3339Nano)
+	})
+	resp := ListTrashResponse{Items: make([]TrashItemResponse, len(items)), Page: info}
+	for i, item := range items {
+		resp.Items[i].TrashItem = item
+		if h.TrashRetention > 0 {
+			purgeAt := item.DeletedAt.Add(h.TrashRetention)
+			resp.Items[i].PurgeAt = &purgeAt
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+// RestoreTrashHandler handles the HTTP request for restoring a collection, tag or data point from the
+// trash together with the records deleted along with it.
+func (h *Handlers) RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	id, kind := chi.URLParam(r, "id"), chi.URLParam(r, "kind")
+	var restored interface{}
+	var err error
+	switch kind {
+	case "collections":
+		restored, err = h.Store.RestoreCollection(r.Context(), id)
+	case "tags":
+		restored, err = h.Store.RestoreTag(r.Context(), id)
+	case "datapoints":
+		restored, err = h.Store.RestoreDataPoint(r.Context(), id)
+	default:
+		sendError(w, r, invalidField("kind", "must be collections, tags or datapoints"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, restoreError(err, kind))
+		return
+	}
+
+	render.JSON(w, r, restored)
+}
+
+// restoreError converts an error restoring a record of kind, as named in
+// the path, from the trash.
+func restoreError(err error, kind string) error {
+	switch {
+	case errors.Is(err, collections.ErrParentDeleted):
+		return err
+	case errors.Is(err, collections.ErrDuplicateContent):
+		return duplicateError(err)
+	case errors.Is(err, collections.ErrConflict) && kind == "collections":
+		return apperr.Wrap(err, apperr.Conflict, "collection_name_taken", "A collection with this name already exists")
+	case errors.Is(err, collections.ErrConflict):
+		return apperr.Wrap(err, apperr.Conflict, "tag_name_taken", "A tag with this name already exists in the collection")
+	case errors.Is(err, collections.ErrNotFound):
+		return apperr.Wrap(err, apperr.NotFound, "trash_item_not_found", "Not found in trash")
+	default:
+		return internalError(err, "Failed to restore from trash")
+	}
+}
+
+// collection looks up a collection by ID, name or slug, writing an error
+// response and returning false if it cannot be found. A collection named by
+// a slug it has given up is answered with a redirect to its current slug.
+func (h *Handlers) collection(w http.ResponseWriter, r *http.Request, name string) (*collections.Collection, bool) {
+	collection, moved, err := h.Store.ResolveCollection(r.Context(), name)
+	if moved {
+		redirectMoved(w, r, collectionSegment, collection.Slug)
+		return nil, false
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "collection_not_found", "Collection not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get collection"))
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// collectionFilter returns the ID of the collection named by the collection
+// query parameter, or "" if it is not given, writing an error response and
+// returning false if it cannot be found.
+func (h *Handlers) collectionFilter(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.URL.Query().Get("collection")
+	if name == "" {
+		return "", true
+	}
+	collection, ok := h.namedCollection(w, r, name)
+	if !ok {
+		return "", false
+	}
+
+	return collection.ID, true
+}
+
+// namedCollection looks up a collection named outside of the path, by a
+// query parameter or in the request body, like collection. A slug it has
+// given up names it as well, since there is no path to redirect to.
+func (h *Handlers) namedCollection(w http.ResponseWriter, r *http.Request, name string) (*collections.Collection, bool) {
+	collection, _, err := h.Store.ResolveCollection(r.Context(), name)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "collection_not_found", "Collection not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get collection"))
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// source looks up the source named by the sourceID URL parameter, writing
+// an error response and returning false if it cannot be found.
+func (h *Handlers) source(w http.ResponseWriter, r *http.Request) (*collections.Source, bool) {
+	src, err := h.Store.GetSource(r.Context(), chi.URLParam(r, "sourceID"))
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "source_not_found", "Source not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get source"))
+		return nil, false
+	}
+
+	return src, true
+}
+
+// feed looks up the feed named by the feedID URL parameter, writing an
+// error response and returning false if it cannot be found.
+func (h *Handlers) feed(w http.ResponseWriter, r *http.Request) (*collections.Feed, bool) {
+	f, err := h.Store.GetFeed(r.Context(), chi.URLParam(r, "feedID"))
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "feed_not_found", "Feed not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get feed"))
+		return nil, false
+	}
+
+	return f, true
+}
+
+// dataPoint looks up the data point named by the dataPointID URL parameter
+// within the collection and tag of the URL, writing an error response and
+// returning false if it cannot be found.
+func (h *Handlers) dataPoint(w http.ResponseWriter, r *http.Request) (*collections.DataPoint, bool) {
+	collection, ok := h.collection(w, r, chi.URLParam(r, "collectionName"))
+	if !ok {
+		return nil, false
+	}
+	tag, ok := h.tag(w, r, collection, chi.URLParam(r, "tagName"))
+	if !ok {
+		return nil, false
+	}
+
+	dp, err := h.Store.GetDataPoint(r.Context(), chi.URLParam(r, "dataPointID"))
+	if err == nil && dp.TagID != tag.ID {
+		// The data point may also be reached through a tag added to it.
+		var tags []collections.Tag
+		tags, err = h.Store.ListDataPointTags(r.Context(), dp.ID)
+		if err == nil && !containsTag(tags, tag.ID) {
+			err = fmt.Errorf("data point %s: %w", dp.ID, collections.ErrNotFound)
+		}
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "data_point_not_found", "Data point not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get data point"))
+		return nil, false
+	}
+
+	return dp, true
+}
+
+// tag looks up a tag by ID, name or slug within collection, writing an error
+// response and returning false if it cannot be found. A tag named by a slug
+// it has given up is answered with a redirect to its current slug.
+func (h *Handlers) tag(w http.ResponseWriter, r *http.Request, collection *collections.Collection, name string) (*collections.Tag, bool) {
+	tag, moved, err := h.Store.ResolveTag(r.Context(), collection.ID, name)
+	if moved {
+		redirectMoved(w, r, tagSegment, tag.Slug)
+		return nil, false
+	}
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "tag_not_found", "Tag not found"))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get tag"))
+		return nil, false
+	}
+
+	return tag, true
+}
+
+// namedTag looks up a tag of collection by ID, name, slug or former slug,
+// writing an error response and returning false if it cannot be found.
+// Unlike tag, it is meant for tags named outside the path of a request.
+func (h *Handlers) namedTag(w http.ResponseWriter, r *http.Request, collection *collections.Collection, name string) (*collections.Tag, bool) {
+	tag, _, err := h.Store.ResolveTag(r.Context(), collection.ID, name)
+	if errors.Is(err, collections.ErrNotFound) {
+		sendError(w, r, apperr.Wrap(err, apperr.NotFound, "tag_not_found", "Tag not found: "+name))
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, internalError(err, "Failed to get tag"))
+		return nil, false
+	}
+
+	return tag, true
+}
+
+func tagIDs(tags []collections.Tag) []string {
+	ids := make([]string, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func containsTag(tags []collections.Tag, id string) bool {
+	for _, t := range tags {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Positions of the collection and tag references in the segments of a
+// request path such as /collections/{collectionName}/tags/{tagName}.
+const (
+	collectionSegment = 2
+	tagSegment        = 4
+)
+
+// redirectMoved permanently redirects a request to the same URL with the
+// path segment at index replaced by slug. The redirect keeps the method and
+// body, so clients holding an old URL can go on using it.
+func redirectMoved(w http.ResponseWriter, r *http.Request, index int, slug string) {
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	if index < len(segments) {
+		segments[index] = url.PathEscape(slug)
+	}
+	location := strings.Join(segments, "/")
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, location, http.StatusPermanentRedirect)
+}
+
+func dataPointIDs(dataPoints []collections.DataPoint) []string {
+	ids := make([]string, len(dataPoints))
+	for i, dp := range dataPoints {
+		ids[i] = dp.ID
+	}
+	return ids
 }
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"bytes"
+	"cognivaultServer/collections"
+	"cognivaultServer/embeddings"
+	"cognivaultServer/fetch"
+	"cognivaultServer/ingest"
+	"cognivaultServer/search"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+// testAPI serves the API over a Store, a MemoryStore unless a test needs
+// another one.
+type testAPI struct {
+	t       *testing.T
+	store   collections.Store
+	handler http.Handler
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	return newTestAPIWithStore(t, collections.NewMemoryStore())
+}
+
+func newTestAPIWithStore(t *testing.T, store collections.Store) *testAPI {
+	searcher := search.NewService(store, embeddings.NewHashEmbedder(64))
+	// URLs are only ever served by httptest servers on loopback.
+	fetchOptions := fetch.DefaultOptions
+	fetchOptions.AllowPrivateNetworks = true
+	h := &Handlers{
+		Store:  store,
+		Search: searcher,
+		Ingest: &ingest.Ingester{Store: store, Search: searcher, Fetcher: fetch.New(fetchOptions)},
+	}
+	return &testAPI{t: t, store: store, handler: SetRoutes(chi.NewRouter(), h)}
+}
+
+// do sends a request with body, encoded as JSON unless it is nil, and
+// returns the recorded response.
+func (a *testAPI) do(method, target string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	w := httptest.NewRecorder()
+	a.handler.ServeHTTP(w, httptest.NewRequest(method, target, &buf))
+	return w
+}
+
+// expect sends a request like do and fails the test unless it is answered
+// with status, decoding the response body into v if it is not nil.
+func (a *testAPI) expect(status int, method, target string, body, v interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	w := a.do(method, target, body)
+	if w.Code != status {
+		a.t.Fatalf("%s %s = %d %s, want %d", method, target, w.Code, w.Body, status)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			a.t.Fatalf("%s %s: decoding %s: %v", method, target, w.Body, err)
+		}
+	}
+	return w
+}
+
+// expectProblem sends a request like do and fails the test unless it is
+// answered with a problem of status and code.
+func (a *testAPI) expectProblem(status int, code, method, target string, body interface{}) Problem {
+	a.t.Helper()
+	var problem Problem
+	w := a.expect(status, method, target, body, &problem)
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		a.t.Errorf("%s %s: Content-Type = %q, want application/problem+json", method, target, ct)
+	}
+	if problem.Code != code {
+		a.t.Errorf("%s %s: code = %q, want %q", method, target, problem.Code, code)
+	}
+	return problem
+}
+
+// collection creates a collection holding text filed under tag.
+func (a *testAPI) collection(name, tag, text string) CreateCollectionResponse {
+	a.t.Helper()
+	var resp CreateCollectionResponse
+	a.expect(http.StatusOK, "POST", "/collections", CreateCollectionRequest{Name: name, Tag: tag, Text: text}, &resp)
+	return resp
+}
+
+func TestCreateAndGetCollection(t *testing.T) {
+	a := newTestAPI(t)
+	created := a.collection("Research Notes", "papers", "a short note")
+	if len(created.DataPointIDs) != 1 {
+		t.Fatalf("DataPointIDs = %v, want one", created.DataPointIDs)
+	}
+
+	var summary collections.CollectionSummary
+	a.expect(http.StatusOK, "GET", "/collections/research-notes", nil, &summary)
+	if summary.ID != created.ID || summary.TagCount != 1 || summary.DataPointCount != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	a.expectProblem(http.StatusNotFound, "collection_not_found", "GET", "/collections/missing", nil)
+}
+
+func TestCreateCollectionValidation(t *testing.T) {
+	a := newTestAPI(t)
+	problem := a.expectProblem(http.StatusUnprocessableEntity, "validation_failed", "POST", "/collections", map[string]string{"text": "x"})
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "name" {
+		t.Errorf("errors = %+v, want name", problem.Errors)
+	}
+	a.expectProblem(http.StatusBadRequest, "unknown_field", "POST", "/collections", map[string]string{"name": "c", "colour": "red"})
+}
+
+func TestRenamedCollectionRedirects(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("Notes", "t", "text")
+	a.expect(http.StatusOK, "PUT", "/collections/notes", UpdateCollectionRequest{NewName: "Journal"}, nil)
+
+	w := a.expect(http.StatusPermanentRedirect, "GET", "/collections/notes/tags?limit=5", nil, nil)
+	if loc := w.Header().Get("Location"); loc != "/collections/journal/tags?limit=5" {
+		t.Errorf("Location = %q", loc)
+	}
+	a.collection("Other", "t", "text")
+	a.expectProblem(http.StatusConflict, "collection_name_taken", "PUT", "/collections/other", UpdateCollectionRequest{NewName: "JOURNAL"})
+}
+
+func TestDataPointCRUD(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("c", "t", "first")
+
+	var dp collections.DataPoint
+	w := a.expect(http.StatusCreated, "POST", "/collections/c/tags/t/datapoints",
+		CreateDataPointRequest{Value: "second", Metadata: map[string]interface{}{"lang": "en"}}, &dp)
+	target := "/collections/c/tags/t/datapoints/" + dp.ID
+	if loc := w.Header().Get("Location"); loc != target {
+		t.Errorf("Location = %q, want %q", loc, target)
+	}
+
+	var patched collections.DataPoint
+	a.expect(http.StatusOK, "PATCH", target, map[string]interface{}{"metadata": map[string]interface{}{"lang": nil, "year": 2024}}, &patched)
+	if _, ok := patched.Metadata["lang"]; ok || patched.Metadata["year"] != float64(2024) {
+		t.Errorf("patched metadata = %v", patched.Metadata)
+	}
+	problem := a.expectProblem(http.StatusUnprocessableEntity, "validation_failed", "PATCH", target, map[string]interface{}{"value": ""})
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "value" {
+		t.Errorf("errors of an empty value = %+v", problem.Errors)
+	}
+
+	a.expect(http.StatusOK, "DELETE", target, nil, nil)
+	a.expectProblem(http.StatusNotFound, "data_point_not_found", "GET", target, nil)
+
+	var trash ListTrashResponse
+	a.expect(http.StatusOK, "GET", "/trash", nil, &trash)
+	if len(trash.Items) != 1 || trash.Items[0].ID != dp.ID {
+		t.Fatalf("trash = %+v", trash.Items)
+	}
+	a.expect(http.StatusOK, "POST", "/trash/datapoints/"+dp.ID+"/restore", nil, nil)
+	a.expect(http.StatusOK, "GET", target, nil, nil)
+}
+
+func TestRestoreDuplicateDataPoint(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("c", "t", "first")
+
+	var dp collections.DataPoint
+	a.expect(http.StatusCreated, "POST", "/collections/c/tags/t/datapoints", CreateDataPointRequest{Value: "again"}, &dp)
+	a.expect(http.StatusOK, "DELETE", "/collections/c/tags/t/datapoints/"+dp.ID, nil, nil)
+	a.expect(http.StatusCreated, "POST", "/collections/c/tags/t/datapoints", CreateDataPointRequest{Value: "again"}, nil)
+	a.expect(http.StatusOK, "PUT", "/collections/c/dedupe", DedupeSettingsRequest{Unique: true}, nil)
+
+	a.expectProblem(http.StatusConflict, "duplicate_content", "POST", "/trash/datapoints/"+dp.ID+"/restore", nil)
+	a.expectProblem(http.StatusNotFound, "data_point_not_found", "GET", "/collections/c/tags/t/datapoints/"+dp.ID, nil)
+}
+
+func TestListDataPointsPages(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("c", "t", "one")
+	for _, v := range []string{"two", "three", "four"} {
+		a.expect(http.StatusCreated, "POST", "/collections/c/tags/t/datapoints", CreateDataPointRequest{Value: v}, nil)
+	}
+
+	var seen int
+	target := "/collections/c/datapoints?limit=3"
+	for {
+		var resp GetCollectionResponse
+		w := a.expect(http.StatusOK, "GET", target, nil, &resp)
+		seen += len(resp.DataPoints)
+		if resp.Page.NextCursor == "" {
+			if w.Header().Get("Link") != "" {
+				t.Error("Link header on the last page")
+			}
+			break
+		}
+		target = "/collections/c/datapoints?limit=3&cursor=" + resp.Page.NextCursor
+	}
+	if seen != 4 {
+		t.Errorf("paged through %d data points, want 4", seen)
+	}
+
+	a.expectProblem(http.StatusBadRequest, "invalid_cursor", "GET", "/collections/c/datapoints?cursor=garbage", nil)
+	a.expectProblem(http.StatusBadRequest, "invalid_limit", "GET", "/collections/c/datapoints?limit=0", nil)
+}
+
+func TestSearchCollectionDataPointsRejectsTags(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("c", "t", "a note about search")
+
+	var resp SearchCollectionResponse
+	a.expect(http.StatusOK, "GET", "/collections/c/datapoints?query=search", nil, &resp)
+	if len(resp.Results) != 1 {
+		t.Errorf("results = %+v, want one", resp.Results)
+	}
+
+	for _, query := range []string{"query=search&tag=t", "query=search&match=all"} {
+		problem := a.expectProblem(http.StatusUnprocessableEntity, "invalid_parameters", "GET", "/collections/c/datapoints?"+query, nil)
+		if len(problem.Errors) != 1 {
+			t.Errorf("%s: errors = %+v, want one", query, problem.Errors)
+		}
+	}
+}
+
+func TestCreateDataPointLocation(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("My Notes", "infra/k8s networking", "first")
+
+	var dp collections.DataPoint
+	w := a.expect(http.StatusCreated, "POST", "/collections/my-notes/tags/infra-k8s-networking/datapoints", CreateDataPointRequest{Value: "second"}, &dp)
+	want := "/collections/my-notes/tags/infra-k8s-networking/datapoints/" + dp.ID
+	if loc := w.Header().Get("Location"); loc != want {
+		t.Fatalf("Location = %q, want %q", loc, want)
+	}
+	a.expect(http.StatusOK, "GET", want, nil, nil)
+}
+
+func TestTamperedCursors(t *testing.T) {
+	a := newTestAPI(t)
+	a.collection("a", "t", "one")
+	a.collection("b", "t", "two")
+	a.expect(http.StatusOK, "DELETE", "/collections/a", nil, nil)
+	a.expect(http.StatusOK, "DELETE", "/collections/b", nil, nil)
+
+	// A well-formed cursor of the right listing whose value is not a time.
+	tamper := func(sort string) string {
+		data, _ := json.Marshal(cursor{Sort: sort, ID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", Value: "garbage"})
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	for _, target := range []string{
+		"/collections?sort=created&cursor=" + tamper("created"),
+		"/collections?sort=updated&order=desc&cursor=" + tamper("updated:desc"),
+		"/trash?cursor=" + tamper("deleted"),
+	} {
+		a.expectProblem(http.StatusBadRequest, "invalid_cursor", "GET", target, nil)
+	}
+
+	// Cursors that were issued keep working.
+	var trash ListTrashResponse
+	a.expect(http.StatusOK, "GET", "/trash?limit=1", nil, &trash)
+	a.expect(http.StatusOK, "GET", "/trash?limit=1&cursor="+trash.Page.NextCursor, nil, &trash)
+	if len(trash.Items) != 1 {
+		t.Errorf("second page of trash = %+v, want one item", trash.Items)
+	}
+}
+
+// tagErrorStore is a Store failing to add tags to data points with err.
+type tagErrorStore struct {
+	collections.Store
+	err error
+}
+
+func (s tagErrorStore) WithTx(ctx context.Context, fn func(collections.Store) error) error {
+	return s.Store.WithTx(ctx, func(tx collections.Store) error {
+		return fn(tagErrorStore{tx, s.err})
+	})
+}
+
+func (s tagErrorStore) AddDataPointTag(ctx context.Context, dataPointID, tagID string) error {
+	return s.err
+}
+
+func TestAddDataPointTagsErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("tag x: %w", collections.ErrConflict), http.StatusConflict, "tag_name_taken"},
+		{fmt.Errorf("tag x: %w", collections.ErrCycle), http.StatusConflict, "tag_cycle"},
+		{fmt.Errorf("tag x: %w", collections.ErrNotFound), http.StatusNotFound, "tag_not_found"},
+		{errors.New("disk full"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		store := &tagErrorStore{Store: collections.NewMemoryStore()}
+		a := newTestAPIWithStore(t, store)
+		created := a.collection("c", "t", "text")
+		store.err = tt.err
+
+		a.expectProblem(tt.status, tt.code, "POST", "/collections/c/tags/t/datapoints/"+created.DataPointIDs[0]+"/tags",
+			DataPointTagsRequest{Tags: []string{"other"}})
+	}
+
+	a := newTestAPI(t)
+	created := a.collection("c", "t", "text")
+	var resp DataPointTagsResponse
+	a.expect(http.StatusOK, "POST", "/collections/c/tags/t/datapoints/"+created.DataPointIDs[0]+"/tags",
+		DataPointTagsRequest{Tags: []string{"other", "t"}}, &resp)
+	if len(resp.Tags) != 2 {
+		t.Errorf("tags = %+v, want t and other", resp.Tags)
+	}
+	a.expectProblem(http.StatusUnprocessableEntity, "validation_failed", "POST", "/collections/c/tags/t/datapoints/"+created.DataPointIDs[0]+"/tags",
+		DataPointTagsRequest{Tags: []string{"a//b"}})
+}
+
+func TestCreateFeedInRenamedCollection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title></channel></rss>`)
+	}))
+	defer server.Close()
+
+	a := newTestAPI(t)
+	created := a.collection("Notes", "t", "text")
+	a.expect(http.StatusOK, "PUT", "/collections/notes", UpdateCollectionRequest{NewName: "Journal"}, nil)
+
+	// The request names the collection by the slug it gave up, which is
+	// followed rather than redirected to: /feeds has no collection in it.
+	var resp FeedPollResponse
+	a.expect(http.StatusCreated, "POST", "/feeds", CreateFeedRequest{Collection: "notes", Tag: "news", URL: server.URL}, &resp)
+	if resp.Feed.CollectionID != created.ID {
+		t.Errorf("feed collection = %s, want %s", resp.Feed.CollectionID, created.ID)
+	}
+	a.expectProblem(http.StatusNotFound, "collection_not_found", "POST", "/feeds",
+		CreateFeedRequest{Collection: "missing", Tag: "news", URL: server.URL})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/collections"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/oklog/ulid/v2"
+)
+
+// RequestID gives every request an ID, taken from the X-Request-Id header if
+// the client sent one or generated otherwise. The ID is echoed in the
+// X-Request-Id response header, included in error responses and logged by
+// middleware.Logger.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(middleware.RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = ulid.Make().String()
+		}
+		w.Header().Set(middleware.RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+const (
+	// AuthorHeader names whoever makes the changes of a request.
+	AuthorHeader    = "X-Author"
+	maxAuthorLength = 200
+)
+
+// Author records the X-Author header of a request, if any, as the author of
+// the data point revisions it creates.
+func Author(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		author := strings.TrimSpace(r.Header.Get(AuthorHeader))
+		if len(author) > maxAuthorLength {
+			message := fmt.Sprintf("must be at most %d characters", maxAuthorLength)
+			sendError(w, r, apperr.Invalid("invalid_author", "Invalid "+AuthorHeader+" header: "+message,
+				apperr.FieldError{Field: AuthorHeader, Message: message}))
+			return
+		}
+		if author != "" {
+			r = r.WithContext(collections.WithAuthor(r.Context(), author))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"cognivaultServer/collections"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// errInvalidCursor is returned for a ?cursor= that was not issued for the
+// listing it is passed to.
+var errInvalidCursor = invalidField("cursor", "not issued for this listing")
+
+// PageInfo describes the page of a listing returned in a response.
+type PageInfo struct {
+	Limit int `json:"limit"`
+	// NextCursor is passed as ?cursor= to get the next page. It is empty on
+	// the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursor is the decoded form of a page cursor: the position of the last item
+// of a page in a listing sorted by Sort.
+type cursor struct {
+	Sort  string `json:"s,omitempty"`
+	ID    string `json:"id"`
+	Value string `json:"v,omitempty"`
+}
+
+// pageRequest reads the ?limit= and ?cursor= of a listing sorted by sort,
+// which is empty for listings ordered by ID. The returned Page asks for one
+// item more than the limit so that nextPage can tell whether another page
+// follows.
+func pageRequest(r *http.Request, sort string) (collections.Page, error) {
+	limit := defaultPageLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return collections.Page{}, invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxPageLimit))
+		}
+	}
+	page := collections.Page{Limit: limit + 1}
+
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" {
+		return page, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return collections.Page{}, errInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" || c.Sort != sort {
+		return collections.Page{}, errInvalidCursor
+	}
+	if sortedByTime(sort) {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return collections.Page{}, errInvalidCursor
+		}
+	}
+	page.After, page.AfterValue = c.ID, c.Value
+	return page, nil
+}
+
+// sortedByTime reports whether listings sorted by sort are ordered by a
+// time, which the cursors of their pages hold as their value.
+func sortedByTime(sort string) bool {
+	field, _, _ := strings.Cut(sort, ":")
+	switch field {
+	case string(collections.OrderByCreated), string(collections.OrderByUpdated), "deleted":
+		return true
+	}
+	return false
+}
+
+// nextPage trims the items fetched for page to its limit and, if more
+// follow, sets a Link header to the next page. key returns the ID of an item
+// and its value in sort.
+func nextPage[T any](w http.ResponseWriter, r *http.Request, items []T, page collections.Page, sort string, key func(T) (id, value string)) ([]T, PageInfo) {
+	limit := page.Limit - 1
+	info := PageInfo{Limit: limit}
+	if len(items) <= limit {
+		return items, info
+	}
+	items = items[:limit]
+
+	id, value := key(items[limit-1])
+	data, _ := json.Marshal(cursor{Sort: sort, ID: id, Value: value})
+	info.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+
+	query := r.URL.Query()
+	query.Set("cursor", info.NextCursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+	return items, info
+}
+
+// dataPointKey is the nextPage key of data point listings, ordered by ID.
+func dataPointKey(dp collections.DataPoint) (string, string) {
+	return dp.ID, ""
+}
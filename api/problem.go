@@ -0,0 +1,135 @@
+package api
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/collections"
+	"cognivaultServer/fetch"
+	"cognivaultServer/files"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed.
+	Instance string `json:"instance,omitempty"`
+	// Code identifies the error for clients, such as "tag_not_found".
+	Code      string              `json:"code"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []apperr.FieldError `json:"errors,omitempty"`
+}
+
+// sendError responds to a failed request with the problem describing err.
+// Errors that are not an apperr.Error are reported as internal errors, and
+// the details of internal and upstream errors are only logged.
+func sendError(w http.ResponseWriter, r *http.Request, err error) {
+	e := apperr.As(err)
+	if e == nil {
+		e = apperr.Wrap(err, apperr.Internal, "internal_error", "Internal server error")
+	}
+	if e.Kind == apperr.Internal || e.Kind == apperr.Upstream {
+		log.Println(err)
+	}
+
+	status := e.HTTPStatus()
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    e.Fields,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// internalError wraps a failure of the server with the message shown to
+// clients.
+func internalError(err error, message string) *apperr.Error {
+	return apperr.Wrap(err, apperr.Internal, "internal_error", message)
+}
+
+// invalidPayload is the error for request bodies that cannot be decoded.
+func invalidPayload(err error) *apperr.Error {
+	return apperr.Wrap(err, apperr.Validation, "invalid_payload", "Invalid request payload")
+}
+
+// invalidField is the error for a single invalid field or parameter of a
+// request, such as a limit out of range.
+func invalidField(field, message string) *apperr.Error {
+	return apperr.Invalid("invalid_"+field, "Invalid "+field+": "+message,
+		apperr.FieldError{Field: field, Message: message})
+}
+
+// fetchError converts a failed fetch of a URL given by the client into an
+// error with a status matching the cause and a code such as
+// "fetch_timeout".
+func fetchError(err error) *apperr.Error {
+	kind, status := apperr.Upstream, http.StatusBadGateway
+	switch fetch.KindOf(err) {
+	case fetch.InvalidURL:
+		kind, status = apperr.Validation, http.StatusBadRequest
+	case fetch.Blocked:
+		kind, status = apperr.Validation, http.StatusForbidden
+	case fetch.Timeout:
+		status = http.StatusGatewayTimeout
+	case fetch.TooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case fetch.UnsupportedContentType:
+		status = http.StatusUnsupportedMediaType
+	}
+
+	msg := "Failed to fetch URL"
+	var fetchErr *fetch.Error
+	if errors.As(err, &fetchErr) {
+		msg += ": " + fetchErr.Err.Error()
+	}
+	return apperr.Wrap(err, kind, "fetch_"+string(fetch.KindOf(err)), msg).WithStatus(status)
+}
+
+// duplicateError converts a *collections.DuplicateError into an error
+// naming the data point that already holds the content.
+func duplicateError(err error) *apperr.Error {
+	msg := "Content is already stored in the collection"
+	var duplicate *collections.DuplicateError
+	if errors.As(err, &duplicate) {
+		msg = "Content is already stored in data point " + duplicate.DataPointID
+	}
+	return apperr.Wrap(err, apperr.Conflict, "duplicate_content", msg)
+}
+
+// fileError converts a failed read of a file given by the client into an
+// error with a status matching the cause and a code such as
+// "file_too_large".
+func fileError(err error) *apperr.Error {
+	kind, status := apperr.Validation, http.StatusBadRequest
+	switch files.KindOf(err) {
+	case files.OutsideRoots:
+		status = http.StatusForbidden
+	case files.NotFound:
+		kind, status = apperr.NotFound, http.StatusNotFound
+	case files.TooLarge, files.TooManyFiles:
+		status = http.StatusRequestEntityTooLarge
+	case files.UnsupportedContentType:
+		status = http.StatusUnsupportedMediaType
+	}
+
+	msg := "Failed to read file"
+	var fileErr *files.Error
+	if errors.As(err, &fileErr) {
+		msg += ": " + fileErr.Err.Error()
+	}
+	return apperr.Wrap(err, kind, "file_"+string(files.KindOf(err)), msg).WithStatus(status)
+}
@@ -7,31 +7,103 @@ import (
 )
 
 // SetRoutes sets up the routes for the API endpoints using the chi router.
-func SetRoutes(r *chi.Mux) http.Handler {
+func SetRoutes(r *chi.Mux, h *Handlers) http.Handler {
 
 	// Create a new collection
-	r.Post("/collections", CreateCollectionHandler)
+	r.Post("/collections", h.CreateCollectionHandler)
+
+	// List collections and get one by ID or name, with tag and data point counts
+	r.Get("/collections", h.ListCollectionsHandler)
+	r.Get("/collections/{collectionName}", h.GetCollectionInfoHandler)
 
 	// Get data points from a collection
-	r.Get("/collections/{collectionName}/datapoints", GetDataPointsHandler)
+	r.Get("/collections/{collectionName}/datapoints", h.GetCollectionHandler)
+
+	// Search data points of a collection by keyword or meaning
+	r.Get("/collections/{collectionName}/search", h.SearchCollectionHandler)
+
+	// Get and update the hybrid search settings of a collection
+	r.Get("/collections/{collectionName}/search/settings", h.GetSearchSettingsHandler)
+	r.Put("/collections/{collectionName}/search/settings", h.UpdateSearchSettingsHandler)
+
+	// Get and update the dedupe settings of a collection, and list its duplicate content
+	r.Get("/collections/{collectionName}/dedupe", h.GetDedupeSettingsHandler)
+	r.Put("/collections/{collectionName}/dedupe", h.UpdateDedupeSettingsHandler)
+	r.Get("/collections/{collectionName}/duplicates", h.ListDuplicatesHandler)
+
+	// Get a chunked document with its chunks
+	r.Get("/collections/{collectionName}/documents/{documentID}", h.GetDocumentHandler)
+
+	// Download an attachment of a document, such as the raw HTML of a page
+	r.Get("/collections/{collectionName}/documents/{documentID}/attachments/{attachmentID}", h.GetAttachmentHandler)
 
 	// Update a tag
-	r.Put("/collections/{collectionName}/tags/{tagName}", UpdateTagHandler)
+	r.Put("/collections/{collectionName}/tags/{tagName}", h.UpdateTagHandler)
 
 	// Delete a tag
-	r.Delete("/collections/{collectionName}/tags/{tagName}", DeleteTagHandler)
+	r.Delete("/collections/{collectionName}/tags/{tagName}", h.DeleteTagHandler)
 
 	// Update a collection
-	r.Put("/collections/{collectionName}", UpdateCollectionHandler)
+	r.Put("/collections/{collectionName}", h.UpdateCollectionHandler)
 
 	// Delete a collection
-	r.Delete("/collections/{collectionName}", DeleteCollectionHandler)
+	r.Delete("/collections/{collectionName}", h.DeleteCollectionHandler)
 
 	// Get tags under a collection
-	r.Get("/collections/{collectionName}/tags", GetTagsHandler)
+	r.Get("/collections/{collectionName}/tags", h.GetTagsHandler)
+
+	// Create a tag, and move a tag with its descendants below another one
+	r.Post("/collections/{collectionName}/tags", h.CreateTagHandler)
+	r.Post("/collections/{collectionName}/tags/{tagName}/move", h.MoveTagHandler)
 
 	// Get data points under a tag
-	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints", GetDataPointsByTagHandler)
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints", h.GetDataPointsByTagHandler)
+
+	// Create, read, update and delete single data points of a tag
+	r.Post("/collections/{collectionName}/tags/{tagName}/datapoints", h.CreateDataPointHandler)
+	r.Post("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}", h.CreateDataPointHandler)
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}", h.GetDataPointHandler)
+	r.Put("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}", h.ReplaceDataPointHandler)
+	r.Patch("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}", h.PatchDataPointHandler)
+	r.Delete("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}", h.DeleteDataPointHandler)
+
+	// List, view, compare and restore the revisions of a data point
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/revisions", h.ListRevisionsHandler)
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/revisions/diff", h.DiffRevisionsHandler)
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/revisions/{revision}", h.GetRevisionHandler)
+	r.Post("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/revisions/{revision}/restore", h.RestoreRevisionHandler)
+
+	// List, add and remove the tags of a data point
+	r.Get("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/tags", h.GetDataPointTagsHandler)
+	r.Post("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/tags", h.AddDataPointTagsHandler)
+	r.Delete("/collections/{collectionName}/tags/{tagName}/datapoints/{dataPointID}/tags/{tagRef}", h.RemoveDataPointTagHandler)
+
+	// List deleted collections, tags and data points, and restore them
+	r.Get("/trash", h.ListTrashHandler)
+	r.Post("/trash/{kind}/{id}/restore", h.RestoreTrashHandler)
+
+	// List, reschedule and delete the fetched URLs crawled again, crawl one right away and list its fetches
+	r.Get("/sources", h.ListSourcesHandler)
+	r.Get("/sources/{sourceID}", h.GetSourceHandler)
+	r.Put("/sources/{sourceID}", h.UpdateSourceHandler)
+	r.Delete("/sources/{sourceID}", h.DeleteSourceHandler)
+	r.Post("/sources/{sourceID}/fetch", h.FetchSourceHandler)
+	r.Get("/sources/{sourceID}/fetches", h.ListSourceFetchesHandler)
+
+	// Subscribe tags to RSS and Atom feeds, list, update and delete feeds, poll one right away and list its entries
+	r.Post("/feeds", h.CreateFeedHandler)
+	r.Get("/feeds", h.ListFeedsHandler)
+	r.Get("/feeds/{feedID}", h.GetFeedHandler)
+	r.Put("/feeds/{feedID}", h.UpdateFeedHandler)
+	r.Delete("/feeds/{feedID}", h.DeleteFeedHandler)
+	r.Post("/feeds/{feedID}/poll", h.PollFeedHandler)
+	r.Get("/feeds/{feedID}/entries", h.ListFeedEntriesHandler)
+
+	// Ingest sources in the background and follow the progress of the jobs
+	r.Post("/jobs", h.SubmitJobHandler)
+	r.Get("/jobs", h.ListJobsHandler)
+	r.Get("/jobs/{jobID}", h.GetJobHandler)
+	r.Post("/jobs/{jobID}/cancel", h.CancelJobHandler)
 
 	return r
 }
@@ -0,0 +1,119 @@
+// Package apperr defines the errors reported to API clients. An Error has a
+// Kind, which decides the HTTP status, and a machine-readable code clients
+// can branch on, such as "collection_not_found".
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies an error by who has to act on it.
+type Kind string
+
+const (
+	// NotFound means a requested record does not exist.
+	NotFound Kind = "not_found"
+	// Conflict means the request clashes with the current state, such as a
+	// record that already exists.
+	Conflict Kind = "conflict"
+	// Validation means the request is malformed or has invalid fields.
+	Validation Kind = "validation"
+	// Upstream means a service the request depends on, such as a web site
+	// being fetched, failed.
+	Upstream Kind = "upstream"
+	// Internal means the server failed. Its details are not shown to
+	// clients.
+	Internal Kind = "internal"
+)
+
+// FieldError describes an invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error to be reported to clients.
+type Error struct {
+	Kind Kind
+	// Code identifies the error for clients, such as "tag_not_found".
+	Code string
+	// Message explains the error to people.
+	Message string
+	// Fields lists the invalid fields of Validation errors.
+	Fields []FieldError
+	// Status overrides the HTTP status implied by Kind, such as 413 for a
+	// Validation error about a body that is too large.
+	Status int
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// New returns an Error of kind with code and message.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap returns an Error of kind with code and message caused by err.
+func Wrap(err error, kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// Invalid returns a Validation error listing the invalid fields.
+func Invalid(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: Validation, Code: code, Message: message, Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithStatus sets the HTTP status of e and returns it.
+func (e *Error) WithStatus(status int) *Error {
+	e.Status = status
+	return e
+}
+
+// HTTPStatus returns the HTTP status reported for e.
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	switch e.Kind {
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Validation:
+		return http.StatusBadRequest
+	case Upstream:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// As returns the outermost Error in the chain of err, or nil if there is
+// none.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// KindOf returns the Kind of err, or Internal if err is not an Error.
+func KindOf(err error) Kind {
+	if e := As(err); e != nil {
+		return e.Kind
+	}
+	return Internal
+}
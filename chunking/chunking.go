@@ -0,0 +1,325 @@
+// Package chunking splits long documents into smaller, overlapping pieces
+// that are stored and retrieved as separate data points.
+package chunking
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Strategy names a way of splitting text.
+type Strategy string
+
+const (
+	// None keeps the whole text as a single chunk.
+	None Strategy = "none"
+	// Fixed packs words into chunks of at most Size bytes.
+	Fixed Strategy = "fixed"
+	// Sentence packs whole sentences into chunks.
+	Sentence Strategy = "sentence"
+	// Paragraph packs whole paragraphs (separated by blank lines) into chunks.
+	Paragraph Strategy = "paragraph"
+	// Markdown starts a new chunk at every Markdown heading and packs the
+	// paragraphs of long sections.
+	Markdown Strategy = "markdown"
+)
+
+// Options configures a Chunker.
+type Options struct {
+	Strategy Strategy `json:"strategy"`
+	// Size is the maximum length of a chunk in bytes.
+	Size int `json:"size"`
+	// Overlap is the maximum number of bytes repeated from the end of one
+	// chunk at the start of the next one.
+	Overlap int `json:"overlap"`
+}
+
+// DefaultOptions is used for documents ingested without chunking options.
+var DefaultOptions = Options{Strategy: Paragraph, Size: 1000, Overlap: 100}
+
+// Validate reports whether the options describe a usable Chunker.
+func (o Options) Validate() error {
+	switch o.Strategy {
+	case None, Fixed, Sentence, Paragraph, Markdown:
+	default:
+		return fmt.Errorf("unknown chunking strategy %q", o.Strategy)
+	}
+	if o.Strategy == None {
+		return nil
+	}
+	if o.Size < 1 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+	if o.Overlap < 0 || o.Overlap >= o.Size {
+		return fmt.Errorf("chunk overlap must be between 0 and the chunk size")
+	}
+	return nil
+}
+
+// Chunk is a piece of a document.
+type Chunk struct {
+	// Index is the 0-based position of the chunk in the document.
+	Index int
+	// Offset is the byte offset of Text in the document.
+	Offset int
+	Text   string
+}
+
+// Chunker splits a document into chunks.
+type Chunker interface {
+	Chunk(text string) []Chunk
+}
+
+// New returns the Chunker described by opts.
+func New(opts Options) (Chunker, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch opts.Strategy {
+	case None:
+		return noneChunker{}, nil
+	case Fixed:
+		return &packer{opts: opts, level: wordLevel}, nil
+	case Sentence:
+		return &packer{opts: opts, level: sentenceLevel}, nil
+	case Paragraph:
+		return &packer{opts: opts, level: paragraphLevel}, nil
+	default:
+		return &markdownChunker{packer{opts: opts, level: paragraphLevel}}, nil
+	}
+}
+
+type noneChunker struct{}
+
+func (noneChunker) Chunk(text string) []Chunk {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return []Chunk{{Index: 0, Offset: 0, Text: text}}
+}
+
+// span is a half-open byte range of the document.
+type span struct {
+	start, end int
+}
+
+func (s span) len() int {
+	return s.end - s.start
+}
+
+// splitters split a range of the document into units, from the coarsest to
+// the finest.
+var splitters = []func(text string, within span) []span{paragraphs, sentences, words}
+
+const (
+	paragraphLevel = iota
+	sentenceLevel
+	wordLevel
+)
+
+// packer groups the units produced by splitters[level] into chunks of at
+// most opts.Size bytes, repeating trailing units of up to opts.Overlap bytes
+// at the start of the next chunk. Units longer than a chunk are packed again
+// from finer units, and words longer than a chunk are cut into fixed-size
+// windows.
+type packer struct {
+	opts  Options
+	level int
+}
+
+func (p *packer) Chunk(text string) []Chunk {
+	spans := p.pack(text, splitters[p.level](text, span{0, len(text)}))
+	return toChunks(text, spans, 0)
+}
+
+func (p *packer) pack(text string, units []span) []span {
+	var out []span
+	var current []span
+
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, span{current[0].start, current[len(current)-1].end})
+		}
+	}
+
+	for _, u := range units {
+		if u.len() > p.opts.Size {
+			flush()
+			current = nil
+			if p.level == wordLevel {
+				out = append(out, p.window(text, u)...)
+			} else {
+				finer := &packer{opts: p.opts, level: p.level + 1}
+				out = append(out, finer.pack(text, splitters[finer.level](text, u))...)
+			}
+			continue
+		}
+
+		if len(current) > 0 && u.end-current[0].start > p.opts.Size {
+			flush()
+			current = p.carry(current, u)
+		}
+		current = append(current, u)
+	}
+	flush()
+
+	return out
+}
+
+// carry returns the trailing units of a finished chunk that should be
+// repeated before next.
+func (p *packer) carry(chunk []span, next span) []span {
+	end := chunk[len(chunk)-1].end
+	i := len(chunk)
+	for i > 0 && end-chunk[i-1].start <= p.opts.Overlap && next.end-chunk[i-1].start <= p.opts.Size {
+		i--
+	}
+	// Never carry the whole chunk, or the next chunk would contain it again.
+	if i == 0 {
+		i = 1
+	}
+	return append([]span(nil), chunk[i:]...)
+}
+
+// window cuts a long unit into pieces of at most opts.Size bytes that overlap
+// by opts.Overlap bytes, without splitting UTF-8 sequences. Only runes longer
+// than opts.Size make longer pieces.
+func (p *packer) window(text string, u span) []span {
+	var out []span
+	start := u.start
+	for {
+		end := start + p.opts.Size
+		if end >= u.end {
+			out = append(out, span{start, u.end})
+			return out
+		}
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if end == start {
+			// A rune longer than a chunk is kept whole.
+			_, size := utf8.DecodeRuneInString(text[start:])
+			end = start + size
+		}
+		out = append(out, span{start, end})
+		if end == u.end {
+			return out
+		}
+
+		next := end - p.opts.Overlap
+		for next > start && next < end && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+}
+
+func toChunks(text string, spans []span, first int) []Chunk {
+	chunks := make([]Chunk, 0, len(spans))
+	for i, s := range spans {
+		chunks = append(chunks, Chunk{Index: first + i, Offset: s.start, Text: text[s.start:s.end]})
+	}
+	return chunks
+}
+
+// trim shrinks s to exclude leading and trailing white space. It returns
+// false if nothing is left.
+func trim(text string, s span) (span, bool) {
+	for s.start < s.end {
+		r, size := utf8.DecodeRuneInString(text[s.start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.start += size
+	}
+	for s.end > s.start {
+		r, size := utf8.DecodeLastRuneInString(text[:s.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		s.end -= size
+	}
+	return s, s.start < s.end
+}
+
+// words splits within into runs of non-space characters.
+func words(text string, within span) []span {
+	var out []span
+	start := -1
+	for i, r := range text[within.start:within.end] {
+		i += within.start
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				out = append(out, span{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		out = append(out, span{start, within.end})
+	}
+	return out
+}
+
+// sentences splits within after sentence-ending punctuation that is followed
+// by white space, and at blank lines.
+func sentences(text string, within span) []span {
+	var out []span
+	for _, para := range paragraphs(text, within) {
+		start := para.start
+		s := text[para.start:para.end]
+		for i, r := range s {
+			if r != '.' && r != '!' && r != '?' {
+				continue
+			}
+			next := i + 1
+			if next < len(s) && !unicode.IsSpace(rune(s[next])) {
+				continue
+			}
+			if u, ok := trim(text, span{start, para.start + next}); ok {
+				out = append(out, u)
+			}
+			start = para.start + next
+		}
+		if u, ok := trim(text, span{start, para.end}); ok {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// paragraphs splits within at blank lines.
+func paragraphs(text string, within span) []span {
+	var out []span
+	start := within.start
+	lineStart := within.start
+	blank := true
+	for i := within.start; i <= within.end; i++ {
+		if i < within.end && text[i] != '\n' {
+			if text[i] != ' ' && text[i] != '\t' && text[i] != '\r' {
+				blank = false
+			}
+			continue
+		}
+		if blank && lineStart > start {
+			if u, ok := trim(text, span{start, lineStart}); ok {
+				out = append(out, u)
+			}
+			start = i
+		}
+		lineStart = i + 1
+		blank = true
+	}
+	if u, ok := trim(text, span{start, within.end}); ok {
+		out = append(out, u)
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package chunking
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkChunks checks that chunks are numbered in order, that each is the
+// text at its offset and that none is longer than opts.Size, except those
+// holding a single rune longer than it.
+func checkChunks(t *testing.T, opts Options, text string, chunks []Chunk) {
+	t.Helper()
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if c.Offset < 0 || c.Offset+len(c.Text) > len(text) || text[c.Offset:c.Offset+len(c.Text)] != c.Text {
+			t.Errorf("chunk %d %q is not the text at offset %d", i, c.Text, c.Offset)
+		}
+		if !utf8.ValidString(c.Text) {
+			t.Errorf("chunk %d %q splits a rune", i, c.Text)
+		}
+		if opts.Strategy != None && len(c.Text) > opts.Size && utf8.RuneCountInString(c.Text) > 1 {
+			t.Errorf("chunk %d %q is longer than %d bytes", i, c.Text, opts.Size)
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		text string
+		want []string
+	}{
+		{"none", Options{Strategy: None}, " all\n\nof it ", []string{" all\n\nof it "}},
+		{"none of nothing", Options{Strategy: None}, " \n ", nil},
+		{"nothing", Options{Strategy: Paragraph, Size: 10}, "\n\n  \n", nil},
+
+		{"fixed", Options{Strategy: Fixed, Size: 10},
+			"one two three four", []string{"one two", "three four"}},
+		// Trailing words are repeated while they fit the overlap and the
+		// next chunk.
+		{"fixed with overlap", Options{Strategy: Fixed, Size: 10, Overlap: 4},
+			"one two three four", []string{"one two", "two three", "four"}},
+		{"carried words that fit the next chunk", Options{Strategy: Fixed, Size: 11, Overlap: 10},
+			"ab cd efghij klm", []string{"ab cd", "cd efghij", "efghij klm"}},
+
+		{"long word", Options{Strategy: Fixed, Size: 4},
+			"abcdefghij", []string{"abcd", "efgh", "ij"}},
+		{"long word with overlap", Options{Strategy: Fixed, Size: 4, Overlap: 2},
+			"abcdefgh", []string{"abcd", "cdef", "efgh"}},
+		// Windows end before the rune that would not fit.
+		{"long word of 2-byte runes", Options{Strategy: Fixed, Size: 5},
+			"ééééé", []string{"éé", "éé", "é"}},
+		{"long word of 2-byte runes with overlap", Options{Strategy: Fixed, Size: 5, Overlap: 2},
+			"éééé", []string{"éé", "éé", "éé"}},
+		// The overlap shrinks to start at a rune, rather than grow.
+		{"overlap inside a rune", Options{Strategy: Fixed, Size: 6, Overlap: 3},
+			"a€b€c", []string{"a€b", "b€c"}},
+		{"runes longer than a chunk", Options{Strategy: Fixed, Size: 3},
+			"😀😀", []string{"😀", "😀"}},
+		{"runes longer than a chunk with overlap", Options{Strategy: Fixed, Size: 3, Overlap: 2},
+			"a😀b", []string{"a", "😀", "b"}},
+
+		{"sentences", Options{Strategy: Sentence, Size: 30},
+			"First one. Second one! Third? Fourth.", []string{"First one. Second one! Third?", "Fourth."}},
+		{"decimal points", Options{Strategy: Sentence, Size: 20},
+			"Pi is 3.14 today. Yes.", []string{"Pi is 3.14 today.", "Yes."}},
+
+		{"paragraphs", Options{Strategy: Paragraph, Size: 20},
+			"Para one.\n\nPara two.\n \nPara three is longer than the size.",
+			[]string{"Para one.\n\nPara two.", "Para three is longer", "than the size."}},
+		{"paragraphs with overlap", Options{Strategy: Paragraph, Size: 25, Overlap: 12},
+			"Alpha one.\n\nBravo two.\n\nCharlie 3.",
+			[]string{"Alpha one.\n\nBravo two.", "Bravo two.\n\nCharlie 3."}},
+		{"long paragraph by sentences", Options{Strategy: Paragraph, Size: 25},
+			"Short.\n\nA first sentence. A second one.",
+			[]string{"Short.", "A first sentence.", "A second one."}},
+		{"windows line endings", Options{Strategy: Paragraph, Size: 10},
+			"One.\r\n\r\nTwo three.\r\n", []string{"One.", "Two three."}},
+
+		{"markdown", Options{Strategy: Markdown, Size: 40},
+			"# Title\nIntro text.\n\n## Install\n```\n# not a heading\n```\n## Use\nRun it. #tag\n####### Not either",
+			[]string{"# Title\nIntro text.", "## Install\n```\n# not a heading\n```", "## Use\nRun it. #tag\n####### Not either"}},
+		{"markdown without headings", Options{Strategy: Markdown, Size: 40},
+			"Just text.\n\nMore text.", []string{"Just text.\n\nMore text."}},
+		{"long markdown section", Options{Strategy: Markdown, Size: 45},
+			"Intro.\n## Long\nFirst paragraph is here.\n\nSecond paragraph is here.\n~~~\n## code\n~~~",
+			[]string{"Intro.", "## Long\nFirst paragraph is here.", "Second paragraph is here.\n~~~\n## code\n~~~"}},
+	}
+	for _, tt := range tests {
+		chunker, err := New(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		chunks := chunker.Chunk(tt.text)
+		var got []string
+		for _, c := range chunks {
+			got = append(got, c.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chunks = %q, want %q", tt.name, got, tt.want)
+		}
+		checkChunks(t, tt.opts, tt.text, chunks)
+	}
+}
+
+func TestChunkOffsets(t *testing.T) {
+	text := "Grüße aus Köln.\n\nÜber 😀 die Brücke. Noch ein Satz!\n\n# Kopf\nEnde."
+	for _, strategy := range []Strategy{None, Fixed, Sentence, Paragraph, Markdown} {
+		for _, size := range []int{1, 3, 5, 8, 13, 40} {
+			for _, overlap := range []int{0, size / 2, size - 1} {
+				opts := Options{Strategy: strategy, Size: size, Overlap: overlap}
+				chunker, err := New(opts)
+				if err != nil {
+					t.Fatalf("%+v: %v", opts, err)
+				}
+				checkChunks(t, opts, text, chunker.Chunk(text))
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		opts  Options
+		valid bool
+	}{
+		{DefaultOptions, true},
+		{Options{Strategy: None}, true},
+		{Options{Strategy: Markdown, Size: 1}, true},
+		{Options{Strategy: Fixed, Size: 10, Overlap: 9}, true},
+		{Options{Strategy: "words", Size: 10}, false},
+		{Options{Size: 10}, false},
+		{Options{Strategy: Sentence}, false},
+		{Options{Strategy: Sentence, Size: -1}, false},
+		{Options{Strategy: Paragraph, Size: 10, Overlap: 10}, false},
+		{Options{Strategy: Paragraph, Size: 10, Overlap: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v.Validate() = %v, want valid %t", tt.opts, err, tt.valid)
+		}
+		if _, err := New(tt.opts); (err == nil) != tt.valid {
+			t.Errorf("New(%+v) error = %v, want valid %t", tt.opts, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package chunking
+
+import (
+	"strings"
+)
+
+// markdownChunker starts a new chunk at every ATX heading (# to ######) that
+// is not inside a fenced code block. Sections longer than the chunk size are
+// packed paragraph by paragraph like the Paragraph strategy.
+type markdownChunker struct {
+	packer
+}
+
+func (m *markdownChunker) Chunk(text string) []Chunk {
+	var spans []span
+	for _, section := range markdownSections(text) {
+		section, ok := trim(text, section)
+		if !ok {
+			continue
+		}
+		if section.len() <= m.opts.Size {
+			spans = append(spans, section)
+			continue
+		}
+		spans = append(spans, m.pack(text, splitters[m.level](text, section))...)
+	}
+	return toChunks(text, spans, 0)
+}
+
+// markdownSections splits text before every heading line.
+func markdownSections(text string) []span {
+	var out []span
+	start := 0
+	fence := ""
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimLeft(text[lineStart:lineEnd], " ")
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```"):
+			fence = "```"
+		case strings.HasPrefix(line, "~~~"):
+			fence = "~~~"
+		case isHeading(line) && lineStart > start:
+			out = append(out, span{start, lineStart})
+			start = lineStart
+		}
+
+		lineStart = lineEnd + 1
+	}
+	out = append(out, span{start, len(text)})
+	return out
+}
+
+// isHeading reports whether line is an ATX heading such as "## Install".
+func isHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return false
+	}
+	return level == len(line) || line[level] == ' ' || line[level] == '\t'
+}
@@ -0,0 +1,70 @@
+package collections
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DedupePolicy decides what happens to a new data point whose content is
+// already stored in its collection.
+type DedupePolicy string
+
+const (
+	// DedupeReject fails with a *DuplicateError.
+	DedupeReject DedupePolicy = "reject"
+	// DedupeSkip keeps the stored data point and drops the new one.
+	DedupeSkip DedupePolicy = "skip"
+	// DedupeMerge adds the metadata of the new data point to the stored
+	// one, keeping the stored values of keys both have.
+	DedupeMerge DedupePolicy = "merge"
+	// DedupeRevision replaces the value and metadata of the stored data
+	// point by those of the new one, recording a revision.
+	DedupeRevision DedupePolicy = "revision"
+)
+
+// DedupeSettings controls duplicate content in one collection.
+type DedupeSettings struct {
+	CollectionID string `json:"collection_id"`
+	// Unique rejects data points whose content is already stored in the
+	// collection, unless a Policy handles them.
+	Unique bool `json:"unique"`
+	// Policy is applied to duplicates of ingested data points that do not
+	// ask for a policy of their own. Without one, duplicates are stored
+	// unless Unique is set.
+	Policy    DedupePolicy `json:"policy,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// DuplicateGroup is a set of data points of a collection with the same
+// content.
+type DuplicateGroup struct {
+	ContentHash string `json:"content_hash"`
+	// DataPointIDs holds the IDs of the data points, oldest first.
+	DataPointIDs []string `json:"data_point_ids"`
+}
+
+// DuplicateError is returned when a data point would duplicate the content
+// of another one in a collection that keeps content unique.
+type DuplicateError struct {
+	// DataPointID is the ID of the data point already holding the content.
+	DataPointID string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("content is already stored in data point %s", e.DataPointID)
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicateContent
+}
+
+// ContentHash returns the hash identifying value as content, which ignores
+// differences in whitespace: runs of spaces, tabs and line breaks count as
+// a single space, and leading and trailing ones are dropped.
+func ContentHash(value string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(value), " ")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,39 @@
+package collections
+
+import (
+	"time"
+)
+
+// Document is a source text that was split into chunks, each stored as a
+// data point linked back to it through DataPoint.Chunk.
+type Document struct {
+	ID           string `json:"id"`
+	CollectionID string `json:"collection_id"`
+	// Source describes where the text came from, such as a URL or file path.
+	Source        string    `json:"source"`
+	ChunkStrategy string    `json:"chunk_strategy"`
+	ChunkSize     int       `json:"chunk_size"`
+	ChunkOverlap  int       `json:"chunk_overlap"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ChunkRef places a data point within the document it was cut from.
+type ChunkRef struct {
+	DocumentID string `json:"document_id"`
+	// Index is the 0-based position of the chunk in the document.
+	Index int `json:"index"`
+	// Offset is the byte offset of the chunk in the document text.
+	Offset int `json:"offset"`
+}
+
+// Attachment is a file kept alongside a document.
+type Attachment struct {
+	ID          string `json:"id"`
+	DocumentID  string `json:"document_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	// Data is only loaded by GetAttachment.
+	Data      []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package collections
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Embedding is the vector computed for a data point by an embedding model.
+type Embedding struct {
+	DataPointID string    `json:"data_point_id"`
+	Model       string    `json:"model"`
+	Vector      []float32 `json:"vector"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// encodeVector packs v as little-endian float32 values.
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// decodeVector is the inverse of encodeVector.
+func decodeVector(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector blob of %d bytes", len(buf))
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
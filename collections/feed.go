@@ -0,0 +1,61 @@
+package collections
+
+import "time"
+
+// Feed is an RSS or Atom feed whose new entries are filed under a tag, one
+// data point per entry. Like a Source it is polled with conditional
+// requests every Interval.
+type Feed struct {
+	ID           string `json:"id"`
+	CollectionID string `json:"collection_id"`
+	TagID        string `json:"tag_id"`
+	URL          string `json:"url"`
+	// Title is the title the feed gives itself.
+	Title string `json:"title,omitempty"`
+	// FullText stores the text of the page each entry links to instead of
+	// the content of the entry in the feed.
+	FullText bool `json:"full_text"`
+	// Interval is the time between polls, 0 if the feed is only polled on
+	// demand.
+	Interval     Duration `json:"interval"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	// NextFetchAt is when the feed is due to be polled, nil if it has no
+	// Interval.
+	NextFetchAt   *time.Time `json:"next_fetch_at,omitempty"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	// LastStatus is FetchChanged if the last poll found new entries and
+	// FetchUnchanged if it found none.
+	LastStatus FetchStatus `json:"last_status,omitempty"`
+	LastError  string      `json:"last_error,omitempty"`
+	// Failures counts the polls that failed in a row.
+	Failures  int       `json:"failures"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Schedule sets f.NextFetchAt to Interval after t, or to nil if f has no
+// Interval.
+func (f *Feed) Schedule(t time.Time) {
+	f.NextFetchAt = nil
+	if f.Interval > 0 {
+		next := t.Add(time.Duration(f.Interval))
+		f.NextFetchAt = &next
+	}
+}
+
+// FeedEntry records an entry of a Feed that has been stored. Entries are
+// identified by their GUID, so each is only stored once.
+type FeedEntry struct {
+	ID     string `json:"id"`
+	FeedID string `json:"feed_id"`
+	GUID   string `json:"guid"`
+	Title  string `json:"title,omitempty"`
+	Link   string `json:"link,omitempty"`
+	// PublishedAt is nil if the feed gives no date for the entry.
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// DataPointID is the data point created for the entry, or the one
+	// already holding its content. It is empty for entries without text.
+	DataPointID string    `json:"data_point_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,413 @@
+package collections
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxFilterConditions bounds the number of comparisons in a metadata filter.
+const maxFilterConditions = 20
+
+// indexedMetadata maps the metadata keys with a generated, indexed column on
+// data_points to that column.
+var indexedMetadata = map[string]string{
+	"source_url": "meta_source_url",
+	"author":     "meta_author",
+	"language":   "meta_language",
+}
+
+var (
+	metadataKey  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	filterNumber = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// MetadataFilter is a parsed filter expression over the metadata of data
+// points, such as
+//
+//	meta.author = "Ada" AND (meta.year > 2020 OR meta.draft = true)
+//
+// Each comparison names a metadata field, nested fields separated by dots,
+// and compares it with a string, number, true, false or null using =, !=, <,
+// <=, > or >=. Comparisons are combined with AND and OR, AND binding
+// tighter, and grouped with parentheses.
+//
+// Values compare the way SQLite compares JSON values: numbers, with true and
+// false counting as 1 and 0, sort before strings, and strings are compared
+// bytewise. A comparison with a missing field is false, except for = null,
+// which matches missing and null fields alike.
+type MetadataFilter struct {
+	// Either op is AND or OR and operands are set, or the filter is a
+	// comparison of the field at path with value.
+	op       string
+	operands []*MetadataFilter
+	path     []string
+	value    interface{}
+}
+
+// ParseMetadataFilter parses a filter expression.
+func ParseMetadataFilter(s string) (*MetadataFilter, error) {
+	tokens, err := filterTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := filterParser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+// filterToken is a token of a filter expression. Quoted strings keep their
+// quotes so they cannot be mistaken for keywords.
+type filterToken string
+
+func (t filterToken) String() string {
+	return strconv.Quote(string(t))
+}
+
+func (t filterToken) keyword(k string) bool {
+	return strings.EqualFold(string(t), k)
+}
+
+// filterTokens splits s into words, quoted strings, operators and
+// parentheses.
+func filterTokens(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken(s[i:i+1]))
+			i++
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			end := i + 1
+			if end < len(s) && s[end] == '=' {
+				end++
+			}
+			if s[i:end] == "!" {
+				return nil, errors.New(`unexpected "!", did you mean "!="?`)
+			}
+			tokens = append(tokens, filterToken(s[i:end]))
+			i = end
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, filterToken(s[i:end+1]))
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n\r()=!<>\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, filterToken(s[i:end]))
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser over the tokens of a filter.
+type filterParser struct {
+	tokens     []filterToken
+	pos        int
+	conditions int
+}
+
+func (p *filterParser) next() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	p.pos++
+	return p.tokens[p.pos-1], true
+}
+
+func (p *filterParser) peek(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].keyword(keyword)
+}
+
+func (p *filterParser) or() (*MetadataFilter, error) {
+	return p.binary("OR", p.and)
+}
+
+func (p *filterParser) and() (*MetadataFilter, error) {
+	return p.binary("AND", p.operand)
+}
+
+// binary parses operands joined by op.
+func (p *filterParser) binary(op string, operand func() (*MetadataFilter, error)) (*MetadataFilter, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*MetadataFilter{first}
+	for p.peek(op) {
+		p.pos++
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &MetadataFilter{op: op, operands: operands}, nil
+}
+
+// operand parses a parenthesized expression or a comparison.
+func (p *filterParser) operand() (*MetadataFilter, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, errors.New("unexpected end of filter, expected a comparison")
+	}
+	if t == "(" {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.next(); !ok || t != ")" {
+			return nil, errors.New(`missing ")"`)
+		}
+		return f, nil
+	}
+
+	field := string(t)
+	if !strings.HasPrefix(field, "meta.") {
+		return nil, fmt.Errorf("unexpected %s, expected a field such as meta.author", t)
+	}
+	path := strings.Split(strings.TrimPrefix(field, "meta."), ".")
+	for _, key := range path {
+		if !metadataKey.MatchString(key) {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+	}
+
+	op, _ := p.next()
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("expected a comparison operator after %s", field)
+	}
+
+	raw, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("expected a value after %s %s", field, op)
+	}
+	value, err := filterValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("null can only be compared with = or !=")
+	}
+
+	p.conditions++
+	if p.conditions > maxFilterConditions {
+		return nil, fmt.Errorf("filter has more than %d comparisons", maxFilterConditions)
+	}
+	return &MetadataFilter{op: string(op), path: path, value: value}, nil
+}
+
+// filterValue parses a literal: a quoted string, a number, true, false or
+// null. Booleans become 1 and 0 like in SQLite.
+func filterValue(t filterToken) (interface{}, error) {
+	s := string(t)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		var str string
+		if err := json.Unmarshal([]byte(s), &str); err != nil {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return str, nil
+	case t.keyword("true"):
+		return int64(1), nil
+	case t.keyword("false"):
+		return int64(0), nil
+	case t.keyword("null"):
+		return nil, nil
+	}
+	if filterNumber.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %s, strings must be quoted", t)
+}
+
+// sql renders the filter as an SQL condition on the data_points table, whose
+// columns are prefixed with prefix, such as "d.". A nil filter renders as an
+// empty string; otherwise the condition starts with " AND ".
+func (f *MetadataFilter) sql(prefix string, args []interface{}) (string, []interface{}) {
+	if f == nil {
+		return "", args
+	}
+	var b strings.Builder
+	b.WriteString(" AND ")
+	args = f.render(&b, prefix, args)
+	return b.String(), args
+}
+
+func (f *MetadataFilter) render(b *strings.Builder, prefix string, args []interface{}) []interface{} {
+	if f.operands != nil {
+		b.WriteString("(")
+		for i, operand := range f.operands {
+			if i > 0 {
+				b.WriteString(" " + f.op + " ")
+			}
+			args = operand.render(b, prefix, args)
+		}
+		b.WriteString(")")
+		return args
+	}
+
+	if column, ok := indexedMetadata[f.path[0]]; ok && len(f.path) == 1 {
+		b.WriteString(prefix + column)
+	} else {
+		b.WriteString("json_extract(" + prefix + "metadata, ?)")
+		args = append(args, `$."`+strings.Join(f.path, `"."`)+`"`)
+	}
+
+	switch {
+	case f.value == nil && f.op == "=":
+		b.WriteString(" IS NULL")
+	case f.value == nil:
+		b.WriteString(" IS NOT NULL")
+	default:
+		b.WriteString(" " + f.op + " ?")
+		args = append(args, f.value)
+	}
+	return args
+}
+
+// Match reports whether metadata passes the filter. A nil filter passes
+// everything.
+func (f *MetadataFilter) Match(metadata map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+	switch f.op {
+	case "AND":
+		for _, operand := range f.operands {
+			if !operand.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	case "OR":
+		for _, operand := range f.operands {
+			if operand.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var field interface{} = metadata
+	for _, key := range f.path {
+		object, ok := field.(map[string]interface{})
+		if !ok {
+			field = nil
+			break
+		}
+		field = object[key]
+	}
+	field = sqlValue(field)
+
+	if f.value == nil {
+		return (field == nil) == (f.op == "=")
+	}
+	if field == nil {
+		return false
+	}
+	c := compareSQLValues(field, f.value)
+	switch f.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// sqlValue converts a JSON value to what json_extract returns for it: nil,
+// a float64 for numbers and booleans, or a string, which for arrays and
+// objects is their JSON text.
+func sqlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case bool:
+		if v {
+			return float64(1)
+		}
+		return float64(0)
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		n, _ := v.Float64()
+		return n
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// compareSQLValues orders two non-nil values returned by sqlValue, or by
+// filterValue, like SQLite: numbers before strings.
+func compareSQLValues(a, b interface{}) int {
+	a, b = sqlValue(a), sqlValue(b)
+	as, aText := a.(string)
+	bs, bText := b.(string)
+	switch {
+	case aText && bText:
+		return strings.Compare(as, bs)
+	case aText:
+		return 1
+	case bText:
+		return -1
+	}
+	an, bn := a.(float64), b.(float64)
+	switch {
+	case an < bn:
+		return -1
+	case an > bn:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+package collections
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetadataFilterRejects(t *testing.T) {
+	tooMany := strings.Repeat(`meta.a = 1 AND `, maxFilterConditions) + `meta.a = 1`
+	for _, s := range []string{
+		"",
+		`author = "Ada"`,
+		`metadata.author = "Ada"`,
+		`meta. = 1`,
+		`meta.a..b = 1`,
+		`meta.a"b = 1`,
+		`meta.a' = 1`,
+		`meta.a') OR 1=1 -- = 1`,
+		`meta.a;DROP = 1`,
+		`meta.a/**/ = 1`,
+		`meta.$.a = 1`,
+		`meta.a[0] = 1`,
+		`meta.a = Ada`,
+		`meta.a = 'Ada'`,
+		`meta.a = "Ada`,
+		`meta.a = 1; DROP TABLE data_points`,
+		`meta.a == 1`,
+		`meta.a ! 1`,
+		`meta.a LIKE "A%"`,
+		`meta.a > null`,
+		`meta.a <= null`,
+		`meta.a =`,
+		`(meta.a = 1`,
+		`meta.a = 1)`,
+		`meta.a = 1 AND`,
+		`meta.a = 1 OR OR meta.b = 2`,
+		`meta.a = 1 meta.b = 2`,
+		tooMany,
+	} {
+		if f, err := ParseMetadataFilter(s); err == nil {
+			t.Errorf("ParseMetadataFilter(%q) = %+v, want an error", s, f)
+		}
+	}
+}
+
+func TestMetadataFilterSQL(t *testing.T) {
+	tests := []struct {
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{`meta.author = "Ada"`, ` AND d.meta_author = ?`, []interface{}{"Ada"}},
+		{`meta.year >= 2020`, ` AND json_extract(d.metadata, ?) >= ?`, []interface{}{`$."year"`, int64(2020)}},
+		{`meta.score < -1.5e2`, ` AND json_extract(d.metadata, ?) < ?`, []interface{}{`$."score"`, -150.0}},
+		{`meta.draft = TRUE`, ` AND json_extract(d.metadata, ?) = ?`, []interface{}{`$."draft"`, int64(1)}},
+		{`meta.draft != false`, ` AND json_extract(d.metadata, ?) != ?`, []interface{}{`$."draft"`, int64(0)}},
+		{`meta.page.title = null`, ` AND json_extract(d.metadata, ?) IS NULL`, []interface{}{`$."page"."title"`}},
+		{`meta.language != null`, ` AND d.meta_language IS NOT NULL`, nil},
+		{`meta.author.name = "Ada"`, ` AND json_extract(d.metadata, ?) = ?`, []interface{}{`$."author"."name"`, "Ada"}},
+		{`meta.note = "it\"s OR 1=1"`, ` AND json_extract(d.metadata, ?) = ?`, []interface{}{`$."note"`, `it"s OR 1=1`}},
+		{`meta.a = 1 or meta.b = 2 AND (meta.c = 3 OR meta.d = "x")`,
+			` AND (json_extract(d.metadata, ?) = ? OR (json_extract(d.metadata, ?) = ? AND (json_extract(d.metadata, ?) = ? OR json_extract(d.metadata, ?) = ?)))`,
+			[]interface{}{`$."a"`, int64(1), `$."b"`, int64(2), `$."c"`, int64(3), `$."d"`, "x"}},
+	}
+	for _, tt := range tests {
+		f, err := ParseMetadataFilter(tt.filter)
+		if err != nil {
+			t.Errorf("ParseMetadataFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		sql, args := f.sql("d.", nil)
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s renders as\n%s %v\nwant\n%s %v", tt.filter, sql, args, tt.sql, tt.args)
+		}
+	}
+
+	var nilFilter *MetadataFilter
+	if sql, args := nilFilter.sql("", []interface{}{"id"}); sql != "" || len(args) != 1 {
+		t.Errorf("nil filter renders as %q %v, want nothing", sql, args)
+	}
+}
+
+func TestMetadataFilterMatch(t *testing.T) {
+	metadata := map[string]interface{}{
+		"author": "Ada",
+		"year":   float64(2021),
+		"draft":  true,
+		"empty":  nil,
+		"page":   map[string]interface{}{"title": "Notes"},
+		"tags":   []interface{}{"a"},
+	}
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`meta.author = "Ada"`, true},
+		{`meta.author = "ada"`, false},
+		{`meta.author > "Ab"`, true},
+		{`meta.year = 2021`, true},
+		{`meta.year = 2021.0`, true},
+		{`meta.year > 2020 AND meta.year < 2022`, true},
+		{`meta.year = "2021"`, false},
+		{`meta.year < "0"`, true},
+		{`meta.draft = true`, true},
+		{`meta.draft = 1`, true},
+		{`meta.draft = false`, false},
+		{`meta.draft > 0`, true},
+		{`meta.empty = null`, true},
+		{`meta.missing = null`, true},
+		{`meta.empty != null`, false},
+		{`meta.missing != null`, false},
+		{`meta.author != null`, true},
+		{`meta.missing != 1`, false},
+		{`meta.missing < 1`, false},
+		{`meta.page.title = "Notes"`, true},
+		{`meta.page.missing = null`, true},
+		{`meta.author.name = null`, true},
+		{`meta.tags = "[\"a\"]"`, true},
+		{`meta.author = "Bob" OR meta.draft = true`, true},
+		{`(meta.author = "Bob" OR meta.draft = true) AND meta.year < 2000`, false},
+	}
+	for _, tt := range tests {
+		f, err := ParseMetadataFilter(tt.filter)
+		if err != nil {
+			t.Errorf("ParseMetadataFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		if got := f.Match(metadata); got != tt.match {
+			t.Errorf("%s matches = %v, want %v", tt.filter, got, tt.match)
+		}
+	}
+
+	var nilFilter *MetadataFilter
+	if !nilFilter.Match(nil) {
+		t.Error("nil filter does not match")
+	}
+}
@@ -0,0 +1,53 @@
+package collections
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	// JobQueued jobs wait for a worker, either for their first attempt or
+	// for a retry at RunAt.
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	// JobSucceeded, JobFailed and JobCanceled are final.
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Final reports whether a job in status s will not run again.
+func (s JobStatus) Final() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// Job is an ingestion request processed in the background.
+type Job struct {
+	ID     string    `json:"id"`
+	Status JobStatus `json:"status"`
+	// Request is the ingestion request as submitted.
+	Request json.RawMessage `json:"request"`
+	// Result summarises what a succeeded job stored.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the error of the last failed attempt.
+	Error       string      `json:"error,omitempty"`
+	Attempts    int         `json:"attempts"`
+	MaxAttempts int         `json:"max_attempts"`
+	Progress    JobProgress `json:"progress"`
+	// RunAt is when a queued job becomes due.
+	RunAt      time.Time  `json:"run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobProgress counts the items of a job that have been processed, such as
+// the files of a directory import.
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
@@ -0,0 +1,2087 @@
+package collections
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in process memory. It is
+// intended for tests and throwaway vaults.
+type MemoryStore struct {
+	mu   *sync.RWMutex
+	data *memoryData
+	// inTx is set on the Store handed to a WithTx callback.
+	inTx bool
+}
+
+type memoryData struct {
+	collections map[string]Collection
+	tags        map[string]Tag
+	dataPoints  map[string]DataPoint
+	documents   map[string]Document
+	attachments map[string]Attachment
+	// embeddings is keyed by data point ID, then model.
+	embeddings map[string]map[string]Embedding
+	// searchSettings is keyed by collection ID.
+	searchSettings map[string]SearchSettings
+	// dedupeSettings is keyed by collection ID.
+	dedupeSettings map[string]DedupeSettings
+	jobs           map[string]Job
+	sources        map[string]Source
+	// sourceFetches is keyed by source ID and holds its fetches in order.
+	sourceFetches map[string][]SourceFetch
+	feeds         map[string]Feed
+	// feedEntries is keyed by feed ID and holds its entries in order.
+	feedEntries map[string][]FeedEntry
+	// redirects maps slugs given up by renames to the renamed record's ID.
+	redirects map[slugRedirect]string
+	// addedTags is keyed by data point ID and holds the IDs of the tags
+	// added to it besides the one it is filed under.
+	addedTags map[string]map[string]bool
+	// revisions is keyed by data point ID and holds its revisions in order.
+	revisions map[string][]Revision
+	// deletedCollections, deletedTags and deletedDataPoints hold the
+	// records in the trash, which are moved out of the maps above, and
+	// deletedAt the time each of them was deleted, by ID.
+	deletedCollections map[string]Collection
+	deletedTags        map[string]Tag
+	deletedDataPoints  map[string]DataPoint
+	deletedAt          map[string]time.Time
+}
+
+// slugRedirect is a former slug of a collection, or of a tag of the
+// collection scope.
+type slugRedirect struct {
+	kind, scope, slug string
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		collections:    make(map[string]Collection, len(d.collections)),
+		tags:           make(map[string]Tag, len(d.tags)),
+		dataPoints:     make(map[string]DataPoint, len(d.dataPoints)),
+		documents:      make(map[string]Document, len(d.documents)),
+		attachments:    make(map[string]Attachment, len(d.attachments)),
+		embeddings:     make(map[string]map[string]Embedding, len(d.embeddings)),
+		searchSettings: make(map[string]SearchSettings, len(d.searchSettings)),
+		dedupeSettings: make(map[string]DedupeSettings, len(d.dedupeSettings)),
+		jobs:           make(map[string]Job, len(d.jobs)),
+		sources:        make(map[string]Source, len(d.sources)),
+		sourceFetches:  make(map[string][]SourceFetch, len(d.sourceFetches)),
+		feeds:          make(map[string]Feed, len(d.feeds)),
+		feedEntries:    make(map[string][]FeedEntry, len(d.feedEntries)),
+		redirects:      make(map[slugRedirect]string, len(d.redirects)),
+		addedTags:      make(map[string]map[string]bool, len(d.addedTags)),
+		revisions:      make(map[string][]Revision, len(d.revisions)),
+
+		deletedCollections: make(map[string]Collection, len(d.deletedCollections)),
+		deletedTags:        make(map[string]Tag, len(d.deletedTags)),
+		deletedDataPoints:  make(map[string]DataPoint, len(d.deletedDataPoints)),
+		deletedAt:          make(map[string]time.Time, len(d.deletedAt)),
+	}
+	for k, v := range d.collections {
+		c.collections[k] = v
+	}
+	for k, v := range d.tags {
+		c.tags[k] = v
+	}
+	for k, v := range d.dataPoints {
+		c.dataPoints[k] = v
+	}
+	for k, v := range d.documents {
+		c.documents[k] = v
+	}
+	for k, v := range d.attachments {
+		c.attachments[k] = v
+	}
+	for k, v := range d.embeddings {
+		byModel := make(map[string]Embedding, len(v))
+		for model, e := range v {
+			byModel[model] = e
+		}
+		c.embeddings[k] = byModel
+	}
+	for k, v := range d.searchSettings {
+		c.searchSettings[k] = v
+	}
+	for k, v := range d.dedupeSettings {
+		c.dedupeSettings[k] = v
+	}
+	for k, v := range d.jobs {
+		c.jobs[k] = v
+	}
+	for k, v := range d.sources {
+		c.sources[k] = v
+	}
+	for k, v := range d.sourceFetches {
+		c.sourceFetches[k] = append([]SourceFetch(nil), v...)
+	}
+	for k, v := range d.feeds {
+		c.feeds[k] = v
+	}
+	for k, v := range d.feedEntries {
+		c.feedEntries[k] = append([]FeedEntry(nil), v...)
+	}
+	for k, v := range d.redirects {
+		c.redirects[k] = v
+	}
+	for k, v := range d.addedTags {
+		tagIDs := make(map[string]bool, len(v))
+		for tagID := range v {
+			tagIDs[tagID] = true
+		}
+		c.addedTags[k] = tagIDs
+	}
+	for k, v := range d.revisions {
+		c.revisions[k] = append([]Revision(nil), v...)
+	}
+	for k, v := range d.deletedCollections {
+		c.deletedCollections[k] = v
+	}
+	for k, v := range d.deletedTags {
+		c.deletedTags[k] = v
+	}
+	for k, v := range d.deletedDataPoints {
+		c.deletedDataPoints[k] = v
+	}
+	for k, v := range d.deletedAt {
+		c.deletedAt[k] = v
+	}
+	return c
+}
+
+// NewMemoryStore returns an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.RWMutex{},
+		data: &memoryData{
+			collections:    map[string]Collection{},
+			tags:           map[string]Tag{},
+			dataPoints:     map[string]DataPoint{},
+			documents:      map[string]Document{},
+			attachments:    map[string]Attachment{},
+			embeddings:     map[string]map[string]Embedding{},
+			searchSettings: map[string]SearchSettings{},
+			dedupeSettings: map[string]DedupeSettings{},
+			jobs:           map[string]Job{},
+			sources:        map[string]Source{},
+			sourceFetches:  map[string][]SourceFetch{},
+			feeds:          map[string]Feed{},
+			feedEntries:    map[string][]FeedEntry{},
+			redirects:      map[slugRedirect]string{},
+			addedTags:      map[string]map[string]bool{},
+			revisions:      map[string][]Revision{},
+
+			deletedCollections: map[string]Collection{},
+			deletedTags:        map[string]Tag{},
+			deletedDataPoints:  map[string]DataPoint{},
+			deletedAt:          map[string]time.Time{},
+		},
+	}
+}
+
+// WithTx implements Store. The callback works on a copy of the data which
+// replaces the original only if it succeeds; other callers block meanwhile.
+func (m *MemoryStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &MemoryStore{mu: &sync.RWMutex{}, data: m.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.data = tx.data
+	return nil
+}
+
+// CreateCollection implements Store.
+func (m *MemoryStore) CreateCollection(ctx context.Context, c *Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.ID = newID()
+	if m.collectionNamedLocked(c.Name, c.ID) != nil {
+		return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+	}
+	c.Slug = m.collectionSlugLocked(c.Name, c.ID)
+	c.CreatedAt = now()
+	c.UpdatedAt = c.CreatedAt
+	m.data.collections[c.ID] = *c
+	m.moveSlugLocked(slugKindCollection, "", c.ID, "", c.Slug)
+	return nil
+}
+
+// collectionNamedLocked returns the collection other than id named name,
+// ignoring case, or nil.
+func (m *MemoryStore) collectionNamedLocked(name, id string) *Collection {
+	for _, c := range m.data.collections {
+		if c.ID != id && strings.EqualFold(c.Name, name) {
+			return &c
+		}
+	}
+	return nil
+}
+
+// collectionSlugLocked returns an unused slug for the collection id named
+// name.
+func (m *MemoryStore) collectionSlugLocked(name, id string) string {
+	slug, _ := uniqueSlug(name, id, func(slug string) (bool, error) {
+		for _, c := range m.data.collections {
+			if c.ID != id && c.Slug == slug {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return slug
+}
+
+// moveSlugLocked records that the record id of kind gave up the slug from
+// for the slug to, dropping redirects of to. from is empty for new records.
+func (m *MemoryStore) moveSlugLocked(kind, scope, id, from, to string) {
+	delete(m.data.redirects, slugRedirect{kind, scope, to})
+	if from != "" && from != to {
+		m.data.redirects[slugRedirect{kind, scope, from}] = id
+	}
+}
+
+// GetCollection implements Store.
+func (m *MemoryStore) GetCollection(ctx context.Context, id string) (*Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.data.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %s: %w", id, ErrNotFound)
+	}
+	return &c, nil
+}
+
+// GetCollectionByName implements Store.
+func (m *MemoryStore) GetCollectionByName(ctx context.Context, name string) (*Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if c := m.collectionNamedLocked(name, ""); c != nil {
+		return c, nil
+	}
+	return nil, fmt.Errorf("collection %s: %w", name, ErrNotFound)
+}
+
+// ResolveCollection implements Store.
+func (m *MemoryStore) ResolveCollection(ctx context.Context, ref string) (*Collection, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byID := func(id string) (*Collection, error) {
+		if c, ok := m.data.collections[id]; ok {
+			return &c, nil
+		}
+		return nil, fmt.Errorf("collection %s: %w", ref, ErrNotFound)
+	}
+	return resolve(ref, byID,
+		func(name string) (*Collection, error) {
+			if c := m.collectionNamedLocked(name, ""); c != nil {
+				return c, nil
+			}
+			return nil, fmt.Errorf("collection %s: %w", ref, ErrNotFound)
+		},
+		func(slug string) (*Collection, error) {
+			for _, c := range m.data.collections {
+				if c.Slug == slug {
+					return &c, nil
+				}
+			}
+			return nil, fmt.Errorf("collection %s: %w", ref, ErrNotFound)
+		},
+		func(slug string) (*Collection, error) {
+			return byID(m.data.redirects[slugRedirect{slugKindCollection, "", slug}])
+		})
+}
+
+// ListCollections implements Store.
+func (m *MemoryStore) ListCollections(ctx context.Context, page Page) ([]Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := sortedCollections(m.data.collections)
+	start, end := window(len(collections), page, func(i int) bool { return collections[i].ID > page.After })
+	return collections[start:end], nil
+}
+
+// ListCollectionSummaries implements Store.
+func (m *MemoryStore) ListCollectionSummaries(ctx context.Context, order CollectionOrder, desc bool, page Page) ([]CollectionSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// after is the collection the page continues from, with only the ID and
+	// the field sorted by set.
+	after := Collection{ID: page.After}
+	var compare func(a, b Collection) int
+	switch order {
+	case OrderByName:
+		compare = func(a, b Collection) int { return strings.Compare(a.Name, b.Name) }
+		after.Name = page.AfterValue
+	case OrderByCreated:
+		compare = func(a, b Collection) int { return a.CreatedAt.Compare(b.CreatedAt) }
+	case OrderByUpdated:
+		compare = func(a, b Collection) int { return a.UpdatedAt.Compare(b.UpdatedAt) }
+	default:
+		return nil, fmt.Errorf("unknown collection order %q", order)
+	}
+	if page.After != "" && order != OrderByName {
+		t, err := page.afterTime()
+		if err != nil {
+			return nil, err
+		}
+		after.CreatedAt, after.UpdatedAt = t, t
+	}
+	// position orders a before b in the listing if it is negative.
+	position := func(a, b Collection) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	}
+
+	summaries := make([]CollectionSummary, 0, len(m.data.collections))
+	for _, c := range m.data.collections {
+		summaries = append(summaries, m.summaryLocked(c))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return position(summaries[i].Collection, summaries[j].Collection) < 0 })
+	start, end := window(len(summaries), page, func(i int) bool { return position(summaries[i].Collection, after) > 0 })
+	return summaries[start:end], nil
+}
+
+// GetCollectionSummary implements Store.
+func (m *MemoryStore) GetCollectionSummary(ctx context.Context, id string) (*CollectionSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.data.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %s: %w", id, ErrNotFound)
+	}
+	summary := m.summaryLocked(c)
+	return &summary, nil
+}
+
+// summaryLocked counts the tags and data points of c.
+func (m *MemoryStore) summaryLocked(c Collection) CollectionSummary {
+	summary := CollectionSummary{Collection: c}
+	for _, t := range m.data.tags {
+		if t.CollectionID == c.ID {
+			summary.TagCount++
+		}
+	}
+	for _, dp := range m.data.dataPoints {
+		if m.data.tags[dp.TagID].CollectionID == c.ID {
+			summary.DataPointCount++
+		}
+	}
+	return summary
+}
+
+// UpdateCollection implements Store.
+func (m *MemoryStore) UpdateCollection(ctx context.Context, c *Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.data.collections[c.ID]
+	if !ok {
+		return fmt.Errorf("collection %s: %w", c.ID, ErrNotFound)
+	}
+	if m.collectionNamedLocked(c.Name, c.ID) != nil {
+		return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+	}
+	formerSlug := stored.Slug
+	if Slugify(c.Name) != Slugify(stored.Name) {
+		stored.Slug = m.collectionSlugLocked(c.Name, c.ID)
+	}
+	stored.Name = c.Name
+	stored.UpdatedAt = now()
+	m.data.collections[c.ID] = stored
+	m.moveSlugLocked(slugKindCollection, "", c.ID, formerSlug, stored.Slug)
+	*c = stored
+	return nil
+}
+
+// DeleteCollection implements Store.
+func (m *MemoryStore) DeleteCollection(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.data.collections[id]
+	if !ok {
+		return fmt.Errorf("collection %s: %w", id, ErrNotFound)
+	}
+	deletedAt := now()
+	for tagID, t := range m.data.tags {
+		if t.CollectionID == id {
+			m.trashTagLocked(tagID, deletedAt)
+		}
+	}
+	delete(m.data.collections, id)
+	m.data.deletedCollections[id] = c
+	m.data.deletedAt[id] = deletedAt
+	return nil
+}
+
+// CreateTag implements Store. Missing ancestors are created in a
+// transaction so that they are rolled back if t cannot be stored.
+func (m *MemoryStore) CreateTag(ctx context.Context, t *Tag) error {
+	return m.WithTx(ctx, func(tx Store) error {
+		m := tx.(*MemoryStore)
+		if _, err := m.GetCollection(ctx, t.CollectionID); err != nil {
+			return fmt.Errorf("failed to create tag: %w", err)
+		}
+		parent, name, err := tagParent(ctx, m, t.CollectionID, t.Name)
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		t.ID = newID()
+		t.Name = name
+		t.ParentID = ""
+		if parent != nil {
+			t.ParentID = parent.ID
+		}
+		if m.tagNamedLocked(t.CollectionID, t.Name, t.ID) != nil {
+			return fmt.Errorf("tag %s: %w", t.Name, ErrConflict)
+		}
+		t.Slug = m.tagSlugLocked(t.CollectionID, t.Name, t.ID)
+		t.CreatedAt = now()
+		t.UpdatedAt = t.CreatedAt
+		m.data.tags[t.ID] = *t
+		m.moveSlugLocked(slugKindTag, t.CollectionID, t.ID, "", t.Slug)
+		return nil
+	})
+}
+
+// tagNamedLocked returns the tag of a collection other than id named name,
+// ignoring case, or nil.
+func (m *MemoryStore) tagNamedLocked(collectionID, name, id string) *Tag {
+	for _, t := range m.data.tags {
+		if t.CollectionID == collectionID && t.ID != id && strings.EqualFold(t.Name, name) {
+			return &t
+		}
+	}
+	return nil
+}
+
+// tagSlugLocked returns an unused slug for the tag id of a collection
+// named name.
+func (m *MemoryStore) tagSlugLocked(collectionID, name, id string) string {
+	slug, _ := uniqueSlug(name, id, func(slug string) (bool, error) {
+		for _, t := range m.data.tags {
+			if t.CollectionID == collectionID && t.ID != id && t.Slug == slug {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return slug
+}
+
+// GetTag implements Store.
+func (m *MemoryStore) GetTag(ctx context.Context, id string) (*Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.data.tags[id]
+	if !ok {
+		return nil, fmt.Errorf("tag %s: %w", id, ErrNotFound)
+	}
+	return &t, nil
+}
+
+// GetTagByName implements Store.
+func (m *MemoryStore) GetTagByName(ctx context.Context, collectionID, name string) (*Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t := m.tagNamedLocked(collectionID, name, ""); t != nil {
+		return t, nil
+	}
+	return nil, fmt.Errorf("tag %s: %w", name, ErrNotFound)
+}
+
+// ResolveTag implements Store.
+func (m *MemoryStore) ResolveTag(ctx context.Context, collectionID, ref string) (*Tag, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byID := func(id string) (*Tag, error) {
+		if t, ok := m.data.tags[id]; ok && t.CollectionID == collectionID {
+			return &t, nil
+		}
+		return nil, fmt.Errorf("tag %s: %w", ref, ErrNotFound)
+	}
+	return resolve(ref, byID,
+		func(name string) (*Tag, error) {
+			if t := m.tagNamedLocked(collectionID, name, ""); t != nil {
+				return t, nil
+			}
+			return nil, fmt.Errorf("tag %s: %w", ref, ErrNotFound)
+		},
+		func(slug string) (*Tag, error) {
+			for _, t := range m.data.tags {
+				if t.CollectionID == collectionID && t.Slug == slug {
+					return &t, nil
+				}
+			}
+			return nil, fmt.Errorf("tag %s: %w", ref, ErrNotFound)
+		},
+		func(slug string) (*Tag, error) {
+			return byID(m.data.redirects[slugRedirect{slugKindTag, collectionID, slug}])
+		})
+}
+
+// ListTags implements Store.
+func (m *MemoryStore) ListTags(ctx context.Context, collectionID string, page Page) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := sortedTags(m.data.tags, collectionID)
+	start, end := window(len(tags), page, func(i int) bool { return tags[i].ID > page.After })
+	return tags[start:end], nil
+}
+
+// UpdateTag implements Store. It works in a transaction like CreateTag.
+func (m *MemoryStore) UpdateTag(ctx context.Context, t *Tag) error {
+	return m.WithTx(ctx, func(tx Store) error {
+		m := tx.(*MemoryStore)
+		subtree, err := m.ListTagSubtree(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		stored := subtree[0]
+		if isBelow(t.Name, stored.Name) {
+			return fmt.Errorf("failed to move tag %s to %s: %w", stored.Name, t.Name, ErrCycle)
+		}
+		parent, name, err := tagParent(ctx, m, stored.CollectionID, t.Name)
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// Descendants keep their place below the tag.
+		for _, d := range subtree {
+			renamed := d.Name
+			d.Name = name + d.Name[len(stored.Name):]
+			if d.ID == stored.ID {
+				d.ParentID = ""
+				if parent != nil {
+					d.ParentID = parent.ID
+				}
+			}
+			if m.tagNamedLocked(d.CollectionID, d.Name, d.ID) != nil {
+				return fmt.Errorf("tag %s: %w", d.Name, ErrConflict)
+			}
+			formerSlug := d.Slug
+			if Slugify(d.Name) != Slugify(renamed) {
+				d.Slug = m.tagSlugLocked(d.CollectionID, d.Name, d.ID)
+			}
+			d.UpdatedAt = now()
+			m.data.tags[d.ID] = d
+			m.moveSlugLocked(slugKindTag, d.CollectionID, d.ID, formerSlug, d.Slug)
+		}
+		*t = m.data.tags[t.ID]
+		return nil
+	})
+}
+
+// DeleteTag implements Store.
+func (m *MemoryStore) DeleteTag(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subtree := m.subtreeLocked(id)
+	if len(subtree) == 0 {
+		return fmt.Errorf("tag %s: %w", id, ErrNotFound)
+	}
+	deletedAt := now()
+	for _, t := range subtree {
+		m.trashTagLocked(t.ID, deletedAt)
+	}
+	return nil
+}
+
+// ListChildTags implements Store.
+func (m *MemoryStore) ListChildTags(ctx context.Context, collectionID, parentID string, page Page) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := []Tag{}
+	for _, t := range sortedTags(m.data.tags, collectionID) {
+		if t.ParentID == parentID {
+			tags = append(tags, t)
+		}
+	}
+	start, end := window(len(tags), page, func(i int) bool { return tags[i].ID > page.After })
+	return tags[start:end], nil
+}
+
+// ListTagSubtree implements Store.
+func (m *MemoryStore) ListTagSubtree(ctx context.Context, id string) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subtree := m.subtreeLocked(id)
+	if len(subtree) == 0 {
+		return nil, fmt.Errorf("tag %s: %w", id, ErrNotFound)
+	}
+	return subtree, nil
+}
+
+// subtreeLocked returns the tag id and its descendants ordered by name, or
+// nothing if there is no such tag.
+func (m *MemoryStore) subtreeLocked(id string) []Tag {
+	t, ok := m.data.tags[id]
+	if !ok {
+		return nil
+	}
+	subtree := []Tag{t}
+	for i := 0; i < len(subtree); i++ {
+		for _, child := range m.data.tags {
+			if child.ParentID == subtree[i].ID {
+				subtree = append(subtree, child)
+			}
+		}
+	}
+	sort.Slice(subtree, func(i, j int) bool { return subtree[i].Name < subtree[j].Name })
+	return subtree
+}
+
+// trashTagLocked moves the tag id and the data points filed under it to the
+// trash.
+func (m *MemoryStore) trashTagLocked(id string, deletedAt time.Time) {
+	for dpID, dp := range m.data.dataPoints {
+		if dp.TagID == id {
+			m.trashDataPointLocked(dpID, deletedAt)
+		}
+	}
+	m.data.deletedTags[id] = m.data.tags[id]
+	m.data.deletedAt[id] = deletedAt
+	delete(m.data.tags, id)
+}
+
+// trashDataPointLocked moves the data point id to the trash.
+func (m *MemoryStore) trashDataPointLocked(id string, deletedAt time.Time) {
+	m.data.deletedDataPoints[id] = m.data.dataPoints[id]
+	m.data.deletedAt[id] = deletedAt
+	delete(m.data.dataPoints, id)
+}
+
+// CreateDataPoint implements Store.
+func (m *MemoryStore) CreateDataPoint(ctx context.Context, dp *DataPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.tags[dp.TagID]; !ok {
+		return fmt.Errorf("failed to create data point: tag %s: %w", dp.TagID, ErrNotFound)
+	}
+	if dp.ID == "" {
+		dp.ID = newID()
+	} else if _, ok := m.data.deletedAt[dp.ID]; ok {
+		return fmt.Errorf("data point %s: %w", dp.ID, ErrConflict)
+	} else if _, ok := m.data.dataPoints[dp.ID]; ok {
+		return fmt.Errorf("data point %s: %w", dp.ID, ErrConflict)
+	}
+	dp.ContentHash = ContentHash(dp.Value)
+	if err := m.checkUniqueLocked(dp); err != nil {
+		return err
+	}
+	dp.CreatedAt = now()
+	dp.UpdatedAt = dp.CreatedAt
+	stored := *dp
+	if dp.Chunk != nil {
+		chunk := *dp.Chunk
+		stored.Chunk = &chunk
+	}
+	stored.Metadata = copyMetadata(dp.Metadata)
+	m.data.dataPoints[dp.ID] = stored
+	m.addRevisionLocked(revisionOf(ctx, &stored, nil, 0))
+	return nil
+}
+
+// GetDataPoint implements Store.
+func (m *MemoryStore) GetDataPoint(ctx context.Context, id string) (*DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dp, ok := m.data.dataPoints[id]
+	if !ok {
+		return nil, fmt.Errorf("data point %s: %w", id, ErrNotFound)
+	}
+	dp.Metadata = copyMetadata(dp.Metadata)
+	return &dp, nil
+}
+
+// ListDataPoints implements Store.
+func (m *MemoryStore) ListDataPoints(ctx context.Context, tagID string, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dataPoints := m.filterDataPoints(filter, func(dp DataPoint) bool { return m.carriesLocked(dp, tagID) })
+	start, end := window(len(dataPoints), page, func(i int) bool { return dataPoints[i].ID > page.After })
+	return dataPoints[start:end], nil
+}
+
+// ListDataPointsByTags implements Store.
+func (m *MemoryStore) ListDataPointsByTags(ctx context.Context, tagIDs []string, all bool, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tagIDs = distinct(tagIDs)
+	dataPoints := m.filterDataPoints(filter, func(dp DataPoint) bool {
+		n := 0
+		for _, tagID := range tagIDs {
+			if m.carriesLocked(dp, tagID) {
+				n++
+			}
+		}
+		return n > 0 && (!all || n == len(tagIDs))
+	})
+	start, end := window(len(dataPoints), page, func(i int) bool { return dataPoints[i].ID > page.After })
+	return dataPoints[start:end], nil
+}
+
+// carriesLocked reports whether dp is filed under the tag tagID or has it
+// added.
+func (m *MemoryStore) carriesLocked(dp DataPoint, tagID string) bool {
+	return dp.TagID == tagID || m.data.addedTags[dp.ID][tagID]
+}
+
+// ListCollectionDataPoints implements Store.
+func (m *MemoryStore) ListCollectionDataPoints(ctx context.Context, collectionID string, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dataPoints := m.filterDataPoints(filter, func(dp DataPoint) bool {
+		return m.data.tags[dp.TagID].CollectionID == collectionID
+	})
+	start, end := window(len(dataPoints), page, func(i int) bool { return dataPoints[i].ID > page.After })
+	return dataPoints[start:end], nil
+}
+
+// UpdateDataPoint implements Store.
+func (m *MemoryStore) UpdateDataPoint(ctx context.Context, dp *DataPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateDataPointLocked(ctx, dp, 0)
+}
+
+// updateDataPointLocked stores dp and records a revision restored from the
+// revision restoredFrom, if not 0.
+func (m *MemoryStore) updateDataPointLocked(ctx context.Context, dp *DataPoint, restoredFrom int) error {
+	stored, ok := m.data.dataPoints[dp.ID]
+	if !ok {
+		return fmt.Errorf("data point %s: %w", dp.ID, ErrNotFound)
+	}
+	if _, ok := m.data.tags[dp.TagID]; !ok {
+		return fmt.Errorf("failed to update data point: tag %s: %w", dp.TagID, ErrNotFound)
+	}
+	dp.ContentHash = ContentHash(dp.Value)
+	if err := m.checkUniqueLocked(dp); err != nil {
+		return err
+	}
+	if stored.TagID != dp.TagID {
+		// Like the data_point_tags triggers of SQLStore, the new tag
+		// replaces the old one rather than being added.
+		delete(m.data.addedTags[dp.ID], stored.TagID)
+		delete(m.data.addedTags[dp.ID], dp.TagID)
+	}
+	stored.TagID = dp.TagID
+	stored.Value = dp.Value
+	stored.Metadata = copyMetadata(dp.Metadata)
+	stored.ContentHash = dp.ContentHash
+	stored.UpdatedAt = now()
+	m.data.dataPoints[dp.ID] = stored
+	*dp = stored
+	dp.Metadata = copyMetadata(stored.Metadata)
+
+	var previous *Revision
+	if revisions := m.data.revisions[dp.ID]; len(revisions) > 0 {
+		previous = &revisions[len(revisions)-1]
+	}
+	m.addRevisionLocked(revisionOf(ctx, &stored, previous, restoredFrom))
+	return nil
+}
+
+// checkUniqueLocked returns a *DuplicateError if the collection of the tag
+// of dp keeps content unique and another data point has the content of dp.
+func (m *MemoryStore) checkUniqueLocked(dp *DataPoint) error {
+	collectionID := m.data.tags[dp.TagID].CollectionID
+	if !m.data.dedupeSettings[collectionID].Unique {
+		return nil
+	}
+	if duplicate := m.findDuplicateLocked(collectionID, dp.ContentHash, dp.ID); duplicate != nil {
+		return &DuplicateError{DataPointID: duplicate.ID}
+	}
+	return nil
+}
+
+// findDuplicateLocked returns the oldest data point of a collection with
+// content hash hash other than the one with ID except, or nil.
+func (m *MemoryStore) findDuplicateLocked(collectionID, hash, except string) *DataPoint {
+	var found *DataPoint
+	for _, dp := range m.data.dataPoints {
+		if dp.ContentHash == hash && dp.ID != except && m.data.tags[dp.TagID].CollectionID == collectionID && (found == nil || dp.ID < found.ID) {
+			dp := dp
+			found = &dp
+		}
+	}
+	return found
+}
+
+// FindDuplicate implements Store.
+func (m *MemoryStore) FindDuplicate(ctx context.Context, collectionID, hash string) (*DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dp := m.findDuplicateLocked(collectionID, hash, "")
+	if dp == nil {
+		return nil, fmt.Errorf("data point with content %s: %w", hash, ErrNotFound)
+	}
+	dp.Metadata = copyMetadata(dp.Metadata)
+	return dp, nil
+}
+
+// ListDuplicates implements Store.
+func (m *MemoryStore) ListDuplicates(ctx context.Context, collectionID string, page Page) ([]DuplicateGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byHash := map[string][]string{}
+	for _, dp := range m.data.dataPoints {
+		if dp.ContentHash != "" && m.data.tags[dp.TagID].CollectionID == collectionID {
+			byHash[dp.ContentHash] = append(byHash[dp.ContentHash], dp.ID)
+		}
+	}
+	groups := []DuplicateGroup{}
+	for hash, ids := range byHash {
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			groups = append(groups, DuplicateGroup{ContentHash: hash, DataPointIDs: ids})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ContentHash < groups[j].ContentHash })
+	start, end := window(len(groups), page, func(i int) bool { return groups[i].ContentHash > page.After })
+	return groups[start:end], nil
+}
+
+// HashDataPoints implements Store.
+func (m *MemoryStore) HashDataPoints(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, records := range []map[string]DataPoint{m.data.dataPoints, m.data.deletedDataPoints} {
+		for id, dp := range records {
+			if dp.ContentHash == "" {
+				dp.ContentHash = ContentHash(dp.Value)
+				records[id] = dp
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// addRevisionLocked stores r, which may be nil.
+func (m *MemoryStore) addRevisionLocked(r *Revision) {
+	if r != nil {
+		m.data.revisions[r.DataPointID] = append(m.data.revisions[r.DataPointID], *r)
+	}
+}
+
+// ListRevisions implements Store.
+func (m *MemoryStore) ListRevisions(ctx context.Context, dataPointID string, page Page) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.data.dataPoints[dataPointID]; !ok {
+		return nil, fmt.Errorf("data point %s: %w", dataPointID, ErrNotFound)
+	}
+	stored := m.data.revisions[dataPointID]
+	revisions := make([]Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, stored[i])
+	}
+	start, end := window(len(revisions), page, func(i int) bool { return revisions[i].ID < page.After })
+	return revisions[start:end], nil
+}
+
+// GetRevision implements Store.
+func (m *MemoryStore) GetRevision(ctx context.Context, dataPointID string, number int) (*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.data.revisions[dataPointID] {
+		if r.Number == number {
+			r.Metadata = copyMetadata(r.Metadata)
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %s/%d: %w", dataPointID, number, ErrNotFound)
+}
+
+// RestoreRevision implements Store.
+func (m *MemoryStore) RestoreRevision(ctx context.Context, dataPointID string, number int) (*DataPoint, error) {
+	var dp *DataPoint
+	err := m.WithTx(ctx, func(tx Store) error {
+		m := tx.(*MemoryStore)
+
+		r, err := m.GetRevision(ctx, dataPointID, number)
+		if err != nil {
+			return err
+		}
+		if dp, err = m.GetDataPoint(ctx, dataPointID); err != nil {
+			return err
+		}
+		dp.Value, dp.Metadata = r.Value, copyMetadata(r.Metadata)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.updateDataPointLocked(ctx, dp, number)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
+// DeleteDataPoint implements Store.
+func (m *MemoryStore) DeleteDataPoint(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.dataPoints[id]; !ok {
+		return fmt.Errorf("data point %s: %w", id, ErrNotFound)
+	}
+	m.trashDataPointLocked(id, now())
+	return nil
+}
+
+// ListTrash implements Store.
+func (m *MemoryStore) ListTrash(ctx context.Context, kind TrashKind, collectionID string, page Page) ([]TrashItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// deletedWith reports whether the record id was deleted at deletedAt,
+	// along with a record below it.
+	deletedWith := func(id string, deletedAt time.Time) bool {
+		at, ok := m.data.deletedAt[id]
+		return ok && at.Equal(deletedAt)
+	}
+	items := []TrashItem{}
+	for id, c := range m.data.deletedCollections {
+		items = append(items, TrashItem{Kind: TrashCollection, ID: id, CollectionID: id, Name: c.Name, DeletedAt: m.data.deletedAt[id]})
+	}
+	for id, t := range m.data.deletedTags {
+		deletedAt := m.data.deletedAt[id]
+		if deletedWith(t.CollectionID, deletedAt) || (t.ParentID != "" && deletedWith(t.ParentID, deletedAt)) {
+			continue
+		}
+		items = append(items, TrashItem{Kind: TrashTag, ID: id, CollectionID: t.CollectionID, Name: t.Name, DeletedAt: deletedAt})
+	}
+	for id, dp := range m.data.deletedDataPoints {
+		deletedAt := m.data.deletedAt[id]
+		if deletedWith(dp.TagID, deletedAt) {
+			continue
+		}
+		t, ok := m.data.tags[dp.TagID]
+		if !ok {
+			t = m.data.deletedTags[dp.TagID]
+		}
+		items = append(items, TrashItem{
+			Kind:         TrashDataPoint,
+			ID:           id,
+			CollectionID: t.CollectionID,
+			TagID:        dp.TagID,
+			Name:         trashExcerpt(dp.Value),
+			DeletedAt:    deletedAt,
+		})
+	}
+
+	selected := items[:0]
+	for _, item := range items {
+		if (kind == "" || item.Kind == kind) && (collectionID == "" || item.CollectionID == collectionID) {
+			selected = append(selected, item)
+		}
+	}
+	items = selected
+	// before orders a before b in the listing.
+	before := func(a, b TrashItem) bool {
+		if !a.DeletedAt.Equal(b.DeletedAt) {
+			return a.DeletedAt.After(b.DeletedAt)
+		}
+		return a.ID > b.ID
+	}
+	sort.Slice(items, func(i, j int) bool { return before(items[i], items[j]) })
+
+	after := TrashItem{ID: page.After}
+	if page.After != "" {
+		t, err := page.afterTime()
+		if err != nil {
+			return nil, err
+		}
+		after.DeletedAt = t
+	}
+	start, end := window(len(items), page, func(i int) bool { return before(after, items[i]) })
+	return items[start:end], nil
+}
+
+// RestoreCollection implements Store. It works in a transaction so that
+// a duplicate among its data points leaves it in the trash.
+func (m *MemoryStore) RestoreCollection(ctx context.Context, id string) (*Collection, error) {
+	var c Collection
+	err := m.WithTx(ctx, func(tx Store) error {
+		m := tx.(*MemoryStore)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		var ok bool
+		c, ok = m.data.deletedCollections[id]
+		if !ok {
+			return fmt.Errorf("collection %s: %w", id, ErrNotFound)
+		}
+		if m.collectionNamedLocked(c.Name, c.ID) != nil {
+			return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+		}
+		for _, other := range m.data.collections {
+			if other.Slug == c.Slug {
+				c.Slug = m.collectionSlugLocked(c.Name, c.ID)
+				break
+			}
+		}
+
+		deletedAt := m.data.deletedAt[id]
+		var dataPointIDs []string
+		for dpID, dp := range m.data.deletedDataPoints {
+			if m.data.deletedTags[dp.TagID].CollectionID == id && m.data.deletedAt[dpID].Equal(deletedAt) {
+				dataPointIDs = append(dataPointIDs, dpID)
+			}
+		}
+		for tagID, t := range m.data.deletedTags {
+			if t.CollectionID == id && m.data.deletedAt[tagID].Equal(deletedAt) {
+				m.data.tags[tagID] = t
+				delete(m.data.deletedTags, tagID)
+				delete(m.data.deletedAt, tagID)
+			}
+		}
+		if err := m.restoreDataPointsLocked(dataPointIDs); err != nil {
+			return err
+		}
+		m.data.collections[id] = c
+		delete(m.data.deletedCollections, id)
+		delete(m.data.deletedAt, id)
+		m.moveSlugLocked(slugKindCollection, "", c.ID, "", c.Slug)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// RestoreTag implements Store. It works in a transaction so that a name
+// clash within the subtree leaves it in the trash.
+func (m *MemoryStore) RestoreTag(ctx context.Context, id string) (*Tag, error) {
+	var restored Tag
+	err := m.WithTx(ctx, func(tx Store) error {
+		m := tx.(*MemoryStore)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		t, ok := m.data.deletedTags[id]
+		if !ok {
+			return fmt.Errorf("tag %s: %w", id, ErrNotFound)
+		}
+		_, collectionDeleted := m.data.deletedCollections[t.CollectionID]
+		_, parentDeleted := m.data.deletedTags[t.ParentID]
+		if collectionDeleted || parentDeleted {
+			return fmt.Errorf("failed to restore tag %s: %w", t.Name, ErrParentDeleted)
+		}
+
+		deletedAt := m.data.deletedAt[id]
+		subtree := []Tag{t}
+		for i := 0; i < len(subtree); i++ {
+			for childID, child := range m.data.deletedTags {
+				if child.ParentID == subtree[i].ID && m.data.deletedAt[childID].Equal(deletedAt) {
+					subtree = append(subtree, child)
+				}
+			}
+		}
+		sort.Slice(subtree, func(i, j int) bool { return subtree[i].Name < subtree[j].Name })
+
+		var dataPointIDs []string
+		for dpID, dp := range m.data.deletedDataPoints {
+			if m.data.deletedAt[dpID].Equal(deletedAt) {
+				for _, d := range subtree {
+					if dp.TagID == d.ID {
+						dataPointIDs = append(dataPointIDs, dpID)
+					}
+				}
+			}
+		}
+		for _, d := range subtree {
+			if m.tagNamedLocked(d.CollectionID, d.Name, d.ID) != nil {
+				return fmt.Errorf("tag %s: %w", d.Name, ErrConflict)
+			}
+			for _, other := range m.data.tags {
+				if other.CollectionID == d.CollectionID && other.Slug == d.Slug {
+					d.Slug = m.tagSlugLocked(d.CollectionID, d.Name, d.ID)
+					break
+				}
+			}
+			m.data.tags[d.ID] = d
+			delete(m.data.deletedTags, d.ID)
+			delete(m.data.deletedAt, d.ID)
+			m.moveSlugLocked(slugKindTag, d.CollectionID, d.ID, "", d.Slug)
+		}
+		if err := m.restoreDataPointsLocked(dataPointIDs); err != nil {
+			return err
+		}
+		restored = m.data.tags[id]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &restored, nil
+}
+
+// RestoreDataPoint implements Store.
+func (m *MemoryStore) RestoreDataPoint(ctx context.Context, id string) (*DataPoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dp, ok := m.data.deletedDataPoints[id]
+	if !ok {
+		return nil, fmt.Errorf("data point %s: %w", id, ErrNotFound)
+	}
+	if _, ok := m.data.tags[dp.TagID]; !ok {
+		return nil, fmt.Errorf("failed to restore data point %s: %w", id, ErrParentDeleted)
+	}
+	if err := m.restoreDataPointsLocked([]string{id}); err != nil {
+		return nil, err
+	}
+	dp.Metadata = copyMetadata(dp.Metadata)
+	return &dp, nil
+}
+
+// restoreDataPointsLocked moves the data points ids out of the trash in
+// order of their IDs, stopping at the first one checkUniqueLocked rejects.
+// The tags they are filed under must be restored already.
+func (m *MemoryStore) restoreDataPointsLocked(ids []string) error {
+	sort.Strings(ids)
+	for _, id := range ids {
+		dp := m.data.deletedDataPoints[id]
+		if err := m.checkUniqueLocked(&dp); err != nil {
+			return err
+		}
+		m.data.dataPoints[id] = dp
+		delete(m.data.deletedDataPoints, id)
+		delete(m.data.deletedAt, id)
+	}
+	return nil
+}
+
+// PurgeTrash implements Store.
+func (m *MemoryStore) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := func(id string) bool {
+		deletedAt, ok := m.data.deletedAt[id]
+		return ok && deletedAt.Before(before)
+	}
+	collections := map[string]bool{}
+	for id := range m.data.deletedCollections {
+		if purged(id) {
+			collections[id] = true
+		}
+	}
+	tags := map[string]bool{}
+	for _, all := range []map[string]Tag{m.data.tags, m.data.deletedTags} {
+		for id, t := range all {
+			if purged(id) || collections[t.CollectionID] {
+				tags[id] = true
+			}
+		}
+	}
+	ids := []string{}
+	for _, all := range []map[string]DataPoint{m.data.dataPoints, m.data.deletedDataPoints} {
+		for id, dp := range all {
+			if purged(id) || tags[dp.TagID] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		delete(m.data.dataPoints, id)
+		delete(m.data.deletedDataPoints, id)
+		delete(m.data.deletedAt, id)
+		delete(m.data.embeddings, id)
+		delete(m.data.addedTags, id)
+		delete(m.data.revisions, id)
+	}
+	for id := range tags {
+		delete(m.data.tags, id)
+		delete(m.data.deletedTags, id)
+		delete(m.data.deletedAt, id)
+		for _, tagIDs := range m.data.addedTags {
+			delete(tagIDs, id)
+		}
+	}
+	for id, src := range m.data.sources {
+		if tags[src.TagID] {
+			delete(m.data.sources, id)
+			delete(m.data.sourceFetches, id)
+		}
+	}
+	for id, f := range m.data.feeds {
+		if tags[f.TagID] {
+			delete(m.data.feeds, id)
+			delete(m.data.feedEntries, id)
+		}
+	}
+	for id := range collections {
+		delete(m.data.deletedCollections, id)
+		delete(m.data.deletedAt, id)
+		delete(m.data.searchSettings, id)
+		delete(m.data.dedupeSettings, id)
+	}
+	for docID, d := range m.data.documents {
+		if collections[d.CollectionID] {
+			delete(m.data.documents, docID)
+		}
+	}
+	for attachmentID, a := range m.data.attachments {
+		if _, ok := m.data.documents[a.DocumentID]; !ok {
+			delete(m.data.attachments, attachmentID)
+		}
+	}
+	for r, target := range m.data.redirects {
+		if tags[target] || collections[target] || (r.kind == slugKindTag && collections[r.scope]) {
+			delete(m.data.redirects, r)
+		}
+	}
+	return ids, nil
+}
+
+// ListDataPointTags implements Store.
+func (m *MemoryStore) ListDataPointTags(ctx context.Context, dataPointID string) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dp, ok := m.data.dataPoints[dataPointID]
+	if !ok {
+		return nil, fmt.Errorf("data point %s: %w", dataPointID, ErrNotFound)
+	}
+	tags := []Tag{}
+	for _, t := range sortedTags(m.data.tags, m.data.tags[dp.TagID].CollectionID) {
+		if m.carriesLocked(dp, t.ID) {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+// AddDataPointTag implements Store.
+func (m *MemoryStore) AddDataPointTag(ctx context.Context, dataPointID, tagID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dp, ok := m.data.dataPoints[dataPointID]
+	if !ok {
+		return fmt.Errorf("data point %s: %w", dataPointID, ErrNotFound)
+	}
+	t, ok := m.data.tags[tagID]
+	if !ok || t.CollectionID != m.data.tags[dp.TagID].CollectionID {
+		return fmt.Errorf("tag %s: %w", tagID, ErrNotFound)
+	}
+	if m.carriesLocked(dp, tagID) {
+		return nil
+	}
+	if m.data.addedTags[dataPointID] == nil {
+		m.data.addedTags[dataPointID] = map[string]bool{}
+	}
+	m.data.addedTags[dataPointID][tagID] = true
+	return nil
+}
+
+// RemoveDataPointTag implements Store.
+func (m *MemoryStore) RemoveDataPointTag(ctx context.Context, dataPointID, tagID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dp, ok := m.data.dataPoints[dataPointID]
+	if !ok {
+		return fmt.Errorf("data point %s: %w", dataPointID, ErrNotFound)
+	}
+	if dp.TagID == tagID {
+		return fmt.Errorf("data point %s is filed under tag %s: %w", dataPointID, tagID, ErrConflict)
+	}
+	if !m.data.addedTags[dataPointID][tagID] {
+		return fmt.Errorf("tag %s: %w", tagID, ErrNotFound)
+	}
+	delete(m.data.addedTags[dataPointID], tagID)
+	return nil
+}
+
+// copyMetadata returns a copy of metadata that does not share its top-level
+// map with the caller, or nil if it is empty like SQLStore does.
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	c := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+	return c
+}
+
+func (m *MemoryStore) filterDataPoints(filter *MetadataFilter, keep func(DataPoint) bool) []DataPoint {
+	dataPoints := []DataPoint{}
+	for _, dp := range m.data.dataPoints {
+		if keep(dp) && filter.Match(dp.Metadata) {
+			dataPoints = append(dataPoints, dp)
+		}
+	}
+	sort.Slice(dataPoints, func(i, j int) bool { return dataPoints[i].ID < dataPoints[j].ID })
+	return dataPoints
+}
+
+func sortedCollections(all map[string]Collection) []Collection {
+	collections := make([]Collection, 0, len(all))
+	for _, c := range all {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].ID < collections[j].ID })
+	return collections
+}
+
+func sortedTags(all map[string]Tag, collectionID string) []Tag {
+	tags := []Tag{}
+	for _, t := range all {
+		if t.CollectionID == collectionID {
+			tags = append(tags, t)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].ID < tags[j].ID })
+	return tags
+}
+
+// CreateDocument implements Store.
+func (m *MemoryStore) CreateDocument(ctx context.Context, d *Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.collections[d.CollectionID]; !ok {
+		return fmt.Errorf("failed to create document: collection %s: %w", d.CollectionID, ErrNotFound)
+	}
+	d.ID = newID()
+	d.CreatedAt = now()
+	m.data.documents[d.ID] = *d
+	return nil
+}
+
+// GetDocument implements Store.
+func (m *MemoryStore) GetDocument(ctx context.Context, id string) (*Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.data.documents[id]
+	if !ok {
+		return nil, fmt.Errorf("document %s: %w", id, ErrNotFound)
+	}
+	return &d, nil
+}
+
+// ListDocumentDataPoints implements Store.
+func (m *MemoryStore) ListDocumentDataPoints(ctx context.Context, documentID string) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dataPoints := m.filterDataPoints(nil, func(dp DataPoint) bool {
+		return dp.Chunk != nil && dp.Chunk.DocumentID == documentID
+	})
+	sort.SliceStable(dataPoints, func(i, j int) bool { return dataPoints[i].Chunk.Index < dataPoints[j].Chunk.Index })
+	return dataPoints, nil
+}
+
+// CreateAttachment implements Store.
+func (m *MemoryStore) CreateAttachment(ctx context.Context, a *Attachment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.documents[a.DocumentID]; !ok {
+		return fmt.Errorf("failed to create attachment: document %s: %w", a.DocumentID, ErrNotFound)
+	}
+	a.ID = newID()
+	a.Size = len(a.Data)
+	a.CreatedAt = now()
+	stored := *a
+	stored.Data = append([]byte(nil), a.Data...)
+	m.data.attachments[a.ID] = stored
+	return nil
+}
+
+// GetAttachment implements Store.
+func (m *MemoryStore) GetAttachment(ctx context.Context, id string) (*Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	a, ok := m.data.attachments[id]
+	if !ok {
+		return nil, fmt.Errorf("attachment %s: %w", id, ErrNotFound)
+	}
+	a.Data = append([]byte(nil), a.Data...)
+	return &a, nil
+}
+
+// ListAttachments implements Store.
+func (m *MemoryStore) ListAttachments(ctx context.Context, documentID string) ([]Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	attachments := []Attachment{}
+	for _, a := range m.data.attachments {
+		if a.DocumentID == documentID {
+			a.Data = nil
+			attachments = append(attachments, a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+// SearchDataPoints implements Store by scanning every data point in the
+// collection. Scores are not comparable with SQLStore's BM25 scores.
+func (m *MemoryStore) SearchDataPoints(ctx context.Context, collectionID, query string, filter *MetadataFilter, limit int) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	parsed := parseSearchQuery(query)
+	results := []SearchResult{}
+	if len(parsed) == 0 {
+		return results, nil
+	}
+
+	for _, dp := range m.data.dataPoints {
+		if m.data.tags[dp.TagID].CollectionID != collectionID || !filter.Match(dp.Metadata) {
+			continue
+		}
+		score, highlights, ok := parsed.match(dp.Value)
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{
+			DataPoint: dp,
+			Score:     score,
+			Snippet:   snippet(dp.Value, highlights),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// SaveEmbedding implements Store.
+func (m *MemoryStore) SaveEmbedding(ctx context.Context, e *Embedding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.dataPoints[e.DataPointID]; !ok {
+		return fmt.Errorf("failed to save embedding: data point %s: %w", e.DataPointID, ErrNotFound)
+	}
+	e.CreatedAt = now()
+	stored := *e
+	stored.Vector = append([]float32(nil), e.Vector...)
+	if m.data.embeddings[e.DataPointID] == nil {
+		m.data.embeddings[e.DataPointID] = map[string]Embedding{}
+	}
+	m.data.embeddings[e.DataPointID][e.Model] = stored
+	return nil
+}
+
+// ListEmbeddings implements Store.
+func (m *MemoryStore) ListEmbeddings(ctx context.Context, model string) ([]Embedding, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	embeddings := []Embedding{}
+	for _, byModel := range m.data.embeddings {
+		if e, ok := byModel[model]; ok {
+			embeddings = append(embeddings, e)
+		}
+	}
+	sort.Slice(embeddings, func(i, j int) bool { return embeddings[i].DataPointID < embeddings[j].DataPointID })
+	return embeddings, nil
+}
+
+// GetSearchSettings implements Store.
+func (m *MemoryStore) GetSearchSettings(ctx context.Context, collectionID string) (*SearchSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings, ok := m.data.searchSettings[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("search settings of collection %s: %w", collectionID, ErrNotFound)
+	}
+	return &settings, nil
+}
+
+// SaveSearchSettings implements Store.
+func (m *MemoryStore) SaveSearchSettings(ctx context.Context, settings *SearchSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.collections[settings.CollectionID]; !ok {
+		return fmt.Errorf("failed to save search settings: collection %s: %w", settings.CollectionID, ErrNotFound)
+	}
+	settings.UpdatedAt = now()
+	m.data.searchSettings[settings.CollectionID] = *settings
+	return nil
+}
+
+// GetDedupeSettings implements Store.
+func (m *MemoryStore) GetDedupeSettings(ctx context.Context, collectionID string) (*DedupeSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings, ok := m.data.dedupeSettings[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("dedupe settings of collection %s: %w", collectionID, ErrNotFound)
+	}
+	return &settings, nil
+}
+
+// SaveDedupeSettings implements Store.
+func (m *MemoryStore) SaveDedupeSettings(ctx context.Context, settings *DedupeSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.collections[settings.CollectionID]; !ok {
+		return fmt.Errorf("failed to save dedupe settings: collection %s: %w", settings.CollectionID, ErrNotFound)
+	}
+	if settings.Unique {
+		seen := map[string]bool{}
+		for _, dp := range m.data.dataPoints {
+			if m.data.tags[dp.TagID].CollectionID != settings.CollectionID {
+				continue
+			}
+			if seen[dp.ContentHash] {
+				return fmt.Errorf("collection %s: %w", settings.CollectionID, ErrDuplicatesExist)
+			}
+			seen[dp.ContentHash] = true
+		}
+	}
+	settings.UpdatedAt = now()
+	m.data.dedupeSettings[settings.CollectionID] = *settings
+	return nil
+}
+
+// CreateSource implements Store.
+func (m *MemoryStore) CreateSource(ctx context.Context, src *Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.collections[src.CollectionID]; !ok {
+		return fmt.Errorf("failed to create source: collection %s: %w", src.CollectionID, ErrNotFound)
+	}
+	if _, ok := m.data.tags[src.TagID]; !ok {
+		return fmt.Errorf("failed to create source: tag %s: %w", src.TagID, ErrNotFound)
+	}
+	src.ID = newID()
+	src.CreatedAt = now()
+	src.UpdatedAt = src.CreatedAt
+	m.data.sources[src.ID] = copySource(*src)
+	return nil
+}
+
+// GetSource implements Store.
+func (m *MemoryStore) GetSource(ctx context.Context, id string) (*Source, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	src, ok := m.data.sources[id]
+	if !ok {
+		return nil, fmt.Errorf("source %s: %w", id, ErrNotFound)
+	}
+	src = copySource(src)
+	return &src, nil
+}
+
+// ListSources implements Store.
+func (m *MemoryStore) ListSources(ctx context.Context, collectionID string, page Page) ([]Source, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := []Source{}
+	for _, src := range m.data.sources {
+		if collectionID == "" || src.CollectionID == collectionID {
+			sources = append(sources, copySource(src))
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	start, end := window(len(sources), page, func(i int) bool { return sources[i].ID > page.After })
+	return sources[start:end], nil
+}
+
+// UpdateSource implements Store.
+func (m *MemoryStore) UpdateSource(ctx context.Context, src *Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.data.sources[src.ID]
+	if !ok {
+		return fmt.Errorf("source %s: %w", src.ID, ErrNotFound)
+	}
+	stored.DocumentID = src.DocumentID
+	stored.Interval = src.Interval
+	stored.ETag = src.ETag
+	stored.LastModified = src.LastModified
+	stored.ContentHash = src.ContentHash
+	stored.NextFetchAt = src.NextFetchAt
+	stored.LastFetchedAt = src.LastFetchedAt
+	stored.LastStatus = src.LastStatus
+	stored.LastError = src.LastError
+	stored.Failures = src.Failures
+	stored.UpdatedAt = now()
+	m.data.sources[src.ID] = copySource(stored)
+	*src = copySource(stored)
+	return nil
+}
+
+// DeleteSource implements Store.
+func (m *MemoryStore) DeleteSource(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.sources[id]; !ok {
+		return fmt.Errorf("source %s: %w", id, ErrNotFound)
+	}
+	delete(m.data.sources, id)
+	delete(m.data.sourceFetches, id)
+	for _, all := range []map[string]DataPoint{m.data.dataPoints, m.data.deletedDataPoints} {
+		for dpID, dp := range all {
+			if dp.SourceID == id {
+				dp.SourceID = ""
+				all[dpID] = dp
+			}
+		}
+	}
+	return nil
+}
+
+// ListDueSources implements Store.
+func (m *MemoryStore) ListDueSources(ctx context.Context, t time.Time, limit int) ([]Source, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := []Source{}
+	for _, src := range m.data.sources {
+		if _, ok := m.data.tags[src.TagID]; !ok || src.NextFetchAt == nil || src.NextFetchAt.After(t) {
+			continue
+		}
+		sources = append(sources, copySource(src))
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		a, b := sources[i], sources[j]
+		if !a.NextFetchAt.Equal(*b.NextFetchAt) {
+			return a.NextFetchAt.Before(*b.NextFetchAt)
+		}
+		return a.ID < b.ID
+	})
+	if limit > 0 && len(sources) > limit {
+		sources = sources[:limit]
+	}
+	return sources, nil
+}
+
+// ListSourceDataPoints implements Store.
+func (m *MemoryStore) ListSourceDataPoints(ctx context.Context, sourceID string) ([]DataPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dataPoints := m.filterDataPoints(nil, func(dp DataPoint) bool { return dp.SourceID == sourceID })
+	index := func(dp DataPoint) int {
+		if dp.Chunk == nil {
+			return -1
+		}
+		return dp.Chunk.Index
+	}
+	sort.SliceStable(dataPoints, func(i, j int) bool { return index(dataPoints[i]) < index(dataPoints[j]) })
+	return dataPoints, nil
+}
+
+// CreateSourceFetch implements Store.
+func (m *MemoryStore) CreateSourceFetch(ctx context.Context, f *SourceFetch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.sources[f.SourceID]; !ok {
+		return fmt.Errorf("failed to record fetch: source %s: %w", f.SourceID, ErrNotFound)
+	}
+	f.ID = newID()
+	if f.FetchedAt.IsZero() {
+		f.FetchedAt = now()
+	}
+	m.data.sourceFetches[f.SourceID] = append(m.data.sourceFetches[f.SourceID], *f)
+	return nil
+}
+
+// ListSourceFetches implements Store.
+func (m *MemoryStore) ListSourceFetches(ctx context.Context, sourceID string, page Page) ([]SourceFetch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.data.sources[sourceID]; !ok {
+		return nil, fmt.Errorf("source %s: %w", sourceID, ErrNotFound)
+	}
+	stored := m.data.sourceFetches[sourceID]
+	fetches := make([]SourceFetch, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		fetches = append(fetches, stored[i])
+	}
+	start, end := window(len(fetches), page, func(i int) bool { return fetches[i].ID < page.After })
+	return fetches[start:end], nil
+}
+
+// copySource returns src with its own copies of its timestamps, so stored
+// sources cannot be changed through returned ones.
+func copySource(src Source) Source {
+	if src.NextFetchAt != nil {
+		t := *src.NextFetchAt
+		src.NextFetchAt = &t
+	}
+	if src.LastFetchedAt != nil {
+		t := *src.LastFetchedAt
+		src.LastFetchedAt = &t
+	}
+	return src
+}
+
+// CreateFeed implements Store.
+func (m *MemoryStore) CreateFeed(ctx context.Context, f *Feed) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.collections[f.CollectionID]; !ok {
+		return fmt.Errorf("failed to create feed: collection %s: %w", f.CollectionID, ErrNotFound)
+	}
+	if _, ok := m.data.tags[f.TagID]; !ok {
+		return fmt.Errorf("failed to create feed: tag %s: %w", f.TagID, ErrNotFound)
+	}
+	for _, other := range m.data.feeds {
+		if other.TagID == f.TagID && other.URL == f.URL {
+			return fmt.Errorf("feed %s: %w", f.URL, ErrConflict)
+		}
+	}
+	f.ID = newID()
+	f.CreatedAt = now()
+	f.UpdatedAt = f.CreatedAt
+	m.data.feeds[f.ID] = copyFeed(*f)
+	return nil
+}
+
+// GetFeed implements Store.
+func (m *MemoryStore) GetFeed(ctx context.Context, id string) (*Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.data.feeds[id]
+	if !ok {
+		return nil, fmt.Errorf("feed %s: %w", id, ErrNotFound)
+	}
+	f = copyFeed(f)
+	return &f, nil
+}
+
+// ListFeeds implements Store.
+func (m *MemoryStore) ListFeeds(ctx context.Context, collectionID string, page Page) ([]Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := []Feed{}
+	for _, f := range m.data.feeds {
+		if collectionID == "" || f.CollectionID == collectionID {
+			feeds = append(feeds, copyFeed(f))
+		}
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID < feeds[j].ID })
+	start, end := window(len(feeds), page, func(i int) bool { return feeds[i].ID > page.After })
+	return feeds[start:end], nil
+}
+
+// UpdateFeed implements Store.
+func (m *MemoryStore) UpdateFeed(ctx context.Context, f *Feed) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.data.feeds[f.ID]
+	if !ok {
+		return fmt.Errorf("feed %s: %w", f.ID, ErrNotFound)
+	}
+	stored.Title = f.Title
+	stored.FullText = f.FullText
+	stored.Interval = f.Interval
+	stored.ETag = f.ETag
+	stored.LastModified = f.LastModified
+	stored.NextFetchAt = f.NextFetchAt
+	stored.LastFetchedAt = f.LastFetchedAt
+	stored.LastStatus = f.LastStatus
+	stored.LastError = f.LastError
+	stored.Failures = f.Failures
+	stored.UpdatedAt = now()
+	m.data.feeds[f.ID] = copyFeed(stored)
+	*f = copyFeed(stored)
+	return nil
+}
+
+// DeleteFeed implements Store.
+func (m *MemoryStore) DeleteFeed(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.feeds[id]; !ok {
+		return fmt.Errorf("feed %s: %w", id, ErrNotFound)
+	}
+	delete(m.data.feeds, id)
+	delete(m.data.feedEntries, id)
+	return nil
+}
+
+// ListDueFeeds implements Store.
+func (m *MemoryStore) ListDueFeeds(ctx context.Context, t time.Time, limit int) ([]Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := []Feed{}
+	for _, f := range m.data.feeds {
+		if _, ok := m.data.tags[f.TagID]; !ok || f.NextFetchAt == nil || f.NextFetchAt.After(t) {
+			continue
+		}
+		feeds = append(feeds, copyFeed(f))
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		a, b := feeds[i], feeds[j]
+		if !a.NextFetchAt.Equal(*b.NextFetchAt) {
+			return a.NextFetchAt.Before(*b.NextFetchAt)
+		}
+		return a.ID < b.ID
+	})
+	if limit > 0 && len(feeds) > limit {
+		feeds = feeds[:limit]
+	}
+	return feeds, nil
+}
+
+// GetFeedEntry implements Store.
+func (m *MemoryStore) GetFeedEntry(ctx context.Context, feedID, guid string) (*FeedEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, e := range m.data.feedEntries[feedID] {
+		if e.GUID == guid {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("feed entry %s: %w", guid, ErrNotFound)
+}
+
+// CreateFeedEntry implements Store.
+func (m *MemoryStore) CreateFeedEntry(ctx context.Context, e *FeedEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.data.feeds[e.FeedID]; !ok {
+		return fmt.Errorf("failed to record feed entry: feed %s: %w", e.FeedID, ErrNotFound)
+	}
+	for _, other := range m.data.feedEntries[e.FeedID] {
+		if other.GUID == e.GUID {
+			return fmt.Errorf("feed entry %s: %w", e.GUID, ErrConflict)
+		}
+	}
+	e.ID = newID()
+	e.CreatedAt = now()
+	m.data.feedEntries[e.FeedID] = append(m.data.feedEntries[e.FeedID], *e)
+	return nil
+}
+
+// ListFeedEntries implements Store.
+func (m *MemoryStore) ListFeedEntries(ctx context.Context, feedID string, page Page) ([]FeedEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.data.feeds[feedID]; !ok {
+		return nil, fmt.Errorf("feed %s: %w", feedID, ErrNotFound)
+	}
+	stored := m.data.feedEntries[feedID]
+	entries := make([]FeedEntry, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		entries = append(entries, stored[i])
+	}
+	start, end := window(len(entries), page, func(i int) bool { return entries[i].ID < page.After })
+	return entries[start:end], nil
+}
+
+// copyFeed returns f with its own copies of its timestamps, so stored feeds
+// cannot be changed through returned ones.
+func copyFeed(f Feed) Feed {
+	if f.NextFetchAt != nil {
+		t := *f.NextFetchAt
+		f.NextFetchAt = &t
+	}
+	if f.LastFetchedAt != nil {
+		t := *f.LastFetchedAt
+		f.LastFetchedAt = &t
+	}
+	return f
+}
+
+// CreateJob implements Store.
+func (m *MemoryStore) CreateJob(ctx context.Context, j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j.ID = newID()
+	j.CreatedAt = now()
+	j.UpdatedAt = j.CreatedAt
+	if j.RunAt.IsZero() {
+		j.RunAt = j.CreatedAt
+	}
+	m.data.jobs[j.ID] = copyJob(*j)
+	return nil
+}
+
+// GetJob implements Store.
+func (m *MemoryStore) GetJob(ctx context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.data.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	j = copyJob(j)
+	return &j, nil
+}
+
+// ListJobs implements Store.
+func (m *MemoryStore) ListJobs(ctx context.Context, status JobStatus, page Page) ([]Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := []Job{}
+	for _, j := range m.data.jobs {
+		if status == "" || j.Status == status {
+			jobs = append(jobs, copyJob(j))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	start, end := window(len(jobs), page, func(i int) bool { return jobs[i].ID < page.After })
+	return jobs[start:end], nil
+}
+
+// UpdateJob implements Store.
+func (m *MemoryStore) UpdateJob(ctx context.Context, j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.data.jobs[j.ID]
+	if !ok {
+		return fmt.Errorf("job %s: %w", j.ID, ErrNotFound)
+	}
+	j.Request = old.Request
+	j.CreatedAt = old.CreatedAt
+	j.UpdatedAt = now()
+	m.data.jobs[j.ID] = copyJob(*j)
+	return nil
+}
+
+// ClaimJob implements Store.
+func (m *MemoryStore) ClaimJob(ctx context.Context) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	var due *Job
+	for _, j := range m.data.jobs {
+		if j.Status != JobQueued || j.RunAt.After(t) {
+			continue
+		}
+		if due == nil || j.RunAt.Before(due.RunAt) || (j.RunAt.Equal(due.RunAt) && j.ID < due.ID) {
+			j := j
+			due = &j
+		}
+	}
+	if due == nil {
+		return nil, fmt.Errorf("due job: %w", ErrNotFound)
+	}
+
+	due.Status = JobRunning
+	due.Attempts++
+	due.StartedAt = &t
+	due.UpdatedAt = t
+	m.data.jobs[due.ID] = copyJob(*due)
+	claimed := copyJob(*due)
+	return &claimed, nil
+}
+
+// copyJob returns j with its own copies of the request, result and
+// timestamps, so stored jobs cannot be changed through returned ones.
+func copyJob(j Job) Job {
+	j.Request = append(json.RawMessage(nil), j.Request...)
+	if j.Result != nil {
+		j.Result = append(json.RawMessage(nil), j.Result...)
+	}
+	if j.StartedAt != nil {
+		t := *j.StartedAt
+		j.StartedAt = &t
+	}
+	if j.FinishedAt != nil {
+		t := *j.FinishedAt
+		j.FinishedAt = &t
+	}
+	return j
+}
@@ -0,0 +1,62 @@
+package collections
+
+import (
+	"fmt"
+	"time"
+)
+
+// Page selects a window of a listing by keyset: the items following a
+// given one in the order of the listing. The zero Page selects everything.
+type Page struct {
+	// Limit is the largest number of items returned, 0 for no limit.
+	Limit int
+	// After is the ID of the last item of the previous page.
+	After string
+	// AfterValue is the sort value of that item for listings not ordered by
+	// ID, such as collections ordered by name. Times are formatted as
+	// RFC 3339 with nanoseconds.
+	AfterValue string
+}
+
+// afterTime parses p.AfterValue as a time.
+func (p Page) afterTime() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, p.AfterValue)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid page value %q: %w", p.AfterValue, err)
+	}
+	return t, nil
+}
+
+// paginate adds the keyset condition, order and limit of p to query, whose
+// WHERE clause must be its last, for a listing ordered by the ID column.
+func paginate(query string, args []interface{}, column string, desc bool, p Page) (string, []interface{}) {
+	op, direction := ">", "ASC"
+	if desc {
+		op, direction = "<", "DESC"
+	}
+	if p.After != "" {
+		query += " AND " + column + " " + op + " ?"
+		args = append(args, p.After)
+	}
+	query += " ORDER BY " + column + " " + direction
+	if p.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, p.Limit)
+	}
+	return query, args
+}
+
+// window returns the items of a sorted listing of n items selected by p.
+// follows reports whether item i comes after the item p continues from.
+func window(n int, p Page, follows func(i int) bool) (start, end int) {
+	if p.After != "" {
+		for start < n && !follows(start) {
+			start++
+		}
+	}
+	end = n
+	if p.Limit > 0 && start+p.Limit < end {
+		end = start + p.Limit
+	}
+	return start, end
+}
@@ -0,0 +1,124 @@
+package collections
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Revision is a version of the value and metadata of a data point. A
+// revision is recorded when a data point is created and whenever its value
+// or metadata change; revisions are never changed afterwards.
+type Revision struct {
+	ID          string `json:"id"`
+	DataPointID string `json:"data_point_id"`
+	// Number counts the revisions of the data point, starting at 1.
+	Number   int                    `json:"number"`
+	Value    string                 `json:"value"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Author is whoever made the change, if known.
+	Author string `json:"author,omitempty"`
+	// RestoredFrom is the number of the revision this one restored.
+	RestoredFrom int `json:"restored_from,omitempty"`
+	// Diff is the change from the previous revision. It is nil for the
+	// first one.
+	Diff      *RevisionDiff `json:"diff,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// RevisionDiff is the change between two revisions of a data point.
+type RevisionDiff struct {
+	// Value is a unified diff of the lines of the value, empty if it is
+	// unchanged.
+	Value string `json:"value,omitempty"`
+	// Metadata holds the changed metadata fields by key.
+	Metadata map[string]MetadataChange `json:"metadata,omitempty"`
+}
+
+// MetadataChange is the old and new value of a metadata field. A field that
+// was added or removed is null on the side it is missing from.
+type MetadataChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// DiffRevisions returns the change from one revision of a data point to
+// another.
+func DiffRevisions(from, to *Revision) *RevisionDiff {
+	diff := &RevisionDiff{Value: unifiedDiff(from.Value, to.Value)}
+
+	keys := make([]string, 0, len(from.Metadata)+len(to.Metadata))
+	for k := range from.Metadata {
+		keys = append(keys, k)
+	}
+	for k := range to.Metadata {
+		if _, ok := from.Metadata[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		old, ok := from.Metadata[k]
+		changed, set := to.Metadata[k]
+		if ok && set && sameJSON(old, changed) {
+			continue
+		}
+		if diff.Metadata == nil {
+			diff.Metadata = map[string]MetadataChange{}
+		}
+		diff.Metadata[k] = MetadataChange{From: old, To: changed}
+	}
+
+	return diff
+}
+
+// revisionOf returns the revision recording the current value and metadata
+// of dp as the one following previous, which is nil for the first. It
+// returns nil if neither changed since previous.
+func revisionOf(ctx context.Context, dp *DataPoint, previous *Revision, restoredFrom int) *Revision {
+	r := &Revision{
+		ID:           newID(),
+		DataPointID:  dp.ID,
+		Number:       1,
+		Value:        dp.Value,
+		Metadata:     copyMetadata(dp.Metadata),
+		Author:       AuthorFrom(ctx),
+		RestoredFrom: restoredFrom,
+		CreatedAt:    dp.UpdatedAt,
+	}
+	if previous != nil {
+		if previous.Value == dp.Value && sameJSON(previous.Metadata, r.Metadata) {
+			return nil
+		}
+		r.Number = previous.Number + 1
+		r.Diff = DiffRevisions(previous, r)
+	}
+	return r
+}
+
+// sameJSON reports whether a and b encode to the same JSON, so that numbers
+// compare equal whether they were decoded or set from Go.
+func sameJSON(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+type authorKey struct{}
+
+// WithAuthor returns a context in which changes to data points are recorded
+// as made by author.
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// AuthorFrom returns the author set on ctx by WithAuthor, or "".
+func AuthorFrom(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
@@ -0,0 +1,282 @@
+package collections
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchResult is a data point matched by a search.
+type SearchResult struct {
+	DataPoint
+	// Score is the relevance of the match; higher is better.
+	Score float64 `json:"score"`
+	// Snippet is an excerpt of the value with matches wrapped in <mark> tags.
+	Snippet string `json:"snippet"`
+	// Breakdown explains a fused score, keyed by retriever. It is only set
+	// by hybrid searches.
+	Breakdown map[string]RankScore `json:"breakdown,omitempty"`
+}
+
+// RankScore is how one retriever ranked a result of a hybrid search.
+type RankScore struct {
+	// Rank is the 1-based position in the retriever's results.
+	Rank int `json:"rank"`
+	// Score is the retriever's own score.
+	Score float64 `json:"score"`
+	// Contribution is the part of the fused score coming from this retriever.
+	Contribution float64 `json:"contribution"`
+}
+
+// SearchSettings tunes hybrid search for one collection.
+type SearchSettings struct {
+	CollectionID   string    `json:"collection_id"`
+	KeywordWeight  float64   `json:"keyword_weight"`
+	SemanticWeight float64   `json:"semantic_weight"`
+	RRFK           int       `json:"rrf_k"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+const (
+	snippetOpen  = "<mark>"
+	snippetClose = "</mark>"
+	snippetTrail = "…"
+	// snippetWords is the number of words kept around the first match.
+	snippetWords = 16
+)
+
+// searchQuery is a parsed search string: a list of alternatives joined by OR,
+// each of which requires all of its terms to match.
+type searchQuery [][]searchTerm
+
+// searchTerm is a single word or a quoted phrase. A term written with a
+// trailing * matches any word starting with its last word.
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// parseSearchQuery parses the user facing query syntax: bare words must all
+// match, "quoted phrases" must match in order, a trailing * makes a prefix
+// query and OR separates alternatives. Anything else is ignored, so the
+// result can always be rendered as a valid FTS5 expression.
+func parseSearchQuery(q string) searchQuery {
+	var query searchQuery
+	var group []searchTerm
+
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		var raw string
+		phrase := false
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				raw, q = q[1:], ""
+			} else {
+				raw, q = q[1:end+1], q[end+2:]
+			}
+			phrase = true
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			raw, q = q[:end], q[end:]
+		}
+
+		if !phrase && raw == "OR" {
+			if len(group) > 0 {
+				query = append(query, group)
+				group = nil
+			}
+			continue
+		}
+
+		prefix := false
+		if strings.HasPrefix(q, "*") {
+			prefix, q = true, q[1:]
+		} else if strings.HasSuffix(raw, "*") {
+			prefix = true
+		}
+
+		words := searchWords(raw)
+		if len(words) == 0 {
+			continue
+		}
+		group = append(group, searchTerm{words: words, prefix: prefix})
+	}
+
+	if len(group) > 0 {
+		query = append(query, group)
+	}
+
+	return query
+}
+
+// searchWords splits s into lower-cased runs of letters and digits.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// fts5 renders the query as an FTS5 MATCH expression. Every term is quoted so
+// user input can never be interpreted as FTS5 syntax.
+func (q searchQuery) fts5() string {
+	alternatives := make([]string, 0, len(q))
+	for _, group := range q {
+		terms := make([]string, 0, len(group))
+		for _, term := range group {
+			expr := `"` + strings.Join(term.words, " ") + `"`
+			if term.prefix {
+				expr += "*"
+			}
+			terms = append(terms, expr)
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return strings.Join(alternatives, " OR ")
+}
+
+// match scores text against the query without an index. It is used by
+// MemoryStore and returns false if the text does not match. The score is the
+// number of matched term occurrences, weighted down for long texts.
+func (q searchQuery) match(text string) (float64, [][2]int, bool) {
+	words, spans := wordSpans(text)
+
+	var best float64
+	var highlights [][2]int
+	matched := false
+	for _, group := range q {
+		var hits int
+		var groupHighlights [][2]int
+		ok := true
+		for _, term := range group {
+			found := term.find(words)
+			if len(found) == 0 {
+				ok = false
+				break
+			}
+			hits += len(found)
+			for _, start := range found {
+				end := start + len(term.words) - 1
+				groupHighlights = append(groupHighlights, [2]int{spans[start][0], spans[end][1]})
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		score := float64(hits) / (1 + float64(len(words))/100)
+		if !matched || score > best {
+			best, highlights = score, groupHighlights
+		}
+		matched = true
+	}
+
+	return best, highlights, matched
+}
+
+// find returns the word offsets at which the term starts in words.
+func (t searchTerm) find(words []string) []int {
+	var found []int
+	last := len(t.words) - 1
+	for i := 0; i+last < len(words); i++ {
+		ok := true
+		for j, w := range t.words {
+			if j == last && t.prefix {
+				ok = strings.HasPrefix(words[i+j], w)
+			} else {
+				ok = words[i+j] == w
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// wordSpans returns the lower-cased words of text and their byte offsets.
+func wordSpans(text string) ([]string, [][2]int) {
+	var words []string
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			words = append(words, strings.ToLower(text[start:i]))
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, strings.ToLower(text[start:]))
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return words, spans
+}
+
+// snippet returns an excerpt of text around the first highlight, in the same
+// format as the FTS5 snippet function used by SQLStore.
+func snippet(text string, highlights [][2]int) string {
+	if len(highlights) == 0 {
+		return ""
+	}
+
+	highlights = append([][2]int(nil), highlights...)
+	sort.Slice(highlights, func(i, j int) bool { return highlights[i][0] < highlights[j][0] })
+
+	_, spans := wordSpans(text)
+	first := highlights[0][0]
+
+	center := 0
+	for i, span := range spans {
+		if span[0] >= first {
+			center = i
+			break
+		}
+	}
+	from := center - snippetWords/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(spans) {
+		to = len(spans)
+	}
+
+	start, end := spans[from][0], spans[to-1][1]
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString(snippetTrail)
+	}
+	pos := start
+	for _, h := range highlights {
+		if h[0] < pos || h[1] > end {
+			continue
+		}
+		b.WriteString(text[pos:h[0]])
+		b.WriteString(snippetOpen)
+		b.WriteString(text[h[0]:h[1]])
+		b.WriteString(snippetClose)
+		pos = h[1]
+	}
+	b.WriteString(text[pos:end])
+	if to < len(spans) {
+		b.WriteString(snippetTrail)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package collections
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Kinds of records slugs redirect to.
+const (
+	slugKindCollection = "collection"
+	slugKindTag        = "tag"
+)
+
+// Slugify returns the URL-friendly form of a name: its letters and digits
+// in lower case, with every run of other characters replaced by a hyphen.
+// "Research Notes (2024)" becomes "research-notes-2024".
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// uniqueSlug returns the slug of name, numbered from 2 if taken reports it is
+// in use. Names without letters or digits get the slug of id instead.
+func uniqueSlug(name, id string, taken func(slug string) (bool, error)) (string, error) {
+	base := Slugify(name)
+	if base == "" {
+		base = strings.ToLower(id)
+	}
+	slug := base
+	for n := 2; ; n++ {
+		used, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return slug, nil
+		}
+		slug = base + "-" + strconv.Itoa(n)
+	}
+}
+
+// resolve looks up ref as an ID, a name, a slug and a former slug, in that
+// order, and reports whether it was found as a former slug. Every lookup
+// returns ErrNotFound (wrapped) if it finds nothing.
+func resolve[T any](ref string, byID, byName, bySlug, byFormerSlug func(string) (*T, error)) (*T, bool, error) {
+	if _, err := ulid.ParseStrict(ref); err == nil {
+		if v, err := byID(ref); !errors.Is(err, ErrNotFound) {
+			return v, false, err
+		}
+	}
+	v, err := byName(ref)
+	if !errors.Is(err, ErrNotFound) {
+		return v, false, err
+	}
+	slug := Slugify(ref)
+	if slug == "" {
+		return nil, false, err
+	}
+	if v, err := bySlug(slug); !errors.Is(err, ErrNotFound) {
+		return v, false, err
+	}
+	v, err = byFormerSlug(slug)
+	return v, err == nil, err
+}
@@ -0,0 +1,103 @@
+package collections
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Source is a fetched URL whose data points can be refreshed by crawling it
+// again. Conditional fetches send the validators of the last fetch, ETag
+// and LastModified, so unchanged pages are not downloaded again.
+type Source struct {
+	ID           string `json:"id"`
+	CollectionID string `json:"collection_id"`
+	TagID        string `json:"tag_id"`
+	URL          string `json:"url"`
+	// DocumentID is set once the text of the page was split into several
+	// data points.
+	DocumentID    string `json:"document_id,omitempty"`
+	ChunkStrategy string `json:"chunk_strategy"`
+	ChunkSize     int    `json:"chunk_size"`
+	ChunkOverlap  int    `json:"chunk_overlap"`
+	// Interval is the time between crawls, 0 if the source is only crawled
+	// on demand.
+	Interval     Duration `json:"interval"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	// ContentHash is the ContentHash of the text extracted from the page.
+	ContentHash string `json:"content_hash"`
+	// NextFetchAt is when the source is due to be crawled, nil if it has no
+	// Interval.
+	NextFetchAt   *time.Time  `json:"next_fetch_at,omitempty"`
+	LastFetchedAt *time.Time  `json:"last_fetched_at,omitempty"`
+	LastStatus    FetchStatus `json:"last_status,omitempty"`
+	LastError     string      `json:"last_error,omitempty"`
+	// Failures counts the crawls that failed in a row.
+	Failures  int       `json:"failures"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Schedule sets s.NextFetchAt to Interval after t, or to nil if s has no
+// Interval.
+func (s *Source) Schedule(t time.Time) {
+	s.NextFetchAt = nil
+	if s.Interval > 0 {
+		next := t.Add(time.Duration(s.Interval))
+		s.NextFetchAt = &next
+	}
+}
+
+// FetchStatus is the outcome of a crawl of a Source.
+type FetchStatus string
+
+const (
+	// FetchChanged means the text of the page changed and its data points
+	// were updated.
+	FetchChanged FetchStatus = "changed"
+	// FetchUnchanged means the page was downloaded, but its text is the
+	// same.
+	FetchUnchanged FetchStatus = "unchanged"
+	// FetchNotModified means the server answered 304 Not Modified.
+	FetchNotModified FetchStatus = "not_modified"
+	// FetchFailed means the page could not be fetched or stored.
+	FetchFailed FetchStatus = "failed"
+)
+
+// SourceFetch records a crawl of a Source.
+type SourceFetch struct {
+	ID       string      `json:"id"`
+	SourceID string      `json:"source_id"`
+	Status   FetchStatus `json:"status"`
+	// HTTPStatus is the status the server answered with, 0 if it could not
+	// be reached.
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Created, Updated and Deleted count the data points changed by the
+	// crawl.
+	Created   int       `json:"created"`
+	Updated   int       `json:"updated"`
+	Deleted   int       `json:"deleted"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Duration is a time.Duration written in JSON as a string such as "6h".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"6h\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,2212 @@
+package collections
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/oklog/ulid/v2"
+)
+
+// querier is the subset of *sql.DB and *sql.Tx used by SQLStore.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// SQLStore is a Store backed by the SQLite schema in database/migrations.
+type SQLStore struct {
+	db *sql.DB
+	q  querier
+}
+
+// NewSQLStore returns a Store that reads and writes db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, q: db}
+}
+
+// WithTx implements Store.
+func (s *SQLStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = fn(&SQLStore{db: s.db, q: tx})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// newID returns a new ULID string.
+func newID() string {
+	return ulid.Make().String()
+}
+
+// now returns the current time in UTC, the zone timestamps are stored in.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound.
+func notFound(err error, kind, id string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %s: %w", kind, id, ErrNotFound)
+	}
+	return err
+}
+
+// isPrimaryKeyViolation reports whether err is SQLite refusing a duplicate
+// primary key.
+func isPrimaryKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+// placeholders returns a parenthesized list of n parameters, such as
+// "(?, ?, ?)", for an IN clause.
+func placeholders(n int) string {
+	return "(?" + strings.Repeat(", ?", n-1) + ")"
+}
+
+// isUniqueViolation reports whether err is SQLite refusing a duplicate
+// value of a unique index, such as a taken name.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// freeSlug returns an unused slug for the record id named name in table.
+// scope restricts the records compared to, such as the tags of a
+// collection.
+func (s *SQLStore) freeSlug(ctx context.Context, table, scope string, scopeArgs []interface{}, name, id string) (string, error) {
+	return uniqueSlug(name, id, func(slug string) (bool, error) {
+		return s.slugTaken(ctx, table, scope, scopeArgs, slug, id)
+	})
+}
+
+// slugTaken reports whether a record in table other than id, and not in
+// the trash, holds slug. scope is like for freeSlug.
+func (s *SQLStore) slugTaken(ctx context.Context, table, scope string, scopeArgs []interface{}, slug, id string) (bool, error) {
+	var taken bool
+	args := append(append([]interface{}{}, scopeArgs...), slug, id)
+	err := s.q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM "+table+" WHERE "+scope+" AND slug = ? AND id != ? AND deleted_at IS NULL)", args...).Scan(&taken)
+	if err != nil {
+		return false, fmt.Errorf("failed to check slug: %w", err)
+	}
+	return taken, nil
+}
+
+// moveSlug records that the record id of kind gave up the slug from for
+// the slug to, so that from keeps resolving to it. Redirects of to, which
+// the record now holds, are dropped. from is empty for new records.
+func (s *SQLStore) moveSlug(ctx context.Context, kind, scopeID, id, from, to string) error {
+	_, err := s.q.ExecContext(ctx,
+		"DELETE FROM slug_redirects WHERE kind = ? AND scope_id = ? AND slug = ?", kind, scopeID, to)
+	if err == nil && from != "" && from != to {
+		_, err = s.q.ExecContext(ctx, `
+			INSERT INTO slug_redirects (kind, scope_id, slug, target_id, created_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (kind, scope_id, slug) DO UPDATE SET target_id = excluded.target_id, created_at = excluded.created_at`,
+			kind, scopeID, from, id, now())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to redirect slug: %w", err)
+	}
+	return nil
+}
+
+// requireRow returns ErrNotFound if res affected no rows.
+func requireRow(res sql.Result, kind, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %s: %w", kind, id, ErrNotFound)
+	}
+	return nil
+}
+
+const collectionColumns = "id, name, slug, created_at, updated_at"
+
+func scanCollection(row interface{ Scan(...interface{}) error }, c *Collection, extra ...interface{}) error {
+	return row.Scan(append([]interface{}{&c.ID, &c.Name, &c.Slug, &c.CreatedAt, &c.UpdatedAt}, extra...)...)
+}
+
+// CreateCollection implements Store.
+func (s *SQLStore) CreateCollection(ctx context.Context, c *Collection) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		c.ID = newID()
+		c.CreatedAt = now()
+		c.UpdatedAt = c.CreatedAt
+		slug, err := s.freeSlug(ctx, "collections", "TRUE", nil, c.Name, c.ID)
+		if err != nil {
+			return err
+		}
+		c.Slug = slug
+
+		_, err = s.q.ExecContext(ctx,
+			"INSERT INTO collections (id, name, slug, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			c.ID, c.Name, c.Slug, c.CreatedAt, c.UpdatedAt)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+
+		return s.moveSlug(ctx, slugKindCollection, "", c.ID, "", c.Slug)
+	})
+}
+
+// GetCollection implements Store.
+func (s *SQLStore) GetCollection(ctx context.Context, id string) (*Collection, error) {
+	var c Collection
+	row := s.q.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE id = ? AND deleted_at IS NULL", id)
+	if err := scanCollection(row, &c); err != nil {
+		return nil, notFound(err, "collection", id)
+	}
+
+	return &c, nil
+}
+
+// GetCollectionByName implements Store.
+func (s *SQLStore) GetCollectionByName(ctx context.Context, name string) (*Collection, error) {
+	var c Collection
+	row := s.q.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE name = ? COLLATE NOCASE AND deleted_at IS NULL", name)
+	if err := scanCollection(row, &c); err != nil {
+		return nil, notFound(err, "collection", name)
+	}
+
+	return &c, nil
+}
+
+// ResolveCollection implements Store.
+func (s *SQLStore) ResolveCollection(ctx context.Context, ref string) (*Collection, bool, error) {
+	query := func(where string) func(string) (*Collection, error) {
+		return func(arg string) (*Collection, error) {
+			var c Collection
+			row := s.q.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE deleted_at IS NULL AND "+where, arg)
+			if err := scanCollection(row, &c); err != nil {
+				return nil, notFound(err, "collection", ref)
+			}
+			return &c, nil
+		}
+	}
+	return resolve(ref,
+		func(id string) (*Collection, error) { return s.GetCollection(ctx, id) },
+		func(name string) (*Collection, error) { return s.GetCollectionByName(ctx, name) },
+		query("slug = ?"),
+		query("id = (SELECT target_id FROM slug_redirects WHERE kind = '"+slugKindCollection+"' AND scope_id = '' AND slug = ?)"))
+}
+
+// ListCollections implements Store.
+func (s *SQLStore) ListCollections(ctx context.Context, page Page) ([]Collection, error) {
+	query, args := paginate("SELECT "+collectionColumns+" FROM collections WHERE deleted_at IS NULL", nil, "id", false, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := scanCollection(rows, &c); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+// collectionSummaryQuery selects collectionColumns and the counts of the
+// collections, which are aliased c, that are not in the trash. Its WHERE
+// clause is its last.
+const collectionSummaryQuery = `
+	SELECT c.id, c.name, c.slug, c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM tags t WHERE t.collection_id = c.id AND t.deleted_at IS NULL),
+		(SELECT COUNT(*) FROM data_points d JOIN tags t ON t.id = d.tag_id
+			WHERE t.collection_id = c.id AND d.deleted_at IS NULL)
+	FROM collections c
+	WHERE c.deleted_at IS NULL`
+
+func scanCollectionSummary(row interface{ Scan(...interface{}) error }, c *CollectionSummary) error {
+	return row.Scan(&c.ID, &c.Name, &c.Slug, &c.CreatedAt, &c.UpdatedAt, &c.TagCount, &c.DataPointCount)
+}
+
+// collectionOrderColumns maps each CollectionOrder to its column.
+var collectionOrderColumns = map[CollectionOrder]string{
+	OrderByName:    "c.name",
+	OrderByCreated: "c.created_at",
+	OrderByUpdated: "c.updated_at",
+}
+
+// ListCollectionSummaries implements Store.
+func (s *SQLStore) ListCollectionSummaries(ctx context.Context, order CollectionOrder, desc bool, page Page) ([]CollectionSummary, error) {
+	column, ok := collectionOrderColumns[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown collection order %q", order)
+	}
+	op, direction := ">", "ASC"
+	if desc {
+		op, direction = "<", "DESC"
+	}
+
+	query, args := collectionSummaryQuery, []interface{}{}
+	if page.After != "" {
+		var value interface{} = page.AfterValue
+		if order != OrderByName {
+			t, err := page.afterTime()
+			if err != nil {
+				return nil, err
+			}
+			value = t
+		}
+		query += " AND (" + column + ", c.id) " + op + " (?, ?)"
+		args = append(args, value, page.After)
+	}
+	query += " ORDER BY " + column + " " + direction + ", c.id " + direction
+	if page.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, page.Limit)
+	}
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []CollectionSummary{}
+	for rows.Next() {
+		var c CollectionSummary
+		if err := scanCollectionSummary(rows, &c); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, c)
+	}
+
+	return summaries, rows.Err()
+}
+
+// GetCollectionSummary implements Store.
+func (s *SQLStore) GetCollectionSummary(ctx context.Context, id string) (*CollectionSummary, error) {
+	var c CollectionSummary
+	row := s.q.QueryRowContext(ctx, collectionSummaryQuery+" AND c.id = ?", id)
+	if err := scanCollectionSummary(row, &c); err != nil {
+		return nil, notFound(err, "collection", id)
+	}
+
+	return &c, nil
+}
+
+// UpdateCollection implements Store.
+func (s *SQLStore) UpdateCollection(ctx context.Context, c *Collection) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		stored, err := s.GetCollection(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		slug := stored.Slug
+		if Slugify(c.Name) != Slugify(stored.Name) {
+			if slug, err = s.freeSlug(ctx, "collections", "TRUE", nil, c.Name, c.ID); err != nil {
+				return err
+			}
+		}
+
+		_, err = s.q.ExecContext(ctx, "UPDATE collections SET name = ?, slug = ?, updated_at = ? WHERE id = ?",
+			c.Name, slug, now(), c.ID)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update collection: %w", err)
+		}
+		if err := s.moveSlug(ctx, slugKindCollection, "", c.ID, stored.Slug, slug); err != nil {
+			return err
+		}
+
+		row := s.q.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE id = ?", c.ID)
+		return scanCollection(row, c)
+	})
+}
+
+// DeleteCollection implements Store.
+func (s *SQLStore) DeleteCollection(ctx context.Context, id string) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		q := tx.(*SQLStore).q
+
+		deletedAt := now()
+		res, err := q.ExecContext(ctx,
+			"UPDATE collections SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", deletedAt, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete collection: %w", err)
+		}
+		if err := requireRow(res, "collection", id); err != nil {
+			return err
+		}
+
+		for _, query := range []string{
+			"UPDATE data_points SET deleted_at = ? WHERE deleted_at IS NULL AND tag_id IN (SELECT id FROM tags WHERE collection_id = ?)",
+			"UPDATE tags SET deleted_at = ? WHERE deleted_at IS NULL AND collection_id = ?",
+		} {
+			if _, err := q.ExecContext(ctx, query, deletedAt, id); err != nil {
+				return fmt.Errorf("failed to delete collection: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+const tagColumns = "id, collection_id, parent_id, name, slug, created_at, updated_at"
+
+func scanTag(row interface{ Scan(...interface{}) error }, t *Tag, extra ...interface{}) error {
+	var parentID sql.NullString
+	dest := append([]interface{}{&t.ID, &t.CollectionID, &parentID, &t.Name, &t.Slug, &t.CreatedAt, &t.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	t.ParentID = parentID.String
+	return nil
+}
+
+// nullIfEmpty returns the column value of s, NULL if s is empty.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullIfZero returns the column value of n, NULL if n is 0.
+func nullIfZero(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// tagSubtree selects the IDs of a tag and its descendants that are not in
+// the trash as subtree.
+const tagSubtree = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM tags WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT t.id FROM tags t JOIN subtree ON t.parent_id = subtree.id WHERE t.deleted_at IS NULL
+	)`
+
+// deletedTagSubtree selects the IDs of a tag in the trash and of its
+// descendants deleted along with it as subtree. It takes the ID twice.
+const deletedTagSubtree = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM tags WHERE id = ? AND deleted_at IS NOT NULL
+		UNION ALL
+		SELECT t.id FROM tags t JOIN subtree ON t.parent_id = subtree.id
+		WHERE t.deleted_at = (SELECT deleted_at FROM tags WHERE id = ?)
+	)`
+
+// CreateTag implements Store.
+func (s *SQLStore) CreateTag(ctx context.Context, t *Tag) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		parent, name, err := tagParent(ctx, s, t.CollectionID, t.Name)
+		if err != nil {
+			return err
+		}
+		t.Name = name
+		t.ParentID = ""
+		if parent != nil {
+			t.ParentID = parent.ID
+		}
+
+		t.ID = newID()
+		t.CreatedAt = now()
+		t.UpdatedAt = t.CreatedAt
+		slug, err := s.freeSlug(ctx, "tags", "collection_id = ?", []interface{}{t.CollectionID}, t.Name, t.ID)
+		if err != nil {
+			return err
+		}
+		t.Slug = slug
+
+		_, err = s.q.ExecContext(ctx,
+			"INSERT INTO tags ("+tagColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+			t.ID, t.CollectionID, nullIfEmpty(t.ParentID), t.Name, t.Slug, t.CreatedAt, t.UpdatedAt)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("tag %s: %w", t.Name, ErrConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create tag: %w", err)
+		}
+
+		return s.moveSlug(ctx, slugKindTag, t.CollectionID, t.ID, "", t.Slug)
+	})
+}
+
+// GetTag implements Store.
+func (s *SQLStore) GetTag(ctx context.Context, id string) (*Tag, error) {
+	var t Tag
+	row := s.q.QueryRowContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE id = ? AND deleted_at IS NULL", id)
+	if err := scanTag(row, &t); err != nil {
+		return nil, notFound(err, "tag", id)
+	}
+
+	return &t, nil
+}
+
+// GetTagByName implements Store.
+func (s *SQLStore) GetTagByName(ctx context.Context, collectionID, name string) (*Tag, error) {
+	var t Tag
+	row := s.q.QueryRowContext(ctx,
+		"SELECT "+tagColumns+" FROM tags WHERE collection_id = ? AND name = ? COLLATE NOCASE AND deleted_at IS NULL",
+		collectionID, name)
+	if err := scanTag(row, &t); err != nil {
+		return nil, notFound(err, "tag", name)
+	}
+
+	return &t, nil
+}
+
+// ResolveTag implements Store.
+func (s *SQLStore) ResolveTag(ctx context.Context, collectionID, ref string) (*Tag, bool, error) {
+	query := func(where string) func(string) (*Tag, error) {
+		return func(arg string) (*Tag, error) {
+			var t Tag
+			row := s.q.QueryRowContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE collection_id = ? AND deleted_at IS NULL AND "+where, collectionID, arg)
+			if err := scanTag(row, &t); err != nil {
+				return nil, notFound(err, "tag", ref)
+			}
+			return &t, nil
+		}
+	}
+	return resolve(ref,
+		query("id = ?"),
+		func(name string) (*Tag, error) { return s.GetTagByName(ctx, collectionID, name) },
+		query("slug = ?"),
+		query("id = (SELECT target_id FROM slug_redirects WHERE kind = '"+slugKindTag+"' AND scope_id = collection_id AND slug = ?)"))
+}
+
+// ListTags implements Store.
+func (s *SQLStore) ListTags(ctx context.Context, collectionID string, page Page) ([]Tag, error) {
+	query, args := paginate("SELECT "+tagColumns+" FROM tags WHERE collection_id = ? AND deleted_at IS NULL", []interface{}{collectionID}, "id", false, page)
+	return s.queryTags(ctx, query, args...)
+}
+
+// ListChildTags implements Store.
+func (s *SQLStore) ListChildTags(ctx context.Context, collectionID, parentID string, page Page) ([]Tag, error) {
+	query, args := paginate("SELECT "+tagColumns+" FROM tags WHERE collection_id = ? AND parent_id IS ? AND deleted_at IS NULL",
+		[]interface{}{collectionID, nullIfEmpty(parentID)}, "id", false, page)
+	return s.queryTags(ctx, query, args...)
+}
+
+// ListTagSubtree implements Store.
+func (s *SQLStore) ListTagSubtree(ctx context.Context, id string) ([]Tag, error) {
+	tags, err := s.queryTags(ctx,
+		tagSubtree+" SELECT "+tagColumns+" FROM tags WHERE id IN (SELECT id FROM subtree) ORDER BY name", id)
+	if err == nil && len(tags) == 0 {
+		err = fmt.Errorf("tag %s: %w", id, ErrNotFound)
+	}
+	return tags, err
+}
+
+func (s *SQLStore) queryTags(ctx context.Context, query string, args ...interface{}) ([]Tag, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := scanTag(rows, &t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// UpdateTag implements Store.
+func (s *SQLStore) UpdateTag(ctx context.Context, t *Tag) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		subtree, err := s.ListTagSubtree(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		stored := subtree[0]
+		if isBelow(t.Name, stored.Name) {
+			return fmt.Errorf("failed to move tag %s to %s: %w", stored.Name, t.Name, ErrCycle)
+		}
+		parent, name, err := tagParent(ctx, s, stored.CollectionID, t.Name)
+		if err != nil {
+			return err
+		}
+
+		// Descendants keep their place below the tag.
+		for _, d := range subtree {
+			parentID := d.ParentID
+			if d.ID == stored.ID {
+				parentID = ""
+				if parent != nil {
+					parentID = parent.ID
+				}
+			}
+			if err := s.renameTag(ctx, d, name+d.Name[len(stored.Name):], parentID); err != nil {
+				return err
+			}
+		}
+
+		row := s.q.QueryRowContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE id = ?", t.ID)
+		return scanTag(row, t)
+	})
+}
+
+// renameTag gives the stored tag t a new name and parent, and a new slug if
+// the name calls for one.
+func (s *SQLStore) renameTag(ctx context.Context, t Tag, name, parentID string) error {
+	slug := t.Slug
+	if Slugify(name) != Slugify(t.Name) {
+		var err error
+		slug, err = s.freeSlug(ctx, "tags", "collection_id = ?", []interface{}{t.CollectionID}, name, t.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.q.ExecContext(ctx, "UPDATE tags SET name = ?, parent_id = ?, slug = ?, updated_at = ? WHERE id = ?",
+		name, nullIfEmpty(parentID), slug, now(), t.ID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("tag %s: %w", name, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+	return s.moveSlug(ctx, slugKindTag, t.CollectionID, t.ID, t.Slug, slug)
+}
+
+// DeleteTag implements Store.
+func (s *SQLStore) DeleteTag(ctx context.Context, id string) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		subtree, err := s.ListTagSubtree(ctx, id)
+		if err != nil {
+			return err
+		}
+		args := make([]interface{}, len(subtree)+1)
+		args[0] = now()
+		for i, t := range subtree {
+			args[i+1] = t.ID
+		}
+
+		for _, query := range []string{
+			"UPDATE data_points SET deleted_at = ? WHERE deleted_at IS NULL AND tag_id IN " + placeholders(len(subtree)),
+			"UPDATE tags SET deleted_at = ? WHERE id IN " + placeholders(len(subtree)),
+		} {
+			if _, err := s.q.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to delete tag: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+const dataPointColumns = "id, tag_id, value, document_id, chunk_index, chunk_offset, metadata, content_hash, source_id, created_at, updated_at"
+
+func scanDataPoint(row interface{ Scan(...interface{}) error }, dp *DataPoint, extra ...interface{}) error {
+	var documentID, metadata, hash, sourceID sql.NullString
+	var index, offset sql.NullInt64
+	dest := append([]interface{}{&dp.ID, &dp.TagID, &dp.Value, &documentID, &index, &offset, &metadata, &hash, &sourceID, &dp.CreatedAt, &dp.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	dp.ContentHash = hash.String
+	dp.SourceID = sourceID.String
+
+	dp.Chunk = nil
+	if documentID.Valid {
+		dp.Chunk = &ChunkRef{DocumentID: documentID.String, Index: int(index.Int64), Offset: int(offset.Int64)}
+	}
+	dp.Metadata = nil
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &dp.Metadata); err != nil {
+			return fmt.Errorf("metadata of data point %s: %w", dp.ID, err)
+		}
+	}
+	return nil
+}
+
+// metadataColumn returns the value stored in the metadata column of dp.
+func metadataColumn(dp *DataPoint) (interface{}, error) {
+	if len(dp.Metadata) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(dp.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return string(b), nil
+}
+
+// chunkColumns returns the values stored in the chunk columns of dp.
+func chunkColumns(dp *DataPoint) (documentID, index, offset interface{}) {
+	if dp.Chunk == nil {
+		return nil, nil, nil
+	}
+	return dp.Chunk.DocumentID, dp.Chunk.Index, dp.Chunk.Offset
+}
+
+func (s *SQLStore) queryDataPoints(ctx context.Context, query string, args ...interface{}) ([]DataPoint, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data points: %w", err)
+	}
+	defer rows.Close()
+
+	dataPoints := []DataPoint{}
+	for rows.Next() {
+		var dp DataPoint
+		if err := scanDataPoint(rows, &dp); err != nil {
+			return nil, err
+		}
+		dataPoints = append(dataPoints, dp)
+	}
+
+	return dataPoints, rows.Err()
+}
+
+// CreateDataPoint implements Store.
+func (s *SQLStore) CreateDataPoint(ctx context.Context, dp *DataPoint) error {
+	if dp.ID == "" {
+		dp.ID = newID()
+	}
+	dp.ContentHash = ContentHash(dp.Value)
+	dp.CreatedAt = now()
+	dp.UpdatedAt = dp.CreatedAt
+
+	metadata, err := metadataColumn(dp)
+	if err != nil {
+		return fmt.Errorf("failed to create data point: %w", err)
+	}
+	documentID, index, offset := chunkColumns(dp)
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		if err := s.checkUnique(ctx, dp); err != nil {
+			return err
+		}
+		_, err := s.q.ExecContext(ctx, `
+			INSERT INTO data_points (`+dataPointColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			dp.ID, dp.TagID, dp.Value, documentID, index, offset, metadata, dp.ContentHash, nullString(dp.SourceID), dp.CreatedAt, dp.UpdatedAt)
+		if isPrimaryKeyViolation(err) {
+			return fmt.Errorf("data point %s: %w", dp.ID, ErrConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create data point: %w", err)
+		}
+
+		return s.createRevision(ctx, revisionOf(ctx, dp, nil, 0))
+	})
+}
+
+// GetDataPoint implements Store.
+func (s *SQLStore) GetDataPoint(ctx context.Context, id string) (*DataPoint, error) {
+	var dp DataPoint
+	row := s.q.QueryRowContext(ctx, "SELECT "+dataPointColumns+" FROM data_points WHERE id = ? AND deleted_at IS NULL", id)
+	if err := scanDataPoint(row, &dp); err != nil {
+		return nil, notFound(err, "data point", id)
+	}
+
+	return &dp, nil
+}
+
+// ListDataPoints implements Store.
+func (s *SQLStore) ListDataPoints(ctx context.Context, tagID string, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	condition, args := filter.sql("", []interface{}{tagID})
+	query, args := paginate(
+		"SELECT "+dataPointColumns+" FROM data_points WHERE deleted_at IS NULL AND id IN (SELECT data_point_id FROM data_point_tags WHERE tag_id = ?)"+condition,
+		args, "id", false, page)
+	return s.queryDataPoints(ctx, query, args...)
+}
+
+// ListDataPointsByTags implements Store.
+func (s *SQLStore) ListDataPointsByTags(ctx context.Context, tagIDs []string, all bool, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	tagIDs = distinct(tagIDs)
+	if len(tagIDs) == 0 {
+		return []DataPoint{}, nil
+	}
+
+	args := make([]interface{}, len(tagIDs))
+	for i, id := range tagIDs {
+		args[i] = id
+	}
+	matching := "SELECT data_point_id FROM data_point_tags WHERE tag_id IN " + placeholders(len(tagIDs))
+	if all {
+		matching += " GROUP BY data_point_id HAVING COUNT(*) = ?"
+		args = append(args, len(tagIDs))
+	}
+	condition, args := filter.sql("", args)
+	query, args := paginate("SELECT "+dataPointColumns+" FROM data_points WHERE deleted_at IS NULL AND id IN ("+matching+")"+condition, args, "id", false, page)
+	return s.queryDataPoints(ctx, query, args...)
+}
+
+// ListCollectionDataPoints implements Store.
+func (s *SQLStore) ListCollectionDataPoints(ctx context.Context, collectionID string, filter *MetadataFilter, page Page) ([]DataPoint, error) {
+	condition, args := filter.sql("", []interface{}{collectionID})
+	query, args := paginate(
+		"SELECT "+dataPointColumns+" FROM data_points WHERE deleted_at IS NULL AND tag_id IN (SELECT id FROM tags WHERE collection_id = ?)"+condition,
+		args, "id", false, page)
+	return s.queryDataPoints(ctx, query, args...)
+}
+
+// UpdateDataPoint implements Store.
+func (s *SQLStore) UpdateDataPoint(ctx context.Context, dp *DataPoint) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		return tx.(*SQLStore).updateDataPoint(ctx, dp, 0)
+	})
+}
+
+// updateDataPoint stores dp and records a revision restored from the
+// revision restoredFrom, if not 0. It must run in a transaction.
+func (s *SQLStore) updateDataPoint(ctx context.Context, dp *DataPoint, restoredFrom int) error {
+	dp.ContentHash = ContentHash(dp.Value)
+	dp.UpdatedAt = now()
+
+	metadata, err := metadataColumn(dp)
+	if err != nil {
+		return fmt.Errorf("failed to update data point: %w", err)
+	}
+	if err := s.checkUnique(ctx, dp); err != nil {
+		return err
+	}
+	res, err := s.q.ExecContext(ctx,
+		"UPDATE data_points SET tag_id = ?, value = ?, metadata = ?, content_hash = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		dp.TagID, dp.Value, metadata, dp.ContentHash, dp.UpdatedAt, dp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update data point: %w", err)
+	}
+	if err := requireRow(res, "data point", dp.ID); err != nil {
+		return err
+	}
+
+	row := s.q.QueryRowContext(ctx, "SELECT "+dataPointColumns+" FROM data_points WHERE id = ?", dp.ID)
+	if err := scanDataPoint(row, dp); err != nil {
+		return err
+	}
+
+	var previous *Revision
+	var latest Revision
+	row = s.q.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM data_point_revisions WHERE data_point_id = ? ORDER BY number DESC LIMIT 1",
+		dp.ID)
+	switch err := scanRevision(row, &latest); {
+	case err == nil:
+		previous = &latest
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("failed to update data point: %w", err)
+	}
+	if r := revisionOf(ctx, dp, previous, restoredFrom); r != nil {
+		return s.createRevision(ctx, r)
+	}
+	return nil
+}
+
+// checkUnique returns a *DuplicateError if the collection of the tag of dp
+// keeps content unique and another data point has the content of dp.
+func (s *SQLStore) checkUnique(ctx context.Context, dp *DataPoint) error {
+	var id string
+	err := s.q.QueryRowContext(ctx, `
+		SELECT d.id FROM data_points d
+		JOIN tags t ON t.id = d.tag_id
+		JOIN dedupe_settings ds ON ds.collection_id = t.collection_id
+		WHERE ds.unique_content AND t.collection_id = (SELECT collection_id FROM tags WHERE id = ?)
+			AND d.content_hash = ? AND d.id != ? AND d.deleted_at IS NULL
+		ORDER BY d.id LIMIT 1`,
+		dp.TagID, dp.ContentHash, dp.ID).Scan(&id)
+	switch {
+	case err == nil:
+		return &DuplicateError{DataPointID: id}
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	default:
+		return fmt.Errorf("failed to check for duplicate content: %w", err)
+	}
+}
+
+// FindDuplicate implements Store.
+func (s *SQLStore) FindDuplicate(ctx context.Context, collectionID, hash string) (*DataPoint, error) {
+	var dp DataPoint
+	row := s.q.QueryRowContext(ctx, `
+		SELECT `+dataPointColumns+` FROM data_points
+		WHERE content_hash = ? AND deleted_at IS NULL AND tag_id IN (SELECT id FROM tags WHERE collection_id = ?)
+		ORDER BY id LIMIT 1`,
+		hash, collectionID)
+	if err := scanDataPoint(row, &dp); err != nil {
+		return nil, notFound(err, "data point with content", hash)
+	}
+
+	return &dp, nil
+}
+
+// ListDuplicates implements Store.
+func (s *SQLStore) ListDuplicates(ctx context.Context, collectionID string, page Page) ([]DuplicateGroup, error) {
+	query, args := paginate(`
+		SELECT content_hash FROM (
+			SELECT d.content_hash FROM data_points d
+			JOIN tags t ON t.id = d.tag_id
+			WHERE t.collection_id = ? AND d.content_hash IS NOT NULL AND d.deleted_at IS NULL
+			GROUP BY d.content_hash
+			HAVING COUNT(*) > 1
+		)
+		WHERE TRUE`,
+		[]interface{}{collectionID}, "content_hash", false, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []DuplicateGroup{}
+	byHash := map[string]int{}
+	for rows.Next() {
+		var g DuplicateGroup
+		if err := rows.Scan(&g.ContentHash); err != nil {
+			return nil, err
+		}
+		byHash[g.ContentHash] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	args = []interface{}{collectionID}
+	for _, g := range groups {
+		args = append(args, g.ContentHash)
+	}
+	rows, err = s.q.QueryContext(ctx, `
+		SELECT content_hash, id FROM data_points
+		WHERE deleted_at IS NULL AND tag_id IN (SELECT id FROM tags WHERE collection_id = ?)
+			AND content_hash IN `+placeholders(len(groups))+`
+		ORDER BY id`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash, id string
+		if err := rows.Scan(&hash, &id); err != nil {
+			return nil, err
+		}
+		g := &groups[byHash[hash]]
+		g.DataPointIDs = append(g.DataPointIDs, id)
+	}
+
+	return groups, rows.Err()
+}
+
+// HashDataPoints implements Store.
+func (s *SQLStore) HashDataPoints(ctx context.Context) (int, error) {
+	var n int
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		rows, err := s.q.QueryContext(ctx, "SELECT id, value FROM data_points WHERE content_hash IS NULL")
+		if err != nil {
+			return fmt.Errorf("failed to hash data points: %w", err)
+		}
+		hashes := map[string]string{}
+		for rows.Next() {
+			var id, value string
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			hashes[id] = ContentHash(value)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, hash := range hashes {
+			if _, err := s.q.ExecContext(ctx, "UPDATE data_points SET content_hash = ? WHERE id = ?", hash, id); err != nil {
+				return fmt.Errorf("failed to hash data points: %w", err)
+			}
+		}
+		n = len(hashes)
+		return nil
+	})
+	return n, err
+}
+
+// DeleteDataPoint implements Store.
+func (s *SQLStore) DeleteDataPoint(ctx context.Context, id string) error {
+	res, err := s.q.ExecContext(ctx,
+		"UPDATE data_points SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete data point: %w", err)
+	}
+
+	return requireRow(res, "data point", id)
+}
+
+// trashQuery selects the records deleted themselves as kind, id,
+// collection_id, tag_id, name and deleted_at. A tag or data point deleted
+// along with its collection or tag has the same deleted_at. It takes the
+// length of the excerpts naming data points, and its WHERE clause is its
+// last.
+const trashQuery = `
+	SELECT kind, id, collection_id, tag_id, name, deleted_at FROM (
+		SELECT '` + string(TrashCollection) + `' AS kind, c.id, c.id AS collection_id, NULL AS tag_id, c.name, c.deleted_at
+		FROM collections c
+		WHERE c.deleted_at IS NOT NULL
+		UNION ALL
+		SELECT '` + string(TrashTag) + `', t.id, t.collection_id, NULL, t.name, t.deleted_at
+		FROM tags t
+		JOIN collections c ON c.id = t.collection_id
+		LEFT JOIN tags p ON p.id = t.parent_id
+		WHERE t.deleted_at IS NOT NULL AND c.deleted_at IS NOT t.deleted_at AND p.deleted_at IS NOT t.deleted_at
+		UNION ALL
+		SELECT '` + string(TrashDataPoint) + `', d.id, t.collection_id, d.tag_id, substr(d.value, 1, ?), d.deleted_at
+		FROM data_points d
+		JOIN tags t ON t.id = d.tag_id
+		WHERE d.deleted_at IS NOT NULL AND t.deleted_at IS NOT d.deleted_at
+	)
+	WHERE TRUE`
+
+// ListTrash implements Store.
+func (s *SQLStore) ListTrash(ctx context.Context, kind TrashKind, collectionID string, page Page) ([]TrashItem, error) {
+	query, args := trashQuery, []interface{}{trashExcerptLength}
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, kind)
+	}
+	if collectionID != "" {
+		query += " AND collection_id = ?"
+		args = append(args, collectionID)
+	}
+	if page.After != "" {
+		t, err := page.afterTime()
+		if err != nil {
+			return nil, err
+		}
+		query += " AND (deleted_at, id) < (?, ?)"
+		args = append(args, t, page.After)
+	}
+	query += " ORDER BY deleted_at DESC, id DESC"
+	if page.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, page.Limit)
+	}
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		var tagID sql.NullString
+		if err := rows.Scan(&item.Kind, &item.ID, &item.CollectionID, &tagID, &item.Name, &item.DeletedAt); err != nil {
+			return nil, err
+		}
+		item.TagID = tagID.String
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// RestoreCollection implements Store.
+func (s *SQLStore) RestoreCollection(ctx context.Context, id string) (*Collection, error) {
+	var c Collection
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		row := s.q.QueryRowContext(ctx,
+			"SELECT "+collectionColumns+" FROM collections WHERE id = ? AND deleted_at IS NOT NULL", id)
+		if err := scanCollection(row, &c); err != nil {
+			return notFound(err, "collection", id)
+		}
+
+		// The tags and data points deleted along with the collection are
+		// restored first, while its deleted_at tells them apart.
+		err := s.restoreDataPoints(ctx, `
+			SELECT `+dataPointColumns+` FROM data_points
+			WHERE tag_id IN (SELECT id FROM tags WHERE collection_id = ?1)
+				AND deleted_at = (SELECT deleted_at FROM collections WHERE id = ?1)
+			ORDER BY id`, id)
+		if err != nil {
+			return err
+		}
+		_, err = s.q.ExecContext(ctx, `
+			UPDATE tags SET deleted_at = NULL
+			WHERE collection_id = ?1 AND deleted_at = (SELECT deleted_at FROM collections WHERE id = ?1)`, id)
+		if err != nil {
+			return fmt.Errorf("failed to restore collection: %w", err)
+		}
+
+		slug, err := s.restoredSlug(ctx, "collections", "TRUE", nil, c.Name, c.Slug, c.ID)
+		if err != nil {
+			return err
+		}
+		_, err = s.q.ExecContext(ctx, "UPDATE collections SET deleted_at = NULL, slug = ? WHERE id = ?", slug, id)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("collection %s: %w", c.Name, ErrConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore collection: %w", err)
+		}
+		c.Slug = slug
+		return s.moveSlug(ctx, slugKindCollection, "", c.ID, "", c.Slug)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// restoredSlug returns slug, the slug a record had when it was deleted, or
+// a new one for name if another record took it meanwhile. The arguments are
+// like for freeSlug.
+func (s *SQLStore) restoredSlug(ctx context.Context, table, scope string, scopeArgs []interface{}, name, slug, id string) (string, error) {
+	taken, err := s.slugTaken(ctx, table, scope, scopeArgs, slug, id)
+	if err != nil || !taken {
+		return slug, err
+	}
+	return s.freeSlug(ctx, table, scope, scopeArgs, name, id)
+}
+
+// RestoreTag implements Store.
+func (s *SQLStore) RestoreTag(ctx context.Context, id string) (*Tag, error) {
+	var t *Tag
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		var stored Tag
+		var parentDeleted bool
+		row := s.q.QueryRowContext(ctx, `
+			SELECT `+tagColumns+`,
+				EXISTS (SELECT 1 FROM collections c WHERE c.id = t.collection_id AND c.deleted_at IS NOT NULL)
+				OR EXISTS (SELECT 1 FROM tags p WHERE p.id = t.parent_id AND p.deleted_at IS NOT NULL)
+			FROM tags t WHERE id = ? AND deleted_at IS NOT NULL`, id)
+		if err := scanTag(row, &stored, &parentDeleted); err != nil {
+			return notFound(err, "tag", id)
+		}
+		if parentDeleted {
+			return fmt.Errorf("failed to restore tag %s: %w", stored.Name, ErrParentDeleted)
+		}
+
+		subtree, err := s.queryTags(ctx,
+			deletedTagSubtree+" SELECT "+tagColumns+" FROM tags WHERE id IN (SELECT id FROM subtree) ORDER BY name", id, id)
+		if err != nil {
+			return err
+		}
+		err = s.restoreDataPoints(ctx, deletedTagSubtree+`
+			SELECT `+dataPointColumns+` FROM data_points
+			WHERE tag_id IN (SELECT id FROM subtree) AND deleted_at = (SELECT deleted_at FROM tags WHERE id = ?)
+			ORDER BY id`,
+			id, id, id)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range subtree {
+			slug, err := s.restoredSlug(ctx, "tags", "collection_id = ?", []interface{}{d.CollectionID}, d.Name, d.Slug, d.ID)
+			if err != nil {
+				return err
+			}
+			_, err = s.q.ExecContext(ctx, "UPDATE tags SET deleted_at = NULL, slug = ? WHERE id = ?", slug, d.ID)
+			if isUniqueViolation(err) {
+				return fmt.Errorf("tag %s: %w", d.Name, ErrConflict)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore tag: %w", err)
+			}
+			if err := s.moveSlug(ctx, slugKindTag, d.CollectionID, d.ID, "", slug); err != nil {
+				return err
+			}
+		}
+
+		t, err = s.GetTag(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// RestoreDataPoint implements Store.
+func (s *SQLStore) RestoreDataPoint(ctx context.Context, id string) (*DataPoint, error) {
+	var dp *DataPoint
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		var tagDeleted bool
+		err := s.q.QueryRowContext(ctx, `
+			SELECT t.deleted_at IS NOT NULL FROM data_points d JOIN tags t ON t.id = d.tag_id
+			WHERE d.id = ? AND d.deleted_at IS NOT NULL`, id).Scan(&tagDeleted)
+		if err != nil {
+			return notFound(err, "data point", id)
+		}
+		if tagDeleted {
+			return fmt.Errorf("failed to restore data point %s: %w", id, ErrParentDeleted)
+		}
+
+		err = s.restoreDataPoints(ctx, "SELECT "+dataPointColumns+" FROM data_points WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		dp, err = s.GetDataPoint(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
+// restoreDataPoints takes the data points selected by query out of the
+// trash one at a time, so that one duplicating the content of another,
+// restored or not, yields the *DuplicateError of checkUnique.
+func (s *SQLStore) restoreDataPoints(ctx context.Context, query string, args ...interface{}) error {
+	dataPoints, err := s.queryDataPoints(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	for i := range dataPoints {
+		if err := s.checkUnique(ctx, &dataPoints[i]); err != nil {
+			return err
+		}
+		if _, err := s.q.ExecContext(ctx, "UPDATE data_points SET deleted_at = NULL WHERE id = ?", dataPoints[i].ID); err != nil {
+			return fmt.Errorf("failed to restore data point: %w", err)
+		}
+	}
+	return nil
+}
+
+// The records purged by PurgeTrash, taking the cutoff time once for every
+// table they select from. Tags and data points below purged records are
+// purged with them.
+const (
+	purgedCollections = "SELECT id FROM collections WHERE deleted_at < ?"
+	purgedTags        = "SELECT id FROM tags WHERE deleted_at < ? OR collection_id IN (" + purgedCollections + ")"
+	purgedDataPoints  = "SELECT id FROM data_points WHERE deleted_at < ? OR tag_id IN (" + purgedTags + ")"
+)
+
+// PurgeTrash implements Store. Revisions, embeddings, documents and the
+// like go along with their records by the foreign keys.
+func (s *SQLStore) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	before = before.UTC()
+	ids := []string{}
+	err := s.WithTx(ctx, func(tx Store) error {
+		q := tx.(*SQLStore).q
+
+		rows, err := q.QueryContext(ctx, purgedDataPoints+" ORDER BY id", before, before, before)
+		if err != nil {
+			return fmt.Errorf("failed to purge trash: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, purge := range []struct {
+			query string
+			args  int
+		}{
+			{"DELETE FROM data_points WHERE id IN (" + purgedDataPoints + ")", 3},
+			{`DELETE FROM slug_redirects
+			WHERE target_id IN (` + purgedTags + ` UNION ALL ` + purgedCollections + `)
+				OR (kind = '` + slugKindTag + `' AND scope_id IN (` + purgedCollections + `))`, 4},
+			{"DELETE FROM tags WHERE id IN (" + purgedTags + ")", 2},
+			{"DELETE FROM collections WHERE id IN (" + purgedCollections + ")", 1},
+		} {
+			args := make([]interface{}, purge.args)
+			for i := range args {
+				args[i] = before
+			}
+			if _, err := q.ExecContext(ctx, purge.query, args...); err != nil {
+				return fmt.Errorf("failed to purge trash: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+const revisionColumns = "id, data_point_id, number, value, metadata, author, restored_from, diff, created_at"
+
+func scanRevision(row interface{ Scan(...interface{}) error }, r *Revision) error {
+	var metadata, author, diff sql.NullString
+	var restoredFrom sql.NullInt64
+	if err := row.Scan(&r.ID, &r.DataPointID, &r.Number, &r.Value, &metadata, &author, &restoredFrom, &diff, &r.CreatedAt); err != nil {
+		return err
+	}
+
+	r.Author = author.String
+	r.RestoredFrom = int(restoredFrom.Int64)
+	r.Metadata, r.Diff = nil, nil
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &r.Metadata); err != nil {
+			return fmt.Errorf("metadata of revision %s: %w", r.ID, err)
+		}
+	}
+	if diff.Valid {
+		if err := json.Unmarshal([]byte(diff.String), &r.Diff); err != nil {
+			return fmt.Errorf("diff of revision %s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// createRevision stores r, which may be nil.
+func (s *SQLStore) createRevision(ctx context.Context, r *Revision) error {
+	if r == nil {
+		return nil
+	}
+
+	metadata, err := metadataColumn(&DataPoint{Metadata: r.Metadata})
+	if err != nil {
+		return fmt.Errorf("failed to create revision: %w", err)
+	}
+	var diff interface{}
+	if r.Diff != nil {
+		b, err := json.Marshal(r.Diff)
+		if err != nil {
+			return fmt.Errorf("failed to create revision: %w", err)
+		}
+		diff = string(b)
+	}
+	_, err = s.q.ExecContext(ctx, `
+		INSERT INTO data_point_revisions (`+revisionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.DataPointID, r.Number, r.Value, metadata, nullIfEmpty(r.Author), nullIfZero(r.RestoredFrom), diff, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create revision: %w", err)
+	}
+
+	return nil
+}
+
+// ListRevisions implements Store.
+func (s *SQLStore) ListRevisions(ctx context.Context, dataPointID string, page Page) ([]Revision, error) {
+	if _, err := s.GetDataPoint(ctx, dataPointID); err != nil {
+		return nil, err
+	}
+
+	query, args := paginate(
+		"SELECT "+revisionColumns+" FROM data_point_revisions WHERE data_point_id = ?",
+		[]interface{}{dataPointID}, "id", true, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var r Revision
+		if err := scanRevision(rows, &r); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetRevision implements Store.
+func (s *SQLStore) GetRevision(ctx context.Context, dataPointID string, number int) (*Revision, error) {
+	var r Revision
+	row := s.q.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM data_point_revisions WHERE data_point_id = ? AND number = ?",
+		dataPointID, number)
+	if err := scanRevision(row, &r); err != nil {
+		return nil, notFound(err, "revision", fmt.Sprintf("%s/%d", dataPointID, number))
+	}
+
+	return &r, nil
+}
+
+// RestoreRevision implements Store.
+func (s *SQLStore) RestoreRevision(ctx context.Context, dataPointID string, number int) (*DataPoint, error) {
+	var dp *DataPoint
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		r, err := s.GetRevision(ctx, dataPointID, number)
+		if err != nil {
+			return err
+		}
+		if dp, err = s.GetDataPoint(ctx, dataPointID); err != nil {
+			return err
+		}
+		dp.Value, dp.Metadata = r.Value, r.Metadata
+		return s.updateDataPoint(ctx, dp, number)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
+// ListDataPointTags implements Store.
+func (s *SQLStore) ListDataPointTags(ctx context.Context, dataPointID string) ([]Tag, error) {
+	if _, err := s.GetDataPoint(ctx, dataPointID); err != nil {
+		return nil, err
+	}
+	return s.queryTags(ctx,
+		"SELECT "+tagColumns+" FROM tags WHERE deleted_at IS NULL AND id IN (SELECT tag_id FROM data_point_tags WHERE data_point_id = ?) ORDER BY id",
+		dataPointID)
+}
+
+// AddDataPointTag implements Store.
+func (s *SQLStore) AddDataPointTag(ctx context.Context, dataPointID, tagID string) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		dp, err := s.GetDataPoint(ctx, dataPointID)
+		if err != nil {
+			return err
+		}
+		var sameCollection bool
+		err = s.q.QueryRowContext(ctx, `
+			SELECT t.collection_id = home.collection_id FROM tags t, tags home
+			WHERE t.id = ? AND t.deleted_at IS NULL AND home.id = ?`, tagID, dp.TagID).Scan(&sameCollection)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !sameCollection) {
+			return fmt.Errorf("tag %s: %w", tagID, ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to tag data point: %w", err)
+		}
+
+		_, err = s.q.ExecContext(ctx,
+			"INSERT OR IGNORE INTO data_point_tags (data_point_id, tag_id, created_at) VALUES (?, ?, ?)",
+			dataPointID, tagID, now())
+		if err != nil {
+			return fmt.Errorf("failed to tag data point: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveDataPointTag implements Store.
+func (s *SQLStore) RemoveDataPointTag(ctx context.Context, dataPointID, tagID string) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		dp, err := s.GetDataPoint(ctx, dataPointID)
+		if err != nil {
+			return err
+		}
+		if dp.TagID == tagID {
+			return fmt.Errorf("data point %s is filed under tag %s: %w", dataPointID, tagID, ErrConflict)
+		}
+
+		res, err := s.q.ExecContext(ctx,
+			"DELETE FROM data_point_tags WHERE data_point_id = ? AND tag_id = ?", dataPointID, tagID)
+		if err != nil {
+			return fmt.Errorf("failed to untag data point: %w", err)
+		}
+		return requireRow(res, "tag", tagID)
+	})
+}
+
+const documentColumns = "id, collection_id, source, chunk_strategy, chunk_size, chunk_overlap, created_at"
+
+// CreateDocument implements Store.
+func (s *SQLStore) CreateDocument(ctx context.Context, d *Document) error {
+	d.ID = newID()
+	d.CreatedAt = now()
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO documents ("+documentColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		d.ID, d.CollectionID, d.Source, d.ChunkStrategy, d.ChunkSize, d.ChunkOverlap, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create document: %w", err)
+	}
+
+	return nil
+}
+
+// GetDocument implements Store.
+func (s *SQLStore) GetDocument(ctx context.Context, id string) (*Document, error) {
+	var d Document
+	err := s.q.QueryRowContext(ctx, "SELECT "+documentColumns+" FROM documents WHERE id = ?", id).
+		Scan(&d.ID, &d.CollectionID, &d.Source, &d.ChunkStrategy, &d.ChunkSize, &d.ChunkOverlap, &d.CreatedAt)
+	if err != nil {
+		return nil, notFound(err, "document", id)
+	}
+
+	return &d, nil
+}
+
+// ListDocumentDataPoints implements Store.
+func (s *SQLStore) ListDocumentDataPoints(ctx context.Context, documentID string) ([]DataPoint, error) {
+	return s.queryDataPoints(ctx,
+		"SELECT "+dataPointColumns+" FROM data_points WHERE document_id = ? AND deleted_at IS NULL ORDER BY chunk_index",
+		documentID)
+}
+
+const attachmentColumns = "id, document_id, name, content_type, size, created_at"
+
+// CreateAttachment implements Store.
+func (s *SQLStore) CreateAttachment(ctx context.Context, a *Attachment) error {
+	a.ID = newID()
+	a.Size = len(a.Data)
+	a.CreatedAt = now()
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO attachments ("+attachmentColumns+", data) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		a.ID, a.DocumentID, a.Name, a.ContentType, a.Size, a.CreatedAt, a.Data)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	return nil
+}
+
+// GetAttachment implements Store.
+func (s *SQLStore) GetAttachment(ctx context.Context, id string) (*Attachment, error) {
+	var a Attachment
+	err := s.q.QueryRowContext(ctx, "SELECT "+attachmentColumns+", data FROM attachments WHERE id = ?", id).
+		Scan(&a.ID, &a.DocumentID, &a.Name, &a.ContentType, &a.Size, &a.CreatedAt, &a.Data)
+	if err != nil {
+		return nil, notFound(err, "attachment", id)
+	}
+
+	return &a, nil
+}
+
+// ListAttachments implements Store.
+func (s *SQLStore) ListAttachments(ctx context.Context, documentID string) ([]Attachment, error) {
+	rows, err := s.q.QueryContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE document_id = ? ORDER BY id", documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.DocumentID, &a.Name, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// SearchDataPoints implements Store using the data_points_fts index, ranked
+// by BM25.
+func (s *SQLStore) SearchDataPoints(ctx context.Context, collectionID, query string, filter *MetadataFilter, limit int) ([]SearchResult, error) {
+	match := parseSearchQuery(query).fts5()
+	if match == "" {
+		return []SearchResult{}, nil
+	}
+
+	condition, args := filter.sql("d.", []interface{}{snippetOpen, snippetClose, snippetTrail, snippetWords, match, collectionID})
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT d.id, d.tag_id, d.value, d.document_id, d.chunk_index, d.chunk_offset, d.metadata, d.content_hash, d.source_id, d.created_at, d.updated_at,
+			-bm25(data_points_fts),
+			snippet(data_points_fts, 0, ?, ?, ?, ?)
+		FROM data_points_fts
+		JOIN data_points d ON d.id = data_points_fts.data_point_id
+		JOIN tags t ON t.id = d.tag_id
+		WHERE data_points_fts MATCH ? AND t.collection_id = ? AND d.deleted_at IS NULL`+condition+`
+		ORDER BY bm25(data_points_fts)
+		LIMIT ?`,
+		append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search data points: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := scanDataPoint(rows, &r.DataPoint, &r.Score, &r.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// SaveEmbedding implements Store.
+func (s *SQLStore) SaveEmbedding(ctx context.Context, e *Embedding) error {
+	e.CreatedAt = now()
+
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO embeddings (data_point_id, model, dimensions, vector, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (data_point_id, model) DO UPDATE SET
+			dimensions = excluded.dimensions,
+			vector = excluded.vector,
+			created_at = excluded.created_at`,
+		e.DataPointID, e.Model, len(e.Vector), encodeVector(e.Vector), e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save embedding: %w", err)
+	}
+
+	return nil
+}
+
+// ListEmbeddings implements Store.
+func (s *SQLStore) ListEmbeddings(ctx context.Context, model string) ([]Embedding, error) {
+	rows, err := s.q.QueryContext(ctx,
+		"SELECT data_point_id, model, vector, created_at FROM embeddings WHERE model = ? ORDER BY data_point_id",
+		model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	embeddings := []Embedding{}
+	for rows.Next() {
+		var e Embedding
+		var blob []byte
+		if err := rows.Scan(&e.DataPointID, &e.Model, &blob, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Vector, err = decodeVector(blob); err != nil {
+			return nil, fmt.Errorf("embedding of data point %s: %w", e.DataPointID, err)
+		}
+		embeddings = append(embeddings, e)
+	}
+
+	return embeddings, rows.Err()
+}
+
+// GetSearchSettings implements Store.
+func (s *SQLStore) GetSearchSettings(ctx context.Context, collectionID string) (*SearchSettings, error) {
+	var settings SearchSettings
+	err := s.q.QueryRowContext(ctx,
+		"SELECT collection_id, keyword_weight, semantic_weight, rrf_k, updated_at FROM search_settings WHERE collection_id = ?",
+		collectionID).Scan(&settings.CollectionID, &settings.KeywordWeight, &settings.SemanticWeight, &settings.RRFK, &settings.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "search settings of collection", collectionID)
+	}
+
+	return &settings, nil
+}
+
+// SaveSearchSettings implements Store.
+func (s *SQLStore) SaveSearchSettings(ctx context.Context, settings *SearchSettings) error {
+	settings.UpdatedAt = now()
+
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO search_settings (collection_id, keyword_weight, semantic_weight, rrf_k, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (collection_id) DO UPDATE SET
+			keyword_weight = excluded.keyword_weight,
+			semantic_weight = excluded.semantic_weight,
+			rrf_k = excluded.rrf_k,
+			updated_at = excluded.updated_at`,
+		settings.CollectionID, settings.KeywordWeight, settings.SemanticWeight, settings.RRFK, settings.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save search settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetDedupeSettings implements Store.
+func (s *SQLStore) GetDedupeSettings(ctx context.Context, collectionID string) (*DedupeSettings, error) {
+	var settings DedupeSettings
+	var policy sql.NullString
+	err := s.q.QueryRowContext(ctx,
+		"SELECT collection_id, unique_content, policy, updated_at FROM dedupe_settings WHERE collection_id = ?",
+		collectionID).Scan(&settings.CollectionID, &settings.Unique, &policy, &settings.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "dedupe settings of collection", collectionID)
+	}
+	settings.Policy = DedupePolicy(policy.String)
+
+	return &settings, nil
+}
+
+// SaveDedupeSettings implements Store.
+func (s *SQLStore) SaveDedupeSettings(ctx context.Context, settings *DedupeSettings) error {
+	settings.UpdatedAt = now()
+
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		if settings.Unique {
+			duplicates, err := s.ListDuplicates(ctx, settings.CollectionID, Page{Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("collection %s: %w", settings.CollectionID, ErrDuplicatesExist)
+			}
+		}
+
+		var policy interface{}
+		if settings.Policy != "" {
+			policy = string(settings.Policy)
+		}
+		_, err := s.q.ExecContext(ctx, `
+			INSERT INTO dedupe_settings (collection_id, unique_content, policy, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (collection_id) DO UPDATE SET
+				unique_content = excluded.unique_content,
+				policy = excluded.policy,
+				updated_at = excluded.updated_at`,
+			settings.CollectionID, settings.Unique, policy, settings.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save dedupe settings: %w", err)
+		}
+		return nil
+	})
+}
+
+const sourceColumns = `id, collection_id, tag_id, url, document_id, chunk_strategy, chunk_size, chunk_overlap, interval_seconds,
+	etag, last_modified, content_hash, next_fetch_at, last_fetched_at, last_status, last_error, failures, created_at, updated_at`
+
+// scanSource scans a row of sourceColumns into src.
+func scanSource(row interface{ Scan(...interface{}) error }, src *Source) error {
+	var documentID sql.NullString
+	var interval int64
+	var nextFetchAt, lastFetchedAt sql.NullTime
+	err := row.Scan(&src.ID, &src.CollectionID, &src.TagID, &src.URL, &documentID, &src.ChunkStrategy, &src.ChunkSize, &src.ChunkOverlap, &interval,
+		&src.ETag, &src.LastModified, &src.ContentHash, &nextFetchAt, &lastFetchedAt, &src.LastStatus, &src.LastError, &src.Failures, &src.CreatedAt, &src.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	src.DocumentID = documentID.String
+	src.Interval = Duration(time.Duration(interval) * time.Second)
+	src.NextFetchAt, src.LastFetchedAt = nil, nil
+	if nextFetchAt.Valid {
+		src.NextFetchAt = &nextFetchAt.Time
+	}
+	if lastFetchedAt.Valid {
+		src.LastFetchedAt = &lastFetchedAt.Time
+	}
+	return nil
+}
+
+// nullString returns the value stored for s in a nullable text column.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (s *SQLStore) querySources(ctx context.Context, query string, args ...interface{}) ([]Source, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+	defer rows.Close()
+
+	sources := []Source{}
+	for rows.Next() {
+		var src Source
+		if err := scanSource(rows, &src); err != nil {
+			return nil, fmt.Errorf("failed to scan source: %w", err)
+		}
+		sources = append(sources, src)
+	}
+
+	return sources, rows.Err()
+}
+
+// CreateSource implements Store.
+func (s *SQLStore) CreateSource(ctx context.Context, src *Source) error {
+	src.ID = newID()
+	src.CreatedAt = now()
+	src.UpdatedAt = src.CreatedAt
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO sources ("+sourceColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		src.ID, src.CollectionID, src.TagID, src.URL, nullString(src.DocumentID), src.ChunkStrategy, src.ChunkSize, src.ChunkOverlap,
+		int64(time.Duration(src.Interval)/time.Second), src.ETag, src.LastModified, src.ContentHash, src.NextFetchAt, src.LastFetchedAt,
+		src.LastStatus, src.LastError, src.Failures, src.CreatedAt, src.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create source: %w", err)
+	}
+
+	return nil
+}
+
+// GetSource implements Store.
+func (s *SQLStore) GetSource(ctx context.Context, id string) (*Source, error) {
+	var src Source
+	if err := scanSource(s.q.QueryRowContext(ctx, "SELECT "+sourceColumns+" FROM sources WHERE id = ?", id), &src); err != nil {
+		return nil, notFound(err, "source", id)
+	}
+
+	return &src, nil
+}
+
+// ListSources implements Store.
+func (s *SQLStore) ListSources(ctx context.Context, collectionID string, page Page) ([]Source, error) {
+	query, args := paginate("SELECT "+sourceColumns+" FROM sources WHERE (? = '' OR collection_id = ?)",
+		[]interface{}{collectionID, collectionID}, "id", false, page)
+	return s.querySources(ctx, query, args...)
+}
+
+// UpdateSource implements Store.
+func (s *SQLStore) UpdateSource(ctx context.Context, src *Source) error {
+	src.UpdatedAt = now()
+
+	res, err := s.q.ExecContext(ctx, `
+		UPDATE sources SET document_id = ?, interval_seconds = ?, etag = ?, last_modified = ?, content_hash = ?,
+			next_fetch_at = ?, last_fetched_at = ?, last_status = ?, last_error = ?, failures = ?, updated_at = ?
+		WHERE id = ?`,
+		nullString(src.DocumentID), int64(time.Duration(src.Interval)/time.Second), src.ETag, src.LastModified, src.ContentHash,
+		src.NextFetchAt, src.LastFetchedAt, src.LastStatus, src.LastError, src.Failures, src.UpdatedAt, src.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update source: %w", err)
+	}
+
+	return requireRow(res, "source", src.ID)
+}
+
+// DeleteSource implements Store.
+func (s *SQLStore) DeleteSource(ctx context.Context, id string) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*SQLStore)
+
+		res, err := s.q.ExecContext(ctx, "DELETE FROM sources WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete source: %w", err)
+		}
+		if err := requireRow(res, "source", id); err != nil {
+			return err
+		}
+		if _, err := s.q.ExecContext(ctx, "UPDATE data_points SET source_id = NULL WHERE source_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete source: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListDueSources implements Store.
+func (s *SQLStore) ListDueSources(ctx context.Context, t time.Time, limit int) ([]Source, error) {
+	return s.querySources(ctx, `
+		SELECT `+sourceColumns+` FROM sources
+		WHERE next_fetch_at <= ? AND tag_id IN (SELECT id FROM tags WHERE deleted_at IS NULL)
+		ORDER BY next_fetch_at, id LIMIT ?`,
+		t, limit)
+}
+
+// ListSourceDataPoints implements Store.
+func (s *SQLStore) ListSourceDataPoints(ctx context.Context, sourceID string) ([]DataPoint, error) {
+	return s.queryDataPoints(ctx,
+		"SELECT "+dataPointColumns+" FROM data_points WHERE source_id = ? AND deleted_at IS NULL ORDER BY chunk_index, id",
+		sourceID)
+}
+
+const sourceFetchColumns = "id, source_id, status, http_status, error, created, updated, deleted, fetched_at"
+
+// CreateSourceFetch implements Store.
+func (s *SQLStore) CreateSourceFetch(ctx context.Context, f *SourceFetch) error {
+	f.ID = newID()
+	if f.FetchedAt.IsZero() {
+		f.FetchedAt = now()
+	}
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO source_fetches ("+sourceFetchColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		f.ID, f.SourceID, f.Status, f.HTTPStatus, f.Error, f.Created, f.Updated, f.Deleted, f.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record fetch: %w", err)
+	}
+
+	return nil
+}
+
+// ListSourceFetches implements Store.
+func (s *SQLStore) ListSourceFetches(ctx context.Context, sourceID string, page Page) ([]SourceFetch, error) {
+	if _, err := s.GetSource(ctx, sourceID); err != nil {
+		return nil, err
+	}
+
+	query, args := paginate("SELECT "+sourceFetchColumns+" FROM source_fetches WHERE source_id = ?", []interface{}{sourceID}, "id", true, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fetches: %w", err)
+	}
+	defer rows.Close()
+
+	fetches := []SourceFetch{}
+	for rows.Next() {
+		var f SourceFetch
+		err := rows.Scan(&f.ID, &f.SourceID, &f.Status, &f.HTTPStatus, &f.Error, &f.Created, &f.Updated, &f.Deleted, &f.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fetch: %w", err)
+		}
+		fetches = append(fetches, f)
+	}
+
+	return fetches, rows.Err()
+}
+
+const feedColumns = `id, collection_id, tag_id, url, title, full_text, interval_seconds, etag, last_modified,
+	next_fetch_at, last_fetched_at, last_status, last_error, failures, created_at, updated_at`
+
+// scanFeed scans a row of feedColumns into f.
+func scanFeed(row interface{ Scan(...interface{}) error }, f *Feed) error {
+	var interval int64
+	var nextFetchAt, lastFetchedAt sql.NullTime
+	err := row.Scan(&f.ID, &f.CollectionID, &f.TagID, &f.URL, &f.Title, &f.FullText, &interval, &f.ETag, &f.LastModified,
+		&nextFetchAt, &lastFetchedAt, &f.LastStatus, &f.LastError, &f.Failures, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	f.Interval = Duration(time.Duration(interval) * time.Second)
+	f.NextFetchAt, f.LastFetchedAt = nil, nil
+	if nextFetchAt.Valid {
+		f.NextFetchAt = &nextFetchAt.Time
+	}
+	if lastFetchedAt.Valid {
+		f.LastFetchedAt = &lastFetchedAt.Time
+	}
+	return nil
+}
+
+func (s *SQLStore) queryFeeds(ctx context.Context, query string, args ...interface{}) ([]Feed, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feeds: %w", err)
+	}
+	defer rows.Close()
+
+	feeds := []Feed{}
+	for rows.Next() {
+		var f Feed
+		if err := scanFeed(rows, &f); err != nil {
+			return nil, fmt.Errorf("failed to scan feed: %w", err)
+		}
+		feeds = append(feeds, f)
+	}
+
+	return feeds, rows.Err()
+}
+
+// CreateFeed implements Store.
+func (s *SQLStore) CreateFeed(ctx context.Context, f *Feed) error {
+	f.ID = newID()
+	f.CreatedAt = now()
+	f.UpdatedAt = f.CreatedAt
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO feeds ("+feedColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		f.ID, f.CollectionID, f.TagID, f.URL, f.Title, f.FullText, int64(time.Duration(f.Interval)/time.Second), f.ETag, f.LastModified,
+		f.NextFetchAt, f.LastFetchedAt, f.LastStatus, f.LastError, f.Failures, f.CreatedAt, f.UpdatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("feed %s: %w", f.URL, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create feed: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeed implements Store.
+func (s *SQLStore) GetFeed(ctx context.Context, id string) (*Feed, error) {
+	var f Feed
+	if err := scanFeed(s.q.QueryRowContext(ctx, "SELECT "+feedColumns+" FROM feeds WHERE id = ?", id), &f); err != nil {
+		return nil, notFound(err, "feed", id)
+	}
+
+	return &f, nil
+}
+
+// ListFeeds implements Store.
+func (s *SQLStore) ListFeeds(ctx context.Context, collectionID string, page Page) ([]Feed, error) {
+	query, args := paginate("SELECT "+feedColumns+" FROM feeds WHERE (? = '' OR collection_id = ?)",
+		[]interface{}{collectionID, collectionID}, "id", false, page)
+	return s.queryFeeds(ctx, query, args...)
+}
+
+// UpdateFeed implements Store.
+func (s *SQLStore) UpdateFeed(ctx context.Context, f *Feed) error {
+	f.UpdatedAt = now()
+
+	res, err := s.q.ExecContext(ctx, `
+		UPDATE feeds SET title = ?, full_text = ?, interval_seconds = ?, etag = ?, last_modified = ?,
+			next_fetch_at = ?, last_fetched_at = ?, last_status = ?, last_error = ?, failures = ?, updated_at = ?
+		WHERE id = ?`,
+		f.Title, f.FullText, int64(time.Duration(f.Interval)/time.Second), f.ETag, f.LastModified,
+		f.NextFetchAt, f.LastFetchedAt, f.LastStatus, f.LastError, f.Failures, f.UpdatedAt, f.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update feed: %w", err)
+	}
+
+	return requireRow(res, "feed", f.ID)
+}
+
+// DeleteFeed implements Store.
+func (s *SQLStore) DeleteFeed(ctx context.Context, id string) error {
+	res, err := s.q.ExecContext(ctx, "DELETE FROM feeds WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed: %w", err)
+	}
+
+	return requireRow(res, "feed", id)
+}
+
+// ListDueFeeds implements Store.
+func (s *SQLStore) ListDueFeeds(ctx context.Context, t time.Time, limit int) ([]Feed, error) {
+	return s.queryFeeds(ctx, `
+		SELECT `+feedColumns+` FROM feeds
+		WHERE next_fetch_at <= ? AND tag_id IN (SELECT id FROM tags WHERE deleted_at IS NULL)
+		ORDER BY next_fetch_at, id LIMIT ?`,
+		t, limit)
+}
+
+const feedEntryColumns = "id, feed_id, guid, title, link, published_at, data_point_id, created_at"
+
+// scanFeedEntry scans a row of feedEntryColumns into e.
+func scanFeedEntry(row interface{ Scan(...interface{}) error }, e *FeedEntry) error {
+	var publishedAt sql.NullTime
+	var dataPointID sql.NullString
+	if err := row.Scan(&e.ID, &e.FeedID, &e.GUID, &e.Title, &e.Link, &publishedAt, &dataPointID, &e.CreatedAt); err != nil {
+		return err
+	}
+	e.PublishedAt = nil
+	if publishedAt.Valid {
+		e.PublishedAt = &publishedAt.Time
+	}
+	e.DataPointID = dataPointID.String
+	return nil
+}
+
+// GetFeedEntry implements Store.
+func (s *SQLStore) GetFeedEntry(ctx context.Context, feedID, guid string) (*FeedEntry, error) {
+	var e FeedEntry
+	row := s.q.QueryRowContext(ctx, "SELECT "+feedEntryColumns+" FROM feed_entries WHERE feed_id = ? AND guid = ?", feedID, guid)
+	if err := scanFeedEntry(row, &e); err != nil {
+		return nil, notFound(err, "feed entry", guid)
+	}
+
+	return &e, nil
+}
+
+// CreateFeedEntry implements Store.
+func (s *SQLStore) CreateFeedEntry(ctx context.Context, e *FeedEntry) error {
+	e.ID = newID()
+	e.CreatedAt = now()
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO feed_entries ("+feedEntryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		e.ID, e.FeedID, e.GUID, e.Title, e.Link, e.PublishedAt, nullString(e.DataPointID), e.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("feed entry %s: %w", e.GUID, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record feed entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListFeedEntries implements Store.
+func (s *SQLStore) ListFeedEntries(ctx context.Context, feedID string, page Page) ([]FeedEntry, error) {
+	if _, err := s.GetFeed(ctx, feedID); err != nil {
+		return nil, err
+	}
+
+	query, args := paginate("SELECT "+feedEntryColumns+" FROM feed_entries WHERE feed_id = ?", []interface{}{feedID}, "id", true, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []FeedEntry{}
+	for rows.Next() {
+		var e FeedEntry
+		if err := scanFeedEntry(rows, &e); err != nil {
+			return nil, fmt.Errorf("failed to scan feed entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+const jobColumns = "id, status, request, result, error, attempts, max_attempts, progress_done, progress_total, run_at, created_at, updated_at, started_at, finished_at"
+
+// scanJob scans a row of jobColumns into j.
+func scanJob(row interface{ Scan(...interface{}) error }, j *Job) error {
+	var request string
+	var result sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&j.ID, &j.Status, &request, &result, &j.Error, &j.Attempts, &j.MaxAttempts,
+		&j.Progress.Done, &j.Progress.Total, &j.RunAt, &j.CreatedAt, &j.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return err
+	}
+	j.Request = json.RawMessage(request)
+	j.Result = nil
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	j.StartedAt, j.FinishedAt = nil, nil
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return nil
+}
+
+// jobResult returns the value stored in the result column of j.
+func jobResult(j *Job) interface{} {
+	if len(j.Result) == 0 {
+		return nil
+	}
+	return string(j.Result)
+}
+
+// CreateJob implements Store.
+func (s *SQLStore) CreateJob(ctx context.Context, j *Job) error {
+	j.ID = newID()
+	j.CreatedAt = now()
+	j.UpdatedAt = j.CreatedAt
+	if j.RunAt.IsZero() {
+		j.RunAt = j.CreatedAt
+	}
+
+	_, err := s.q.ExecContext(ctx,
+		"INSERT INTO jobs ("+jobColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		j.ID, j.Status, string(j.Request), jobResult(j), j.Error, j.Attempts, j.MaxAttempts,
+		j.Progress.Done, j.Progress.Total, j.RunAt, j.CreatedAt, j.UpdatedAt, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return nil
+}
+
+// GetJob implements Store.
+func (s *SQLStore) GetJob(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	err := scanJob(s.q.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id), &j)
+	if err != nil {
+		return nil, notFound(err, "job", id)
+	}
+
+	return &j, nil
+}
+
+// ListJobs implements Store.
+func (s *SQLStore) ListJobs(ctx context.Context, status JobStatus, page Page) ([]Job, error) {
+	query, args := paginate("SELECT "+jobColumns+" FROM jobs WHERE (? = '' OR status = ?)", []interface{}{status, status}, "id", true, page)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var j Job
+		if err := scanJob(rows, &j); err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// UpdateJob implements Store.
+func (s *SQLStore) UpdateJob(ctx context.Context, j *Job) error {
+	j.UpdatedAt = now()
+
+	res, err := s.q.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, result = ?, error = ?, attempts = ?, max_attempts = ?,
+			progress_done = ?, progress_total = ?, run_at = ?, updated_at = ?, started_at = ?, finished_at = ?
+		WHERE id = ?`,
+		j.Status, jobResult(j), j.Error, j.Attempts, j.MaxAttempts, j.Progress.Done, j.Progress.Total,
+		j.RunAt, j.UpdatedAt, j.StartedAt, j.FinishedAt, j.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	return requireRow(res, "job", j.ID)
+}
+
+// ClaimJob implements Store. The job is selected and updated by a single
+// statement, so concurrent workers never claim the same job.
+func (s *SQLStore) ClaimJob(ctx context.Context) (*Job, error) {
+	t := now()
+
+	var j Job
+	err := scanJob(s.q.QueryRowContext(ctx, `
+		UPDATE jobs SET status = ?, attempts = attempts + 1, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs WHERE status = ? AND run_at <= ? ORDER BY run_at, id LIMIT 1
+		)
+		RETURNING `+jobColumns,
+		JobRunning, t, t, JobQueued, t), &j)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("due job: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	return &j, nil
+}
@@ -0,0 +1,29 @@
+//go:build sqlite_fts5
+
+package collections
+
+import (
+	"cognivaultServer/database"
+	"context"
+	"testing"
+)
+
+func TestSQLStore(t *testing.T) {
+	testStore(t, newTestSQLStore)
+}
+
+// newTestSQLStore returns an SQLStore backed by a migrated in-memory
+// database.
+func newTestSQLStore(t *testing.T) Store {
+	db, err := database.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would open a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLStore(db)
+}
@@ -0,0 +1,264 @@
+package collections
+
+import (
+	"cognivaultServer/apperr"
+	"context"
+	"time"
+)
+
+// ErrNotFound is returned (wrapped) by a Store when a record does not exist.
+var ErrNotFound error = apperr.New(apperr.NotFound, "not_found", "not found")
+
+// ErrConflict is returned (wrapped) by a Store when a record with the same
+// ID, or a collection or tag with the same name, already exists.
+var ErrConflict error = apperr.New(apperr.Conflict, "conflict", "already exists")
+
+// ErrCycle is returned (wrapped) by a Store when a tag would be moved below
+// itself.
+var ErrCycle error = apperr.New(apperr.Conflict, "tag_cycle", "tag cannot be moved below itself")
+
+// ErrParentDeleted is returned (wrapped) by a Store when a record cannot be
+// restored from the trash because its collection or tag is still there.
+var ErrParentDeleted error = apperr.New(apperr.Conflict, "parent_deleted", "the collection or tag it belongs to is deleted")
+
+// ErrDuplicateContent is wrapped by the *DuplicateError a Store returns for a
+// data point duplicating content in a collection that keeps content unique.
+var ErrDuplicateContent error = apperr.New(apperr.Conflict, "duplicate_content", "the content is already stored in the collection")
+
+// ErrDuplicatesExist is returned (wrapped) by a Store when content cannot be
+// made unique in a collection that already holds duplicates.
+var ErrDuplicatesExist error = apperr.New(apperr.Conflict, "duplicates_exist", "the collection holds duplicate content")
+
+// Store persists collections, tags and data points. Implementations assign
+// IDs and timestamps on create and refresh them on update. Listings are
+// ordered by ID unless documented otherwise and windowed by a Page.
+//
+// Collection names, and tag names within a collection, are unique ignoring
+// case; creating or renaming to a taken name yields ErrConflict. Each gets
+// a unique slug derived from its name. A rename that changes the slug keeps
+// the old one resolving to the record.
+//
+// Data point listings and searches take a MetadataFilter selecting the data
+// points by their metadata; a nil filter selects all of them.
+//
+// Deleting a collection, tag or data point moves it to the trash together
+// with the records below it. Records in the trash are left out by every
+// method but the trash ones and do not hold on to their names, until they
+// are restored or purged for good.
+type Store interface {
+	CreateCollection(ctx context.Context, c *Collection) error
+	GetCollection(ctx context.Context, id string) (*Collection, error)
+	GetCollectionByName(ctx context.Context, name string) (*Collection, error)
+	// ResolveCollection finds a collection by ID, name, slug or former
+	// slug, and reports whether ref was a former slug.
+	ResolveCollection(ctx context.Context, ref string) (*Collection, bool, error)
+	ListCollections(ctx context.Context, page Page) ([]Collection, error)
+	// ListCollectionSummaries returns collections with their counts, sorted
+	// by order, descending if desc. Ties are broken by ID. page.AfterValue
+	// is the name, creation or update time of the collection page.After.
+	ListCollectionSummaries(ctx context.Context, order CollectionOrder, desc bool, page Page) ([]CollectionSummary, error)
+	GetCollectionSummary(ctx context.Context, id string) (*CollectionSummary, error)
+	UpdateCollection(ctx context.Context, c *Collection) error
+	// DeleteCollection moves a collection with all of its tags and data
+	// points to the trash.
+	DeleteCollection(ctx context.Context, id string) error
+
+	// CreateTag stores t below the tag named by the path of t.Name without
+	// its last segment, creating missing ancestors, and spells t.Name with
+	// the name of that parent.
+	CreateTag(ctx context.Context, t *Tag) error
+	GetTag(ctx context.Context, id string) (*Tag, error)
+	GetTagByName(ctx context.Context, collectionID, name string) (*Tag, error)
+	// ResolveTag finds a tag of a collection by ID, name, slug or former
+	// slug, and reports whether ref was a former slug.
+	ResolveTag(ctx context.Context, collectionID, ref string) (*Tag, bool, error)
+	ListTags(ctx context.Context, collectionID string, page Page) ([]Tag, error)
+	// ListChildTags returns the children of the tag parentID, or the
+	// top-level tags of the collection if parentID is empty.
+	ListChildTags(ctx context.Context, collectionID, parentID string, page Page) ([]Tag, error)
+	// ListTagSubtree returns a tag followed by all of its descendants,
+	// ordered by name.
+	ListTagSubtree(ctx context.Context, id string) ([]Tag, error)
+	// UpdateTag renames t, which moves it, together with its descendants,
+	// below the tag named by the new path like CreateTag. Moving a tag
+	// below itself yields ErrCycle.
+	UpdateTag(ctx context.Context, t *Tag) error
+	// DeleteTag moves a tag with its descendants and all data points filed
+	// under them to the trash.
+	DeleteTag(ctx context.Context, id string) error
+
+	// CreateDataPoint stores dp, including its link to a document if
+	// dp.Chunk is set. The link cannot be changed by UpdateDataPoint. An ID
+	// is only assigned if dp.ID is empty; a taken ID yields ErrConflict.
+	// Like UpdateDataPoint, it records a Revision made by the author of ctx
+	// and sets dp.ContentHash, yielding a *DuplicateError if the content is
+	// already stored in a collection whose DedupeSettings keep it unique.
+	CreateDataPoint(ctx context.Context, dp *DataPoint) error
+	GetDataPoint(ctx context.Context, id string) (*DataPoint, error)
+	// ListDataPoints returns the data points carrying a tag, whether they
+	// are filed under it or it was added to them.
+	ListDataPoints(ctx context.Context, tagID string, filter *MetadataFilter, page Page) ([]DataPoint, error)
+	// ListDataPointsByTags returns the data points carrying any of tagIDs,
+	// or all of them if all is set.
+	ListDataPointsByTags(ctx context.Context, tagIDs []string, all bool, filter *MetadataFilter, page Page) ([]DataPoint, error)
+	ListCollectionDataPoints(ctx context.Context, collectionID string, filter *MetadataFilter, page Page) ([]DataPoint, error)
+	// UpdateDataPoint stores dp, recording a Revision if its value or
+	// metadata changed.
+	UpdateDataPoint(ctx context.Context, dp *DataPoint) error
+	// FindDuplicate returns the oldest data point of a collection with
+	// content hash hash, or ErrNotFound if there is none.
+	FindDuplicate(ctx context.Context, collectionID, hash string) (*DataPoint, error)
+	// ListDuplicates returns the groups of data points of a collection
+	// sharing their content, ordered by content hash. page.After is the
+	// content hash of the last group of the previous page.
+	ListDuplicates(ctx context.Context, collectionID string, page Page) ([]DuplicateGroup, error)
+	// HashDataPoints sets the content hash of the data points stored
+	// without one and returns their number.
+	HashDataPoints(ctx context.Context) (int, error)
+	// DeleteDataPoint moves a data point to the trash.
+	DeleteDataPoint(ctx context.Context, id string) error
+
+	// ListTrash returns the records that were deleted themselves rather
+	// than along with their collection or tag, most recently deleted first.
+	// An empty kind or collectionID selects records of any. page.AfterValue
+	// is the deletion time of the record page.After.
+	ListTrash(ctx context.Context, kind TrashKind, collectionID string, page Page) ([]TrashItem, error)
+	// RestoreCollection takes a collection out of the trash together with
+	// the records deleted along with it. If another collection took its
+	// name meanwhile, it yields ErrConflict; a taken slug is replaced. A
+	// data point whose content is stored meanwhile in a collection keeping
+	// content unique yields a *DuplicateError.
+	RestoreCollection(ctx context.Context, id string) (*Collection, error)
+	// RestoreTag restores a tag like RestoreCollection. It yields
+	// ErrParentDeleted while its collection or parent is in the trash.
+	RestoreTag(ctx context.Context, id string) (*Tag, error)
+	// RestoreDataPoint restores a data point. It yields ErrParentDeleted
+	// while the tag it is filed under is in the trash and a *DuplicateError
+	// like RestoreCollection.
+	RestoreDataPoint(ctx context.Context, id string) (*DataPoint, error)
+	// PurgeTrash deletes the records moved to the trash before before for
+	// good and returns the IDs of the data points among them.
+	PurgeTrash(ctx context.Context, before time.Time) ([]string, error)
+
+	// ListRevisions returns the revisions of a data point, newest first.
+	ListRevisions(ctx context.Context, dataPointID string, page Page) ([]Revision, error)
+	GetRevision(ctx context.Context, dataPointID string, number int) (*Revision, error)
+	// RestoreRevision sets the value and metadata of a data point back to
+	// those of one of its revisions, which records a new revision, and
+	// returns the data point.
+	RestoreRevision(ctx context.Context, dataPointID string, number int) (*DataPoint, error)
+
+	// ListDataPointTags returns the tags of a data point: the one it is
+	// filed under and those added to it.
+	ListDataPointTags(ctx context.Context, dataPointID string) ([]Tag, error)
+	// AddDataPointTag adds a tag of the same collection to a data point.
+	// Adding a tag the data point already carries does nothing.
+	AddDataPointTag(ctx context.Context, dataPointID, tagID string) error
+	// RemoveDataPointTag removes a tag added to a data point. The tag it is
+	// filed under cannot be removed and yields ErrConflict.
+	RemoveDataPointTag(ctx context.Context, dataPointID, tagID string) error
+	// SearchDataPoints runs a full-text query over the data points of a
+	// collection and returns at most limit results, best match first.
+	SearchDataPoints(ctx context.Context, collectionID, query string, filter *MetadataFilter, limit int) ([]SearchResult, error)
+
+	CreateDocument(ctx context.Context, d *Document) error
+	GetDocument(ctx context.Context, id string) (*Document, error)
+	// ListDocumentDataPoints returns the chunks of a document in order.
+	ListDocumentDataPoints(ctx context.Context, documentID string) ([]DataPoint, error)
+	// CreateAttachment stores a.Data and sets a.Size from it.
+	CreateAttachment(ctx context.Context, a *Attachment) error
+	// GetAttachment returns an attachment including its data.
+	GetAttachment(ctx context.Context, id string) (*Attachment, error)
+	// ListAttachments returns the attachments of a document without their data.
+	ListAttachments(ctx context.Context, documentID string) ([]Attachment, error)
+
+	// SaveEmbedding stores the vector of a data point for e.Model, replacing
+	// any previous one. Embeddings are deleted together with their data point.
+	SaveEmbedding(ctx context.Context, e *Embedding) error
+	// ListEmbeddings returns every stored embedding computed by model.
+	ListEmbeddings(ctx context.Context, model string) ([]Embedding, error)
+
+	// GetSearchSettings returns the hybrid search settings saved for a
+	// collection, or ErrNotFound if it uses the defaults.
+	GetSearchSettings(ctx context.Context, collectionID string) (*SearchSettings, error)
+	SaveSearchSettings(ctx context.Context, settings *SearchSettings) error
+	// GetDedupeSettings returns the dedupe settings saved for a collection,
+	// or ErrNotFound if it has none.
+	GetDedupeSettings(ctx context.Context, collectionID string) (*DedupeSettings, error)
+	// SaveDedupeSettings stores settings. Making content unique yields
+	// ErrDuplicatesExist while the collection holds duplicates.
+	SaveDedupeSettings(ctx context.Context, settings *DedupeSettings) error
+
+	// CreateSource stores a new source.
+	CreateSource(ctx context.Context, src *Source) error
+	GetSource(ctx context.Context, id string) (*Source, error)
+	// ListSources returns the sources of a collection, or of every
+	// collection if collectionID is empty.
+	ListSources(ctx context.Context, collectionID string, page Page) ([]Source, error)
+	UpdateSource(ctx context.Context, src *Source) error
+	// DeleteSource deletes a source with its fetch history. Its data points
+	// are kept.
+	DeleteSource(ctx context.Context, id string) error
+	// ListDueSources returns up to limit sources due to be crawled at t,
+	// the longest due first. Sources whose tag is in the trash are left out.
+	ListDueSources(ctx context.Context, t time.Time, limit int) ([]Source, error)
+	// ListSourceDataPoints returns the data points created from a source in
+	// the order of their chunks.
+	ListSourceDataPoints(ctx context.Context, sourceID string) ([]DataPoint, error)
+	// CreateSourceFetch adds a crawl to the fetch history of its source.
+	CreateSourceFetch(ctx context.Context, f *SourceFetch) error
+	// ListSourceFetches returns the fetch history of a source, newest first.
+	ListSourceFetches(ctx context.Context, sourceID string, page Page) ([]SourceFetch, error)
+
+	// CreateFeed stores a new feed. A feed with the same URL under the same
+	// tag yields ErrConflict.
+	CreateFeed(ctx context.Context, f *Feed) error
+	GetFeed(ctx context.Context, id string) (*Feed, error)
+	// ListFeeds returns the feeds of a collection, or of every collection if
+	// collectionID is empty.
+	ListFeeds(ctx context.Context, collectionID string, page Page) ([]Feed, error)
+	UpdateFeed(ctx context.Context, f *Feed) error
+	// DeleteFeed deletes a feed with the record of its entries. The data
+	// points of the entries are kept.
+	DeleteFeed(ctx context.Context, id string) error
+	// ListDueFeeds returns up to limit feeds due to be polled at t, the
+	// longest due first. Feeds whose tag is in the trash are left out.
+	ListDueFeeds(ctx context.Context, t time.Time, limit int) ([]Feed, error)
+	// GetFeedEntry returns the entry of a feed with the given GUID.
+	GetFeedEntry(ctx context.Context, feedID, guid string) (*FeedEntry, error)
+	// CreateFeedEntry records a stored entry of a feed. An entry with the
+	// same GUID yields ErrConflict.
+	CreateFeedEntry(ctx context.Context, e *FeedEntry) error
+	// ListFeedEntries returns the stored entries of a feed, newest first.
+	ListFeedEntries(ctx context.Context, feedID string, page Page) ([]FeedEntry, error)
+
+	// CreateJob stores a new job. A zero j.RunAt makes it due immediately.
+	CreateJob(ctx context.Context, j *Job) error
+	GetJob(ctx context.Context, id string) (*Job, error)
+	// ListJobs returns the jobs in status, or all jobs if status is empty,
+	// newest first.
+	ListJobs(ctx context.Context, status JobStatus, page Page) ([]Job, error)
+	UpdateJob(ctx context.Context, j *Job) error
+	// ClaimJob marks the queued job that has been due the longest as
+	// running, counts the attempt and returns it. It returns ErrNotFound if
+	// no job is due.
+	ClaimJob(ctx context.Context) (*Job, error)
+
+	// WithTx runs fn against a Store whose changes are committed only if fn
+	// returns nil. Calling WithTx on the Store passed to fn reuses the same
+	// transaction.
+	WithTx(ctx context.Context, fn func(Store) error) error
+}
+
+// distinct returns ids without duplicates, in their original order.
+func distinct(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
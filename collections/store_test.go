@@ -0,0 +1,700 @@
+package collections
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// storeTests are the conformance tests every Store must pass. Each runs
+// against an empty store.
+var storeTests = []struct {
+	name string
+	fn   func(t *testing.T, s Store)
+}{
+	{"Collections", testCollections},
+	{"CollectionSlugs", testCollectionSlugs},
+	{"CollectionSummaries", testCollectionSummaries},
+	{"TagTree", testTagTree},
+	{"DataPoints", testDataPoints},
+	{"DataPointPages", testDataPointPages},
+	{"DataPointTags", testDataPointTags},
+	{"MetadataFilters", testMetadataFilters},
+	{"Revisions", testRevisions},
+	{"Transactions", testTransactions},
+	{"Trash", testTrash},
+	{"Dedupe", testDedupe},
+	{"Jobs", testJobs},
+	{"Feeds", testFeeds},
+}
+
+// testStore runs the conformance tests against the stores returned by
+// newStore.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	for _, tt := range storeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewMemoryStore() })
+}
+
+func mustCollection(t *testing.T, s Store, name string) *Collection {
+	t.Helper()
+	c := &Collection{Name: name}
+	if err := s.CreateCollection(context.Background(), c); err != nil {
+		t.Fatalf("CreateCollection(%q): %v", name, err)
+	}
+	return c
+}
+
+func mustTag(t *testing.T, s Store, collectionID, name string) *Tag {
+	t.Helper()
+	tag := &Tag{CollectionID: collectionID, Name: name}
+	if err := s.CreateTag(context.Background(), tag); err != nil {
+		t.Fatalf("CreateTag(%q): %v", name, err)
+	}
+	return tag
+}
+
+func mustDataPoint(t *testing.T, s Store, tagID, value string, metadata map[string]interface{}) *DataPoint {
+	t.Helper()
+	dp := &DataPoint{TagID: tagID, Value: value, Metadata: metadata}
+	if err := s.CreateDataPoint(context.Background(), dp); err != nil {
+		t.Fatalf("CreateDataPoint(%q): %v", value, err)
+	}
+	return dp
+}
+
+// dataPointValues returns the values of dataPoints in order.
+func dataPointValues(dataPoints []DataPoint) []string {
+	values := make([]string, len(dataPoints))
+	for i, dp := range dataPoints {
+		values[i] = dp.Value
+	}
+	return values
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCollections(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "Research Notes")
+	if c.ID == "" || c.Slug != "research-notes" || c.CreatedAt.IsZero() {
+		t.Fatalf("created collection = %+v", c)
+	}
+
+	got, err := s.GetCollection(ctx, c.ID)
+	if err != nil || got.Name != "Research Notes" {
+		t.Fatalf("GetCollection = %+v, %v", got, err)
+	}
+	if _, err := s.GetCollection(ctx, "01ARZ3NDEKTSV4RRFFQ69G5FAV"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCollection(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := s.CreateCollection(ctx, &Collection{Name: "research notes"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateCollection(same name in other case) error = %v, want ErrConflict", err)
+	}
+
+	for _, ref := range []string{c.ID, "Research Notes", "research-notes"} {
+		got, moved, err := s.ResolveCollection(ctx, ref)
+		if err != nil || moved || got.ID != c.ID {
+			t.Errorf("ResolveCollection(%q) = %+v, %v, %v", ref, got, moved, err)
+		}
+	}
+	if _, _, err := s.ResolveCollection(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveCollection(unknown) error = %v, want ErrNotFound", err)
+	}
+
+	other := mustCollection(t, s, "Other")
+	other.Name = "RESEARCH NOTES"
+	if err := s.UpdateCollection(ctx, other); !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdateCollection(taken name) error = %v, want ErrConflict", err)
+	}
+
+	list, err := s.ListCollections(ctx, Page{Limit: 1})
+	if err != nil || len(list) != 1 || list[0].ID != c.ID {
+		t.Fatalf("ListCollections(first page) = %+v, %v", list, err)
+	}
+	list, err = s.ListCollections(ctx, Page{After: c.ID})
+	if err != nil || len(list) != 1 || list[0].ID != other.ID {
+		t.Fatalf("ListCollections(after first) = %+v, %v", list, err)
+	}
+}
+
+func testCollectionSlugs(t *testing.T, s Store) {
+	ctx := context.Background()
+	a := mustCollection(t, s, "Notes!")
+	b := mustCollection(t, s, "notes?")
+	if a.Slug != "notes" || b.Slug != "notes-2" {
+		t.Fatalf("slugs = %q, %q, want notes, notes-2", a.Slug, b.Slug)
+	}
+
+	a.Name = "Journal"
+	if err := s.UpdateCollection(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Slug != "journal" {
+		t.Errorf("renamed slug = %q, want journal", a.Slug)
+	}
+	got, moved, err := s.ResolveCollection(ctx, "notes")
+	if err != nil || !moved || got.ID != a.ID {
+		t.Errorf("ResolveCollection(former slug) = %+v, %v, %v", got, moved, err)
+	}
+
+	// A new record taking the former slug wins over the redirect.
+	c := mustCollection(t, s, "Notes")
+	got, moved, err = s.ResolveCollection(ctx, "notes")
+	if err != nil || moved || got.ID != c.ID {
+		t.Errorf("ResolveCollection(reused slug) = %+v, %v, %v", got, moved, err)
+	}
+}
+
+func testCollectionSummaries(t *testing.T, s Store) {
+	ctx := context.Background()
+	b := mustCollection(t, s, "b")
+	a := mustCollection(t, s, "a")
+	tag := mustTag(t, s, a.ID, "x/y")
+	mustDataPoint(t, s, tag.ID, "one", nil)
+	mustDataPoint(t, s, tag.ID, "two", nil)
+
+	summary, err := s.GetCollectionSummary(ctx, a.ID)
+	if err != nil || summary.TagCount != 2 || summary.DataPointCount != 2 {
+		t.Fatalf("GetCollectionSummary = %+v, %v, want 2 tags and 2 data points", summary, err)
+	}
+
+	summaries, err := s.ListCollectionSummaries(ctx, OrderByName, false, Page{})
+	if err != nil || len(summaries) != 2 || summaries[0].ID != a.ID || summaries[1].ID != b.ID {
+		t.Fatalf("ListCollectionSummaries(name) = %+v, %v", summaries, err)
+	}
+	summaries, err = s.ListCollectionSummaries(ctx, OrderByName, false, Page{After: a.ID, AfterValue: "a"})
+	if err != nil || len(summaries) != 1 || summaries[0].ID != b.ID {
+		t.Fatalf("ListCollectionSummaries(name, after a) = %+v, %v", summaries, err)
+	}
+	summaries, err = s.ListCollectionSummaries(ctx, OrderByCreated, true, Page{Limit: 1})
+	if err != nil || len(summaries) != 1 || summaries[0].ID != a.ID {
+		t.Fatalf("ListCollectionSummaries(created desc) = %+v, %v", summaries, err)
+	}
+	after := Page{After: a.ID, AfterValue: summaries[0].CreatedAt.Format(time.RFC3339Nano)}
+	summaries, err = s.ListCollectionSummaries(ctx, OrderByCreated, true, after)
+	if err != nil || len(summaries) != 1 || summaries[0].ID != b.ID {
+		t.Fatalf("ListCollectionSummaries(created desc, after a) = %+v, %v", summaries, err)
+	}
+}
+
+func testTagTree(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	leaf := mustTag(t, s, c.ID, "infra/k8s/networking")
+
+	parent, err := s.GetTagByName(ctx, c.ID, "INFRA/K8S")
+	if err != nil {
+		t.Fatalf("GetTagByName(created ancestor): %v", err)
+	}
+	if leaf.ParentID != parent.ID {
+		t.Errorf("ParentID = %q, want %q", leaf.ParentID, parent.ID)
+	}
+	if err := s.CreateTag(ctx, &Tag{CollectionID: c.ID, Name: "Infra/K8s/Networking"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateTag(taken name) error = %v, want ErrConflict", err)
+	}
+
+	top, err := s.ListChildTags(ctx, c.ID, "", Page{})
+	if err != nil || len(top) != 1 || top[0].Name != "infra" {
+		t.Fatalf("ListChildTags(top level) = %+v, %v", top, err)
+	}
+	subtree, err := s.ListTagSubtree(ctx, top[0].ID)
+	if err != nil || len(subtree) != 3 || subtree[2].ID != leaf.ID {
+		t.Fatalf("ListTagSubtree = %+v, %v", subtree, err)
+	}
+
+	top[0].Name = "infra/k8s/networking/infra"
+	if err := s.UpdateTag(ctx, &top[0]); !errors.Is(err, ErrCycle) {
+		t.Errorf("UpdateTag(below itself) error = %v, want ErrCycle", err)
+	}
+
+	parent.Name = "platform"
+	if err := s.UpdateTag(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := s.GetTag(ctx, leaf.ID)
+	if err != nil || moved.Name != "platform/networking" || moved.ParentID != parent.ID {
+		t.Errorf("descendant after move = %+v, %v", moved, err)
+	}
+	got, wasMoved, err := s.ResolveTag(ctx, c.ID, "infra-k8s")
+	if err != nil || !wasMoved || got.ID != parent.ID {
+		t.Errorf("ResolveTag(former slug) = %+v, %v, %v", got, wasMoved, err)
+	}
+}
+
+func testDataPoints(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "t")
+
+	const id = "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+	dp := &DataPoint{ID: id, TagID: tag.ID, Value: "hello", Metadata: map[string]interface{}{"lang": "en"}}
+	if err := s.CreateDataPoint(ctx, dp); err != nil {
+		t.Fatal(err)
+	}
+	if dp.ID != id || dp.ContentHash != ContentHash("hello") {
+		t.Errorf("created data point = %+v", dp)
+	}
+	if err := s.CreateDataPoint(ctx, &DataPoint{ID: id, TagID: tag.ID, Value: "again"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateDataPoint(taken ID) error = %v, want ErrConflict", err)
+	}
+
+	got, err := s.GetDataPoint(ctx, id)
+	if err != nil || got.Value != "hello" || got.Metadata["lang"] != "en" {
+		t.Fatalf("GetDataPoint = %+v, %v", got, err)
+	}
+	got.Metadata["lang"] = "changed"
+	if again, _ := s.GetDataPoint(ctx, id); again.Metadata["lang"] != "en" {
+		t.Error("changing a returned data point changed the stored one")
+	}
+
+	got.Value = "hello world"
+	got.Metadata = nil
+	if err := s.UpdateDataPoint(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetDataPoint(ctx, id)
+	if err != nil || got.Value != "hello world" || len(got.Metadata) != 0 || got.ContentHash != ContentHash("hello world") {
+		t.Errorf("updated data point = %+v, %v", got, err)
+	}
+	if err := s.UpdateDataPoint(ctx, &DataPoint{ID: "01ARZ3NDEKTSV4RRFFQ69G5FAW", TagID: tag.ID, Value: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateDataPoint(missing) error = %v, want ErrNotFound", err)
+	}
+
+	results, err := s.SearchDataPoints(ctx, c.ID, "world", nil, 10)
+	if err != nil || len(results) != 1 || results[0].DataPoint.ID != id {
+		t.Errorf("SearchDataPoints = %+v, %v", results, err)
+	}
+}
+
+func testDataPointPages(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	a := mustTag(t, s, c.ID, "a")
+	b := mustTag(t, s, c.ID, "b")
+	var want []string
+	for _, v := range []string{"1", "2", "3", "4", "5"} {
+		tag := a
+		if v == "5" {
+			tag = b
+		}
+		mustDataPoint(t, s, tag.ID, v, nil)
+		want = append(want, v)
+	}
+
+	var got []string
+	page := Page{Limit: 2}
+	for {
+		dataPoints, err := s.ListCollectionDataPoints(ctx, c.ID, nil, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, dataPointValues(dataPoints)...)
+		if len(dataPoints) < page.Limit {
+			break
+		}
+		page.After = dataPoints[len(dataPoints)-1].ID
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("paged values = %v, want %v", got, want)
+	}
+
+	dataPoints, err := s.ListDataPoints(ctx, a.ID, nil, Page{})
+	if err != nil || len(dataPoints) != 4 {
+		t.Errorf("ListDataPoints(a) = %d data points, %v, want 4", len(dataPoints), err)
+	}
+}
+
+func testDataPointTags(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	a := mustTag(t, s, c.ID, "a")
+	b := mustTag(t, s, c.ID, "b")
+	one := mustDataPoint(t, s, a.ID, "one", nil)
+	mustDataPoint(t, s, a.ID, "two", nil)
+
+	if err := s.AddDataPointTag(ctx, one.ID, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddDataPointTag(ctx, one.ID, b.ID); err != nil {
+		t.Errorf("AddDataPointTag(carried tag) error = %v, want nil", err)
+	}
+	tags, err := s.ListDataPointTags(ctx, one.ID)
+	if err != nil || len(tags) != 2 {
+		t.Fatalf("ListDataPointTags = %+v, %v", tags, err)
+	}
+
+	dataPoints, err := s.ListDataPoints(ctx, b.ID, nil, Page{})
+	if err != nil || !equalStrings(dataPointValues(dataPoints), []string{"one"}) {
+		t.Errorf("ListDataPoints(added tag) = %v, %v", dataPointValues(dataPoints), err)
+	}
+	dataPoints, err = s.ListDataPointsByTags(ctx, []string{a.ID, b.ID}, false, nil, Page{})
+	if err != nil || len(dataPoints) != 2 {
+		t.Errorf("ListDataPointsByTags(any) = %v, %v", dataPointValues(dataPoints), err)
+	}
+	dataPoints, err = s.ListDataPointsByTags(ctx, []string{a.ID, b.ID}, true, nil, Page{})
+	if err != nil || !equalStrings(dataPointValues(dataPoints), []string{"one"}) {
+		t.Errorf("ListDataPointsByTags(all) = %v, %v", dataPointValues(dataPoints), err)
+	}
+
+	if err := s.RemoveDataPointTag(ctx, one.ID, a.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("RemoveDataPointTag(filed under) error = %v, want ErrConflict", err)
+	}
+	if err := s.RemoveDataPointTag(ctx, one.ID, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveDataPointTag(ctx, one.ID, b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveDataPointTag(not carried) error = %v, want ErrNotFound", err)
+	}
+}
+
+// testMetadataFilters checks that stores select the same data points by
+// metadata, which for SQLStore is the SQL rendering of filters and for
+// MemoryStore their Match method.
+func testMetadataFilters(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "t")
+	for value, metadata := range map[string]map[string]interface{}{
+		"ada":   {"author": "Ada", "language": "en", "year": 2021, "draft": true, "page": map[string]interface{}{"title": "Notes"}},
+		"bob":   {"author": "Bob", "language": "de", "year": 1999.5, "draft": false},
+		"carol": {"author": "carol", "year": "2021", "empty": nil},
+		"none":  nil,
+	} {
+		mustDataPoint(t, s, tag.ID, value, metadata)
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`meta.author = "Ada"`, []string{"ada"}},
+		{`meta.author > "B"`, []string{"bob", "carol"}},
+		{`meta.language != "en"`, []string{"bob"}},
+		{`meta.language = null`, []string{"carol", "none"}},
+		{`meta.language != null`, []string{"ada", "bob"}},
+		{`meta.year = 2021`, []string{"ada"}},
+		{`meta.year = "2021"`, []string{"carol"}},
+		{`meta.year < 2000`, []string{"bob"}},
+		{`meta.year > 2000`, []string{"ada", "carol"}},
+		{`meta.draft = true`, []string{"ada"}},
+		{`meta.draft = false`, []string{"bob"}},
+		{`meta.draft = 1`, []string{"ada"}},
+		{`meta.empty = null`, []string{"ada", "bob", "carol", "none"}},
+		{`meta.empty != null`, nil},
+		{`meta.missing != 1`, nil},
+		{`meta.page.title = "Notes"`, []string{"ada"}},
+		{`meta.author.name = null`, []string{"ada", "bob", "carol", "none"}},
+		{`meta.author = "Bob" OR meta.draft = true`, []string{"ada", "bob"}},
+		{`(meta.author = "Bob" OR meta.draft = true) AND meta.year < 2000`, []string{"bob"}},
+	}
+	for _, tt := range tests {
+		f, err := ParseMetadataFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseMetadataFilter(%q): %v", tt.filter, err)
+		}
+		dataPoints, err := s.ListCollectionDataPoints(ctx, c.ID, f, Page{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		got := dataPointValues(dataPoints)
+		sort.Strings(got)
+		if !equalStrings(got, tt.want) {
+			t.Errorf("%s selects %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	f, _ := ParseMetadataFilter(`meta.language = "de"`)
+	results, err := s.SearchDataPoints(ctx, c.ID, "ada OR bob", f, 10)
+	if err != nil || len(results) != 1 || results[0].Value != "bob" {
+		t.Errorf("SearchDataPoints(filtered) = %+v, %v, want bob", results, err)
+	}
+}
+
+func testRevisions(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "t")
+	dp := mustDataPoint(t, s, tag.ID, "first", nil)
+
+	dp.Value = "second"
+	if err := s.UpdateDataPoint(ctx, dp); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := s.ListRevisions(ctx, dp.ID, Page{})
+	if err != nil || len(revisions) != 2 || revisions[0].Number != 2 || revisions[1].Value != "first" {
+		t.Fatalf("ListRevisions = %+v, %v", revisions, err)
+	}
+
+	restored, err := s.RestoreRevision(ctx, dp.ID, 1)
+	if err != nil || restored.Value != "first" {
+		t.Fatalf("RestoreRevision = %+v, %v", restored, err)
+	}
+	revision, err := s.GetRevision(ctx, dp.ID, 3)
+	if err != nil || revision.Value != "first" {
+		t.Errorf("GetRevision(3) = %+v, %v", revision, err)
+	}
+	if _, err := s.GetRevision(ctx, dp.ID, 4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRevision(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTransactions(t *testing.T, s Store) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+	err := s.WithTx(ctx, func(tx Store) error {
+		mustCollection(t, tx, "rolled back")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithTx error = %v, want the error of fn", err)
+	}
+	if _, err := s.GetCollectionByName(ctx, "rolled back"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("collection of rolled back transaction: %v, want ErrNotFound", err)
+	}
+
+	err = s.WithTx(ctx, func(tx Store) error {
+		return tx.WithTx(ctx, func(tx Store) error {
+			mustCollection(t, tx, "committed")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCollectionByName(ctx, "committed"); err != nil {
+		t.Errorf("collection of committed transaction: %v", err)
+	}
+}
+
+func testTrash(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "t")
+	dp := mustDataPoint(t, s, tag.ID, "value", nil)
+	kept := mustDataPoint(t, s, tag.ID, "kept", nil)
+
+	if err := s.DeleteDataPoint(ctx, dp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDataPoint(ctx, dp.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDataPoint(trashed) error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteTag(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreDataPoint(ctx, dp.ID); !errors.Is(err, ErrParentDeleted) {
+		t.Errorf("RestoreDataPoint(tag trashed) error = %v, want ErrParentDeleted", err)
+	}
+
+	items, err := s.ListTrash(ctx, "", c.ID, Page{})
+	if err != nil || len(items) != 2 || items[0].Kind != TrashTag || items[1].Kind != TrashDataPoint {
+		t.Fatalf("ListTrash = %+v, %v", items, err)
+	}
+	items, err = s.ListTrash(ctx, TrashDataPoint, "", Page{})
+	if err != nil || len(items) != 1 || items[0].ID != dp.ID || items[0].Name != "value" {
+		t.Fatalf("ListTrash(data points) = %+v, %v", items, err)
+	}
+
+	// The trashed tag gives up its name until it is restored.
+	taken := mustTag(t, s, c.ID, "t")
+	if _, err := s.RestoreTag(ctx, tag.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("RestoreTag(name taken) error = %v, want ErrConflict", err)
+	}
+	if err := s.DeleteTag(ctx, taken.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTag(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDataPoint(ctx, kept.ID); err != nil {
+		t.Errorf("data point deleted along with its tag not restored: %v", err)
+	}
+	if _, err := s.GetDataPoint(ctx, dp.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("data point deleted by itself restored along with its tag: %v", err)
+	}
+	if _, err := s.RestoreDataPoint(ctx, dp.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteCollection(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.ResolveCollection(ctx, "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveCollection(trashed) error = %v, want ErrNotFound", err)
+	}
+	purged, err := s.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	if err != nil || len(purged) != 2 {
+		t.Fatalf("PurgeTrash = %v, %v, want the 2 data points of the collection", purged, err)
+	}
+	if _, err := s.RestoreCollection(ctx, c.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestoreCollection(purged) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDedupe(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "t")
+	first := mustDataPoint(t, s, tag.ID, "same  content", nil)
+	second := mustDataPoint(t, s, tag.ID, " same content\n", nil)
+
+	found, err := s.FindDuplicate(ctx, c.ID, ContentHash("same content"))
+	if err != nil || found.ID != first.ID {
+		t.Errorf("FindDuplicate = %+v, %v, want the oldest data point", found, err)
+	}
+	groups, err := s.ListDuplicates(ctx, c.ID, Page{})
+	if err != nil || len(groups) != 1 || !equalStrings(groups[0].DataPointIDs, []string{first.ID, second.ID}) {
+		t.Fatalf("ListDuplicates = %+v, %v", groups, err)
+	}
+
+	settings := &DedupeSettings{CollectionID: c.ID, Unique: true}
+	if err := s.SaveDedupeSettings(ctx, settings); !errors.Is(err, ErrDuplicatesExist) {
+		t.Errorf("SaveDedupeSettings(unique with duplicates) error = %v, want ErrDuplicatesExist", err)
+	}
+	if err := s.DeleteDataPoint(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveDedupeSettings(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	var duplicate *DuplicateError
+	err = s.CreateDataPoint(ctx, &DataPoint{TagID: tag.ID, Value: "same content"})
+	if !errors.As(err, &duplicate) || duplicate.DataPointID != first.ID || !errors.Is(err, ErrDuplicateContent) {
+		t.Errorf("CreateDataPoint(duplicate) error = %v, want a DuplicateError naming %s", err, first.ID)
+	}
+	other := mustDataPoint(t, s, tag.ID, "other", nil)
+	other.Value = "same content"
+	if err := s.UpdateDataPoint(ctx, other); !errors.As(err, &duplicate) {
+		t.Errorf("UpdateDataPoint(duplicate) error = %v, want a DuplicateError", err)
+	}
+
+	// Restoring content stored again meanwhile fails and leaves the trash
+	// as it was.
+	_, err = s.RestoreDataPoint(ctx, second.ID)
+	if !errors.As(err, &duplicate) || duplicate.DataPointID != first.ID {
+		t.Errorf("RestoreDataPoint(duplicate) error = %v, want a DuplicateError naming %s", err, first.ID)
+	}
+	if _, err := s.GetDataPoint(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDataPoint(rejected restore) error = %v, want ErrNotFound", err)
+	}
+	archive := mustTag(t, s, c.ID, "archive")
+	archived := mustDataPoint(t, s, archive.ID, "archived", nil)
+	if err := s.DeleteTag(ctx, archive.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustDataPoint(t, s, tag.ID, "archived", nil)
+	if _, err := s.RestoreTag(ctx, archive.ID); !errors.As(err, &duplicate) {
+		t.Errorf("RestoreTag(with a duplicate) error = %v, want a DuplicateError", err)
+	}
+	if _, err := s.GetTag(ctx, archive.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTag(rejected restore) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetDataPoint(ctx, archived.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDataPoint(rejected restore) error = %v, want ErrNotFound", err)
+	}
+
+}
+
+func testJobs(t *testing.T, s Store) {
+	ctx := context.Background()
+	if _, err := s.ClaimJob(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ClaimJob(no jobs) error = %v, want ErrNotFound", err)
+	}
+
+	later := &Job{Status: JobQueued, Request: json.RawMessage(`{}`), MaxAttempts: 3, RunAt: time.Now().Add(time.Hour)}
+	if err := s.CreateJob(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	due := &Job{Status: JobQueued, Request: json.RawMessage(`{"name":"c"}`), MaxAttempts: 3}
+	if err := s.CreateJob(ctx, due); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := s.ClaimJob(ctx)
+	if err != nil || claimed.ID != due.ID || claimed.Status != JobRunning || claimed.Attempts != 1 || claimed.StartedAt == nil {
+		t.Fatalf("ClaimJob = %+v, %v", claimed, err)
+	}
+	if _, err := s.ClaimJob(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ClaimJob(only future jobs) error = %v, want ErrNotFound", err)
+	}
+
+	claimed.Status = JobSucceeded
+	claimed.Result = json.RawMessage(`{"ok":true}`)
+	if err := s.UpdateJob(ctx, claimed); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetJob(ctx, due.ID)
+	if err != nil || got.Status != JobSucceeded || string(got.Request) != `{"name":"c"}` {
+		t.Errorf("GetJob = %+v, %v", got, err)
+	}
+
+	jobs, err := s.ListJobs(ctx, "", Page{})
+	if err != nil || len(jobs) != 2 || jobs[0].ID != due.ID {
+		t.Errorf("ListJobs = %+v, %v, want the newest job first", jobs, err)
+	}
+	jobs, err = s.ListJobs(ctx, JobQueued, Page{})
+	if err != nil || len(jobs) != 1 || jobs[0].ID != later.ID {
+		t.Errorf("ListJobs(queued) = %+v, %v", jobs, err)
+	}
+}
+
+func testFeeds(t *testing.T, s Store) {
+	ctx := context.Background()
+	c := mustCollection(t, s, "c")
+	tag := mustTag(t, s, c.ID, "news")
+	f := &Feed{CollectionID: c.ID, TagID: tag.ID, URL: "https://example.com/feed.xml", Interval: Duration(time.Hour)}
+	f.Schedule(time.Now().Add(-2 * time.Hour))
+	if err := s.CreateFeed(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateFeed(ctx, &Feed{CollectionID: c.ID, TagID: tag.ID, URL: f.URL}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateFeed(same URL and tag) error = %v, want ErrConflict", err)
+	}
+
+	due, err := s.ListDueFeeds(ctx, time.Now(), 10)
+	if err != nil || len(due) != 1 || due[0].ID != f.ID {
+		t.Fatalf("ListDueFeeds = %+v, %v", due, err)
+	}
+
+	entry := &FeedEntry{FeedID: f.ID, GUID: "entry-1", Title: "First"}
+	if err := s.CreateFeedEntry(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateFeedEntry(ctx, &FeedEntry{FeedID: f.ID, GUID: "entry-1"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateFeedEntry(same GUID) error = %v, want ErrConflict", err)
+	}
+	got, err := s.GetFeedEntry(ctx, f.ID, "entry-1")
+	if err != nil || got.ID != entry.ID || got.Title != "First" {
+		t.Errorf("GetFeedEntry = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteTag(ctx, tag.ID); err != nil {
+		t.Fatal(err)
+	}
+	due, err = s.ListDueFeeds(ctx, time.Now(), 10)
+	if err != nil || len(due) != 0 {
+		t.Errorf("ListDueFeeds(tag trashed) = %+v, %v, want none", due, err)
+	}
+}
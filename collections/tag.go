@@ -1,104 +1,57 @@
 package collections
 
 import (
-	"database/sql"
+	"context"
 	"errors"
-	"fmt"
-	"log"
+	"strings"
+	"time"
 )
 
-// Tag represents a tag in the database
+// Tag represents a tag in the database. Tags form trees: the name of a tag
+// is a path such as "infra/k8s/networking", and its parent is the tag
+// named by the path without the last segment, "infra/k8s".
 type Tag struct {
 	ID           string `json:"id"`
 	CollectionID string `json:"collection_id"`
-	Name         string `json:"name"`
-	CreatedAt    string `json:"created_at"`
-	UpdatedAt    string `json:"updated_at"`
+	// ParentID is empty for top-level tags.
+	ParentID  string    `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// CreateTag creates a new tag in the database
-func (t *Tag) CreateTag(db *sql.DB) error {
-	result, err := db.Exec("INSERT INTO tags(collection_id, name) VALUES(?,?, ?)", t.ID, t.CollectionID, t.Name)
-	if err != nil {
-		log.Println(err)
-		return errors.New("failed to create tag")
+// splitTagPath splits the name of a tag into the name of its parent, empty
+// for top-level tags, and its last segment.
+func splitTagPath(name string) (parent, leaf string) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return "", name
 	}
-	return nil
+	return name[:i], name[i+1:]
 }
 
-// GetTagsByCollectionID gets all tags under a collection
-func GetTagsByCollectionID(db *sql.DB, collectionID int) ([]Tag, error) {
-	rows, err := db.Query("SELECT id, collection_id, name FROM tags WHERE collection_id=?", collectionID)
-	if err != nil {
-		log.Println(err)
-		return nil, errors.New("failed to get tags")
-	}
-	defer rows.Close()
-
-	tags := []Tag{}
-	for rows.Next() {
-		var tag Tag
-		err := rows.Scan(&tag.ID, &tag.CollectionID, &tag.Name)
-		if err != nil {
-			log.Println(err)
-			return nil, errors.New("failed to get tags")
-		}
-		tags = append(tags, tag)
-	}
-
-	return tags, nil
-}
-
-// UpdateTag updates a tag in the database
-func UpdateTag(db *sql.DB, tagID int, name string) error {
-	result, err := db.Exec("UPDATE tags SET name=? WHERE id=?", name, tagID)
-	if err != nil {
-		log.Println(err)
-		return errors.New("failed to update tag")
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Println(err)
-		return errors.New("failed to update tag")
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("tag with id %d not found", tagID)
-	}
-	return nil
+// isBelow reports whether the tag named name is a descendant of the tag
+// named ancestor, ignoring case.
+func isBelow(name, ancestor string) bool {
+	return len(name) > len(ancestor) && name[len(ancestor)] == '/' && strings.EqualFold(name[:len(ancestor)], ancestor)
 }
 
-// DeleteTag deletes a tag and all data points under it from the database
-func DeleteTag(db *sql.DB, tagID int) error {
-	tx, err := db.Begin()
-	if err != nil {
-		log.Println(err)
-		return errors.New("failed to delete tag")
+// tagParent returns the parent of the tag of a collection named name, nil
+// for top-level tags, and name spelled with the name of the parent. Missing
+// ancestors are created in store.
+func tagParent(ctx context.Context, store Store, collectionID, name string) (*Tag, string, error) {
+	parentName, leaf := splitTagPath(name)
+	if parentName == "" {
+		return nil, name, nil
 	}
-
-	_, err = tx.Exec("DELETE FROM data_points WHERE tag_id=?", tagID)
-	if err != nil {
-		log.Println(err)
-		tx.Rollback()
-		return errors.New("failed to delete tag")
+	parent, err := store.GetTagByName(ctx, collectionID, parentName)
+	if errors.Is(err, ErrNotFound) {
+		parent = &Tag{CollectionID: collectionID, Name: parentName}
+		err = store.CreateTag(ctx, parent)
 	}
-
-	result, err := tx.Exec("DELETE FROM tags WHERE id=?", tagID)
 	if err != nil {
-		log.Println(err)
-		tx.Rollback()
-		return errors.New("failed to delete tag")
+		return nil, "", err
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Println(err)
-		tx.Rollback()
-		return errors.New("failed to delete tag")
-	}
-	if rowsAffected == 0 {
-		tx.Rollback()
-		return fmt.Errorf("tag with id %d not found", tagID)
-	}
-
-	return tx.Commit()
+	return parent, parent.Name + "/" + leaf, nil
 }
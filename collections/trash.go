@@ -0,0 +1,42 @@
+package collections
+
+import (
+	"time"
+)
+
+// TrashKind is a kind of record that can be in the trash.
+type TrashKind string
+
+const (
+	TrashCollection TrashKind = "collection"
+	TrashTag        TrashKind = "tag"
+	TrashDataPoint  TrashKind = "data_point"
+)
+
+// trashExcerptLength is the number of characters of the value of a data
+// point a TrashItem names it by.
+const trashExcerptLength = 100
+
+// TrashItem is a collection, tag or data point in the trash, with the
+// records deleted along with it.
+type TrashItem struct {
+	Kind         TrashKind `json:"kind"`
+	ID           string    `json:"id"`
+	CollectionID string    `json:"collection_id"`
+	// TagID is the tag a data point is filed under.
+	TagID string `json:"tag_id,omitempty"`
+	// Name is the name of a collection or tag, or the start of the value
+	// of a data point.
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// trashExcerpt returns the start of value that names a data point in the
+// trash.
+func trashExcerpt(value string) string {
+	runes := []rune(value)
+	if len(runes) > trashExcerptLength {
+		return string(runes[:trashExcerptLength])
+	}
+	return value
+}
@@ -0,0 +1,158 @@
+// Package config reads server settings from the environment.
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings of the server.
+type Config struct {
+	// Embedder selects the embedding backend: "hash" (default) or "http".
+	Embedder string
+	// EmbeddingDimensions is the vector size of the hash embedder.
+	EmbeddingDimensions int
+	// EmbeddingsURL is the base URL of an OpenAI-compatible embeddings API.
+	EmbeddingsURL string
+	// EmbeddingsModel is the model requested from the embeddings API.
+	EmbeddingsModel string
+	// EmbeddingsAPIKey is sent as a bearer token to the embeddings API.
+	EmbeddingsAPIKey string
+
+	// FetchTimeout bounds fetching a URL for ingestion.
+	FetchTimeout time.Duration
+	// FetchMaxBytes is the largest response body accepted from a URL.
+	FetchMaxBytes int64
+	// FetchMaxRedirects is the number of redirects followed per URL.
+	FetchMaxRedirects int
+	// FetchContentTypes lists the media types accepted from URLs. Nil keeps
+	// the defaults of the fetch package.
+	FetchContentTypes []string
+	// FetchAllowPrivate allows fetching URLs on loopback and private networks.
+	FetchAllowPrivate bool
+	// FetchUserAgent is sent with every fetch.
+	FetchUserAgent string
+
+	// ImportRoots are the directories files may be ingested from. File
+	// ingestion is disabled without them.
+	ImportRoots []string
+	// ImportMaxBytes is the largest file accepted for ingestion.
+	ImportMaxBytes int64
+	// ImportMaxFiles is the largest number of files imported from a directory.
+	ImportMaxFiles int
+	// ImportContentTypes lists the media types accepted from files. Nil
+	// keeps the defaults of the files package.
+	ImportContentTypes []string
+
+	// JobWorkers is the number of ingestion jobs run at the same time.
+	JobWorkers int
+	// JobMaxAttempts is the number of times a job is tried before it fails.
+	JobMaxAttempts int
+	// JobBackoff is the delay before the first retry of a failed job.
+	JobBackoff time.Duration
+
+	// TrashRetention is how long deleted records are kept in the trash
+	// before they are purged. 0 keeps them until they are restored.
+	TrashRetention time.Duration
+	// TrashPurgeInterval is how often the trash is checked for records to
+	// purge.
+	TrashPurgeInterval time.Duration
+
+	// RecrawlInterval is the time between crawls of fetched URLs whose
+	// ingestion request does not set one. 0 crawls them only on demand.
+	RecrawlInterval time.Duration
+	// RecrawlPollInterval is how often sources and feeds are checked for due
+	// crawls and polls.
+	RecrawlPollInterval time.Duration
+	// FeedInterval is the time between polls of feeds subscribed to without
+	// an interval.
+	FeedInterval time.Duration
+
+	// MaxBodyBytes is the largest request body accepted by the API.
+	MaxBodyBytes int64
+}
+
+// FromEnv reads the configuration from COGNIVAULT_* environment variables,
+// falling back to defaults for unset ones.
+func FromEnv() Config {
+	return Config{
+		Embedder:            getString("COGNIVAULT_EMBEDDER", "hash"),
+		EmbeddingDimensions: getInt("COGNIVAULT_EMBEDDING_DIMENSIONS", 384),
+		EmbeddingsURL:       getString("COGNIVAULT_EMBEDDINGS_URL", "http://localhost:8081/v1"),
+		EmbeddingsModel:     getString("COGNIVAULT_EMBEDDINGS_MODEL", "text-embedding-3-small"),
+		EmbeddingsAPIKey:    os.Getenv("COGNIVAULT_EMBEDDINGS_API_KEY"),
+
+		FetchTimeout:      getDuration("COGNIVAULT_FETCH_TIMEOUT", 30*time.Second),
+		FetchMaxBytes:     int64(getInt("COGNIVAULT_FETCH_MAX_BYTES", 10<<20)),
+		FetchMaxRedirects: getInt("COGNIVAULT_FETCH_MAX_REDIRECTS", 5),
+		FetchContentTypes: getList("COGNIVAULT_FETCH_CONTENT_TYPES", nil),
+		FetchAllowPrivate: getBool("COGNIVAULT_FETCH_ALLOW_PRIVATE", false),
+		FetchUserAgent:    getString("COGNIVAULT_FETCH_USER_AGENT", "CognivaultBot/1.0"),
+
+		ImportRoots:        getList("COGNIVAULT_IMPORT_ROOTS", nil),
+		ImportMaxBytes:     int64(getInt("COGNIVAULT_IMPORT_MAX_BYTES", 10<<20)),
+		ImportMaxFiles:     getInt("COGNIVAULT_IMPORT_MAX_FILES", 1000),
+		ImportContentTypes: getList("COGNIVAULT_IMPORT_CONTENT_TYPES", nil),
+
+		JobWorkers:     getInt("COGNIVAULT_JOB_WORKERS", 2),
+		JobMaxAttempts: getInt("COGNIVAULT_JOB_MAX_ATTEMPTS", 3),
+		JobBackoff:     getDuration("COGNIVAULT_JOB_BACKOFF", 10*time.Second),
+
+		TrashRetention:     getDuration("COGNIVAULT_TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: getDuration("COGNIVAULT_TRASH_PURGE_INTERVAL", time.Hour),
+
+		RecrawlInterval:     getDuration("COGNIVAULT_RECRAWL_INTERVAL", 0),
+		RecrawlPollInterval: getDuration("COGNIVAULT_RECRAWL_POLL_INTERVAL", time.Minute),
+		FeedInterval:        getDuration("COGNIVAULT_FEED_INTERVAL", time.Hour),
+
+		MaxBodyBytes: int64(getInt("COGNIVAULT_MAX_BODY_BYTES", 10<<20)),
+	}
+}
+
+func getString(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getList reads a comma-separated list.
+func getList(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -8,66 +9,53 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DefaultPath is the SQLite file used when no other path is configured.
+const DefaultPath = "./mydb.db"
+
 var db *sql.DB
 
-// ConnectDB connects to the SQLite database and returns a pointer to the database object.
+// Open opens the SQLite database at path with foreign keys enforced. It does
+// not touch the schema; use ConnectDB or a Migrator for that.
+func Open(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+
+	return conn, nil
+}
+
+// ConnectDB connects to the SQLite database, applies any pending migrations
+// and returns a pointer to the database object.
 func ConnectDB() (*sql.DB, error) {
 	var err error
-	db, err = sql.Open("sqlite3", "./mydb.db")
+	db, err = Open(DefaultPath)
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
 
-	err = CreateTables()
+	err = Migrate(context.Background(), db)
 	if err != nil {
-		log.Fatal(err)
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-// createTables creates tables for collections, tags, and data points in the database.
-func CreateTables() error {
-	collectionsTable := `
-		CREATE TABLE IF NOT EXISTS collections (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL
-		);
-	`
-
-	tagsTable := `
-		CREATE TABLE IF NOT EXISTS tags (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			collection_id INTEGER NOT NULL,
-			FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
-		);
-	`
-
-	dataPointsTable := `
-		CREATE TABLE IF NOT EXISTS data_points (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			content TEXT NOT NULL,
-			tag_id INTEGER NOT NULL,
-			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
-		);
-	`
-
-	_, err := db.Exec(collectionsTable)
+// Migrate brings the schema of conn up to the latest migration.
+func Migrate(ctx context.Context, conn *sql.DB) error {
+	migrator, err := NewMigrator(conn)
 	if err != nil {
-		return fmt.Errorf("error creating collections table: %v", err)
+		return err
 	}
 
-	_, err = db.Exec(tagsTable)
+	applied, err := migrator.Up(ctx)
 	if err != nil {
-		return fmt.Errorf("error creating tags table: %v", err)
+		return err
 	}
-
-	_, err = db.Exec(dataPointsTable)
-	if err != nil {
-		return fmt.Errorf("error creating data points table: %v", err)
+	if applied > 0 {
+		log.Printf("Applied %d database migration(s)", applied)
 	}
 
 	return nil
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationFS returns a file system holding the given scripts below
+// "migrations".
+func migrationFS(scripts map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, script := range scripts {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte(script)}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS(map[string]string{
+		"0002_second.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"0001_first.up.sql":    "CREATE TABLE a (id INTEGER);",
+		"0001_first.down.sql":  "DROP TABLE a;",
+		"0002_second.down.sql": "DROP TABLE b;",
+		"README.md":            "not a migration",
+	}), "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Version != 2 || migrations[1].Down != "DROP TABLE b;" {
+		t.Errorf("loadMigrations = %+v", migrations)
+	}
+}
+
+func TestLoadMigrationsRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		scripts map[string]string
+		err     string
+	}{
+		{"gap", map[string]string{
+			"0001_first.up.sql": "SELECT 1;",
+			"0003_third.up.sql": "SELECT 1;",
+		}, "contiguous"},
+		{"not starting at 1", map[string]string{
+			"0002_second.up.sql": "SELECT 1;",
+		}, "contiguous"},
+		{"missing up script", map[string]string{
+			"0001_first.up.sql":    "SELECT 1;",
+			"0002_second.down.sql": "SELECT 1;",
+		}, "has no up script"},
+		{"conflicting names", map[string]string{
+			"0001_first.up.sql":   "SELECT 1;",
+			"0001_other.down.sql": "SELECT 1;",
+		}, "conflicting names"},
+		{"no name", map[string]string{
+			"0001.up.sql": "SELECT 1;",
+		}, "expected NNNN_name"},
+		{"invalid version", map[string]string{
+			"first_table.up.sql": "SELECT 1;",
+		}, "invalid version"},
+	}
+	for _, tt := range tests {
+		_, err := loadMigrations(migrationFS(tt.scripts), "migrations")
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: loadMigrations error = %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// newTestMigrator returns a Migrator running migrations against a new
+// in-memory database.
+func newTestMigrator(t *testing.T, migrations ...Migration) (*Migrator, *sql.DB) {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would open a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for i := range migrations {
+		migrations[i].Version = i + 1
+	}
+	return &Migrator{db: db, migrations: migrations}, db
+}
+
+// tableExists reports whether db has a table named name.
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t,
+		Migration{Name: "a", Up: "CREATE TABLE a (id INTEGER PRIMARY KEY);", Down: "DROP TABLE a;"},
+		Migration{Name: "b", Up: "CREATE TABLE b (id INTEGER PRIMARY KEY);", Down: "DROP TABLE b;"},
+		Migration{Name: "c", Up: "CREATE TABLE c (id INTEGER PRIMARY KEY);"},
+	)
+
+	if n, err := m.Up(ctx); err != nil || n != 3 {
+		t.Fatalf("Up = %d, %v, want 3", n, err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Errorf("Up(again) = %d, %v, want 0", n, err)
+	}
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "cannot be rolled back") {
+		t.Errorf("Down(without down script) error = %v", err)
+	}
+
+	m.migrations[2].Down = "DROP TABLE c;"
+	if n, err := m.Down(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("Down(2) = %d, %v, want 2", n, err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != 1 || tableExists(t, db, "b") || !tableExists(t, db, "a") {
+		t.Errorf("after Down(2): version %d, %v; tables a %v, b %v", v, err, tableExists(t, db, "a"), tableExists(t, db, "b"))
+	}
+	if n, err := m.Down(ctx, 5); err != nil || n != 1 {
+		t.Errorf("Down(more than applied) = %d, %v, want 1", n, err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 3 {
+		t.Errorf("Up(after Down) = %d, %v, want 3", n, err)
+	}
+}
+
+func TestMigratorRefusesModifiedMigrations(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t,
+		Migration{Name: "a", Up: "CREATE TABLE a (id INTEGER PRIMARY KEY);"},
+	)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	applied := m.migrations[0].Checksum()
+
+	m.migrations[0].Up = "CREATE TABLE a (id INTEGER PRIMARY KEY, name TEXT);"
+	m.migrations = append(m.migrations, Migration{Version: 2, Name: "b", Up: "CREATE TABLE b (id INTEGER);"})
+	want := "(applied with checksum " + applied + ", script now has " + m.migrations[0].Checksum() + ")"
+	for name, run := range map[string]func() error{
+		"Up":     func() error { _, err := m.Up(ctx); return err },
+		"Down":   func() error { _, err := m.Down(ctx, 1); return err },
+		"Verify": func() error { return m.Verify(ctx) },
+	} {
+		if err := run(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s error = %v, want one containing %q", name, err, want)
+		}
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Errorf("version = %d after refusing to migrate, want 1", v)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil || len(statuses) != 2 || !statuses[0].Modified || statuses[1].Applied {
+		t.Errorf("Status = %+v, %v", statuses, err)
+	}
+}
+
+func TestMigratorRefusesUnknownVersions(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t,
+		Migration{Name: "a", Up: "CREATE TABLE a (id INTEGER);"},
+		Migration{Name: "b", Up: "CREATE TABLE b (id INTEGER);"},
+	)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.migrations = m.migrations[:1]
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "does not know about") {
+		t.Errorf("Up(older build) error = %v", err)
+	}
+}
+
+func TestMigratorRollsBackFailedMigrations(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t,
+		Migration{Name: "parents", Up: "CREATE TABLE parents (id INTEGER PRIMARY KEY);"},
+		Migration{Name: "broken", Up: "CREATE TABLE broken (id INTEGER); INSERT INTO missing VALUES (1);"},
+	)
+	n, err := m.Up(ctx)
+	if err == nil || n != 1 || !strings.Contains(err.Error(), "2_broken up") {
+		t.Fatalf("Up = %d, %v, want the second migration to fail", n, err)
+	}
+	if v, _ := m.Version(ctx); v != 1 || tableExists(t, db, "broken") {
+		t.Errorf("version %d, table of failed migration %v, want 1 and none", v, tableExists(t, db, "broken"))
+	}
+
+	m.migrations[1] = Migration{Version: 2, Name: "orphans", Up: `
+		CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER NOT NULL REFERENCES parents(id));
+		INSERT INTO children (id, parent_id) VALUES (1, 42);`}
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "foreign key violations") {
+		t.Errorf("Up(orphans) error = %v, want foreign key violations", err)
+	}
+	if v, _ := m.Version(ctx); v != 1 || tableExists(t, db, "children") {
+		t.Errorf("version %d, table of failed migration %v, want 1 and none", v, tableExists(t, db, "children"))
+	}
+
+	// Foreign keys are only off while migrating.
+	var on int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&on); err != nil || on != 1 {
+		t.Errorf("foreign_keys = %d, %v after migrating, want 1", on, err)
+	}
+}
@@ -0,0 +1,20 @@
+-- Schema created by the original database.CreateTables. Existing vault files
+-- already have these tables, so every statement is idempotent.
+CREATE TABLE IF NOT EXISTS collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	collection_id INTEGER NOT NULL,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS data_points (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	content TEXT NOT NULL,
+	tag_id INTEGER NOT NULL,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,39 @@
+-- Text ids cannot be turned back into integers, so rows are renumbered from
+-- their rowid and references are remapped through joins.
+CREATE TABLE old_collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE old_tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	collection_id INTEGER NOT NULL,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE TABLE old_data_points (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	content TEXT NOT NULL,
+	tag_id INTEGER NOT NULL,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+INSERT INTO old_collections (id, name)
+	SELECT rowid, name FROM collections;
+
+INSERT INTO old_tags (id, name, collection_id)
+	SELECT t.rowid, t.name, c.rowid
+	FROM tags t JOIN collections c ON c.id = t.collection_id;
+
+INSERT INTO old_data_points (id, content, tag_id)
+	SELECT d.rowid, d.value, t.rowid
+	FROM data_points d JOIN tags t ON t.id = d.tag_id;
+
+DROP TABLE data_points;
+DROP TABLE tags;
+DROP TABLE collections;
+
+ALTER TABLE old_collections RENAME TO collections;
+ALTER TABLE old_tags RENAME TO tags;
+ALTER TABLE old_data_points RENAME TO data_points;
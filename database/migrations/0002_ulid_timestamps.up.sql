@@ -0,0 +1,47 @@
+-- Rebuild the baseline tables with ULID text ids, timestamps and the value
+-- column used by the collections package. Legacy integer ids are kept as
+-- their decimal text form so existing references stay valid.
+CREATE TABLE new_collections (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE new_tags (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	collection_id TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE TABLE new_data_points (
+	id TEXT PRIMARY KEY,
+	tag_id TEXT NOT NULL,
+	value TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+INSERT INTO new_collections (id, name)
+	SELECT CAST(id AS TEXT), name FROM collections;
+
+INSERT INTO new_tags (id, name, collection_id)
+	SELECT CAST(id AS TEXT), name, CAST(collection_id AS TEXT) FROM tags;
+
+INSERT INTO new_data_points (id, tag_id, value)
+	SELECT CAST(id AS TEXT), CAST(tag_id AS TEXT), content FROM data_points;
+
+DROP TABLE data_points;
+DROP TABLE tags;
+DROP TABLE collections;
+
+ALTER TABLE new_collections RENAME TO collections;
+ALTER TABLE new_tags RENAME TO tags;
+ALTER TABLE new_data_points RENAME TO data_points;
+
+CREATE INDEX idx_tags_collection_id ON tags(collection_id);
+CREATE INDEX idx_data_points_tag_id ON data_points(tag_id);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS data_points_fts_delete;
+DROP TRIGGER IF EXISTS data_points_fts_update;
+DROP TRIGGER IF EXISTS data_points_fts_insert;
+DROP TABLE IF EXISTS data_points_fts;
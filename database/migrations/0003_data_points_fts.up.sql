@@ -0,0 +1,23 @@
+-- Full-text index over data point values. Requires SQLite built with FTS5
+-- (build with `-tags sqlite_fts5`). The index is kept in sync with
+-- data_points by the triggers below.
+CREATE VIRTUAL TABLE data_points_fts USING fts5(
+	value,
+	data_point_id UNINDEXED,
+	tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+INSERT INTO data_points_fts (value, data_point_id)
+	SELECT value, id FROM data_points;
+
+CREATE TRIGGER data_points_fts_insert AFTER INSERT ON data_points BEGIN
+	INSERT INTO data_points_fts (value, data_point_id) VALUES (new.value, new.id);
+END;
+
+CREATE TRIGGER data_points_fts_update AFTER UPDATE OF value ON data_points BEGIN
+	UPDATE data_points_fts SET value = new.value WHERE data_point_id = old.id;
+END;
+
+CREATE TRIGGER data_points_fts_delete AFTER DELETE ON data_points BEGIN
+	DELETE FROM data_points_fts WHERE data_point_id = old.id;
+END;
@@ -0,0 +1,13 @@
+-- One vector per data point and embedding model, stored as little-endian
+-- float32 values.
+CREATE TABLE embeddings (
+	data_point_id TEXT NOT NULL,
+	model TEXT NOT NULL,
+	dimensions INTEGER NOT NULL,
+	vector BLOB NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (data_point_id, model),
+	FOREIGN KEY (data_point_id) REFERENCES data_points(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_embeddings_model ON embeddings(model);
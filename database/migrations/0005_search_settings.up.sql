@@ -0,0 +1,10 @@
+-- Per-collection tuning of hybrid search. Collections without a row use the
+-- server defaults.
+CREATE TABLE search_settings (
+	collection_id TEXT PRIMARY KEY,
+	keyword_weight REAL NOT NULL,
+	semantic_weight REAL NOT NULL,
+	rrf_k INTEGER NOT NULL,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_data_points_document_id;
+
+ALTER TABLE data_points DROP COLUMN chunk_offset;
+ALTER TABLE data_points DROP COLUMN chunk_index;
+ALTER TABLE data_points DROP COLUMN document_id;
+
+DROP TABLE IF EXISTS documents;
@@ -0,0 +1,23 @@
+-- Documents that were split into several data points. Every chunk keeps a
+-- link to its document, its position and its byte offset in the source text.
+CREATE TABLE documents (
+	id TEXT PRIMARY KEY,
+	collection_id TEXT NOT NULL,
+	source TEXT NOT NULL DEFAULT '',
+	chunk_strategy TEXT NOT NULL,
+	chunk_size INTEGER NOT NULL DEFAULT 0,
+	chunk_overlap INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_documents_collection_id ON documents(collection_id);
+
+-- document_id has no foreign key so that the down migration can drop it.
+-- Chunks are removed together with their tag or collection, which also owns
+-- the document.
+ALTER TABLE data_points ADD COLUMN document_id TEXT;
+ALTER TABLE data_points ADD COLUMN chunk_index INTEGER;
+ALTER TABLE data_points ADD COLUMN chunk_offset INTEGER;
+
+CREATE INDEX idx_data_points_document_id ON data_points(document_id, chunk_index);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attachments;
+
+ALTER TABLE data_points DROP COLUMN metadata;
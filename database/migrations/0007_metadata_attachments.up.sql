@@ -0,0 +1,16 @@
+-- Free-form metadata of a data point, stored as a JSON object.
+ALTER TABLE data_points ADD COLUMN metadata TEXT;
+
+-- Files kept alongside a document, such as the raw HTML of a fetched page.
+CREATE TABLE attachments (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	data BLOB NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_attachments_document_id ON attachments(document_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_status_run_at;
+
+DROP TABLE IF EXISTS jobs;
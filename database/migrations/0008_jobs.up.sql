@@ -0,0 +1,20 @@
+-- Background ingestion jobs. Queued jobs are picked up in run_at order;
+-- failed attempts are retried by moving run_at into the future.
+CREATE TABLE jobs (
+	id TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	request TEXT NOT NULL,
+	result TEXT,
+	error TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 1,
+	progress_done INTEGER NOT NULL DEFAULT 0,
+	progress_total INTEGER NOT NULL DEFAULT 0,
+	run_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	started_at DATETIME,
+	finished_at DATETIME
+);
+
+CREATE INDEX idx_jobs_status_run_at ON jobs(status, run_at);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_slug_redirects_target_id;
+DROP TABLE IF EXISTS slug_redirects;
+
+DROP INDEX IF EXISTS idx_tags_collection_slug;
+DROP INDEX IF EXISTS idx_tags_collection_name;
+DROP INDEX IF EXISTS idx_collections_slug;
+DROP INDEX IF EXISTS idx_collections_name;
+
+ALTER TABLE tags DROP COLUMN slug;
+ALTER TABLE collections DROP COLUMN slug;
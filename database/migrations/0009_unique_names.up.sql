@@ -0,0 +1,62 @@
+-- Collection names are unique, and tag names unique within their collection,
+-- regardless of case. Of existing duplicates, the oldest record keeps its
+-- name and the others get their id appended.
+UPDATE collections SET name = name || ' ' || id
+WHERE EXISTS (
+	SELECT 1 FROM collections o
+	WHERE o.name = collections.name COLLATE NOCASE AND o.id < collections.id
+);
+
+UPDATE tags SET name = name || ' ' || id
+WHERE EXISTS (
+	SELECT 1 FROM tags o
+	WHERE o.collection_id = tags.collection_id AND o.name = tags.name COLLATE NOCASE AND o.id < tags.id
+);
+
+-- URL-friendly forms of the names. New slugs are derived by
+-- collections.Slugify; existing rows get an approximation that folds ASCII
+-- case and turns common separators into hyphens. Slugs that are left empty
+-- or with other characters are replaced by the id, and the id is appended
+-- where they are ambiguous.
+ALTER TABLE collections ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+ALTER TABLE tags ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+
+UPDATE collections SET slug = trim(replace(replace(replace(
+	replace(replace(replace(replace(replace(replace(lower(trim(name)),
+		' ', '-'), '_', '-'), '.', '-'), '/', '-'), ':', '-'), ',', '-'),
+	'--', '-'), '--', '-'), '--', '-'), '-');
+UPDATE tags SET slug = trim(replace(replace(replace(
+	replace(replace(replace(replace(replace(replace(lower(trim(name)),
+		' ', '-'), '_', '-'), '.', '-'), '/', '-'), ':', '-'), ',', '-'),
+	'--', '-'), '--', '-'), '--', '-'), '-');
+
+UPDATE collections SET slug = lower(id) WHERE slug = '' OR slug GLOB '*[^a-z0-9-]*';
+UPDATE tags SET slug = lower(id) WHERE slug = '' OR slug GLOB '*[^a-z0-9-]*';
+
+UPDATE collections SET slug = slug || '-' || lower(id)
+WHERE EXISTS (
+	SELECT 1 FROM collections o WHERE o.slug = collections.slug AND o.id < collections.id
+);
+UPDATE tags SET slug = slug || '-' || lower(id)
+WHERE EXISTS (
+	SELECT 1 FROM tags o
+	WHERE o.collection_id = tags.collection_id AND o.slug = tags.slug AND o.id < tags.id
+);
+
+CREATE UNIQUE INDEX idx_collections_name ON collections(name COLLATE NOCASE);
+CREATE UNIQUE INDEX idx_collections_slug ON collections(slug);
+CREATE UNIQUE INDEX idx_tags_collection_name ON tags(collection_id, name COLLATE NOCASE);
+CREATE UNIQUE INDEX idx_tags_collection_slug ON tags(collection_id, slug);
+
+-- Slugs given up by renames, which keep resolving to the renamed record.
+-- scope_id is '' for collections and the collection id for tags.
+CREATE TABLE slug_redirects (
+	kind TEXT NOT NULL,
+	scope_id TEXT NOT NULL,
+	slug TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (kind, scope_id, slug)
+);
+
+CREATE INDEX idx_slug_redirects_target_id ON slug_redirects(target_id);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS data_point_tags_update;
+DROP TRIGGER IF EXISTS data_point_tags_insert;
+DROP INDEX IF EXISTS idx_data_point_tags_tag_id;
+DROP TABLE IF EXISTS data_point_tags;
@@ -0,0 +1,25 @@
+-- The tags carried by each data point: the tag it is filed under, kept in
+-- sync with data_points.tag_id by the triggers below, and any tags added to
+-- it since.
+CREATE TABLE data_point_tags (
+	data_point_id TEXT NOT NULL,
+	tag_id TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (data_point_id, tag_id),
+	FOREIGN KEY (data_point_id) REFERENCES data_points(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_data_point_tags_tag_id ON data_point_tags(tag_id);
+
+INSERT INTO data_point_tags (data_point_id, tag_id, created_at)
+	SELECT id, tag_id, created_at FROM data_points;
+
+CREATE TRIGGER data_point_tags_insert AFTER INSERT ON data_points BEGIN
+	INSERT OR IGNORE INTO data_point_tags (data_point_id, tag_id, created_at) VALUES (new.id, new.tag_id, new.created_at);
+END;
+
+CREATE TRIGGER data_point_tags_update AFTER UPDATE OF tag_id ON data_points WHEN new.tag_id != old.tag_id BEGIN
+	DELETE FROM data_point_tags WHERE data_point_id = old.id AND tag_id = old.tag_id;
+	INSERT OR IGNORE INTO data_point_tags (data_point_id, tag_id, created_at) VALUES (new.id, new.tag_id, new.updated_at);
+END;
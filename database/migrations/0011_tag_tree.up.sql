@@ -0,0 +1,75 @@
+-- Tags form a tree within their collection: a tag named by a path, such as
+-- infra/k8s/networking, is the child of the tag named by the path without
+-- its last segment, infra/k8s, and the name of a child always starts with
+-- the name of its parent. There is no foreign key so that the column can be
+-- dropped again; the store removes subtrees itself.
+ALTER TABLE tags ADD COLUMN parent_id TEXT;
+
+-- Create the missing ancestors of existing tags. An ancestor is named like
+-- its oldest descendant, which decides the case of names that differ only
+-- in case, and gets its creation time and a ULID of that time, whatever
+-- the form of the IDs of existing tags: the 48-bit millisecond timestamp in
+-- Crockford's base 32 followed by 64 random bits in hex, whose digits are
+-- all base 32 digits as well. The placeholder slugs are replaced below.
+WITH RECURSIVE ancestors(collection_id, path, rest, child_id, created_at) AS (
+	SELECT collection_id, substr(name, 1, instr(name, '/') - 1), substr(name, instr(name, '/') + 1), id, created_at
+	FROM tags WHERE instr(name, '/') > 1
+	UNION ALL
+	SELECT collection_id, path || '/' || substr(rest, 1, instr(rest, '/') - 1), substr(rest, instr(rest, '/') + 1), child_id, created_at
+	FROM ancestors WHERE instr(rest, '/') > 1
+),
+oldest AS (
+	SELECT collection_id, path, created_at, '0123456789ABCDEFGHJKMNPQRSTVWXYZ' AS digits,
+		CAST(round((coalesce(julianday(created_at), julianday('now')) - 2440587.5) * 86400000) AS INTEGER) AS ms,
+		row_number() OVER (PARTITION BY collection_id, lower(path) ORDER BY created_at, child_id) AS n
+	FROM ancestors a
+	WHERE NOT EXISTS (SELECT 1 FROM tags t WHERE t.collection_id = a.collection_id AND t.name = a.path COLLATE NOCASE)
+)
+INSERT INTO tags (id, collection_id, name, slug, created_at, updated_at)
+SELECT substr(digits, ((ms >> 45) & 31) + 1, 1) || substr(digits, ((ms >> 40) & 31) + 1, 1) || substr(digits, ((ms >> 35) & 31) + 1, 1) || substr(digits, ((ms >> 30) & 31) + 1, 1) || substr(digits, ((ms >> 25) & 31) + 1, 1)
+	|| substr(digits, ((ms >> 20) & 31) + 1, 1) || substr(digits, ((ms >> 15) & 31) + 1, 1) || substr(digits, ((ms >> 10) & 31) + 1, 1) || substr(digits, ((ms >> 5) & 31) + 1, 1) || substr(digits, ((ms >> 0) & 31) + 1, 1)
+	|| hex(randomblob(8)),
+	collection_id, path, '#' || lower(path), created_at, created_at
+FROM oldest WHERE n = 1;
+
+-- Slugs of the created tags, derived as in 0009_unique_names. They are
+-- worked out aside as they may clash until they are made unique.
+CREATE TABLE new_tag_slugs AS
+SELECT id, collection_id, trim(replace(replace(replace(
+	replace(replace(replace(replace(replace(replace(lower(trim(name)),
+		' ', '-'), '_', '-'), '.', '-'), '/', '-'), ':', '-'), ',', '-'),
+	'--', '-'), '--', '-'), '--', '-'), '-') AS slug
+FROM tags WHERE slug GLOB '#*';
+
+UPDATE new_tag_slugs SET slug = lower(id) WHERE slug = '' OR slug GLOB '*[^a-z0-9-]*';
+UPDATE new_tag_slugs SET slug = slug || '-' || lower(id)
+WHERE EXISTS (
+	SELECT 1 FROM tags o WHERE o.collection_id = new_tag_slugs.collection_id AND o.slug = new_tag_slugs.slug
+) OR EXISTS (
+	SELECT 1 FROM new_tag_slugs o
+	WHERE o.collection_id = new_tag_slugs.collection_id AND o.slug = new_tag_slugs.slug AND o.id < new_tag_slugs.id
+);
+
+UPDATE tags SET slug = (SELECT slug FROM new_tag_slugs n WHERE n.id = tags.id) WHERE slug GLOB '#*';
+DROP TABLE new_tag_slugs;
+
+UPDATE tags SET parent_id = (
+	SELECT p.id FROM tags p
+	WHERE p.collection_id = tags.collection_id
+		AND p.name = substr(tags.name, 1, length(p.name)) COLLATE NOCASE
+		AND substr(tags.name, length(p.name) + 1, 1) = '/'
+		AND instr(substr(tags.name, length(p.name) + 2), '/') = 0
+);
+
+-- Spell the path of every tag like the names of its ancestors, which may
+-- differ in case.
+WITH RECURSIVE paths(id, name) AS (
+	SELECT id, name FROM tags WHERE parent_id IS NULL
+	UNION ALL
+	SELECT t.id, paths.name || substr(t.name, length(paths.name) + 1)
+	FROM tags t JOIN paths ON t.parent_id = paths.id
+)
+UPDATE tags SET name = (SELECT name FROM paths WHERE paths.id = tags.id)
+WHERE parent_id IS NOT NULL;
+
+CREATE INDEX idx_tags_parent_id ON tags(parent_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_data_points_meta_language;
+DROP INDEX IF EXISTS idx_data_points_meta_author;
+DROP INDEX IF EXISTS idx_data_points_meta_source_url;
+
+ALTER TABLE data_points DROP COLUMN meta_language;
+ALTER TABLE data_points DROP COLUMN meta_author;
+ALTER TABLE data_points DROP COLUMN meta_source_url;
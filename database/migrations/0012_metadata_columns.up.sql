@@ -0,0 +1,11 @@
+-- Generated columns exposing frequently filtered metadata fields, so that
+-- metadata filters on them can use an index. Other fields are filtered with
+-- json_extract. The columns have no type, so they compare like the values
+-- json_extract returns.
+ALTER TABLE data_points ADD COLUMN meta_source_url GENERATED ALWAYS AS (json_extract(metadata, '$.source_url')) VIRTUAL;
+ALTER TABLE data_points ADD COLUMN meta_author GENERATED ALWAYS AS (json_extract(metadata, '$.author')) VIRTUAL;
+ALTER TABLE data_points ADD COLUMN meta_language GENERATED ALWAYS AS (json_extract(metadata, '$.language')) VIRTUAL;
+
+CREATE INDEX idx_data_points_meta_source_url ON data_points(meta_source_url);
+CREATE INDEX idx_data_points_meta_author ON data_points(meta_author);
+CREATE INDEX idx_data_points_meta_language ON data_points(meta_language);
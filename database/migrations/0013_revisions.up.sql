@@ -0,0 +1,20 @@
+-- Append-only history of the value and metadata of each data point. diff is
+-- the JSON encoded change from the previous revision, NULL for the first.
+CREATE TABLE data_point_revisions (
+	id TEXT PRIMARY KEY,
+	data_point_id TEXT NOT NULL,
+	number INTEGER NOT NULL,
+	value TEXT NOT NULL,
+	metadata TEXT,
+	author TEXT,
+	restored_from INTEGER,
+	diff TEXT,
+	created_at DATETIME NOT NULL,
+	UNIQUE (data_point_id, number),
+	FOREIGN KEY (data_point_id) REFERENCES data_points(id) ON DELETE CASCADE
+);
+
+-- The current state of existing data points becomes their first revision.
+INSERT INTO data_point_revisions (id, data_point_id, number, value, metadata, created_at)
+	SELECT substr(id, 1, 10) || hex(randomblob(8)), id, 1, value, metadata, updated_at
+	FROM data_points;
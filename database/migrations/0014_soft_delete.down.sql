@@ -0,0 +1,45 @@
+-- Records in the trash are deleted for good, as they could clash with the
+-- names of the others. Migrations run without foreign keys, so their
+-- dependent rows are deleted here too.
+CREATE TEMP TABLE purged_collections AS SELECT id FROM collections WHERE deleted_at IS NOT NULL;
+CREATE TEMP TABLE purged_tags AS SELECT id FROM tags
+	WHERE deleted_at IS NOT NULL OR collection_id IN (SELECT id FROM purged_collections);
+CREATE TEMP TABLE purged_data_points AS SELECT id FROM data_points
+	WHERE deleted_at IS NOT NULL OR tag_id IN (SELECT id FROM purged_tags);
+
+DELETE FROM data_point_revisions WHERE data_point_id IN (SELECT id FROM purged_data_points);
+DELETE FROM embeddings WHERE data_point_id IN (SELECT id FROM purged_data_points);
+DELETE FROM data_point_tags WHERE data_point_id IN (SELECT id FROM purged_data_points)
+	OR tag_id IN (SELECT id FROM purged_tags);
+DELETE FROM data_points WHERE id IN (SELECT id FROM purged_data_points);
+DELETE FROM slug_redirects WHERE target_id IN (SELECT id FROM purged_tags UNION ALL SELECT id FROM purged_collections)
+	OR (kind = 'tag' AND scope_id IN (SELECT id FROM purged_collections));
+DELETE FROM tags WHERE id IN (SELECT id FROM purged_tags);
+DELETE FROM attachments WHERE document_id IN (
+	SELECT id FROM documents WHERE collection_id IN (SELECT id FROM purged_collections)
+);
+DELETE FROM documents WHERE collection_id IN (SELECT id FROM purged_collections);
+DELETE FROM search_settings WHERE collection_id IN (SELECT id FROM purged_collections);
+DELETE FROM collections WHERE id IN (SELECT id FROM purged_collections);
+
+DROP TABLE purged_data_points;
+DROP TABLE purged_tags;
+DROP TABLE purged_collections;
+
+DROP INDEX IF EXISTS idx_data_points_deleted_at;
+DROP INDEX IF EXISTS idx_tags_deleted_at;
+DROP INDEX IF EXISTS idx_collections_deleted_at;
+
+DROP INDEX IF EXISTS idx_tags_collection_slug;
+DROP INDEX IF EXISTS idx_tags_collection_name;
+DROP INDEX IF EXISTS idx_collections_slug;
+DROP INDEX IF EXISTS idx_collections_name;
+
+CREATE UNIQUE INDEX idx_collections_name ON collections(name COLLATE NOCASE);
+CREATE UNIQUE INDEX idx_collections_slug ON collections(slug);
+CREATE UNIQUE INDEX idx_tags_collection_name ON tags(collection_id, name COLLATE NOCASE);
+CREATE UNIQUE INDEX idx_tags_collection_slug ON tags(collection_id, slug);
+
+ALTER TABLE data_points DROP COLUMN deleted_at;
+ALTER TABLE tags DROP COLUMN deleted_at;
+ALTER TABLE collections DROP COLUMN deleted_at;
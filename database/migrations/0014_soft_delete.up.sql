@@ -0,0 +1,23 @@
+-- Deleting a collection, tag or data point moves it to the trash by setting
+-- deleted_at, from where it can be restored until it is purged. The records
+-- taken along, such as the tags and data points of a collection, get the
+-- same deleted_at, so that restoring it brings them back too.
+ALTER TABLE collections ADD COLUMN deleted_at DATETIME;
+ALTER TABLE tags ADD COLUMN deleted_at DATETIME;
+ALTER TABLE data_points ADD COLUMN deleted_at DATETIME;
+
+-- Names and slugs only need to be unique among records that are not in the
+-- trash, so that deleting a record frees them.
+DROP INDEX idx_collections_name;
+DROP INDEX idx_collections_slug;
+DROP INDEX idx_tags_collection_name;
+DROP INDEX idx_tags_collection_slug;
+
+CREATE UNIQUE INDEX idx_collections_name ON collections(name COLLATE NOCASE) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_collections_slug ON collections(slug) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_tags_collection_name ON tags(collection_id, name COLLATE NOCASE) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_tags_collection_slug ON tags(collection_id, slug) WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_collections_deleted_at ON collections(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_tags_deleted_at ON tags(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_data_points_deleted_at ON data_points(deleted_at) WHERE deleted_at IS NOT NULL;
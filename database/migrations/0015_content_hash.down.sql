@@ -0,0 +1,5 @@
+DROP TABLE dedupe_settings;
+
+DROP INDEX idx_data_points_content_hash;
+
+ALTER TABLE data_points DROP COLUMN content_hash;
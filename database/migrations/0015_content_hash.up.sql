@@ -0,0 +1,16 @@
+-- Hash of the whitespace-normalized value of each data point, used to find
+-- duplicate content. Existing data points are hashed by the server on its
+-- next start, since SQLite has no SHA-256.
+ALTER TABLE data_points ADD COLUMN content_hash TEXT;
+
+CREATE INDEX idx_data_points_content_hash ON data_points(content_hash);
+
+-- Per-collection handling of duplicate content. Collections without a row
+-- allow duplicates.
+CREATE TABLE dedupe_settings (
+	collection_id TEXT PRIMARY KEY,
+	unique_content INTEGER NOT NULL DEFAULT 0,
+	policy TEXT,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
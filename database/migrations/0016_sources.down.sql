@@ -0,0 +1,7 @@
+DROP INDEX idx_data_points_source_id;
+
+ALTER TABLE data_points DROP COLUMN source_id;
+
+DROP TABLE source_fetches;
+
+DROP TABLE sources;
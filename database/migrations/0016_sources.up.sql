@@ -0,0 +1,53 @@
+-- Fetched URLs that can be crawled again. etag and last_modified are the
+-- validators of the last download, sent with conditional requests;
+-- next_fetch_at is NULL for sources only crawled on demand.
+CREATE TABLE sources (
+	id TEXT PRIMARY KEY,
+	collection_id TEXT NOT NULL,
+	tag_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	document_id TEXT,
+	chunk_strategy TEXT NOT NULL,
+	chunk_size INTEGER NOT NULL,
+	chunk_overlap INTEGER NOT NULL,
+	interval_seconds INTEGER NOT NULL DEFAULT 0,
+	etag TEXT NOT NULL DEFAULT '',
+	last_modified TEXT NOT NULL DEFAULT '',
+	content_hash TEXT NOT NULL,
+	next_fetch_at DATETIME,
+	last_fetched_at DATETIME,
+	last_status TEXT NOT NULL DEFAULT '',
+	last_error TEXT NOT NULL DEFAULT '',
+	failures INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+	FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_sources_collection_id ON sources(collection_id);
+CREATE INDEX idx_sources_next_fetch_at ON sources(next_fetch_at) WHERE next_fetch_at IS NOT NULL;
+
+-- The history of crawls of each source.
+CREATE TABLE source_fetches (
+	id TEXT PRIMARY KEY,
+	source_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	http_status INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created INTEGER NOT NULL DEFAULT 0,
+	updated INTEGER NOT NULL DEFAULT 0,
+	deleted INTEGER NOT NULL DEFAULT 0,
+	fetched_at DATETIME NOT NULL,
+	FOREIGN KEY (source_id) REFERENCES sources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_source_fetches_source_id ON source_fetches(source_id, id);
+
+-- The source a data point was created from. It is cleared by the store when
+-- the source is deleted rather than by a foreign key, which SQLite would not
+-- let the down migration drop.
+ALTER TABLE data_points ADD COLUMN source_id TEXT;
+
+CREATE INDEX idx_data_points_source_id ON data_points(source_id) WHERE source_id IS NOT NULL;
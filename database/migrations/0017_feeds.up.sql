@@ -0,0 +1,46 @@
+-- RSS and Atom feeds whose entries are filed under a tag. etag and
+-- last_modified are the validators of the last download, sent with
+-- conditional requests; next_fetch_at is NULL for feeds only polled on
+-- demand.
+CREATE TABLE feeds (
+	id TEXT PRIMARY KEY,
+	collection_id TEXT NOT NULL,
+	tag_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	full_text INTEGER NOT NULL DEFAULT 0,
+	interval_seconds INTEGER NOT NULL DEFAULT 0,
+	etag TEXT NOT NULL DEFAULT '',
+	last_modified TEXT NOT NULL DEFAULT '',
+	next_fetch_at DATETIME,
+	last_fetched_at DATETIME,
+	last_status TEXT NOT NULL DEFAULT '',
+	last_error TEXT NOT NULL DEFAULT '',
+	failures INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	UNIQUE (tag_id, url),
+	FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_feeds_collection_id ON feeds(collection_id);
+CREATE INDEX idx_feeds_next_fetch_at ON feeds(next_fetch_at) WHERE next_fetch_at IS NOT NULL;
+
+-- The entries of each feed that have been stored, by GUID. data_point_id is
+-- not a foreign key: the record keeps the entry from being stored again
+-- after its data point is deleted.
+CREATE TABLE feed_entries (
+	id TEXT PRIMARY KEY,
+	feed_id TEXT NOT NULL,
+	guid TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	link TEXT NOT NULL DEFAULT '',
+	published_at DATETIME,
+	data_point_id TEXT,
+	created_at DATETIME NOT NULL,
+	UNIQUE (feed_id, guid),
+	FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_feed_entries_feed_id ON feed_entries(feed_id, id);
@@ -0,0 +1,144 @@
+//go:build sqlite_fts5
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// schema returns the SQL of every table, index and trigger of db except
+// schema_version and the internal ones of SQLite, in a stable order.
+func schema(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	rows, err := db.Query(`
+		SELECT type || ' ' || name || ': ' || coalesce(sql, '') FROM sqlite_master
+		WHERE name != 'schema_version' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY type, name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(objects, "\n")
+}
+
+func TestEmbeddedMigrationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	embedded, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, db := newTestMigrator(t, embedded...)
+
+	if n, err := m.Up(ctx); err != nil || n != len(embedded) {
+		t.Fatalf("Up = %d, %v, want %d", n, err, len(embedded))
+	}
+	latest := schema(t, db)
+
+	for _, steps := range []int{1, 3, len(embedded) / 2} {
+		if n, err := m.Down(ctx, steps); err != nil || n != steps {
+			t.Fatalf("Down(%d) = %d, %v", steps, n, err)
+		}
+		if n, err := m.Up(ctx); err != nil || n != steps {
+			t.Fatalf("Up(after Down(%d)) = %d, %v", steps, n, err)
+		}
+		if got := schema(t, db); got != latest {
+			t.Errorf("schema after Down(%d) and Up differs:\n%s\nwant\n%s", steps, got, latest)
+		}
+	}
+
+	if n, err := m.Down(ctx, len(embedded)); err != nil || n != len(embedded) {
+		t.Fatalf("Down(all) = %d, %v", n, err)
+	}
+	if got := schema(t, db); got != "" {
+		t.Errorf("schema after rolling back every migration:\n%s", got)
+	}
+	if n, err := m.Up(ctx); err != nil || n != len(embedded) {
+		t.Fatalf("Up(after Down(all)) = %d, %v", n, err)
+	}
+	if got := schema(t, db); got != latest {
+		t.Errorf("schema after Down(all) and Up differs:\n%s\nwant\n%s", got, latest)
+	}
+}
+
+func TestTagTreeMigrationCreatesAncestors(t *testing.T) {
+	ctx := context.Background()
+	embedded, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, db := newTestMigrator(t, embedded[:10]...)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy integer IDs, with times in the formats of CURRENT_TIMESTAMP
+	// and of the Go driver.
+	_, err = db.Exec(`
+		INSERT INTO collections (id, name, slug) VALUES ('1', 'c', 'c');
+		INSERT INTO tags (id, collection_id, name, slug, created_at) VALUES
+			('2', '1', 'infra/K8S/dns', 'infra-k8s-dns', '2021-06-01 00:00:00'),
+			('3', '1', 'Infra/k8s/net', 'infra-k8s-net', '2020-01-02 03:04:05.123456789+00:00');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.migrations = embedded[:11]
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT id, name, coalesce(parent_id, '') FROM tags ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tags []string
+	ids := map[string]string{}
+	for rows.Next() {
+		var id, name, parentID string
+		if err := rows.Scan(&id, &name, &parentID); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+		tags = append(tags, name+" < "+parentID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ancestors are spelled like the oldest tag below them.
+	want := []string{
+		"Infra < ",
+		"Infra/k8s < " + ids["Infra"],
+		"Infra/k8s/dns < " + ids["Infra/k8s"],
+		"Infra/k8s/net < " + ids["Infra/k8s"],
+	}
+	if strings.Join(tags, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("tags =\n%s\nwant\n%s", strings.Join(tags, "\n"), strings.Join(want, "\n"))
+	}
+	for _, name := range []string{"Infra", "Infra/k8s"} {
+		id, err := ulid.ParseStrict(ids[name])
+		if err != nil {
+			t.Errorf("ID %q of %s is not a ULID: %v", ids[name], name, err)
+			continue
+		}
+		if got, want := ulid.Time(id.Time()).UTC(), time.Date(2020, 1, 2, 3, 4, 5, 123e6, time.UTC); !got.Equal(want) {
+			t.Errorf("time of the ID of %s = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package embeddings turns text into vectors and finds the stored vectors
+// closest to a query.
+package embeddings
+
+import (
+	"context"
+	"math"
+)
+
+// Embedder converts texts into fixed-length vectors. Vectors produced by the
+// same Model are comparable with each other.
+type Embedder interface {
+	// Model identifies the embedding model; it is stored next to every vector.
+	Model() string
+	// Embed returns one vector per text, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Normalize scales v to unit length in place so that the dot product of two
+// normalized vectors is their cosine similarity.
+func Normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
@@ -0,0 +1,76 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDimensions is the vector size used by NewHashEmbedder when none is given.
+const DefaultHashDimensions = 384
+
+// HashEmbedder is a deterministic, offline Embedder. It hashes every word and
+// every character trigram of a text into a fixed number of buckets (the
+// "hashing trick"), so texts sharing words or word fragments end up close
+// together. It needs no model files or network access.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder returns a HashEmbedder producing vectors of the given size.
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = DefaultHashDimensions
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Model implements Embedder.
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-ngram-%d", e.dimensions)
+}
+
+// Embed implements Embedder.
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	v := make([]float32, e.dimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		// Whole words carry more signal than their fragments.
+		e.add(v, "w:"+word, 2)
+
+		padded := []rune("^" + word + "$")
+		for i := 0; i+3 <= len(padded); i++ {
+			e.add(v, "g:"+string(padded[i:i+3]), 1)
+		}
+	}
+
+	Normalize(v)
+	return v
+}
+
+// add hashes feature into a bucket of v. A second bit of the hash picks the
+// sign so that collisions tend to cancel out instead of piling up.
+func (e *HashEmbedder) add(v []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	bucket := int(sum % uint64(e.dimensions))
+	if sum&(1<<63) != 0 {
+		weight = -weight
+	}
+	v[bucket] += weight
+}
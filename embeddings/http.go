@@ -0,0 +1,110 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPEmbedder calls an OpenAI-compatible POST /embeddings endpoint, such as
+// a local inference server.
+type HTTPEmbedder struct {
+	// BaseURL is the API root, e.g. http://localhost:8081/v1.
+	BaseURL string
+	// ModelName is sent as the model of every request.
+	ModelName string
+	// APIKey, if set, is sent as a bearer token.
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTPEmbedder returns an HTTPEmbedder for the API at baseURL.
+func NewHTTPEmbedder(baseURL, model, apiKey string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		ModelName: model,
+		APIKey:    apiKey,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Model implements Embedder.
+func (e *HTTPEmbedder) Model() string {
+	return e.ModelName
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed implements Embedder.
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	body, err := json.Marshal(embeddingRequest{Model: e.ModelName, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+
+	var parsed embeddingResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid embedding response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if parsed.Error != nil {
+			return nil, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, parsed.Error.Message)
+		}
+		return nil, fmt.Errorf("embedding request failed with status %d", resp.StatusCode)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response has out of range index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+
+	return vectors, nil
+}
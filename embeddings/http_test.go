@@ -0,0 +1,77 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPEmbedder(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req embeddingRequest
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer key" || json.NewDecoder(r.Body).Decode(&req) != nil || req.Model != "small" {
+			http.Error(w, `{"error": {"message": "bad request"}}`, http.StatusBadRequest)
+			return
+		}
+		// The vectors come back in reverse order, placed by their index.
+		var data []string
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index": %d, "embedding": [%d, 0.5]}`, i, len(req.Input[i])))
+		}
+		fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(data, ","))
+	}))
+	defer server.Close()
+
+	e := NewHTTPEmbedder(server.URL+"/v1/", "small", "key")
+	if e.Model() != "small" {
+		t.Errorf("Model = %s", e.Model())
+	}
+	vectors, err := e.Embed(context.Background(), []string{"a", "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 3 || vectors[1][1] != 0.5 {
+		t.Errorf("Embed = %v", vectors)
+	}
+
+	if vectors, err := e.Embed(context.Background(), nil); err != nil || len(vectors) != 0 || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Embed(nothing) = %v, %v after %d requests, want no request", vectors, err, requests)
+	}
+
+	e.ModelName = "large"
+	if _, err := e.Embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "400: bad request") {
+		t.Errorf("Embed(rejected) error = %v", err)
+	}
+}
+
+func TestHTTPEmbedderInvalidResponses(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{http.StatusOK, `{"data": [{"index": 0, "embedding": [1]}]}`, "1 vectors for 2 inputs"},
+		{http.StatusOK, `{"data": [{"index": 0, "embedding": [1]}, {"index": 2, "embedding": [1]}]}`, "out of range index 2"},
+		{http.StatusOK, `not json`, "invalid embedding response (status 200)"},
+		{http.StatusBadGateway, `<html>proxy error</html>`, "invalid embedding response (status 502)"},
+		{http.StatusServiceUnavailable, `{}`, "failed with status 503"},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+		_, err := NewHTTPEmbedder(server.URL, "m", "").Embed(context.Background(), []string{"a", "b"})
+		server.Close()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Embed(%d %s) error = %v, want %q", tt.status, tt.body, err, tt.err)
+		}
+	}
+}
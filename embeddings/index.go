@@ -0,0 +1,91 @@
+package embeddings
+
+import (
+	"sort"
+	"sync"
+)
+
+// Match is a vector found by Index.Search.
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// Index is an exact nearest-neighbour index that compares the query with every
+// stored vector by cosine similarity. It is safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+// NewIndex returns an empty Index.
+func NewIndex() *Index {
+	return &Index{vectors: map[string][]float32{}}
+}
+
+// Add stores a copy of v under id, replacing any previous vector.
+func (ix *Index) Add(id string, v []float32) {
+	stored := make([]float32, len(v))
+	copy(stored, v)
+	Normalize(stored)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.vectors[id] = stored
+}
+
+// Remove deletes the vector stored under id.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.vectors, id)
+}
+
+// Has reports whether a vector is stored under id.
+func (ix *Index) Has(id string) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.vectors[id]
+	return ok
+}
+
+// Len returns the number of stored vectors.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.vectors)
+}
+
+// Search returns the k vectors most similar to query among those for which
+// keep returns true (all of them if keep is nil), most similar first.
+func (ix *Index) Search(query []float32, k int, keep func(id string) bool) []Match {
+	q := make([]float32, len(query))
+	copy(q, query)
+	Normalize(q)
+
+	ix.mu.RLock()
+	matches := make([]Match, 0, len(ix.vectors))
+	for id, v := range ix.vectors {
+		if len(v) != len(q) || (keep != nil && !keep(id)) {
+			continue
+		}
+		var dot float64
+		for i := range v {
+			dot += float64(v[i]) * float64(q[i])
+		}
+		matches = append(matches, Match{ID: id, Score: dot})
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+
+	return matches
+}
@@ -0,0 +1,120 @@
+package embeddings
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func ids(matches []Match) []string {
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("x", []float32{3, 0})
+	ix.Add("diagonal", []float32{1, 1})
+	ix.Add("y", []float32{0, 2})
+	ix.Add("also-x", []float32{1, 0})
+	ix.Add("other-model", []float32{1, 0, 0})
+
+	matches := ix.Search([]float32{2, 0}, 0, nil)
+	// Vectors of another length are skipped and ties are ordered by ID.
+	if got := ids(matches); len(got) != 4 || got[0] != "also-x" || got[1] != "x" || got[2] != "diagonal" || got[3] != "y" {
+		t.Fatalf("Search = %v", got)
+	}
+	if math.Abs(matches[0].Score-1) > 1e-6 || math.Abs(matches[2].Score-1/math.Sqrt2) > 1e-6 || math.Abs(matches[3].Score) > 1e-6 {
+		t.Errorf("scores = %+v, want cosine similarities", matches)
+	}
+
+	if got := ids(ix.Search([]float32{0, 1}, 2, nil)); len(got) != 2 || got[0] != "y" || got[1] != "diagonal" {
+		t.Errorf("Search(k = 2) = %v", got)
+	}
+	keep := func(id string) bool { return id != "y" }
+	if got := ids(ix.Search([]float32{0, 1}, 1, keep)); len(got) != 1 || got[0] != "diagonal" {
+		t.Errorf("Search(without y) = %v", got)
+	}
+}
+
+func TestIndexAddAndRemove(t *testing.T) {
+	ix := NewIndex()
+	v := []float32{0, 5}
+	ix.Add("a", v)
+	v[0], v[1] = 5, 0
+	if m := ix.Search([]float32{0, 1}, 1, nil); len(m) != 1 || math.Abs(m[0].Score-1) > 1e-6 {
+		t.Errorf("Search = %+v, want the vector as it was added", m)
+	}
+
+	// Adding a vector again replaces it.
+	ix.Add("a", []float32{1, 0})
+	if m := ix.Search([]float32{0, 1}, 1, nil); len(m) != 1 || math.Abs(m[0].Score) > 1e-6 {
+		t.Errorf("Search after replacing = %+v", m)
+	}
+	if !ix.Has("a") || ix.Len() != 1 {
+		t.Errorf("Has(a) = %t, Len = %d", ix.Has("a"), ix.Len())
+	}
+
+	ix.Remove("a")
+	ix.Remove("missing")
+	if ix.Has("a") || ix.Len() != 0 || len(ix.Search([]float32{1, 0}, 0, nil)) != 0 {
+		t.Errorf("index still has a after Remove")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := []float32{3, 4}
+	Normalize(v)
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Errorf("Normalize(3, 4) = %v", v)
+	}
+	zero := []float32{0, 0}
+	Normalize(zero)
+	if zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("Normalize(0, 0) = %v", zero)
+	}
+}
+
+func TestHashEmbedder(t *testing.T) {
+	e := NewHashEmbedder(0)
+	if e.Model() != "hash-ngram-384" {
+		t.Errorf("Model = %s", e.Model())
+	}
+
+	texts := []string{"Deploying Kubernetes clusters", "deploying kubernetes CLUSTERS!", "kubernetes deployments", "baking sourdough bread"}
+	vectors, err := e.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosine := func(a, b []float32) float64 {
+		var dot float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+		}
+		return dot
+	}
+	if len(vectors) != len(texts) || len(vectors[0]) != DefaultHashDimensions || math.Abs(cosine(vectors[0], vectors[0])-1) > 1e-5 {
+		t.Fatalf("Embed returned %d vectors of length %d, want unit vectors", len(vectors), len(vectors[0]))
+	}
+	// Case and punctuation are ignored, shared words and fragments count.
+	if s := cosine(vectors[0], vectors[1]); math.Abs(s-1) > 1e-5 {
+		t.Errorf("similarity of the same words = %v, want 1", s)
+	}
+	if related, unrelated := cosine(vectors[0], vectors[2]), cosine(vectors[0], vectors[3]); related <= unrelated {
+		t.Errorf("similarity of related texts %v <= that of unrelated ones %v", related, unrelated)
+	}
+
+	again, _ := NewHashEmbedder(DefaultHashDimensions).Embed(context.Background(), texts[:1])
+	for i := range again[0] {
+		if again[0][i] != vectors[0][i] {
+			t.Fatal("HashEmbedder is not deterministic")
+		}
+	}
+	empty, _ := e.Embed(context.Background(), []string{"  ...  "})
+	if cosine(empty[0], empty[0]) != 0 {
+		t.Errorf("embedding of a text without words = %v, want 0", empty[0])
+	}
+}
@@ -0,0 +1,668 @@
+// Package extract turns fetched web pages into readable text.
+package extract
+
+import (
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Article is the readable content of an HTML page.
+type Article struct {
+	Title  string
+	Byline string
+	// Text is the main content as plain text. Blocks are separated by blank
+	// lines and headings are written as Markdown headings, so the text can
+	// be split with the paragraph or Markdown chunkers.
+	Text string
+	// CanonicalURL is the URL the page declares for itself, or the URL it
+	// was fetched from.
+	CanonicalURL string
+	SiteName     string
+	// Excerpt is the description the page declares for itself.
+	Excerpt string
+	// Published is when the page says it was published, or nil.
+	Published *time.Time
+}
+
+// Metadata returns the non-empty metadata fields of the article, keyed the
+// way they are stored on data points.
+func (a *Article) Metadata() map[string]interface{} {
+	meta := map[string]interface{}{}
+	for key, value := range map[string]string{
+		"title":         a.Title,
+		"byline":        a.Byline,
+		"canonical_url": a.CanonicalURL,
+		"site_name":     a.SiteName,
+		"excerpt":       a.Excerpt,
+	} {
+		if value != "" {
+			meta[key] = value
+		}
+	}
+	if a.Published != nil {
+		meta["published_at"] = a.Published.Format(time.RFC3339)
+	}
+	return meta
+}
+
+// IsHTML reports whether a response with the given Content-Type header, or
+// starting with body if the header is missing, is an HTML document.
+func IsHTML(contentType, body string) bool {
+	if contentType != "" {
+		contentType = strings.ToLower(contentType)
+		return strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "application/xhtml+xml")
+	}
+
+	head := strings.ToLower(strings.TrimSpace(body))
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return strings.HasPrefix(head, "<!doctype html") || strings.Contains(head, "<html")
+}
+
+var (
+	// unlikelyCandidates match the class and id of page furniture.
+	unlikelyCandidates = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|menu|modal|nav|pager|pagination|popup|related|remark|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tweet|widget`)
+	// maybeCandidates rescue elements that also match unlikelyCandidates.
+	maybeCandidates = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames   = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|story|text|blog`)
+	negativeNames   = regexp.MustCompile(`(?i)-ad-|^ad-|-ad$|hidden|^hid$| hid$| hid |banner|combx|comment|contact|footer|footnote|masthead|media|meta|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+	bylineNames     = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	titleSeparator  = regexp.MustCompile(`\s+[|\-–—»:]\s+`)
+)
+
+// dateLayouts are the formats of the publication dates pages declare.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ignoredElements never contain readable text.
+var ignoredElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Svg: true, atom.Canvas: true, atom.Object: true,
+	atom.Embed: true, atom.Form: true, atom.Button: true, atom.Input: true,
+	atom.Select: true, atom.Textarea: true, atom.Nav: true, atom.Aside: true,
+	atom.Footer: true, atom.Dialog: true,
+}
+
+// blockElements start a new block of text.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Dd: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true,
+	atom.Figure: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Tr: true, atom.Ul: true,
+}
+
+// HTML extracts the article from an HTML page fetched from pageURL, which is
+// used to resolve the canonical URL and may be nil.
+func HTML(r io.Reader, pageURL *url.URL) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Article{}
+	readMetadata(doc, pageURL, a)
+
+	body := find(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	if a.Published == nil {
+		a.Published = findPublished(body)
+	}
+	if a.Byline == "" {
+		// The byline is kept out of the text, as it is returned on its own.
+		if n := findByline(body); n != nil {
+			a.Byline = collapse(textContent(n))
+			n.Parent.RemoveChild(n)
+		}
+	}
+
+	a.Text = render(mainContent(body))
+	if a.Title == "" {
+		if h1 := find(body, atom.H1); h1 != nil {
+			a.Title = collapse(textContent(h1))
+		}
+	}
+
+	return a, nil
+}
+
+// Fragment returns the readable text of an HTML fragment, such as the
+// content of a feed entry. Unlike HTML it keeps all of the text rather than
+// picking the main content.
+func Fragment(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	body := find(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	return render(body), nil
+}
+
+// readMetadata fills the fields of a that pages declare in their head.
+func readMetadata(doc *html.Node, pageURL *url.URL, a *Article) {
+	var docTitle, ogTitle, canonical, ogURL string
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Body:
+			return false
+		case atom.Title:
+			if docTitle == "" {
+				docTitle = collapse(textContent(n))
+			}
+		case atom.Link:
+			if hasToken(attr(n, "rel"), "canonical") && canonical == "" {
+				canonical = attr(n, "href")
+			}
+		case atom.Meta:
+			key := strings.ToLower(attr(n, "property"))
+			if key == "" {
+				key = strings.ToLower(attr(n, "name"))
+			}
+			content := collapse(attr(n, "content"))
+			if content == "" {
+				return true
+			}
+			switch key {
+			case "og:title", "twitter:title":
+				if ogTitle == "" {
+					ogTitle = content
+				}
+			case "author", "article:author", "dc.creator", "parsely-author":
+				if a.Byline == "" && !strings.HasPrefix(content, "http") {
+					a.Byline = content
+				}
+			case "og:site_name":
+				a.SiteName = content
+			case "description", "og:description", "twitter:description":
+				if a.Excerpt == "" {
+					a.Excerpt = content
+				}
+			case "og:url":
+				ogURL = content
+			case "article:published_time", "og:published_time", "datepublished", "date", "dc.date", "dc.date.issued", "parsely-pub-date":
+				if a.Published == nil {
+					a.Published = parseDate(content)
+				}
+			}
+			if a.Published == nil && attr(n, "itemprop") == "datePublished" {
+				a.Published = parseDate(content)
+			}
+		}
+		return true
+	})
+
+	a.Title = ogTitle
+	if a.Title == "" {
+		a.Title = cleanTitle(docTitle, a.SiteName)
+	}
+
+	for _, raw := range []string{canonical, ogURL} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if pageURL != nil {
+			u = pageURL.ResolveReference(u)
+		}
+		if u.IsAbs() {
+			a.CanonicalURL = u.String()
+			break
+		}
+	}
+	if a.CanonicalURL == "" && pageURL != nil {
+		a.CanonicalURL = pageURL.String()
+	}
+}
+
+// cleanTitle removes the site name that pages commonly append to or prepend
+// before the title in the <title> element.
+func cleanTitle(title, siteName string) string {
+	parts := titleSeparator.Split(title, -1)
+	if len(parts) < 2 {
+		return title
+	}
+	if siteName != "" {
+		var kept []string
+		for _, p := range parts {
+			if !strings.EqualFold(p, siteName) {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) > 0 && len(kept) < len(parts) {
+			return strings.Join(kept, " - ")
+		}
+	}
+
+	// Keep the longest part if it is long enough to stand on its own.
+	longest := parts[0]
+	for _, p := range parts[1:] {
+		if len(p) > len(longest) {
+			longest = p
+		}
+	}
+	if len(strings.Fields(longest)) >= 3 {
+		return longest
+	}
+	return title
+}
+
+// findPublished returns the publication date marked up in the body, in a
+// <time pubdate> element or with the datePublished microdata property.
+func findPublished(body *html.Node) *time.Time {
+	var published *time.Time
+	walk(body, func(n *html.Node) bool {
+		if published != nil || n.Type == html.ElementNode && ignoredElements[n.DataAtom] {
+			return false
+		}
+		if n.Type != html.ElementNode {
+			return true
+		}
+		_, pubdate := attrOf(n, "pubdate")
+		if attr(n, "itemprop") == "datePublished" || n.DataAtom == atom.Time && pubdate {
+			value := attr(n, "datetime")
+			if value == "" {
+				value = attr(n, "content")
+			}
+			published = parseDate(value)
+		}
+		return published == nil
+	})
+	return published
+}
+
+// parseDate parses a date in one of dateLayouts and returns it in UTC, or
+// nil if s is not such a date.
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// findByline returns the first short element marked as the author of the
+// page.
+func findByline(body *html.Node) *html.Node {
+	var byline *html.Node
+	walk(body, func(n *html.Node) bool {
+		if byline != nil || n.Type != html.ElementNode || ignoredElements[n.DataAtom] {
+			return false
+		}
+		if n != body && (hasToken(attr(n, "rel"), "author") || attr(n, "itemprop") == "author" ||
+			bylineNames.MatchString(attr(n, "class")+" "+attr(n, "id"))) {
+			text := collapse(textContent(n))
+			if text != "" && len(text) < 100 {
+				byline = n
+				return false
+			}
+		}
+		return true
+	})
+	return byline
+}
+
+// mainContent returns the element holding the main content of the page,
+// picked by scoring the containers of paragraphs like Readability does.
+func mainContent(body *html.Node) *html.Node {
+	if article := find(body, atom.Article); article != nil && len(textContent(article)) > 250 {
+		if only := findAll(body, atom.Article); len(only) == 1 {
+			return article
+		}
+	}
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	walk(body, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		if ignoredElements[n.DataAtom] || unlikely(n) {
+			return false
+		}
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		default:
+			return true
+		}
+
+		text := collapse(textContent(n))
+		if len(text) < 25 {
+			return false
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+		return false
+	})
+
+	var best *html.Node
+	var bestScore float64
+	for _, n := range candidates {
+		score := scores[n] * (1 - linkDensity(n))
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return body
+	}
+
+	// A sibling with enough content is likely part of the same article, for
+	// example when it is split into several sections.
+	if best.Parent != nil {
+		threshold := math.Max(10, bestScore*0.2)
+		var keep []*html.Node
+		for s := best.Parent.FirstChild; s != nil; s = s.NextSibling {
+			if s == best {
+				keep = append(keep, s)
+				continue
+			}
+			if score, ok := scores[s]; ok && score*(1-linkDensity(s)) >= threshold {
+				keep = append(keep, s)
+			}
+		}
+		if len(keep) > 1 {
+			wrapper := &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"}
+			for _, s := range keep {
+				wrapper.AppendChild(clone(s))
+			}
+			return wrapper
+		}
+	}
+
+	return best
+}
+
+// initialScore weighs an element by its tag and by its class and id.
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article:
+		score = 10
+	case atom.Div, atom.Main, atom.Section:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Form, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Address:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+
+	names := attr(n, "class") + " " + attr(n, "id")
+	if negativeNames.MatchString(names) {
+		score -= 25
+	}
+	if positiveNames.MatchString(names) {
+		score += 25
+	}
+	return score
+}
+
+// unlikely reports whether n looks like page furniture rather than content.
+func unlikely(n *html.Node) bool {
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	if strings.EqualFold(attr(n, "role"), "navigation") || strings.EqualFold(attr(n, "role"), "complementary") ||
+		attr(n, "hidden") != "" || strings.EqualFold(attr(n, "aria-hidden"), "true") {
+		return true
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyCandidates.MatchString(names) && !maybeCandidates.MatchString(names)
+}
+
+// linkDensity is the share of the text of n that is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(collapse(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	for _, a := range findAll(n, atom.A) {
+		links += len(collapse(textContent(a)))
+	}
+	return float64(links) / float64(total)
+}
+
+// render writes the readable text below n.
+func render(n *html.Node) string {
+	var blocks []string
+	var b strings.Builder
+
+	flush := func() {
+		if text := collapse(b.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		b.Reset()
+	}
+
+	var visit func(n *html.Node, listDepth int)
+	visit = func(n *html.Node, listDepth int) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+		case html.DocumentNode:
+		default:
+			return
+		}
+		if ignoredElements[n.DataAtom] || (n.Type == html.ElementNode && unlikely(n)) {
+			return
+		}
+
+		switch n.DataAtom {
+		case atom.Br:
+			b.WriteString("\n")
+			return
+		case atom.Pre:
+			flush()
+			if text := strings.Trim(textContent(n), "\n"); strings.TrimSpace(text) != "" {
+				blocks = append(blocks, text)
+			}
+			return
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			flush()
+			if text := collapse(textContent(n)); text != "" {
+				level := int(n.Data[1] - '0')
+				blocks = append(blocks, strings.Repeat("#", level)+" "+text)
+			}
+			return
+		case atom.Li:
+			flush()
+			b.WriteString(strings.Repeat("  ", listDepth) + "- ")
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				visit(c, listDepth)
+			}
+			flush()
+			return
+		case atom.Ul, atom.Ol:
+			listDepth++
+		}
+
+		block := blockElements[n.DataAtom]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c, listDepth)
+		}
+		if block {
+			flush()
+		}
+	}
+	visit(n, -1)
+	flush()
+
+	return strings.Join(mergeListItems(blocks), "\n\n")
+}
+
+// mergeListItems joins consecutive list items into one block so a list is
+// kept together as a single paragraph.
+func mergeListItems(blocks []string) []string {
+	var out []string
+	for _, block := range blocks {
+		isItem := strings.HasPrefix(strings.TrimLeft(block, " "), "- ")
+		if isItem && len(out) > 0 && strings.HasPrefix(strings.TrimLeft(lastLine(out[len(out)-1]), " "), "- ") {
+			out[len(out)-1] += "\n" + block
+			continue
+		}
+		out = append(out, block)
+	}
+	return out
+}
+
+func lastLine(s string) string {
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
+
+// collapse replaces runs of white space within each line of s by a single
+// space and drops empty lines, keeping the indentation of list items.
+func collapse(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		indent := ""
+		if trimmed := strings.TrimLeft(line, " "); strings.HasPrefix(trimmed, "- ") {
+			indent = line[:len(line)-len(trimmed)]
+		}
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, indent+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textContent returns the text below n, skipping elements without readable
+// text.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style || n.DataAtom == atom.Noscript) {
+			return
+		}
+		if n.DataAtom == atom.Br {
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(n)
+	return b.String()
+}
+
+// walk calls fn for n and its descendants in document order. It skips the
+// children of nodes for which fn returns false.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.DataAtom == a {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func findAll(n *html.Node, a atom.Atom) []*html.Node {
+	var found []*html.Node
+	walk(n, func(n *html.Node) bool {
+		if n.DataAtom == a {
+			found = append(found, n)
+		}
+		return true
+	})
+	return found
+}
+
+// clone returns a deep copy of n that is not attached to a parent.
+func clone(n *html.Node) *html.Node {
+	c := &html.Node{Type: n.Type, DataAtom: n.DataAtom, Data: n.Data, Namespace: n.Namespace, Attr: n.Attr}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.AppendChild(clone(child))
+	}
+	return c
+}
+
+func attr(n *html.Node, key string) string {
+	val, _ := attrOf(n, key)
+	return val
+}
+
+// attrOf returns the value of the attribute key of n and whether n has it.
+func attrOf(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package extract
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func extractFile(t *testing.T, name string, pageURL *url.URL) *Article {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := HTML(f, pageURL)
+	if err != nil {
+		t.Fatalf("HTML(%s): %v", name, err)
+	}
+	return a
+}
+
+func date(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestHTMLArticle(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/posts/1?utm_source=feed")
+	a := extractFile(t, "article.html", pageURL)
+
+	want := &Article{
+		Title:        "Tuning SQLite for servers",
+		Byline:       "Ada Lovelace",
+		CanonicalURL: "https://example.com/blog/tuning-sqlite",
+		SiteName:     "Example Engineering",
+		Excerpt:      "How we made SQLite fast enough.",
+		Published:    date("2024-03-05T08:30:00Z"),
+		// The header, navigation, cookie banner, share buttons, scripts,
+		// aside and footer are left out.
+		Text: strings.Join([]string{
+			"# Tuning SQLite for servers",
+			"SQLite is often dismissed as a database for phones and tests, but it serves many websites well.",
+			"## Write-ahead logging",
+			"Turning on WAL mode lets readers continue while a writer commits, which removes most lock contention.",
+			"- Set journal_mode to WAL.\n- Keep synchronous at NORMAL.",
+			"PRAGMA journal_mode = WAL;\nPRAGMA synchronous = NORMAL;",
+			"With these settings, a single file can handle thousands of requests per second on modest hardware.",
+		}, "\n\n"),
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("article =\n%+v\nwant\n%+v", a, want)
+	}
+
+	meta := a.Metadata()
+	if meta["published_at"] != "2024-03-05T08:30:00Z" || meta["byline"] != "Ada Lovelace" || len(meta) != 6 {
+		t.Errorf("metadata = %v", meta)
+	}
+}
+
+func TestHTMLScoredContent(t *testing.T) {
+	a := extractFile(t, "blog.html", nil)
+
+	want := &Article{
+		// The site name is cut from the <title> and the byline from the
+		// text. The menu, the links below the post and the comments are
+		// left out, and the continuation of the post is kept.
+		Title:     "Notes on Go error handling",
+		Byline:    "Grace Hopper",
+		SiteName:  "Gopher Diary",
+		Published: date("2023-11-20T00:00:00Z"),
+		Text: strings.Join([]string{
+			"# Notes on Go error handling",
+			"November 20, 2023",
+			"Errors in Go are values, so they can be wrapped, compared and inspected like any other value.",
+			"Wrapping an error with fmt.Errorf and the %w verb keeps the original, so callers can still test for it with errors.Is.",
+			"Sentinel errors, such as io.EOF, are best kept for conditions callers are expected to handle.",
+			"Custom error types carry more details, and errors.As finds them anywhere in a chain of wrapped errors.",
+		}, "\n\n"),
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("article =\n%+v\nwant\n%+v", a, want)
+	}
+}
+
+func TestHTMLMetadataFallbacks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/page")
+	tests := []struct {
+		name string
+		html string
+		want Article
+	}{
+		{"first h1 as title",
+			`<body><h1>The <em>heading</em></h1><h1>Another</h1></body>`,
+			Article{Title: "The heading", Text: "# The heading\n\n# Another", CanonicalURL: "https://example.com/page"}},
+		{"short titles are kept whole",
+			`<title>Home | Site</title>`,
+			Article{Title: "Home | Site", CanonicalURL: "https://example.com/page"}},
+		{"og:url without a canonical link",
+			`<meta property="og:url" content="https://example.org/a"><meta name="author" content="https://example.org/me">`,
+			Article{CanonicalURL: "https://example.org/a"}},
+		{"byline by itemprop",
+			`<body><span itemprop="author">Alan Turing</span><p>Text.</p></body>`,
+			Article{Byline: "Alan Turing", Text: "Text.", CanonicalURL: "https://example.com/page"}},
+		{"date in a meta element",
+			`<meta name="date" content="2022-02-03"><meta name="dc.date" content="2000-01-01">`,
+			Article{Published: date("2022-02-03T00:00:00Z"), CanonicalURL: "https://example.com/page"}},
+		{"date by microdata in the head",
+			`<meta itemprop="datePublished" content="2022-02-03T10:11:12Z">`,
+			Article{Published: date("2022-02-03T10:11:12Z"), CanonicalURL: "https://example.com/page"}},
+		{"time element with pubdate",
+			`<body><p><time datetime="2021-05-06T07:08+02:00" pubdate>May 6</time></p></body>`,
+			Article{Published: date("2021-05-06T05:08:00Z"), Text: "May 6", CanonicalURL: "https://example.com/page"}},
+		{"time element without pubdate",
+			`<body><p><time datetime="2021-05-06">May 6</time></p></body>`,
+			Article{Text: "May 6", CanonicalURL: "https://example.com/page"}},
+		{"unreadable date",
+			`<meta property="article:published_time" content="last Tuesday">`,
+			Article{CanonicalURL: "https://example.com/page"}},
+	}
+	for _, tt := range tests {
+		a, err := HTML(strings.NewReader(tt.html), pageURL)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(*a, tt.want) {
+			t.Errorf("%s: article =\n%+v\nwant\n%+v", tt.name, *a, tt.want)
+		}
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		title, siteName, want string
+	}{
+		{"A long post title | Site", "Site", "A long post title"},
+		{"Site » Short", "site", "Short"},
+		{"Site", "Site", "Site"},
+		{"Part one - Part two - Site", "Site", "Part one - Part two"},
+		{"Short | A much longer title", "", "A much longer title"},
+		{"Short | Name", "", "Short | Name"},
+		{"Title with-hyphen and no separator", "", "Title with-hyphen and no separator"},
+	}
+	for _, tt := range tests {
+		if got := cleanTitle(tt.title, tt.siteName); got != tt.want {
+			t.Errorf("cleanTitle(%q, %q) = %q, want %q", tt.title, tt.siteName, got, tt.want)
+		}
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              bool
+	}{
+		{"text/html; charset=utf-8", "", true},
+		{"application/xhtml+xml", "", true},
+		{"text/plain", "<html><body>looks like HTML</body></html>", false},
+		{"", "  <!DOCTYPE html><title>a</title>", true},
+		{"", "<?xml version=\"1.0\"?>\n<html xmlns=\"http://www.w3.org/1999/xhtml\">", true},
+		{"", "Just text about <html> tags.", true},
+		{"", "Just text.", false},
+	}
+	for _, tt := range tests {
+		if got := IsHTML(tt.contentType, tt.body); got != tt.want {
+			t.Errorf("IsHTML(%q, %q) = %t, want %t", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFragment(t *testing.T) {
+	text, err := Fragment(strings.NewReader(`<p>First <b>bold</b>.</p><script>x()</script><ul><li>a<ul><li>b</li></ul></li><li>c</li></ul><p>Line<br>break</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "First bold.\n\n- a\n  - b\n- c\n\nLine\nbreak"; text != want {
+		t.Errorf("Fragment = %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,330 @@
+// Package feed parses RSS 2.0 and Atom feeds into their entries.
+package feed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is returned for XML documents that are neither an RSS 2.0
+// nor an Atom feed.
+var ErrUnsupported = errors.New("document is not an RSS 2.0 or Atom feed")
+
+// Feed is a parsed feed.
+type Feed struct {
+	Title string
+	// Link is the URL of the site the feed belongs to.
+	Link string
+	// Entries are in the order of the feed, usually newest first.
+	Entries []Entry
+}
+
+// Entry is an item of an RSS feed or an entry of an Atom feed.
+type Entry struct {
+	// GUID identifies the entry within its feed: the guid of an RSS item
+	// or the id of an Atom entry, falling back to the link, or else to a
+	// hash of the title and content.
+	GUID   string
+	Title  string
+	Link   string
+	Author string
+	// Published is nil if the feed gives no date for the entry.
+	Published *time.Time
+	// Content is the full content of the entry if the feed has it, or
+	// else its summary. It is usually HTML.
+	Content string
+}
+
+// Parse parses an RSS 2.0 or Atom feed.
+func Parse(data []byte) (*Feed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charsetReader
+	dec.Strict = false
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, ErrUnsupported
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse feed: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var f *Feed
+		switch {
+		case start.Name.Local == "rss":
+			var doc rssDocument
+			err = dec.DecodeElement(&doc, &start)
+			f = doc.feed()
+		case start.Name.Local == "feed" && start.Name.Space == atomNS:
+			var doc atomFeed
+			err = dec.DecodeElement(&doc, &start)
+			f = doc.feed()
+		default:
+			return nil, ErrUnsupported
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse feed: %w", err)
+		}
+		return f, nil
+	}
+}
+
+const (
+	atomNS    = "http://www.w3.org/2005/Atom"
+	contentNS = "http://purl.org/rss/1.0/modules/content/"
+	dcNS      = "http://purl.org/dc/elements/1.1/"
+)
+
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Link  string    `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	GUID        string `xml:"guid"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+func (d *rssDocument) feed() *Feed {
+	f := &Feed{Title: strings.TrimSpace(d.Channel.Title), Link: strings.TrimSpace(d.Channel.Link)}
+	for _, item := range d.Channel.Items {
+		e := Entry{
+			GUID:    strings.TrimSpace(item.GUID),
+			Title:   strings.TrimSpace(item.Title),
+			Link:    strings.TrimSpace(item.Link),
+			Author:  strings.TrimSpace(firstOf(item.Creator, item.Author)),
+			Content: strings.TrimSpace(firstOf(item.Encoded, item.Description)),
+		}
+		e.Published = parseDate(firstOf(item.PubDate, item.Date))
+		f.Entries = append(f.Entries, e.withGUID())
+	}
+	return f
+}
+
+type atomFeed struct {
+	Title   string      `xml:"http://www.w3.org/2005/Atom title"`
+	Links   []atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title     string     `xml:"http://www.w3.org/2005/Atom title"`
+	Links     []atomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Published string     `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors   []struct {
+		Name string `xml:"http://www.w3.org/2005/Atom name"`
+	} `xml:"http://www.w3.org/2005/Atom author"`
+	Summary atomText `xml:"http://www.w3.org/2005/Atom summary"`
+	Content atomText `xml:"http://www.w3.org/2005/Atom content"`
+}
+
+// atomText is an Atom text construct, such as the content of an entry.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// html returns t as HTML. The markup of xhtml content is kept as it is,
+// without the div wrapping it, html content is unescaped, and text and
+// other content is escaped.
+func (t atomText) html() string {
+	switch strings.ToLower(strings.TrimSpace(t.Type)) {
+	case "xhtml", "application/xhtml+xml":
+		return xhtmlContent(t.Inner)
+	case "html", "text/html":
+		return t.Text
+	default:
+		return html.EscapeString(t.Text)
+	}
+}
+
+// xhtmlContent returns the markup inside the div that the XML of xhtml
+// content consists of, or all of it if it is not a single div.
+func xhtmlContent(inner string) string {
+	dec := xml.NewDecoder(strings.NewReader(inner))
+	dec.Strict = false
+	start, end := int64(-1), int64(-1)
+	depth := 0
+	for {
+		before := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if start >= 0 || tok.Name.Local != "div" {
+					return inner
+				}
+				start = dec.InputOffset()
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				end = before
+			}
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(tok)) > 0 {
+				return inner
+			}
+		}
+	}
+	if start < 0 || end < start {
+		return inner
+	}
+	return inner[start:end]
+}
+
+func (d *atomFeed) feed() *Feed {
+	f := &Feed{Title: strings.TrimSpace(d.Title), Link: alternate(d.Links)}
+	for _, entry := range d.Entries {
+		e := Entry{
+			GUID:    strings.TrimSpace(entry.ID),
+			Title:   strings.TrimSpace(entry.Title),
+			Link:    alternate(entry.Links),
+			Content: strings.TrimSpace(firstOf(entry.Content.html(), entry.Summary.html())),
+		}
+		if len(entry.Authors) > 0 {
+			e.Author = strings.TrimSpace(entry.Authors[0].Name)
+		}
+		e.Published = parseDate(firstOf(entry.Published, entry.Updated))
+		f.Entries = append(f.Entries, e.withGUID())
+	}
+	return f
+}
+
+// alternate returns the link to the web page of an Atom feed or entry.
+func alternate(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+// withGUID returns e with a GUID, falling back to its link or to a hash of
+// its title and content if the feed does not identify it.
+func (e Entry) withGUID() Entry {
+	if e.GUID == "" {
+		e.GUID = e.Link
+	}
+	if e.GUID == "" {
+		sum := sha256.Sum256([]byte(e.Title + "\n" + e.Content))
+		e.GUID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return e
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// dateLayouts are the date formats found in feeds: RFC 822 dates of RSS,
+// with and without seconds or day names, and RFC 3339 dates of Atom and
+// Dublin Core.
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses a date of a feed, returning nil if it is missing or in
+// an unknown format.
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// charsetReader decodes the single-byte charsets feeds declare besides
+// UTF-8. Like browsers, it reads ISO 8859-1 and ASCII as Windows-1252,
+// which only differs from ISO 8859-1 in 0x80-0x9F: printable characters
+// there rather than C1 controls, which is what those bytes mean in
+// practice.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "windows-1252", "cp1252", "us-ascii", "ascii":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, len(data)*2)
+		for _, b := range data {
+			r := rune(b)
+			if b >= 0x80 && b <= 0x9f {
+				r = windows1252[b-0x80]
+			}
+			buf = utf8.AppendRune(buf, r)
+		}
+		return bytes.NewReader(buf), nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to runes. The five
+// bytes it leaves undefined are kept as the C1 controls of ISO 8859-1.
+var windows1252 = [32]rune{
+	'\u20ac', '\u0081', '\u201a', '\u0192', '\u201e', '\u2026', '\u2020', '\u2021',
+	'\u02c6', '\u2030', '\u0160', '\u2039', '\u0152', '\u008d', '\u017d', '\u008f',
+	'\u0090', '\u2018', '\u2019', '\u201c', '\u201d', '\u2022', '\u2013', '\u2014',
+	'\u02dc', '\u2122', '\u0161', '\u203a', '\u0153', '\u009d', '\u017e', '\u0178',
+}
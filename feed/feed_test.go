@@ -0,0 +1,213 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parseFile(t *testing.T, name string) *Feed {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", name, err)
+	}
+	return f
+}
+
+func date(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// checkFeed compares f field by field with want, so that a mismatch names
+// the entry it is in.
+func checkFeed(t *testing.T, f, want *Feed) {
+	t.Helper()
+	if f.Title != want.Title || f.Link != want.Link || len(f.Entries) != len(want.Entries) {
+		t.Fatalf("feed = %q, %q with %d entries, want %q, %q with %d", f.Title, f.Link, len(f.Entries), want.Title, want.Link, len(want.Entries))
+	}
+	for i, e := range f.Entries {
+		if !reflect.DeepEqual(e, want.Entries[i]) {
+			t.Errorf("entry %d =\n%+v\nwant\n%+v", i, e, want.Entries[i])
+		}
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	sum := sha256.Sum256([]byte("First\nNo link and no guid."))
+	checkFeed(t, parseFile(t, "rss.xml"), &Feed{
+		Title: "Example News",
+		Link:  "https://example.com/",
+		Entries: []Entry{{
+			GUID:      "tag:example.com,2024:3",
+			Title:     "Third",
+			Link:      "https://example.com/third",
+			Author:    "Ada Lovelace",
+			Published: date("2024-01-02T14:04:05Z"),
+			Content:   "<p>The <b>full</b> text.</p>",
+		}, {
+			GUID:      "https://example.com/second",
+			Title:     "Second",
+			Link:      "https://example.com/second",
+			Author:    "bob@example.com (Bob)",
+			Published: date("2024-01-01T10:00:00Z"),
+			Content:   "<p>Escaped &amp; HTML.</p>",
+		}, {
+			GUID:    "sha256:" + hex.EncodeToString(sum[:]),
+			Title:   "First",
+			Content: "No link and no guid.",
+		}},
+	})
+}
+
+func TestParseAtom(t *testing.T) {
+	checkFeed(t, parseFile(t, "atom.xml"), &Feed{
+		Title: "Example Blog",
+		Link:  "https://example.com/blog",
+		Entries: []Entry{{
+			GUID:      "urn:uuid:3",
+			Title:     "Markup",
+			Link:      "https://example.com/blog/markup",
+			Author:    "Ada",
+			Published: date("2024-01-02T08:00:00Z"),
+			Content:   "<p>Some <em>xhtml</em> &amp; more.</p>",
+		}, {
+			GUID:      "urn:uuid:2",
+			Title:     "Escaped",
+			Link:      "https://example.com/blog/escaped",
+			Published: date("2024-01-01T10:00:00Z"),
+			Content:   "<p>Escaped <b>html</b>.</p>",
+		}, {
+			GUID:    "https://example.com/blog/plain",
+			Title:   "Plain",
+			Link:    "https://example.com/blog/plain",
+			Content: "1 &lt; 2 &amp; text",
+		}},
+	})
+}
+
+func TestAtomText(t *testing.T) {
+	tests := []struct {
+		text atomText
+		html string
+	}{
+		{atomText{Text: "a < b"}, "a &lt; b"},
+		{atomText{Type: "text", Text: "<b>"}, "&lt;b&gt;"},
+		{atomText{Type: "HTML", Text: "<b>bold</b>"}, "<b>bold</b>"},
+		{atomText{Type: "text/html", Text: "<b>bold</b>"}, "<b>bold</b>"},
+		{atomText{Type: "xhtml", Inner: `<div xmlns="http://www.w3.org/1999/xhtml"><p>a</p><p>b</p></div>`}, "<p>a</p><p>b</p>"},
+		{atomText{Type: "xhtml", Inner: "\n  <xhtml:div><xhtml:p>a</xhtml:p></xhtml:div>\n"}, "<xhtml:p>a</xhtml:p>"},
+		{atomText{Type: "xhtml", Inner: `<div><div>nested</div></div>`}, "<div>nested</div>"},
+		// Markup that is not a single div is kept whole.
+		{atomText{Type: "xhtml", Inner: `<p>a</p><p>b</p>`}, "<p>a</p><p>b</p>"},
+		{atomText{Type: "xhtml", Inner: `text <div>b</div>`}, "text <div>b</div>"},
+		{atomText{Type: "xhtml", Inner: `<div>a</div><div>b</div>`}, "<div>a</div><div>b</div>"},
+	}
+	for _, tt := range tests {
+		if got := tt.text.html(); got != tt.html {
+			t.Errorf("%+v as HTML = %q, want %q", tt.text, got, tt.html)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		"not xml at all",
+		`<html><body><p>A web page</p></body></html>`,
+		`<feed><title>Atom without its namespace</title></feed>`,
+		`<?xml version="1.0"?><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`,
+	} {
+		if f, err := Parse([]byte(doc)); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Parse(%q) = %+v, %v, want ErrUnsupported", doc, f, err)
+		}
+	}
+
+	_, err := Parse([]byte(`<?xml version="1.0" encoding="koi8-r"?><rss version="2.0"><channel/></rss>`))
+	if err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("Parse(unsupported charset) error = %v", err)
+	}
+}
+
+func TestParseCharsets(t *testing.T) {
+	tests := []struct {
+		charset string
+		title   string
+		want    string
+	}{
+		{"windows-1252", "\x80 \x93quoted\x94 \x96 \x85 caf\xe9", "€ “quoted” – … café"},
+		{"ISO-8859-1", "\x91a\x92 \x99 \xfc", "‘a’ ™ ü"},
+		{"us-ascii", "plain", "plain"},
+		{"utf-8", "caf\xc3\xa9 \xe2\x82\xac", "café €"},
+	}
+	for _, tt := range tests {
+		doc := `<?xml version="1.0" encoding="` + tt.charset + `"?><rss version="2.0"><channel><title>` + tt.title + `</title></channel></rss>`
+		f, err := Parse([]byte(doc))
+		if err != nil || f.Title != tt.want {
+			t.Errorf("Parse(%s) title = %+v, %v, want %q", tt.charset, f, err, tt.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *time.Time
+	}{
+		{"Tue, 02 Jan 2024 15:04:05 +0100", date("2024-01-02T14:04:05Z")},
+		{"Tue, 02 Jan 2024 15:04:05 GMT", date("2024-01-02T15:04:05Z")},
+		{"Tue, 2 Jan 2024 15:04:05 -0500", date("2024-01-02T20:04:05Z")},
+		{"Tue, 2 Jan 2024 15:04:05 UTC", date("2024-01-02T15:04:05Z")},
+		{"2 Jan 2024 15:04:05 +0000", date("2024-01-02T15:04:05Z")},
+		{"2 Jan 2024 15:04:05 GMT", date("2024-01-02T15:04:05Z")},
+		{"Tue, 2 Jan 2024 15:04 +0200", date("2024-01-02T13:04:00Z")},
+		{"Tue, 2 Jan 2024 15:04 GMT", date("2024-01-02T15:04:00Z")},
+		{"2024-01-02T15:04:05+02:00", date("2024-01-02T13:04:05Z")},
+		{"2024-01-02T15:04:05.250Z", date("2024-01-02T15:04:05.25Z")},
+		{"2024-01-02T15:04:05", date("2024-01-02T15:04:05Z")},
+		{" 2024-01-02 ", date("2024-01-02T00:00:00Z")},
+		{"", nil},
+		{"yesterday", nil},
+		{"02/01/2024", nil},
+	}
+	for _, tt := range tests {
+		got := parseDate(tt.s)
+		if (got == nil) != (tt.want == nil) || got != nil && (!got.Equal(*tt.want) || got.Location() != time.UTC) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWithGUID(t *testing.T) {
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	tests := []struct {
+		entry Entry
+		guid  string
+	}{
+		{Entry{GUID: "id", Link: "https://example.com/a"}, "id"},
+		{Entry{Link: "https://example.com/a", Title: "A"}, "https://example.com/a"},
+		{Entry{Title: "A", Content: "text"}, hash("A\ntext")},
+		{Entry{Title: "A\ntext"}, hash("A\ntext\n")},
+		{Entry{}, hash("\n")},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.withGUID().GUID; got != tt.guid {
+			t.Errorf("%+v has GUID %q, want %q", tt.entry, got, tt.guid)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Kind classifies why a fetch failed.
+type Kind string
+
+const (
+	// InvalidURL means the URL is malformed or uses a scheme other than
+	// http and https.
+	InvalidURL Kind = "invalid_url"
+	// Blocked means the URL resolves to an address the Fetcher may not
+	// connect to.
+	Blocked Kind = "blocked"
+	// Timeout means the fetch did not complete within the configured time.
+	Timeout Kind = "timeout"
+	// Network means the server could not be reached or the connection
+	// failed.
+	Network Kind = "network"
+	// TooManyRedirects means the redirect cap was reached.
+	TooManyRedirects Kind = "too_many_redirects"
+	// Status means the server responded with a non-2xx status code.
+	Status Kind = "status"
+	// TooLarge means the body exceeds the maximum size.
+	TooLarge Kind = "too_large"
+	// UnsupportedContentType means the media type is not in the allowlist.
+	UnsupportedContentType Kind = "unsupported_content_type"
+)
+
+// Error is returned by Fetcher.Fetch.
+type Error struct {
+	Kind Kind
+	URL  string
+	// StatusCode is the status of the response for errors of kind Status.
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fetch %s: %v", e.URL, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the Kind of a fetch error, or "" if err is not one.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return ""
+}
+
+// blockedError is returned by the dialer for non-public addresses.
+type blockedError struct {
+	ip string
+}
+
+func (e *blockedError) Error() string {
+	return fmt.Sprintf("address %s is not public", e.ip)
+}
+
+// classify wraps an error returned by the HTTP client.
+func classify(rawURL string, err error) error {
+	var fetchErr *Error
+	if errors.As(err, &fetchErr) {
+		// Returned by CheckRedirect; report the URL that was requested.
+		fetchErr.URL = rawURL
+		return fetchErr
+	}
+
+	var blocked *blockedError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &blocked):
+		return &Error{Kind: Blocked, URL: rawURL, Err: blocked}
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return &Error{Kind: Timeout, URL: rawURL, Err: errors.New("timed out")}
+	case errors.As(err, &urlErr):
+		return &Error{Kind: Network, URL: rawURL, Err: urlErr.Err}
+	default:
+		return &Error{Kind: Network, URL: rawURL, Err: err}
+	}
+}
+
+func tooLarge(rawURL string, max int64) error {
+	return &Error{Kind: TooLarge, URL: rawURL, Err: fmt.Errorf("body exceeds %d bytes", max)}
+}
+
+func unsupported(rawURL, mediaType string) error {
+	return &Error{Kind: UnsupportedContentType, URL: rawURL, Err: fmt.Errorf("content type %q is not allowed", mediaType)}
+}
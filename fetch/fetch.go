@@ -0,0 +1,298 @@
+// Package fetch downloads documents from URLs supplied by API callers. It
+// bounds the time, size and redirects of every request and refuses to
+// connect to private networks unless configured otherwise, so the server
+// cannot be used to reach internal services.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Options configures a Fetcher.
+type Options struct {
+	// Timeout bounds a whole fetch, including redirects and reading the body.
+	Timeout time.Duration
+	// MaxBodySize is the largest accepted response body in bytes.
+	MaxBodySize int64
+	// MaxRedirects is the number of redirects followed before giving up.
+	MaxRedirects int
+	// AllowedContentTypes lists the accepted media types. An entry ending
+	// in "/*", such as "text/*", accepts every subtype. An empty list
+	// accepts everything.
+	AllowedContentTypes []string
+	// AllowPrivateNetworks permits connections to loopback, private,
+	// link-local and other non-public addresses.
+	AllowPrivateNetworks bool
+	UserAgent            string
+}
+
+// DefaultOptions suit fetching web pages and feeds for ingestion.
+var DefaultOptions = Options{
+	Timeout:      30 * time.Second,
+	MaxBodySize:  10 << 20,
+	MaxRedirects: 5,
+	AllowedContentTypes: []string{
+		"text/*",
+		"application/xhtml+xml",
+		"application/xml",
+		"application/json",
+		"application/rss+xml",
+		"application/atom+xml",
+	},
+	UserAgent: "CognivaultBot/1.0",
+}
+
+// Response is a successfully fetched document. The response to a
+// conditional fetch of an unchanged document has StatusCode 304 and no
+// body.
+type Response struct {
+	// URL is the final URL after following redirects.
+	URL        *url.URL
+	StatusCode int
+	Header     http.Header
+	// ContentType is the media type of the body, from the Content-Type
+	// header or sniffed if the header is missing.
+	ContentType string
+	Body        []byte
+}
+
+// Fetcher downloads documents. It is safe for concurrent use.
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+	// public reports whether an address may be connected to unless
+	// AllowPrivateNetworks is set. It is isPublic except in tests.
+	public func(net.IP) bool
+}
+
+// New returns a Fetcher configured by opts.
+func New(opts Options) *Fetcher {
+	f := &Fetcher{opts: opts, public: isPublic}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !opts.AllowPrivateNetworks {
+		// The address is checked after DNS resolution, right before
+		// connecting, so names resolving to private addresses and DNS
+		// rebinding are caught as well.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !f.public(ip) {
+				return &blockedError{ip: host}
+			}
+			return nil
+		}
+	}
+
+	f.client = &http.Client{
+		// No proxy is used: the address checks above would only see the
+		// proxy's address.
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: opts.Timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return &Error{Kind: TooManyRedirects, URL: via[0].URL.String(),
+					Err: fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)}
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return &Error{Kind: InvalidURL, URL: req.URL.String(),
+					Err: fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)}
+			}
+			return nil
+		},
+	}
+
+	return f
+}
+
+// Fetch downloads rawURL. Failures are returned as *Error.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	return f.fetch(ctx, rawURL, nil)
+}
+
+// FetchIfModified downloads rawURL like Fetch unless it is unchanged since
+// the version the server described by the ETag and Last-Modified headers
+// etag and lastModified, either of which may be empty.
+func (f *Fetcher) FetchIfModified(ctx context.Context, rawURL, etag, lastModified string) (*Response, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return f.fetch(ctx, rawURL, header)
+}
+
+// fetch downloads rawURL, sending header with the request.
+func (f *Fetcher) fetch(ctx context.Context, rawURL string, header http.Header) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, &Error{Kind: InvalidURL, URL: rawURL, Err: err}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, &Error{Kind: InvalidURL, URL: rawURL, Err: fmt.Errorf("unsupported scheme %q", u.Scheme)}
+	}
+	if u.Host == "" {
+		return nil, &Error{Kind: InvalidURL, URL: rawURL, Err: errors.New("missing host")}
+	}
+
+	if f.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.opts.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, &Error{Kind: InvalidURL, URL: rawURL, Err: err}
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if f.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", f.opts.UserAgent)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, classify(rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && header != nil {
+		return &Response{URL: resp.Request.URL, StatusCode: resp.StatusCode, Header: resp.Header}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &Error{Kind: Status, URL: rawURL, StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("server responded with %s", resp.Status)}
+	}
+	if f.opts.MaxBodySize > 0 && resp.ContentLength > f.opts.MaxBodySize {
+		return nil, tooLarge(rawURL, f.opts.MaxBodySize)
+	}
+
+	// Check the declared type before downloading the body.
+	declared := mediaType(resp.Header.Get("Content-Type"))
+	if declared != "" && !f.allowed(declared) {
+		return nil, unsupported(rawURL, declared)
+	}
+
+	var body io.Reader = resp.Body
+	if f.opts.MaxBodySize > 0 {
+		body = io.LimitReader(resp.Body, f.opts.MaxBodySize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, classify(rawURL, err)
+	}
+	if f.opts.MaxBodySize > 0 && int64(len(data)) > f.opts.MaxBodySize {
+		return nil, tooLarge(rawURL, f.opts.MaxBodySize)
+	}
+
+	contentType := declared
+	if contentType == "" {
+		contentType = mediaType(http.DetectContentType(data))
+		if !f.allowed(contentType) {
+			return nil, unsupported(rawURL, contentType)
+		}
+	}
+
+	return &Response{
+		URL:         resp.Request.URL,
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		ContentType: contentType,
+		Body:        data,
+	}, nil
+}
+
+// allowed reports whether the media type is in the allowlist.
+func (f *Fetcher) allowed(mediaType string) bool {
+	if len(f.opts.AllowedContentTypes) == 0 {
+		return true
+	}
+	for _, pattern := range f.opts.AllowedContentTypes {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaType returns the lower-cased media type of a Content-Type header
+// without its parameters.
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// isPublic reports whether ip is a globally routable unicast address.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicNetworks are the special-purpose ranges not covered by the net.IP
+// predicates used in isPublic.
+var nonPublicNetworks = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "this" network
+		"100.64.0.0/10",   // carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"240.0.0.0/4",     // reserved, including broadcast
+		"64:ff9b::/96",    // NAT64, which can embed private IPv4 addresses
+		"64:ff9b:1::/48",  // local-use NAT64
+		"100::/64",        // discard
+		"2001:db8::/32",   // documentation
+		"2002::/16",       // 6to4, which can embed private IPv4 addresses
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
@@ -0,0 +1,134 @@
+package fetch
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"::ffff:8.8.8.8", true},
+
+		{"127.0.0.1", false},
+		{"127.255.255.254", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"169.254.0.1", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"fd12:3456:789a::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:192.168.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"2002:a00:1::1", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("invalid test address %s", tt.ip)
+		}
+		if got := isPublic(ip); got != tt.public {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	_, err := New(DefaultOptions).Fetch(context.Background(), server.URL)
+	if KindOf(err) != Blocked {
+		t.Errorf("Fetch(loopback) error = %v, want kind %s", err, Blocked)
+	}
+
+	opts := DefaultOptions
+	opts.AllowPrivateNetworks = true
+	resp, err := New(opts).Fetch(context.Background(), server.URL)
+	if err != nil || string(resp.Body) != "internal" {
+		t.Errorf("Fetch(loopback, private networks allowed) = %v, %v", resp, err)
+	}
+}
+
+func TestFetchRejectsInvalidURLs(t *testing.T) {
+	f := New(DefaultOptions)
+	for _, rawURL := range []string{"file:///etc/passwd", "gopher://example.com/", "http://", "://bad"} {
+		if _, err := f.Fetch(context.Background(), rawURL); KindOf(err) != InvalidURL {
+			t.Errorf("Fetch(%q) error = %v, want kind %s", rawURL, err, InvalidURL)
+		}
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	var target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("page"))
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer server.Close()
+
+	// Only the test server counts as public, so the redirects below are
+	// followed to it and to nothing else.
+	f := New(DefaultOptions)
+	host, _, _ := net.SplitHostPort(server.Listener.Addr().String())
+	serverIP := net.ParseIP(host)
+	f.public = func(ip net.IP) bool { return ip.Equal(serverIP) }
+
+	tests := []struct {
+		target string
+		kind   Kind
+	}{
+		{"/page", ""},
+		{"http://10.0.0.1/", Blocked},
+		{"http://192.168.0.1:8080/admin", Blocked},
+		{"http://169.254.169.254/latest/meta-data/", Blocked},
+		{"http://[::1]/", Blocked},
+		{"http://[fd00::1]/", Blocked},
+		{"http://[::ffff:127.0.0.2]/", Blocked},
+		{"file:///etc/passwd", InvalidURL},
+		{"ftp://example.com/file", InvalidURL},
+		{"/redirect", TooManyRedirects},
+	}
+	for _, tt := range tests {
+		target = tt.target
+		resp, err := f.Fetch(context.Background(), server.URL+"/start")
+		if KindOf(err) != tt.kind {
+			t.Errorf("redirect to %s: error = %v, want kind %q", tt.target, err, tt.kind)
+			continue
+		}
+		if tt.kind == "" && string(resp.Body) != "page" {
+			t.Errorf("redirect to %s: body = %q, want page", tt.target, resp.Body)
+		}
+		if tt.kind != "" && err.(*Error).URL != server.URL+"/start" {
+			t.Errorf("redirect to %s: error URL = %q, want the requested URL", tt.target, err.(*Error).URL)
+		}
+	}
+}
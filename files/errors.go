@@ -0,0 +1,58 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies why a file could not be read.
+type Kind string
+
+const (
+	// OutsideRoots means the path, or the file a symbolic link points to,
+	// is not inside an import root.
+	OutsideRoots Kind = "outside_roots"
+	// NotFound means the file does not exist or cannot be opened.
+	NotFound Kind = "not_found"
+	// NotRegular means the path names a directory or a special file.
+	NotRegular Kind = "not_regular"
+	// TooLarge means the file exceeds the maximum size.
+	TooLarge Kind = "too_large"
+	// TooManyFiles means a directory holds more files than allowed.
+	TooManyFiles Kind = "too_many_files"
+	// UnsupportedContentType means the sniffed media type is not allowed.
+	UnsupportedContentType Kind = "unsupported_content_type"
+)
+
+// Error is returned by the methods of Sandbox.
+type Error struct {
+	Kind Kind
+	// Path is the path as given by the caller.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("read %s: %v", e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the Kind of a file error, or "" if err is not one.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return ""
+}
+
+func outside(path string) error {
+	return &Error{Kind: OutsideRoots, Path: path, Err: errors.New("path is outside the import roots")}
+}
+
+func tooLarge(path string, max int64) error {
+	return &Error{Kind: TooLarge, Path: path, Err: fmt.Errorf("file exceeds %d bytes", max)}
+}
@@ -0,0 +1,284 @@
+// Package files reads files for ingestion from a set of import roots. Paths
+// given by API callers can never reach outside the roots, neither with ".."
+// nor through symbolic links.
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Options configures a Sandbox.
+type Options struct {
+	// Roots are the directories files may be read from. Relative paths are
+	// resolved against the first root. Without roots no file can be read.
+	Roots []string
+	// MaxFileSize is the largest file accepted, in bytes.
+	MaxFileSize int64
+	// MaxFiles is the largest number of files Walk returns.
+	MaxFiles int
+	// AllowedContentTypes lists the accepted media types, which are sniffed
+	// from the content of a file. An entry ending in "/*", such as
+	// "text/*", accepts every subtype. An empty list accepts everything.
+	AllowedContentTypes []string
+}
+
+// DefaultOptions accept text documents of up to 10 MiB and directories of up
+// to 1000 files. They have no roots,
+// so file ingestion stays disabled until roots are configured.
+var DefaultOptions = Options{
+	MaxFileSize: 10 << 20,
+	MaxFiles:    1000,
+	AllowedContentTypes: []string{
+		"text/*",
+		"application/xhtml+xml",
+		"application/xml",
+		"application/json",
+	},
+}
+
+// File is a file read from an import root.
+type File struct {
+	// Path is the absolute path of the file with symbolic links resolved.
+	Path string
+	// Root is the import root containing the file.
+	Root string
+	// ContentType is the media type sniffed from the content.
+	ContentType string
+	ModTime     time.Time
+	Body        []byte
+}
+
+// RelPath returns the path of the file relative to its root, with forward
+// slashes.
+func (f *File) RelPath() string {
+	rel, err := filepath.Rel(f.Root, f.Path)
+	if err != nil {
+		return f.Path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Sandbox reads files from import roots. It is safe for concurrent use.
+type Sandbox struct {
+	opts  Options
+	roots []string
+}
+
+// New returns a Sandbox for opts. The roots must be existing directories.
+func New(opts Options) (*Sandbox, error) {
+	s := &Sandbox{opts: opts}
+	for _, root := range opts.Roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("import root %s: %w", root, err)
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("import root %s: %w", root, err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("import root %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("import root %s is not a directory", root)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	return s, nil
+}
+
+// Roots returns the absolute, resolved import roots.
+func (s *Sandbox) Roots() []string {
+	return append([]string(nil), s.roots...)
+}
+
+// Resolve returns the absolute path of name with symbolic links resolved and
+// the root containing it. It fails with an *Error of kind OutsideRoots if
+// either the path as written or the file it resolves to is outside every
+// root.
+func (s *Sandbox) Resolve(name string) (path, root string, err error) {
+	if len(s.roots) == 0 {
+		return "", "", &Error{Kind: OutsideRoots, Path: name, Err: errors.New("file ingestion is disabled")}
+	}
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", "", &Error{Kind: OutsideRoots, Path: name, Err: errors.New("invalid path")}
+	}
+
+	// Relative paths are taken relative to the first root; Join cleans
+	// "..", so the check below sees where the path really points.
+	path = name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.roots[0], path)
+	}
+	path = filepath.Clean(path)
+	if s.rootOf(path) == "" {
+		// The roots are resolved, so a path written through a symbolic
+		// link to a root is only recognised after resolving it below.
+		// Missing files outside the roots are reported like existing ones
+		// so callers cannot probe the rest of the file system.
+		if _, err := os.Lstat(path); err != nil {
+			return "", "", outside(name)
+		}
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", &Error{Kind: NotFound, Path: name, Err: errors.New("no such file")}
+	}
+	if err != nil {
+		return "", "", &Error{Kind: NotFound, Path: name, Err: err}
+	}
+	root = s.rootOf(resolved)
+	if root == "" {
+		return "", "", outside(name)
+	}
+
+	return resolved, root, nil
+}
+
+// Read reads the file at name, which is absolute or relative to the first
+// root. Failures are returned as *Error.
+func (s *Sandbox) Read(name string) (*File, error) {
+	path, root, err := s.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, &Error{Kind: NotFound, Path: name, Err: err}
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &Error{Kind: NotRegular, Path: name, Err: errors.New("not a regular file")}
+	}
+	if s.opts.MaxFileSize > 0 && info.Size() > s.opts.MaxFileSize {
+		return nil, tooLarge(name, s.opts.MaxFileSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &Error{Kind: NotFound, Path: name, Err: err}
+	}
+	defer f.Close()
+
+	opened, err := checkOpened(name, path, info, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = f
+	if s.opts.MaxFileSize > 0 {
+		r = io.LimitReader(f, s.opts.MaxFileSize+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &Error{Kind: NotFound, Path: name, Err: err}
+	}
+	if s.opts.MaxFileSize > 0 && int64(len(body)) > s.opts.MaxFileSize {
+		return nil, tooLarge(name, s.opts.MaxFileSize)
+	}
+
+	contentType := sniff(path, body)
+	if !s.allowed(contentType) {
+		return nil, &Error{Kind: UnsupportedContentType, Path: name, Err: fmt.Errorf("content type %q is not allowed", contentType)}
+	}
+
+	return &File{
+		Path:        path,
+		Root:        root,
+		ContentType: contentType,
+		ModTime:     opened.ModTime(),
+		Body:        body,
+	}, nil
+}
+
+// checkOpened returns the FileInfo of f, opened from the resolved path of
+// name. The path may have been swapped for a symbolic link or another file
+// since it was resolved and described by info; if so, it fails with an
+// *Error of kind OutsideRoots.
+func checkOpened(name, path string, info fs.FileInfo, f *os.File) (fs.FileInfo, error) {
+	opened, err := f.Stat()
+	if err != nil {
+		return nil, &Error{Kind: NotFound, Path: name, Err: err}
+	}
+	if again, err := filepath.EvalSymlinks(path); err != nil || again != path || !os.SameFile(info, opened) {
+		return nil, outside(name)
+	}
+	return opened, nil
+}
+
+// rootOf returns the root containing path, or "" if there is none.
+func (s *Sandbox) rootOf(path string) string {
+	for _, root := range s.roots {
+		if within(root, path) {
+			return root
+		}
+	}
+	return ""
+}
+
+// within reports whether path is root or below it. Both must be clean.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// sniff returns the media type of a file from its content. Plain text is
+// refined by the file extension, so that Markdown, CSV or JSON files keep
+// their more specific type.
+func sniff(path string, body []byte) string {
+	contentType := mediaType(http.DetectContentType(body))
+	if contentType != "text/plain" {
+		return contentType
+	}
+
+	byExt := mediaType(mime.TypeByExtension(filepath.Ext(path)))
+	if byExt == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown":
+			byExt = "text/markdown"
+		}
+	}
+	if strings.HasPrefix(byExt, "text/") || byExt == "application/json" || byExt == "application/xml" {
+		return byExt
+	}
+	return contentType
+}
+
+// allowed reports whether the media type is in the allowlist.
+func (s *Sandbox) allowed(mediaType string) bool {
+	if len(s.opts.AllowedContentTypes) == 0 {
+		return true
+	}
+	for _, pattern := range s.opts.AllowedContentTypes {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mt))
+}
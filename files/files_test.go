@@ -0,0 +1,224 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sandboxDirs lays out an import root next to a directory outside of it:
+//
+//	root/note.txt
+//	root/sub/inner.md
+//	root/link.txt     -> root/note.txt
+//	root/escape.txt   -> outside/secret.txt
+//	root/escape       -> outside
+//	outside/secret.txt
+func sandboxDirs(t *testing.T) (root, outsideDir string) {
+	t.Helper()
+	tmp := t.TempDir()
+	root = filepath.Join(tmp, "root")
+	outsideDir = filepath.Join(tmp, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outsideDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{
+		filepath.Join(root, "note.txt"):         "a note",
+		filepath.Join(root, "sub", "inner.md"):  "# Inner",
+		filepath.Join(outsideDir, "secret.txt"): "secret",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		filepath.Join(root, "link.txt"):   filepath.Join(root, "note.txt"),
+		filepath.Join(root, "escape.txt"): filepath.Join(outsideDir, "secret.txt"),
+		filepath.Join(root, "escape"):     outsideDir,
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, outsideDir
+}
+
+func newSandbox(t *testing.T, roots ...string) *Sandbox {
+	t.Helper()
+	opts := DefaultOptions
+	opts.Roots = roots
+	s, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRead(t *testing.T) {
+	root, outsideDir := sandboxDirs(t)
+	s := newSandbox(t, root)
+
+	tests := []struct {
+		name string
+		kind Kind
+		body string
+	}{
+		{"note.txt", "", "a note"},
+		{"./sub/../note.txt", "", "a note"},
+		{"sub/inner.md", "", "# Inner"},
+		{filepath.Join(root, "note.txt"), "", "a note"},
+		{"link.txt", "", "a note"},
+
+		{"../outside/secret.txt", OutsideRoots, ""},
+		{"sub/../../outside/secret.txt", OutsideRoots, ""},
+		{"sub/../../../../../../etc/passwd", OutsideRoots, ""},
+		{filepath.Join(outsideDir, "secret.txt"), OutsideRoots, ""},
+		{filepath.Join(root, "..", "outside", "secret.txt"), OutsideRoots, ""},
+		{"/etc/passwd", OutsideRoots, ""},
+		{"escape.txt", OutsideRoots, ""},
+		{"escape/secret.txt", OutsideRoots, ""},
+		{"", OutsideRoots, ""},
+		{"note.txt\x00.md", OutsideRoots, ""},
+
+		// Missing files outside the roots are not told apart from
+		// existing ones.
+		{filepath.Join(outsideDir, "missing.txt"), OutsideRoots, ""},
+		{"../outside/missing.txt", OutsideRoots, ""},
+		{"missing.txt", NotFound, ""},
+		{"sub", NotRegular, ""},
+	}
+	for _, tt := range tests {
+		f, err := s.Read(tt.name)
+		if KindOf(err) != tt.kind {
+			t.Errorf("Read(%q) error = %v, want kind %q", tt.name, err, tt.kind)
+			continue
+		}
+		if tt.kind == "" && string(f.Body) != tt.body {
+			t.Errorf("Read(%q) body = %q, want %q", tt.name, f.Body, tt.body)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root, _ := sandboxDirs(t)
+	s := newSandbox(t, root)
+	resolvedRoot := s.Roots()[0]
+
+	path, gotRoot, err := s.Resolve("link.txt")
+	if err != nil || path != filepath.Join(resolvedRoot, "note.txt") || gotRoot != resolvedRoot {
+		t.Errorf("Resolve(link) = %q, %q, %v, want the file it points to", path, gotRoot, err)
+	}
+
+	f, err := s.Read("sub/inner.md")
+	if err != nil || f.RelPath() != "sub/inner.md" || f.ContentType != "text/markdown" {
+		t.Errorf("Read(sub/inner.md) = %+v, %v", f, err)
+	}
+}
+
+func TestResolveThroughLinkedRoot(t *testing.T) {
+	root, _ := sandboxDirs(t)
+	linkedRoot := filepath.Join(t.TempDir(), "linked")
+	if err := os.Symlink(root, linkedRoot); err != nil {
+		t.Fatal(err)
+	}
+	s := newSandbox(t, linkedRoot)
+
+	if _, err := s.Read(filepath.Join(linkedRoot, "note.txt")); err != nil {
+		t.Errorf("Read(through linked root) error = %v", err)
+	}
+	if _, err := s.Read(filepath.Join(linkedRoot, "escape.txt")); KindOf(err) != OutsideRoots {
+		t.Errorf("Read(escaping link through linked root) error = %v, want kind %q", err, OutsideRoots)
+	}
+}
+
+func TestReadWithoutRoots(t *testing.T) {
+	root, _ := sandboxDirs(t)
+	s := newSandbox(t)
+	if _, err := s.Read(filepath.Join(root, "note.txt")); KindOf(err) != OutsideRoots {
+		t.Errorf("Read(no roots) error = %v, want kind %q", err, OutsideRoots)
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	root, _ := sandboxDirs(t)
+	if err := os.WriteFile(filepath.Join(root, "image.png"), []byte("\x89PNG\r\n\x1a\n0000"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions
+	opts.Roots = []string{root}
+	opts.MaxFileSize = 4
+	s, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Read("note.txt"); KindOf(err) != TooLarge {
+		t.Errorf("Read(too large) error = %v, want kind %q", err, TooLarge)
+	}
+	s.opts.MaxFileSize = 0
+	if _, err := s.Read("image.png"); KindOf(err) != UnsupportedContentType {
+		t.Errorf("Read(png) error = %v, want kind %q", err, UnsupportedContentType)
+	}
+}
+
+func TestCheckOpenedDetectsSwaps(t *testing.T) {
+	root, outsideDir := sandboxDirs(t)
+	path := filepath.Join(root, "note.txt")
+
+	tests := []struct {
+		name string
+		swap func() error
+	}{
+		{"unchanged", func() error { return nil }},
+		{"replaced by another file", func() error {
+			other := filepath.Join(root, "other.txt")
+			if err := os.WriteFile(other, []byte("other"), 0o644); err != nil {
+				return err
+			}
+			return os.Rename(other, path)
+		}},
+		{"replaced by a link outside", func() error {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			return os.Symlink(filepath.Join(outsideDir, "secret.txt"), path)
+		}},
+	}
+	for _, tt := range tests {
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("a note"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The swap happens between checking the path and opening it.
+		if err := tt.swap(); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(resolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = checkOpened("note.txt", resolved, info, f)
+		f.Close()
+
+		want := Kind("")
+		if tt.name != "unchanged" {
+			want = OutsideRoots
+		}
+		if KindOf(err) != want {
+			t.Errorf("%s: checkOpened error = %v, want kind %q", tt.name, err, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package files
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// pattern is a path pattern with .gitignore semantics: a pattern without a
+// slash, other than a trailing one, matches a name at any depth; any other
+// pattern matches paths relative to its base directory. "**" matches any
+// number of directories and a trailing slash only matches directories.
+type pattern struct {
+	// base is the slash-separated directory the pattern is relative to,
+	// "" for the directory being walked.
+	base     string
+	segments []string
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// parsePattern parses one line of an ignore file, or an include or exclude
+// glob if negation is not allowed. It returns false for blank lines and
+// comments.
+func parsePattern(line, base string, allowNegation bool) (pattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	p := pattern{base: base}
+	if allowNegation && strings.HasPrefix(line, "!") {
+		p.negate, line = true, line[1:]
+	}
+	// A leading backslash escapes "#" and "!".
+	if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored, line = true, strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+	p.segments = strings.Split(line, "/")
+
+	return p, true
+}
+
+// match reports whether the slash-separated path rel, relative to the
+// directory being walked, matches the pattern.
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		rel = rel[len(p.base)+1:]
+	}
+
+	parts := strings.Split(rel, "/")
+	if !p.anchored {
+		return matchSegments(p.segments, parts[len(parts)-1:])
+	}
+	return matchSegments(p.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments.
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// patternList is an ordered list of patterns in which the last match wins,
+// so a negated pattern can re-include what an earlier one excluded.
+type patternList []pattern
+
+func (l patternList) match(rel string, isDir bool) bool {
+	matched := false
+	for _, p := range l {
+		if p.match(rel, isDir) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// parseGlobs parses include or exclude globs.
+func parseGlobs(globs []string) patternList {
+	var l patternList
+	for _, g := range globs {
+		if p, ok := parsePattern(g, "", false); ok {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// readIgnoreFile appends the patterns of the ignore file at file, which is in
+// the directory base relative to the directory being walked. A missing file
+// adds nothing.
+func readIgnoreFile(l patternList, file, base string) (patternList, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parsePattern(scanner.Text(), base, true); ok {
+			l = append(l, p)
+		}
+	}
+	return l, scanner.Err()
+}
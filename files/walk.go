@@ -0,0 +1,129 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultIgnoreFiles are the ignore files honoured by Walk unless
+// WalkOptions.IgnoreFiles is set.
+var DefaultIgnoreFiles = []string{".gitignore", ".ignore"}
+
+// WalkOptions selects the files of a directory.
+type WalkOptions struct {
+	// Include, if not empty, keeps only files matching one of the globs.
+	Include []string
+	// Exclude skips files and directories matching one of the globs.
+	Exclude []string
+	// IgnoreFiles are the names of .gitignore-style files whose patterns
+	// apply to the directory containing them and everything below.
+	// Nil means DefaultIgnoreFiles.
+	IgnoreFiles []string
+}
+
+// Entry is a file found by Walk.
+type Entry struct {
+	// Path is the path to pass to Read.
+	Path string
+	// RelPath is the slash-separated path relative to the walked directory.
+	RelPath string
+}
+
+// Walk returns the files below the directory dir, which is absolute or
+// relative to the first root, in lexical order. Globs use .gitignore syntax:
+// "*.md" matches Markdown files at any depth, "docs/**/*.md" only those below
+// docs. Version control directories, ignore files and symbolic links to
+// directories are always skipped; symbolic links to files are returned and
+// checked by Read.
+func (s *Sandbox) Walk(dir string, opts WalkOptions) ([]Entry, error) {
+	root, _, err := s.Resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, &Error{Kind: NotFound, Path: dir, Err: err}
+	}
+	if !info.IsDir() {
+		return nil, &Error{Kind: NotRegular, Path: dir, Err: errors.New("not a directory")}
+	}
+
+	ignoreFiles := opts.IgnoreFiles
+	if ignoreFiles == nil {
+		ignoreFiles = DefaultIgnoreFiles
+	}
+	isIgnoreFile := map[string]bool{}
+	for _, name := range ignoreFiles {
+		isIgnoreFile[name] = true
+	}
+	include := parseGlobs(opts.Include)
+	exclude := parseGlobs(opts.Exclude)
+	var ignored patternList
+
+	var entries []Entry
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." {
+				if d.Name() == ".git" || d.Name() == ".hg" || d.Name() == ".svn" ||
+					ignored.match(rel, true) || exclude.match(rel, true) {
+					return filepath.SkipDir
+				}
+			}
+			base := rel
+			if base == "." {
+				base = ""
+			}
+			for _, name := range ignoreFiles {
+				if ignored, err = readIgnoreFile(ignored, filepath.Join(path, name), base); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if isIgnoreFile[d.Name()] || ignored.match(rel, false) || exclude.match(rel, false) {
+			return nil
+		}
+		if len(include) > 0 && !include.match(rel, false) {
+			return nil
+		}
+		switch {
+		case d.Type().IsRegular():
+		case d.Type()&fs.ModeSymlink != 0:
+			if target, err := os.Stat(path); err != nil || target.IsDir() {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		if s.opts.MaxFiles > 0 && len(entries) >= s.opts.MaxFiles {
+			return &Error{Kind: TooManyFiles, Path: dir, Err: fmt.Errorf("more than %d files", s.opts.MaxFiles)}
+		}
+		entries = append(entries, Entry{Path: path, RelPath: rel})
+		return nil
+	})
+	if err != nil {
+		var fileErr *Error
+		if errors.As(err, &fileErr) {
+			return nil, fileErr
+		}
+		return nil, &Error{Kind: NotFound, Path: dir, Err: err}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelPath < entries[j].RelPath })
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"cognivaultServer/collections"
+	"context"
+	"errors"
+)
+
+// Duplicate is a data point of a Document whose content was already stored
+// in the collection and was handled by a dedupe policy instead of being
+// stored again.
+type Duplicate struct {
+	// DataPointID is the ID of the data point already holding the content.
+	DataPointID string                   `json:"data_point_id"`
+	Policy      collections.DedupePolicy `json:"policy"`
+}
+
+// DedupePolicy returns the policy applied to duplicates in a collection:
+// policy if set, otherwise the one of the collection's DedupeSettings, or
+// DedupeReject if they only keep content unique. It returns the empty
+// policy if duplicates are stored.
+func DedupePolicy(ctx context.Context, store collections.Store, collectionID string, policy collections.DedupePolicy) (collections.DedupePolicy, error) {
+	if policy != "" {
+		return policy, nil
+	}
+	settings, err := store.GetDedupeSettings(ctx, collectionID)
+	if errors.Is(err, collections.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if settings.Policy == "" && settings.Unique {
+		return collections.DedupeReject, nil
+	}
+	return settings.Policy, nil
+}
+
+// Deduplicate applies policy to dp, which is about to be created in a
+// collection, if its content is already stored there. It then replaces dp
+// by the stored data point, as changed by the policy, and returns the
+// Duplicate; otherwise it returns nil and dp is to be created. DedupeReject
+// yields a *collections.DuplicateError. Nothing is done for the empty
+// policy.
+func Deduplicate(ctx context.Context, store collections.Store, collectionID string, dp *collections.DataPoint, policy collections.DedupePolicy) (*Duplicate, error) {
+	if policy == "" {
+		return nil, nil
+	}
+	existing, err := store.FindDuplicate(ctx, collectionID, collections.ContentHash(dp.Value))
+	if errors.Is(err, collections.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch policy {
+	case collections.DedupeReject:
+		return nil, &collections.DuplicateError{DataPointID: existing.ID}
+	case collections.DedupeMerge:
+		changed := false
+		for k, v := range dp.Metadata {
+			if _, ok := existing.Metadata[k]; ok {
+				continue
+			}
+			if existing.Metadata == nil {
+				existing.Metadata = map[string]interface{}{}
+			}
+			existing.Metadata[k] = v
+			changed = true
+		}
+		if changed {
+			err = store.UpdateDataPoint(ctx, existing)
+		}
+	case collections.DedupeRevision:
+		existing.Value, existing.Metadata = dp.Value, dp.Metadata
+		err = store.UpdateDataPoint(ctx, existing)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	*dp = *existing
+	return &Duplicate{DataPointID: existing.ID, Policy: policy}, nil
+}
@@ -0,0 +1,256 @@
+package ingest
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"cognivaultServer/extract"
+	"cognivaultServer/feed"
+	"cognivaultServer/fetch"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFeed is returned when a feed URL does not serve an RSS 2.0 or Atom
+// feed.
+var ErrNotFeed error = apperr.New(apperr.Validation, "invalid_feed", "URL is not an RSS or Atom feed")
+
+// FeedPoll describes a poll of a feed.
+type FeedPoll struct {
+	// Status is FetchChanged if new entries were stored and FetchUnchanged
+	// if there were none.
+	Status collections.FetchStatus `json:"status"`
+	// HTTPStatus is the status the server answered with, 0 if it could not
+	// be reached.
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Entries lists the entries stored by the poll, oldest first.
+	Entries []collections.FeedEntry `json:"entries"`
+}
+
+// Subscribe fetches f.URL and, if it serves a feed, stores f under the tag
+// of f.CollectionID named tag, which is created if it does not exist yet.
+// The entries of the feed are then stored like those of later polls. Feeds
+// that cannot be fetched are not stored, and their error is returned as a
+// *fetch.Error or ErrNotFeed.
+func (i *Ingester) Subscribe(ctx context.Context, f *collections.Feed, tag string) (*FeedPoll, error) {
+	page, err := i.Fetcher.Fetch(ctx, f.URL)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := feed.Parse(page.Body)
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Validation, "invalid_feed", ErrNotFeed.Error())
+	}
+
+	err = i.Store.WithTx(ctx, func(store collections.Store) error {
+		t, err := store.GetTagByName(ctx, f.CollectionID, tag)
+		if errors.Is(err, collections.ErrNotFound) {
+			t = &collections.Tag{CollectionID: f.CollectionID, Name: tag}
+			err = store.CreateTag(ctx, t)
+		}
+		if err != nil {
+			return err
+		}
+		f.TagID = t.ID
+		f.Title = parsed.Title
+		return store.CreateFeed(ctx, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &FeedPoll{HTTPStatus: page.StatusCode}
+	poll.Entries, err = i.storeEntries(ctx, f, parsed)
+	return i.finishPoll(ctx, f, page, poll, err)
+}
+
+// Poll fetches f again, sending the validators of its last fetch, and
+// stores the entries that are new to it: one data point per entry, or none
+// for entries without text. Entries whose content is already stored in the
+// collection are handled by its dedupe policy.
+//
+// The outcome is recorded on f, which is then scheduled for its next poll.
+// Polls that fail are only reported by the returned FeedPoll; the entries
+// stored before the failure are kept. An error is returned if the outcome
+// could not be recorded.
+func (i *Ingester) Poll(ctx context.Context, f *collections.Feed) (*FeedPoll, error) {
+	poll := &FeedPoll{}
+	page, err := i.Fetcher.FetchIfModified(ctx, f.URL, f.ETag, f.LastModified)
+	if err != nil {
+		var fetchErr *fetch.Error
+		if errors.As(err, &fetchErr) {
+			poll.HTTPStatus = fetchErr.StatusCode
+		}
+		return i.finishPoll(ctx, f, nil, poll, err)
+	}
+
+	poll.HTTPStatus = page.StatusCode
+	if page.StatusCode == http.StatusNotModified {
+		poll.Status = collections.FetchNotModified
+		return i.finishPoll(ctx, f, nil, poll, nil)
+	}
+	parsed, err := feed.Parse(page.Body)
+	if err == nil {
+		f.Title = parsed.Title
+		poll.Entries, err = i.storeEntries(ctx, f, parsed)
+	}
+	return i.finishPoll(ctx, f, page, poll, err)
+}
+
+// finishPoll records the outcome of a poll of f, which failed with err if
+// not nil. The validators of page, if not nil, are kept for the next poll
+// once all entries are stored.
+func (i *Ingester) finishPoll(ctx context.Context, f *collections.Feed, page *fetch.Response, poll *FeedPoll, err error) (*FeedPoll, error) {
+	switch {
+	case err != nil:
+		poll.Status, poll.Error = collections.FetchFailed, err.Error()
+		f.Failures++
+	case poll.Status == "" && len(poll.Entries) > 0:
+		poll.Status = collections.FetchChanged
+	case poll.Status == "":
+		poll.Status = collections.FetchUnchanged
+	}
+	if err == nil {
+		f.Failures = 0
+		if page != nil {
+			f.ETag, f.LastModified = page.Header.Get("ETag"), page.Header.Get("Last-Modified")
+		}
+	}
+	if poll.Entries == nil {
+		poll.Entries = []collections.FeedEntry{}
+	}
+
+	t := time.Now().UTC()
+	f.LastFetchedAt = &t
+	f.LastStatus = poll.Status
+	f.LastError = poll.Error
+	f.Schedule(t)
+	if err := i.Store.UpdateFeed(ctx, f); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// storeEntries stores the entries of parsed that are new to f, oldest
+// first, and returns them.
+func (i *Ingester) storeEntries(ctx context.Context, f *collections.Feed, parsed *feed.Feed) ([]collections.FeedEntry, error) {
+	collection, err := i.Store.GetCollection(ctx, f.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := i.Store.GetTag(ctx, f.TagID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []collections.FeedEntry
+	for n := len(parsed.Entries) - 1; n >= 0; n-- {
+		e := parsed.Entries[n]
+		_, err := i.Store.GetFeedEntry(ctx, f.ID, e.GUID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, collections.ErrNotFound) {
+			return stored, err
+		}
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+
+		entry := collections.FeedEntry{FeedID: f.ID, GUID: e.GUID, Title: e.Title, Link: e.Link, PublishedAt: e.Published}
+		entry.DataPointID, err = i.storeEntry(ctx, f, collection.Name, tag.Name, e)
+		if err != nil {
+			return stored, err
+		}
+		if err := i.Store.CreateFeedEntry(ctx, &entry); err != nil {
+			return stored, err
+		}
+		stored = append(stored, entry)
+	}
+	return stored, nil
+}
+
+// storeEntry stores e as a single data point under the collection and tag
+// of f and returns its ID, or the ID of the data point already holding its
+// content. It returns an empty ID for an entry without text.
+func (i *Ingester) storeEntry(ctx context.Context, f *collections.Feed, collection, tag string, e feed.Entry) (string, error) {
+	doc, err := i.entryDocument(ctx, f, collection, tag, e)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := i.Ingest(ctx, *doc)
+	var duplicate *collections.DuplicateError
+	switch {
+	case errors.Is(err, ErrEmpty):
+		return "", nil
+	case errors.As(err, &duplicate):
+		return duplicate.DataPointID, nil
+	case err != nil:
+		return "", err
+	}
+	return res.DataPoints[0].ID, nil
+}
+
+// entryDocument turns e into a Document kept in one piece. With FullText
+// the text is that of the page e links to, fetched like a URL source; the
+// content of the entry is used if the page cannot be fetched.
+func (i *Ingester) entryDocument(ctx context.Context, f *collections.Feed, collection, tag string, e feed.Entry) (*Document, error) {
+	none := &chunking.Options{Strategy: chunking.None}
+	var doc *Document
+	if f.FullText && e.Link != "" {
+		var err error
+		doc, err = i.loadURL(ctx, Request{Collection: collection, Tag: tag, URL: e.Link, Chunking: none})
+		if err != nil {
+			// The entry is still worth keeping with the text of the feed.
+			log.Printf("Failed to fetch the full text of %s: %v", e.Link, err)
+			doc = nil
+		} else {
+			doc.Crawl = nil
+		}
+	}
+	if doc == nil {
+		text, err := extract.Fragment(strings.NewReader(e.Content))
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) == "" {
+			text = e.Title
+		}
+		source := e.Link
+		if source == "" {
+			source = f.URL
+		}
+		doc = &Document{
+			Collection: collection,
+			Tag:        tag,
+			Source:     source,
+			Text:       text,
+			Chunking:   none,
+			Metadata:   map[string]interface{}{},
+		}
+		if e.Link != "" {
+			doc.Metadata["source_url"] = e.Link
+		}
+	}
+
+	doc.Metadata["feed_id"] = f.ID
+	doc.Metadata["feed_url"] = f.URL
+	doc.Metadata["entry_guid"] = e.GUID
+	entry := map[string]string{"title": e.Title, "byline": e.Author}
+	if e.Published != nil {
+		entry["published_at"] = e.Published.Format(time.RFC3339)
+	}
+	// The page knows its title, author and date best.
+	for k, v := range entry {
+		if _, ok := doc.Metadata[k]; !ok && v != "" {
+			doc.Metadata[k] = v
+		}
+	}
+	return doc, nil
+}
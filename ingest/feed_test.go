@@ -0,0 +1,218 @@
+package ingest
+
+import (
+	"cognivaultServer/collections"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// feedServer serves an RSS feed with the items set on it, newest first,
+// answering conditional requests for its current ETag with 304.
+type feedServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	etag   string
+	items  []string
+	status int
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	s := &feedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>`)
+		for _, item := range s.items {
+			fmt.Fprint(w, item)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// set makes s serve items from now on, with a new ETag.
+func (s *feedServer) set(etag string, items ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag, s.items, s.status = etag, items, 0
+}
+
+func rssItem(guid, text string) string {
+	return fmt.Sprintf(`<item><guid>%s</guid><title>%s</title><description>%s</description></item>`, guid, strings.ToUpper(guid), text)
+}
+
+// entryGUIDs returns the GUIDs of entries in order.
+func entryGUIDs(entries []collections.FeedEntry) string {
+	guids := make([]string, len(entries))
+	for i, e := range entries {
+		guids[i] = e.GUID
+	}
+	return strings.Join(guids, ",")
+}
+
+func TestSubscribeAndPoll(t *testing.T) {
+	ctx := context.Background()
+	server := newFeedServer(t)
+	i := newTestIngester(t)
+	c := &collections.Collection{Name: "news"}
+	if err := i.Store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	server.set(`"v1"`, rssItem("b", "Second story."), rssItem("a", "First story."))
+	f := &collections.Feed{CollectionID: c.ID, URL: server.URL, Interval: collections.Duration(3600e9)}
+	poll, err := i.Subscribe(ctx, f, "blogs/news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.Status != collections.FetchChanged || entryGUIDs(poll.Entries) != "a,b" || f.ETag != `"v1"` || f.Title != "News" || f.NextFetchAt == nil {
+		t.Fatalf("Subscribe = %+v, feed %+v, want a and b stored oldest first", poll, f)
+	}
+	dp, err := i.Store.GetDataPoint(ctx, poll.Entries[0].DataPointID)
+	if err != nil || dp.Value != "First story." || dp.Metadata["entry_guid"] != "a" || dp.Metadata["title"] != "A" || dp.Metadata["feed_id"] != f.ID {
+		t.Errorf("data point of a = %+v, %v", dp, err)
+	}
+
+	poll, err = i.Poll(ctx, f)
+	if err != nil || poll.Status != collections.FetchNotModified || poll.HTTPStatus != http.StatusNotModified || len(poll.Entries) != 0 {
+		t.Errorf("Poll(not modified) = %+v, %v", poll, err)
+	}
+
+	// Entries already stored are told apart by their GUID, even if their
+	// content changed.
+	server.set(`"v2"`, rssItem("c", "Third story."), rssItem("b", "Second story, edited."), rssItem("a", "First story."))
+	poll, err = i.Poll(ctx, f)
+	if err != nil || poll.Status != collections.FetchChanged || entryGUIDs(poll.Entries) != "c" || f.ETag != `"v2"` {
+		t.Errorf("Poll(new entry) = %+v, %v, ETag %s", poll, err, f.ETag)
+	}
+
+	server.set(`"v3"`, rssItem("c", "Third story."))
+	poll, err = i.Poll(ctx, f)
+	if err != nil || poll.Status != collections.FetchUnchanged || f.ETag != `"v3"` {
+		t.Errorf("Poll(no new entries) = %+v, %v, ETag %s", poll, err, f.ETag)
+	}
+
+	server.mu.Lock()
+	server.status = http.StatusInternalServerError
+	server.mu.Unlock()
+	for n := 1; n <= 2; n++ {
+		poll, err = i.Poll(ctx, f)
+		if err != nil || poll.Status != collections.FetchFailed || poll.HTTPStatus != http.StatusInternalServerError || f.Failures != n || f.LastError == "" {
+			t.Errorf("Poll(failing %d) = %+v, %v, failures %d", n, poll, err, f.Failures)
+		}
+	}
+	if f.ETag != `"v3"` {
+		t.Errorf("ETag after failed polls = %s, want the last one", f.ETag)
+	}
+
+	server.set(`"v4"`, rssItem("d", "Fourth story."))
+	poll, err = i.Poll(ctx, f)
+	if err != nil || poll.Status != collections.FetchChanged || f.Failures != 0 || f.LastError != "" {
+		t.Errorf("Poll(recovered) = %+v, %v, feed %+v", poll, err, f)
+	}
+
+	stored, err := i.Store.ListFeedEntries(ctx, f.ID, collections.Page{})
+	if err != nil || entryGUIDs(stored) != "d,c,b,a" {
+		t.Errorf("ListFeedEntries = %s, %v, want d,c,b,a", entryGUIDs(stored), err)
+	}
+	saved, err := i.Store.GetFeed(ctx, f.ID)
+	if err != nil || saved.ETag != `"v4"` || saved.LastStatus != collections.FetchChanged || saved.LastFetchedAt == nil {
+		t.Errorf("GetFeed = %+v, %v", saved, err)
+	}
+}
+
+func TestPollDuplicateEntries(t *testing.T) {
+	ctx := context.Background()
+	server := newFeedServer(t)
+	i := newTestIngester(t)
+	c := &collections.Collection{Name: "news"}
+	if err := i.Store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Store.SaveDedupeSettings(ctx, &collections.DedupeSettings{CollectionID: c.ID, Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy is rejected as a duplicate, and its entry points at the
+	// data point of the original. Entries without text are kept by their
+	// title.
+	server.set(`"v1"`, rssItem("copy", "Same story."), rssItem("empty", ""), rssItem("original", "Same story."))
+	f := &collections.Feed{CollectionID: c.ID, URL: server.URL}
+	poll, err := i.Subscribe(ctx, f, "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.Status != collections.FetchChanged || entryGUIDs(poll.Entries) != "original,empty,copy" {
+		t.Fatalf("Subscribe = %+v", poll)
+	}
+	if original, copied := poll.Entries[0].DataPointID, poll.Entries[2].DataPointID; original == "" || copied != original {
+		t.Errorf("data points of original and copy = %q, %q, want the same", original, copied)
+	}
+	dp, err := i.Store.GetDataPoint(ctx, poll.Entries[1].DataPointID)
+	if err != nil || dp.Value != "EMPTY" {
+		t.Errorf("data point of the entry without text = %+v, %v, want its title", dp, err)
+	}
+}
+
+func TestSubscribeAtomXHTML(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+			<entry><id>urn:1</id><title>Post</title><content type="xhtml">
+				<div xmlns="http://www.w3.org/1999/xhtml"><p>The <em>body</em> of the post.</p></div>
+			</content></entry></feed>`)
+	}))
+	defer server.Close()
+
+	i := newTestIngester(t)
+	c := &collections.Collection{Name: "blog"}
+	if err := i.Store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	poll, err := i.Subscribe(ctx, &collections.Feed{CollectionID: c.ID, URL: server.URL}, "posts")
+	if err != nil || len(poll.Entries) != 1 {
+		t.Fatalf("Subscribe = %+v, %v", poll, err)
+	}
+	dp, err := i.Store.GetDataPoint(ctx, poll.Entries[0].DataPointID)
+	if err != nil || !strings.Contains(dp.Value, "The body of the post.") {
+		t.Errorf("data point = %+v, %v, want the xhtml content", dp, err)
+	}
+}
+
+func TestSubscribeRejectsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Not a feed.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	i := newTestIngester(t)
+	c := &collections.Collection{Name: "blog"}
+	if err := i.Store.CreateCollection(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	f := &collections.Feed{CollectionID: c.ID, URL: server.URL}
+	if _, err := i.Subscribe(context.Background(), f, "posts"); err == nil || !strings.Contains(err.Error(), ErrNotFeed.Error()) {
+		t.Errorf("Subscribe(page) error = %v, want %v", err, ErrNotFeed)
+	}
+	if feeds, _ := i.Store.ListFeeds(context.Background(), "", collections.Page{}); len(feeds) != 0 {
+		t.Errorf("feeds = %+v after a rejected subscription", feeds)
+	}
+}
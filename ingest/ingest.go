@@ -0,0 +1,294 @@
+// Package ingest files text into a collection: it resolves or creates the
+// collection and tag, splits the text into chunks, stores every chunk as a
+// data point and indexes it for semantic search.
+package ingest
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"cognivaultServer/fetch"
+	"cognivaultServer/files"
+	"cognivaultServer/search"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrEmpty is returned when a document has no text to store.
+var ErrEmpty error = apperr.New(apperr.Validation, "empty_source", "document is empty")
+
+// Document is a text to be filed under a collection and tag, both of which
+// are created if they do not exist yet.
+type Document struct {
+	Collection string
+	Tag        string
+	// Source describes where the text came from, such as a URL or file path.
+	Source string
+	Text   string
+	// Chunking defaults to chunking.DefaultOptions if nil.
+	Chunking *chunking.Options
+	// Metadata is stored on every data point created for the text.
+	Metadata map[string]interface{}
+	// Attachments are kept with the document, which is recorded even if the
+	// text fits in a single chunk.
+	Attachments []collections.Attachment
+	// Dedupe handles chunks whose content is already stored in the
+	// collection. It defaults to the policy of the collection, see
+	// DedupePolicy.
+	Dedupe collections.DedupePolicy
+	// Crawl tracks the URL the text was fetched from so that it can be
+	// crawled again. It is created with the first data point of the text.
+	Crawl *collections.Source
+	// fetchStatus is the HTTP status of the fetch of Crawl.
+	fetchStatus int
+}
+
+// Result describes what was stored for a Document.
+type Result struct {
+	Collection *collections.Collection
+	Tag        *collections.Tag
+	// Document is nil if the text was stored as a single data point
+	// without attachments.
+	Document *collections.Document
+	// DataPoints holds a data point per chunk: the one created for it, or
+	// the one already holding its content for the chunks in Duplicates.
+	DataPoints  []collections.DataPoint
+	Duplicates  []Duplicate
+	Attachments []collections.Attachment
+	// Source is the Crawl of the Document, nil if it had none or if no data
+	// point was created for it.
+	Source *collections.Source
+	// Metadata is the metadata of the Document.
+	Metadata map[string]interface{}
+}
+
+// Ingester stores documents. Fetcher and Files load the URL and file
+// sources of a Request and are only needed by Run.
+type Ingester struct {
+	Store   collections.Store
+	Search  *search.Service
+	Fetcher *fetch.Fetcher
+	Files   *files.Sandbox
+	// RecrawlInterval is the Interval of the sources of fetched URLs whose
+	// Request does not set one.
+	RecrawlInterval time.Duration
+}
+
+// Ingest stores doc. All data points are created in a single transaction;
+// indexing them for semantic search happens afterwards and only logs errors.
+func (i *Ingester) Ingest(ctx context.Context, doc Document) (*Result, error) {
+	opts := chunking.DefaultOptions
+	if doc.Chunking != nil {
+		opts = *doc.Chunking
+	}
+	chunker, err := chunking.New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(doc.Text) == "" {
+		return nil, ErrEmpty
+	}
+	chunks := chunker.Chunk(doc.Text)
+
+	res := Result{Metadata: doc.Metadata}
+	// changed holds the data points to index: those created or changed by
+	// a dedupe policy.
+	var changed []collections.DataPoint
+	err = i.Store.WithTx(ctx, func(store collections.Store) error {
+		var err error
+		res.Collection, err = collection(ctx, store, doc.Collection)
+		if err != nil {
+			return err
+		}
+
+		res.Tag, err = store.GetTagByName(ctx, res.Collection.ID, doc.Tag)
+		if errors.Is(err, collections.ErrNotFound) {
+			res.Tag = &collections.Tag{CollectionID: res.Collection.ID, Name: doc.Tag}
+			err = store.CreateTag(ctx, res.Tag)
+		}
+		if err != nil {
+			return err
+		}
+		policy, err := DedupePolicy(ctx, store, res.Collection.ID, doc.Dedupe)
+		if err != nil {
+			return err
+		}
+
+		// deduplicate handles dp if it duplicates stored content and
+		// reports whether it did.
+		deduplicate := func(dp *collections.DataPoint) (bool, error) {
+			duplicate, err := Deduplicate(ctx, store, res.Collection.ID, dp, policy)
+			if err != nil || duplicate == nil {
+				return false, err
+			}
+			res.Duplicates = append(res.Duplicates, *duplicate)
+			res.DataPoints = append(res.DataPoints, *dp)
+			if duplicate.Policy != collections.DedupeSkip {
+				changed = append(changed, *dp)
+			}
+			return true, nil
+		}
+
+		// A text that fits in one chunk is stored as a plain data point.
+		if len(chunks) == 1 && chunks[0].Text == strings.TrimSpace(doc.Text) && len(doc.Attachments) == 0 {
+			dp := collections.DataPoint{TagID: res.Tag.ID, Value: chunks[0].Text, Metadata: doc.Metadata}
+			if ok, err := deduplicate(&dp); ok || err != nil {
+				return err
+			}
+			if err := trackSource(ctx, store, &res, doc, opts, &dp); err != nil {
+				return err
+			}
+			if err := store.CreateDataPoint(ctx, &dp); err != nil {
+				return err
+			}
+			res.DataPoints = []collections.DataPoint{dp}
+			changed = res.DataPoints
+			return recordFetch(ctx, store, &res, doc)
+		}
+
+		res.DataPoints = make([]collections.DataPoint, 0, len(chunks))
+		for _, c := range chunks {
+			dp := collections.DataPoint{TagID: res.Tag.ID, Value: c.Text, Metadata: doc.Metadata}
+			if ok, err := deduplicate(&dp); err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+
+			// The document is only recorded once it has a chunk of its own.
+			if res.Document == nil {
+				if err := createDocument(ctx, store, &res, doc, opts); err != nil {
+					return err
+				}
+			}
+			dp.Chunk = &collections.ChunkRef{
+				DocumentID: res.Document.ID,
+				Index:      c.Index,
+				Offset:     c.Offset,
+			}
+			if err := trackSource(ctx, store, &res, doc, opts, &dp); err != nil {
+				return err
+			}
+			if err := store.CreateDataPoint(ctx, &dp); err != nil {
+				return err
+			}
+			res.DataPoints = append(res.DataPoints, dp)
+			changed = append(changed, dp)
+		}
+		return recordFetch(ctx, store, &res, doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed embedding only degrades semantic search; it is retried on the next start.
+	if err := i.Search.Index(ctx, changed...); err != nil {
+		log.Println(err)
+	}
+
+	return &res, nil
+}
+
+// createDocument records the document of doc with its attachments in res.
+func createDocument(ctx context.Context, store collections.Store, res *Result, doc Document, opts chunking.Options) error {
+	res.Document = &collections.Document{
+		CollectionID:  res.Collection.ID,
+		Source:        doc.Source,
+		ChunkStrategy: string(opts.Strategy),
+		ChunkSize:     opts.Size,
+		ChunkOverlap:  opts.Overlap,
+	}
+	if err := store.CreateDocument(ctx, res.Document); err != nil {
+		return err
+	}
+
+	for _, a := range doc.Attachments {
+		a.DocumentID = res.Document.ID
+		if err := store.CreateAttachment(ctx, &a); err != nil {
+			return err
+		}
+		a.Data = nil
+		res.Attachments = append(res.Attachments, a)
+	}
+	return nil
+}
+
+// trackSource creates the source of doc.Crawl in res when dp is the first
+// data point created for doc, and files dp under it.
+func trackSource(ctx context.Context, store collections.Store, res *Result, doc Document, opts chunking.Options, dp *collections.DataPoint) error {
+	if doc.Crawl == nil {
+		return nil
+	}
+	if res.Source == nil {
+		src := *doc.Crawl
+		src.CollectionID = res.Collection.ID
+		src.TagID = res.Tag.ID
+		if res.Document != nil {
+			src.DocumentID = res.Document.ID
+		}
+		src.ChunkStrategy = string(opts.Strategy)
+		src.ChunkSize = opts.Size
+		src.ChunkOverlap = opts.Overlap
+		src.ContentHash = collections.ContentHash(doc.Text)
+		fetchedAt := time.Now().UTC()
+		src.LastFetchedAt = &fetchedAt
+		src.LastStatus = collections.FetchChanged
+		src.Schedule(fetchedAt)
+		if err := store.CreateSource(ctx, &src); err != nil {
+			return err
+		}
+		res.Source = &src
+	}
+	dp.SourceID = res.Source.ID
+	return nil
+}
+
+// recordFetch adds the fetch of doc.Crawl to the history of the source in
+// res, if one was created.
+func recordFetch(ctx context.Context, store collections.Store, res *Result, doc Document) error {
+	if res.Source == nil {
+		return nil
+	}
+	f := collections.SourceFetch{
+		SourceID:   res.Source.ID,
+		Status:     collections.FetchChanged,
+		HTTPStatus: doc.fetchStatus,
+		FetchedAt:  *res.Source.LastFetchedAt,
+	}
+	for _, dp := range res.DataPoints {
+		if dp.SourceID == res.Source.ID {
+			f.Created++
+		}
+	}
+	return store.CreateSourceFetch(ctx, &f)
+}
+
+// ensureCollection returns the collection called name, creating it if it
+// does not exist yet.
+func (i *Ingester) ensureCollection(ctx context.Context, name string) (*collections.Collection, error) {
+	var c *collections.Collection
+	err := i.Store.WithTx(ctx, func(store collections.Store) error {
+		var err error
+		c, err = collection(ctx, store, name)
+		return err
+	})
+	return c, err
+}
+
+// collection returns the collection called name from store, creating it if
+// it does not exist yet.
+func collection(ctx context.Context, store collections.Store, name string) (*collections.Collection, error) {
+	c, err := store.GetCollectionByName(ctx, name)
+	if errors.Is(err, collections.ErrNotFound) {
+		c = &collections.Collection{Name: name}
+		err = store.CreateCollection(ctx, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,240 @@
+package ingest
+
+import (
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"cognivaultServer/fetch"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Recrawl fetches src again, sending the validators of its last fetch, and
+// brings its data points up to date if the text of the page changed: those
+// holding the text of a chunk still are kept, the others are updated in
+// place, which records revisions, the chunks the text gained are created
+// and the data points of the chunks it lost are moved to the trash.
+// Updated chunks keep their position in the document. Text the collection
+// already holds elsewhere is handled by its dedupe policy: rejected chunks
+// are not stored, and data points that would take such text are moved to
+// the trash.
+//
+// The crawl is added to the fetch history of src, which is then scheduled
+// for its next crawl. Crawls that fail are only reported by the returned
+// SourceFetch; an error is returned if the crawl could not be recorded.
+func (i *Ingester) Recrawl(ctx context.Context, src *collections.Source) (*collections.SourceFetch, error) {
+	f := &collections.SourceFetch{SourceID: src.ID}
+	var changed []collections.DataPoint
+	page, err := i.Fetcher.FetchIfModified(ctx, src.URL, src.ETag, src.LastModified)
+	if err == nil {
+		f.HTTPStatus = page.StatusCode
+		changed, err = i.refresh(ctx, src, page, f)
+	} else {
+		var fetchErr *fetch.Error
+		if errors.As(err, &fetchErr) {
+			f.HTTPStatus = fetchErr.StatusCode
+		}
+	}
+	if err != nil {
+		f.Status, f.Error = collections.FetchFailed, err.Error()
+		f.Created, f.Updated, f.Deleted = 0, 0, 0
+		src.Failures++
+	} else {
+		src.Failures = 0
+	}
+
+	t := time.Now().UTC()
+	f.FetchedAt = t
+	src.LastFetchedAt = &t
+	src.LastStatus = f.Status
+	src.LastError = f.Error
+	src.Schedule(t)
+	err = i.Store.WithTx(ctx, func(store collections.Store) error {
+		if err := store.UpdateSource(ctx, src); err != nil {
+			return err
+		}
+		return store.CreateSourceFetch(ctx, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed embedding only degrades semantic search; it is retried on the next start.
+	if err := i.Search.Index(ctx, changed...); err != nil {
+		log.Println(err)
+	}
+
+	return f, nil
+}
+
+// refresh stores the text of page as the new content of src, counting the
+// data points it changes in f, and returns the data points to index. The
+// validators and hash of src are only updated once the text is stored.
+func (i *Ingester) refresh(ctx context.Context, src *collections.Source, page *fetch.Response, f *collections.SourceFetch) ([]collections.DataPoint, error) {
+	if page.StatusCode == http.StatusNotModified {
+		f.Status = collections.FetchNotModified
+		return nil, nil
+	}
+
+	contentType := page.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = page.ContentType
+	}
+	doc := &Document{Text: string(page.Body), Metadata: map[string]interface{}{"source_url": src.URL}}
+	if err := readable(doc, contentType, page.URL, false); err != nil {
+		return nil, err
+	}
+
+	hash := collections.ContentHash(doc.Text)
+	if hash == src.ContentHash {
+		f.Status = collections.FetchUnchanged
+		src.ETag, src.LastModified = page.Header.Get("ETag"), page.Header.Get("Last-Modified")
+		return nil, nil
+	}
+	if strings.TrimSpace(doc.Text) == "" {
+		return nil, ErrEmpty
+	}
+
+	opts := chunking.Options{Strategy: chunking.Strategy(src.ChunkStrategy), Size: src.ChunkSize, Overlap: src.ChunkOverlap}
+	chunker, err := chunking.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	chunks := chunker.Chunk(doc.Text)
+
+	var changed []collections.DataPoint
+	documentID := src.DocumentID
+	err = i.Store.WithTx(ctx, func(store collections.Store) error {
+		changed = nil
+		f.Created, f.Updated, f.Deleted = 0, 0, 0
+		documentID = src.DocumentID
+
+		existing, err := store.ListSourceDataPoints(ctx, src.ID)
+		if err != nil {
+			return err
+		}
+		policy, err := DedupePolicy(ctx, store, src.CollectionID, "")
+		if err != nil {
+			return err
+		}
+
+		// Chunks whose text a data point of the source still holds keep
+		// it, so that text moving between chunks is not taken for a
+		// duplicate of itself. The other chunks update the remaining data
+		// points in order.
+		kept := make([]*collections.DataPoint, len(chunks))
+		claimed := make([]bool, len(existing))
+		for n, c := range chunks {
+			for m := range existing {
+				if !claimed[m] && existing[m].Value == c.Text {
+					kept[n], claimed[m] = &existing[m], true
+					break
+				}
+			}
+		}
+		next := 0
+		for n := range chunks {
+			for kept[n] == nil && next < len(existing) {
+				if !claimed[next] {
+					kept[n], claimed[next] = &existing[next], true
+				}
+				next++
+			}
+		}
+
+		for n, c := range chunks {
+			if dp := kept[n]; dp != nil {
+				metadata := map[string]interface{}{}
+				for k, v := range dp.Metadata {
+					metadata[k] = v
+				}
+				for k, v := range doc.Metadata {
+					metadata[k] = v
+				}
+				if dp.Value == c.Text && reflect.DeepEqual(dp.Metadata, metadata) {
+					continue
+				}
+				updated := *dp
+				updated.Value, updated.Metadata = c.Text, metadata
+				err := store.UpdateDataPoint(ctx, &updated)
+				if errors.As(err, new(*collections.DuplicateError)) {
+					// The text is stored in the collection already, so the
+					// data point is as outdated as those of lost chunks.
+					if err := store.DeleteDataPoint(ctx, dp.ID); err != nil {
+						return err
+					}
+					f.Deleted++
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				changed = append(changed, updated)
+				f.Updated++
+				continue
+			}
+
+			dp := collections.DataPoint{TagID: src.TagID, Value: c.Text, Metadata: doc.Metadata, SourceID: src.ID}
+			// Text stored in the collection already is handled by its
+			// dedupe policy like that of new sources, and not stored again
+			// when the policy rejects it.
+			duplicate, err := Deduplicate(ctx, store, src.CollectionID, &dp, policy)
+			if errors.As(err, new(*collections.DuplicateError)) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if duplicate != nil {
+				if duplicate.Policy != collections.DedupeSkip {
+					changed = append(changed, dp)
+				}
+				continue
+			}
+
+			if documentID == "" {
+				d := collections.Document{
+					CollectionID:  src.CollectionID,
+					Source:        src.URL,
+					ChunkStrategy: src.ChunkStrategy,
+					ChunkSize:     src.ChunkSize,
+					ChunkOverlap:  src.ChunkOverlap,
+				}
+				if err := store.CreateDocument(ctx, &d); err != nil {
+					return err
+				}
+				documentID = d.ID
+			}
+			dp.Chunk = &collections.ChunkRef{DocumentID: documentID, Index: c.Index, Offset: c.Offset}
+			if err := store.CreateDataPoint(ctx, &dp); err != nil {
+				return err
+			}
+			changed = append(changed, dp)
+			f.Created++
+		}
+
+		for m, dp := range existing {
+			if claimed[m] {
+				continue
+			}
+			if err := store.DeleteDataPoint(ctx, dp.ID); err != nil {
+				return err
+			}
+			f.Deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f.Status = collections.FetchChanged
+	src.DocumentID = documentID
+	src.ContentHash = hash
+	src.ETag, src.LastModified = page.Header.Get("ETag"), page.Header.Get("Last-Modified")
+	return changed, nil
+}
@@ -0,0 +1,227 @@
+package ingest
+
+import (
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pageServer serves a text page, answering conditional requests for its
+// current ETag with 304.
+type pageServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	etag   string
+	text   string
+	status int
+}
+
+func newPageServer(t *testing.T, etag, text string) *pageServer {
+	s := &pageServer{etag: etag, text: text}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+		w.Header().Set("Last-Modified", "Tue, 02 Jan 2024 15:04:05 GMT")
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, s.text)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// set makes s serve text from now on, under etag.
+func (s *pageServer) set(etag, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag, s.text, s.status = etag, text, 0
+}
+
+func (s *pageServer) fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// paragraphs chunks every paragraph of the test pages on its own.
+var paragraphs = &chunking.Options{Strategy: chunking.Paragraph, Size: 15}
+
+// sourceValues returns the values of the data points of src in the order
+// of their chunks.
+func sourceValues(t *testing.T, store collections.Store, src *collections.Source) string {
+	t.Helper()
+	dataPoints, err := store.ListSourceDataPoints(context.Background(), src.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]string, len(dataPoints))
+	for i, dp := range dataPoints {
+		values[i] = dp.Value
+	}
+	return strings.Join(values, "|")
+}
+
+// crawledSource ingests the page of server and returns its source.
+func crawledSource(t *testing.T, i *Ingester, server *pageServer) *collections.Source {
+	t.Helper()
+	report, err := i.Run(context.Background(), Request{Collection: "web", Tag: "pages", URL: server.URL, Chunking: paragraphs}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := report.Results[0].Source
+	if src == nil || src.ETag != `"v1"` {
+		t.Fatalf("source = %+v, want one with the ETag of the page", src)
+	}
+	return src
+}
+
+func TestRecrawl(t *testing.T) {
+	ctx := context.Background()
+	server := newPageServer(t, `"v1"`, "Alpha one.\n\nBravo two.\n\nCharlie three.")
+	i := newTestIngester(t)
+	i.RecrawlInterval = time.Hour
+	src := crawledSource(t, i, server)
+
+	tests := []struct {
+		name                      string
+		etag, text                string
+		status                    collections.FetchStatus
+		httpStatus                int
+		created, updated, deleted int
+		values                    string
+	}{
+		{"not modified", `"v1"`, "Alpha one.\n\nBravo two.\n\nCharlie three.",
+			collections.FetchNotModified, http.StatusNotModified, 0, 0, 0, "Alpha one.|Bravo two.|Charlie three."},
+		{"same text", `"v2"`, "Alpha one.\n\nBravo two.\n\nCharlie three.\n",
+			collections.FetchUnchanged, http.StatusOK, 0, 0, 0, "Alpha one.|Bravo two.|Charlie three."},
+		{"edited", `"v3"`, "Alpha one.\n\nBravo 2.\n\nCharlie three.",
+			collections.FetchChanged, http.StatusOK, 0, 1, 0, "Alpha one.|Bravo 2.|Charlie three."},
+		{"grown", `"v4"`, "Alpha one.\n\nBravo 2.\n\nCharlie three.\n\nDelta four.",
+			collections.FetchChanged, http.StatusOK, 1, 0, 0, "Alpha one.|Bravo 2.|Charlie three.|Delta four."},
+		// The chunks that moved up keep their data points.
+		{"shrunk at the top", `"v5"`, "Bravo 2.\n\nCharlie three.\n\nDelta four.",
+			collections.FetchChanged, http.StatusOK, 0, 0, 1, "Bravo 2.|Charlie three.|Delta four."},
+		{"rewritten", `"v6"`, "Echo five.",
+			collections.FetchChanged, http.StatusOK, 0, 1, 2, "Echo five."},
+	}
+	for _, tt := range tests {
+		server.set(tt.etag, tt.text)
+		f, err := i.Recrawl(ctx, src)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if f.Status != tt.status || f.HTTPStatus != tt.httpStatus || f.Created != tt.created || f.Updated != tt.updated || f.Deleted != tt.deleted {
+			t.Errorf("%s: fetch = %+v, want %s (%d) with %d created, %d updated and %d deleted",
+				tt.name, f, tt.status, tt.httpStatus, tt.created, tt.updated, tt.deleted)
+		}
+		if values := sourceValues(t, i.Store, src); values != tt.values {
+			t.Errorf("%s: data points = %s, want %s", tt.name, values, tt.values)
+		}
+		// Unchanged pages keep their new validators too.
+		if src.ETag != tt.etag || src.LastStatus != tt.status || src.Failures != 0 {
+			t.Errorf("%s: source = %+v", tt.name, src)
+		}
+	}
+
+	fetches, err := i.Store.ListSourceFetches(ctx, src.ID, collections.Page{})
+	if err != nil || len(fetches) != len(tests)+1 {
+		t.Errorf("ListSourceFetches = %d fetches, %v, want %d", len(fetches), err, len(tests)+1)
+	}
+}
+
+func TestRecrawlFailures(t *testing.T) {
+	ctx := context.Background()
+	server := newPageServer(t, `"v1"`, "Alpha one.")
+	i := newTestIngester(t)
+	i.RecrawlInterval = time.Hour
+	src := crawledSource(t, i, server)
+
+	server.fail(http.StatusServiceUnavailable)
+	for n := 1; n <= 2; n++ {
+		before := time.Now()
+		f, err := i.Recrawl(ctx, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Status != collections.FetchFailed || f.HTTPStatus != http.StatusServiceUnavailable || f.Error == "" || src.Failures != n || src.LastError != f.Error {
+			t.Errorf("failed crawl %d = %+v, source %+v", n, f, src)
+		}
+		if src.NextFetchAt == nil || src.NextFetchAt.Before(before.Add(time.Hour)) {
+			t.Errorf("failed crawl %d scheduled the next one at %v, want an hour later", n, src.NextFetchAt)
+		}
+	}
+	if src.ETag != `"v1"` {
+		t.Errorf("ETag after failed crawls = %s, want the last one", src.ETag)
+	}
+
+	server.set(`"v2"`, "")
+	if f, err := i.Recrawl(ctx, src); err != nil || f.Status != collections.FetchFailed || src.Failures != 3 {
+		t.Errorf("crawl of an empty page = %+v, %v, failures %d", f, err, src.Failures)
+	}
+	if values := sourceValues(t, i.Store, src); values != "Alpha one." {
+		t.Errorf("data points after failed crawls = %s", values)
+	}
+
+	server.set(`"v3"`, "Alpha 1.")
+	if f, err := i.Recrawl(ctx, src); err != nil || f.Status != collections.FetchChanged || src.Failures != 0 || src.LastError != "" {
+		t.Errorf("recovered crawl = %+v, %v, source %+v", f, err, src)
+	}
+	saved, err := i.Store.GetSource(ctx, src.ID)
+	if err != nil || saved.Failures != 0 || saved.ETag != `"v3"` || saved.NextFetchAt == nil {
+		t.Errorf("GetSource = %+v, %v", saved, err)
+	}
+}
+
+func TestRecrawlDuplicates(t *testing.T) {
+	ctx := context.Background()
+	server := newPageServer(t, `"v1"`, "Alpha one.\n\nBravo two.")
+	i := newTestIngester(t)
+	src := crawledSource(t, i, server)
+	other, err := i.Run(ctx, Request{Collection: "web", Tag: "notes", Text: "Shared text."}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = i.Store.SaveDedupeSettings(ctx, &collections.DedupeSettings{CollectionID: src.CollectionID, Unique: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gained chunks holding text of the collection are rejected, and the
+	// crawl goes on with the others.
+	server.set(`"v2"`, "Alpha one.\n\nBravo two.\n\nShared text.\n\nCharlie three.")
+	f, err := i.Recrawl(ctx, src)
+	if err != nil || f.Status != collections.FetchChanged || f.Created != 1 || src.Failures != 0 {
+		t.Fatalf("crawl with a duplicate chunk = %+v, %v, source %+v", f, err, src)
+	}
+	if values := sourceValues(t, i.Store, src); values != "Alpha one.|Bravo two.|Charlie three." {
+		t.Errorf("data points = %s", values)
+	}
+
+	// A data point that would take such text goes.
+	server.set(`"v3"`, "Alpha one.\n\nShared text.\n\nCharlie three.")
+	f, err = i.Recrawl(ctx, src)
+	if err != nil || f.Status != collections.FetchChanged || f.Updated != 0 || f.Deleted != 1 {
+		t.Fatalf("crawl updating to a duplicate = %+v, %v", f, err)
+	}
+	if values := sourceValues(t, i.Store, src); values != "Alpha one.|Charlie three." {
+		t.Errorf("data points = %s", values)
+	}
+	if dp, err := i.Store.GetDataPoint(ctx, other.Results[0].DataPoints[0].ID); err != nil || dp.Value != "Shared text." {
+		t.Errorf("duplicated data point = %+v, %v", dp, err)
+	}
+}
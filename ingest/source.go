@@ -0,0 +1,311 @@
+package ingest
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"cognivaultServer/extract"
+	"cognivaultServer/files"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrNoSource is returned for a Request without a URL, file, directory or
+// text.
+var ErrNoSource error = apperr.Invalid("missing_source", "missing data source",
+	apperr.FieldError{Field: "url", Message: "one of url, file, dir or text is required"})
+
+// Request asks for a source to be loaded and stored. Exactly one of URL,
+// File, Dir and Text is used, in that order of precedence.
+type Request struct {
+	Collection string `json:"collection"`
+	// Tag defaults to the host and path of the URL for URL sources, as in
+	// "example.com/docs/guide", and to the directory name for directory
+	// sources.
+	Tag  string `json:"tag,omitempty"`
+	URL  string `json:"url,omitempty"`
+	File string `json:"file,omitempty"`
+	// Dir imports every file below a directory. Files are tagged by their
+	// subdirectory below Tag.
+	Dir  string `json:"dir,omitempty"`
+	Text string `json:"text,omitempty"`
+	// Include and Exclude filter the files of Dir with .gitignore-style
+	// globs.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// KeepHTML keeps the raw HTML of a page or HTML file as an attachment.
+	KeepHTML bool              `json:"keep_html,omitempty"`
+	Chunking *chunking.Options `json:"chunking,omitempty"`
+	// Dedupe handles content that is already stored in the collection, see
+	// Document.Dedupe.
+	Dedupe collections.DedupePolicy `json:"dedupe,omitempty"`
+	// RecrawlInterval is the time between crawls of URL sources. It
+	// defaults to Ingester.RecrawlInterval; 0 crawls them only on demand.
+	RecrawlInterval *collections.Duration `json:"recrawl_interval,omitempty"`
+}
+
+// Report describes what a Request stored.
+type Report struct {
+	Collection *collections.Collection
+	// Results has one entry per stored source; directory imports have one
+	// per file.
+	Results []*Result
+	// Failures lists the files of a directory import that were skipped.
+	Failures []Failure
+}
+
+// Duplicates returns the duplicates of all results.
+func (r *Report) Duplicates() []Duplicate {
+	var duplicates []Duplicate
+	for _, res := range r.Results {
+		duplicates = append(duplicates, res.Duplicates...)
+	}
+	return duplicates
+}
+
+// Failure is a file of a directory import that could not be stored.
+type Failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// DataPoints returns the data points of all results.
+func (r *Report) DataPoints() []collections.DataPoint {
+	var dataPoints []collections.DataPoint
+	for _, res := range r.Results {
+		dataPoints = append(dataPoints, res.DataPoints...)
+	}
+	return dataPoints
+}
+
+// Run loads the source of req and stores it. Errors loading a single URL or
+// file are returned as *fetch.Error or *files.Error; the files of a
+// directory that fail, including those rejected as duplicates, are reported
+// in Report.Failures instead, after progress is called for each of them.
+func (i *Ingester) Run(ctx context.Context, req Request, progress func(done, total int)) (*Report, error) {
+	if req.Chunking != nil {
+		if err := req.Chunking.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+
+	var doc *Document
+	var err error
+	switch {
+	case req.URL != "":
+		doc, err = i.loadURL(ctx, req)
+	case req.File != "":
+		var file *files.File
+		file, err = i.Files.Read(req.File)
+		if err == nil {
+			doc, err = fileDocument(req, req.Tag, file, file.RelPath())
+		}
+	case req.Dir != "":
+		return i.runDir(ctx, req, progress)
+	case req.Text != "":
+		doc = &Document{Collection: req.Collection, Tag: req.Tag, Text: req.Text, Chunking: req.Chunking, Dedupe: req.Dedupe}
+	default:
+		return nil, ErrNoSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	progress(0, 1)
+	res, err := i.Ingest(ctx, *doc)
+	if err != nil {
+		return nil, err
+	}
+	progress(1, 1)
+
+	return &Report{Collection: res.Collection, Results: []*Result{res}}, nil
+}
+
+// runDir stores every file selected from req.Dir.
+func (i *Ingester) runDir(ctx context.Context, req Request, progress func(done, total int)) (*Report, error) {
+	entries, err := i.Files.Walk(req.Dir, files.WalkOptions{Include: req.Include, Exclude: req.Exclude})
+	if err != nil {
+		return nil, err
+	}
+
+	baseTag := req.Tag
+	if baseTag == "" {
+		resolved, _, _ := i.Files.Resolve(req.Dir)
+		baseTag = filepath.Base(resolved)
+	}
+
+	report := &Report{}
+	progress(0, len(entries))
+	for n, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tag := baseTag
+		if dir := path.Dir(entry.RelPath); dir != "." {
+			tag = path.Join(baseTag, dir)
+		}
+
+		var res *Result
+		file, err := i.Files.Read(entry.Path)
+		if err == nil {
+			var doc *Document
+			doc, err = fileDocument(req, tag, file, entry.RelPath)
+			if err == nil {
+				res, err = i.Ingest(ctx, *doc)
+			}
+		}
+		if errors.Is(err, ErrEmpty) || errors.Is(err, collections.ErrDuplicateContent) || files.KindOf(err) != "" {
+			report.Failures = append(report.Failures, Failure{Path: entry.RelPath, Error: err.Error()})
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", entry.RelPath, err)
+		} else {
+			report.Collection = res.Collection
+			report.Results = append(report.Results, res)
+		}
+		progress(n+1, len(entries))
+	}
+
+	if report.Collection == nil {
+		// Nothing was stored, but the collection should exist all the same.
+		res, err := i.ensureCollection(ctx, req.Collection)
+		if err != nil {
+			return nil, err
+		}
+		report.Collection = res
+	}
+
+	return report, nil
+}
+
+// loadURL fetches req.URL and turns it into a Document.
+func (i *Ingester) loadURL(ctx context.Context, req Request) (*Document, error) {
+	page, err := i.Fetcher.Fetch(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := req.Tag
+	if tag == "" {
+		tag = urlTag(req.URL)
+	}
+	contentType := page.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = page.ContentType
+	}
+
+	doc := &Document{
+		Collection: req.Collection,
+		Tag:        tag,
+		Source:     req.URL,
+		Text:       string(page.Body),
+		Chunking:   req.Chunking,
+		Metadata:   map[string]interface{}{"source_url": req.URL},
+		Dedupe:     req.Dedupe,
+		Crawl: &collections.Source{
+			URL:          req.URL,
+			Interval:     collections.Duration(i.RecrawlInterval),
+			ETag:         page.Header.Get("ETag"),
+			LastModified: page.Header.Get("Last-Modified"),
+		},
+		fetchStatus: page.StatusCode,
+	}
+	if req.RecrawlInterval != nil {
+		doc.Crawl.Interval = *req.RecrawlInterval
+	}
+	if err := readable(doc, contentType, page.URL, req.KeepHTML); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// urlTag returns the tag of a URL ingested without one: its host followed
+// by the segments of its path, so that pages of a site are filed in a tree
+// below the host. Empty, "." and ".." segments are dropped, and segments
+// that would not make a valid tag name are kept escaped.
+func urlTag(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.Trim(rawURL, "/")
+	}
+	segments := []string{u.Host}
+	for _, segment := range strings.Split(u.EscapedPath(), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(segment); err == nil && !strings.ContainsFunc(unescaped, invalidTagRune) {
+			segment = unescaped
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// invalidTagRune reports whether r may not appear in a segment of a tag
+// name.
+func invalidTagRune(r rune) bool {
+	return r == '/' || unicode.IsControl(r)
+}
+
+// fileDocument turns a file into a Document filed under tag. relPath is
+// the path reported in the metadata: relative to the import root for a
+// single file and to the imported directory for directory imports.
+func fileDocument(req Request, tag string, file *files.File, relPath string) (*Document, error) {
+	doc := &Document{
+		Collection: req.Collection,
+		Tag:        tag,
+		Source:     file.Path,
+		Text:       string(file.Body),
+		Chunking:   req.Chunking,
+		Dedupe:     req.Dedupe,
+		Metadata: map[string]interface{}{
+			"source_path": file.Path,
+			"path":        relPath,
+			"file_name":   path.Base(relPath),
+			"modified_at": file.ModTime.UTC().Format(time.RFC3339),
+		},
+	}
+	if ext := strings.TrimPrefix(path.Ext(relPath), "."); ext != "" {
+		doc.Metadata["extension"] = strings.ToLower(ext)
+	}
+	if err := readable(doc, file.ContentType, nil, req.KeepHTML); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// readable replaces an HTML document by its readable text and adds the
+// metadata of the page. pageURL may be nil.
+func readable(doc *Document, contentType string, pageURL *url.URL, keepHTML bool) error {
+	if !extract.IsHTML(contentType, doc.Text) {
+		return nil
+	}
+
+	if keepHTML {
+		doc.Attachments = append(doc.Attachments, collections.Attachment{
+			Name:        "page.html",
+			ContentType: contentType,
+			Data:        []byte(doc.Text),
+		})
+	}
+
+	article, err := extract.HTML(strings.NewReader(doc.Text), pageURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	doc.Text = article.Text
+	for k, v := range article.Metadata() {
+		doc.Metadata[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/embeddings"
+	"cognivaultServer/fetch"
+	"cognivaultServer/search"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestIngester returns an Ingester over a MemoryStore whose fetcher
+// reaches the httptest servers on loopback.
+func newTestIngester(t *testing.T) *Ingester {
+	t.Helper()
+	store := collections.NewMemoryStore()
+	opts := fetch.DefaultOptions
+	opts.AllowPrivateNetworks = true
+	return &Ingester{
+		Store:   store,
+		Search:  search.NewService(store, embeddings.NewHashEmbedder(64)),
+		Fetcher: fetch.New(opts),
+	}
+}
+
+// tagNames returns the names of the tags of a collection with the names
+// of their parents, as "parent > name".
+func tagNames(t *testing.T, store collections.Store, collectionID string) []string {
+	t.Helper()
+	tags, err := store.ListTags(context.Background(), collectionID, collections.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{}
+	for _, tag := range tags {
+		names[tag.ID] = tag.Name
+	}
+	var result []string
+	for _, tag := range tags {
+		result = append(result, names[tag.ParentID]+" > "+tag.Name)
+	}
+	return result
+}
+
+func TestURLTag(t *testing.T) {
+	tests := []struct {
+		url, tag string
+	}{
+		{"https://example.com", "example.com"},
+		{"https://example.com/", "example.com"},
+		{"http://127.0.0.1:8080/a/b", "127.0.0.1:8080/a/b"},
+		{"https://example.com/docs/guide?page=2#intro", "example.com/docs/guide"},
+		{"https://example.com//a///b/", "example.com/a/b"},
+		{"https://example.com/a/./../b", "example.com/a/b"},
+		{"https://example.com/hello%20world", "example.com/hello world"},
+		{"https://example.com/a%2Fb/c", "example.com/a%2Fb/c"},
+		{"https://example.com/a%0Ab", "example.com/a%0Ab"},
+	}
+	for _, tt := range tests {
+		if got := urlTag(tt.url); got != tt.tag {
+			t.Errorf("urlTag(%q) = %q, want %q", tt.url, got, tt.tag)
+		}
+	}
+}
+
+func TestRunURLTagTree(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "The page at %s.", r.URL.Path)
+	}))
+	defer server.Close()
+
+	i := newTestIngester(t)
+	for _, target := range []string{"/a/b", "/a//c"} {
+		if _, err := i.Run(context.Background(), Request{Collection: "web", URL: server.URL + target}, nil); err != nil {
+			t.Fatalf("Run(%s): %v", target, err)
+		}
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	report, err := i.Run(context.Background(), Request{Collection: "web", URL: server.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag := report.Results[0].Tag.Name; tag != host {
+		t.Errorf("tag of the site root = %q, want %q", tag, host)
+	}
+
+	want := []string{
+		" > " + host,
+		host + " > " + host + "/a",
+		host + "/a > " + host + "/a/b",
+		host + "/a > " + host + "/a/c",
+	}
+	got := tagNames(t, i.Store, report.Collection.ID)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("tags =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
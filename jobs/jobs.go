@@ -0,0 +1,341 @@
+// Package jobs runs ingestion requests in the background. Jobs are persisted
+// in the Store, so they survive restarts, and are processed by a pool of
+// workers that retry transient failures with exponential backoff.
+package jobs
+
+import (
+	"cognivaultServer/apperr"
+	"cognivaultServer/collections"
+	"cognivaultServer/fetch"
+	"cognivaultServer/ingest"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrFinished is returned when canceling a job that has already finished.
+var ErrFinished error = apperr.New(apperr.Conflict, "job_finished", "job has already finished")
+
+// Options configures a Queue.
+type Options struct {
+	// Workers is the number of jobs run at the same time.
+	Workers int
+	// MaxAttempts is the number of times a job is tried before it fails.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is how often idle workers look for jobs that became due,
+	// such as retries.
+	PollInterval time.Duration
+}
+
+// DefaultOptions run two jobs at a time and try each up to three times.
+var DefaultOptions = Options{
+	Workers:      2,
+	MaxAttempts:  3,
+	Backoff:      10 * time.Second,
+	MaxBackoff:   10 * time.Minute,
+	PollInterval: 5 * time.Second,
+}
+
+// Result is stored on a succeeded job.
+type Result struct {
+	CollectionID string             `json:"collection_id"`
+	DocumentIDs  []string           `json:"document_ids,omitempty"`
+	DataPointIDs []string           `json:"data_point_ids"`
+	Duplicates   []ingest.Duplicate `json:"duplicates,omitempty"`
+	SourceIDs    []string           `json:"source_ids,omitempty"`
+	Failures     []ingest.Failure   `json:"failures,omitempty"`
+}
+
+// Queue stores submitted jobs and runs them. It is safe for concurrent use.
+type Queue struct {
+	store collections.Store
+	opts  Options
+	wake  chan struct{}
+	wg    sync.WaitGroup
+
+	// handle runs the request of a job. It is the Run method of the
+	// Ingester given to New.
+	handle func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error)
+
+	// mu orders claiming jobs against canceling them.
+	mu sync.Mutex
+	// running holds the cancel functions of the jobs being run, and
+	// canceled the ones among them canceled through Cancel.
+	running  map[string]context.CancelFunc
+	canceled map[string]bool
+}
+
+// New returns a Queue running jobs with ingester. Workers only start with
+// Start.
+func New(store collections.Store, ingester *ingest.Ingester, opts Options) *Queue {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultOptions.PollInterval
+	}
+	return &Queue{
+		store:    store,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		handle:   ingester.Run,
+		running:  map[string]context.CancelFunc{},
+		canceled: map[string]bool{},
+	}
+}
+
+// Start requeues the jobs interrupted by a previous shutdown and starts the
+// workers, which stop when ctx is done. Jobs interrupted by the shutdown are
+// queued again; Wait waits for that.
+func (q *Queue) Start(ctx context.Context) error {
+	interrupted, err := q.store.ListJobs(ctx, collections.JobRunning, collections.Page{})
+	if err != nil {
+		return err
+	}
+	for _, job := range interrupted {
+		job := job
+		if job.Attempts >= job.MaxAttempts {
+			q.finish(&job, collections.JobFailed, "interrupted by a server restart")
+		} else {
+			job.Status = collections.JobQueued
+			job.RunAt = time.Now().UTC()
+		}
+		if err := q.store.UpdateJob(ctx, &job); err != nil {
+			return err
+		}
+	}
+
+	for n := 0; n < q.opts.Workers; n++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+	return nil
+}
+
+// Wait blocks until the workers have stopped.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Submit stores req as a new job. It fails without storing anything if req
+// has no source or invalid chunking options.
+func (q *Queue) Submit(ctx context.Context, req ingest.Request) (*collections.Job, error) {
+	if req.URL == "" && req.File == "" && req.Dir == "" && req.Text == "" {
+		return nil, ingest.ErrNoSource
+	}
+	if req.Chunking != nil {
+		if err := req.Chunking.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job request: %w", err)
+	}
+	job := &collections.Job{
+		Status:      collections.JobQueued,
+		Request:     request,
+		MaxAttempts: q.opts.MaxAttempts,
+	}
+	if err := q.store.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel cancels a job. A queued job is canceled right away; a running job
+// is interrupted and becomes canceled once its worker has stopped it, so
+// the returned job is still running. Finished jobs yield ErrFinished.
+func (q *Queue) Cancel(ctx context.Context, id string) (*collections.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch job.Status {
+	case collections.JobQueued:
+		q.finish(job, collections.JobCanceled, "")
+		if err := q.store.UpdateJob(ctx, job); err != nil {
+			return nil, err
+		}
+	case collections.JobRunning:
+		if cancel, ok := q.running[id]; ok {
+			q.canceled[id] = true
+			cancel()
+		}
+	default:
+		return job, ErrFinished
+	}
+
+	return job, nil
+}
+
+// work runs due jobs until ctx is done.
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for q.next(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// next claims and runs one due job. It returns false if there was none.
+func (q *Queue) next(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	q.mu.Lock()
+	job, err := q.store.ClaimJob(ctx)
+	if err != nil {
+		q.mu.Unlock()
+		if !errors.Is(err, collections.ErrNotFound) {
+			log.Println(err)
+		}
+		return false
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+
+	result, err := q.run(jobCtx, job)
+
+	q.mu.Lock()
+	canceled := q.canceled[job.ID]
+	delete(q.running, job.ID)
+	delete(q.canceled, job.ID)
+	q.mu.Unlock()
+	cancel()
+
+	switch {
+	case err == nil:
+		job.Result = result
+		q.finish(job, collections.JobSucceeded, "")
+	case canceled:
+		q.finish(job, collections.JobCanceled, "")
+	case ctx.Err() != nil:
+		// The server is shutting down; the attempt does not count.
+		job.Status = collections.JobQueued
+		job.Attempts--
+		job.RunAt = time.Now().UTC()
+	case !retryable(err) || job.Attempts >= job.MaxAttempts:
+		q.finish(job, collections.JobFailed, err.Error())
+	default:
+		job.Status = collections.JobQueued
+		job.Error = err.Error()
+		job.RunAt = time.Now().UTC().Add(q.backoff(job.Attempts))
+	}
+
+	// The job is saved even if ctx is done, so it can resume after a restart.
+	if err := q.store.UpdateJob(context.WithoutCancel(ctx), job); err != nil {
+		log.Println(err)
+	}
+	return true
+}
+
+// run runs one attempt of job and returns its encoded Result.
+func (q *Queue) run(ctx context.Context, job *collections.Job) (json.RawMessage, error) {
+	var req ingest.Request
+	if err := json.Unmarshal(job.Request, &req); err != nil {
+		return nil, fmt.Errorf("invalid job request: %w", err)
+	}
+
+	report, err := q.handle(ctx, req, func(done, total int) {
+		job.Progress = collections.JobProgress{Done: done, Total: total}
+		if err := q.store.UpdateJob(context.WithoutCancel(ctx), job); err != nil {
+			log.Println(err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := Result{
+		CollectionID: report.Collection.ID,
+		DataPointIDs: []string{},
+		Duplicates:   report.Duplicates(),
+		Failures:     report.Failures,
+	}
+	for _, res := range report.Results {
+		if res.Document != nil {
+			result.DocumentIDs = append(result.DocumentIDs, res.Document.ID)
+		}
+		if res.Source != nil {
+			result.SourceIDs = append(result.SourceIDs, res.Source.ID)
+		}
+		for _, dp := range res.DataPoints {
+			result.DataPointIDs = append(result.DataPointIDs, dp.ID)
+		}
+	}
+	return json.Marshal(result)
+}
+
+// finish moves job into the final status with the error message msg.
+func (q *Queue) finish(job *collections.Job, status collections.JobStatus, msg string) {
+	t := time.Now().UTC()
+	job.Status = status
+	job.FinishedAt = &t
+	if msg != "" || status == collections.JobSucceeded {
+		job.Error = msg
+	}
+}
+
+// backoff returns the delay before the retry following attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.opts.Backoff
+	for n := 1; n < attempt; n++ {
+		d *= 2
+		if q.opts.MaxBackoff > 0 && d >= q.opts.MaxBackoff {
+			return q.opts.MaxBackoff
+		}
+	}
+	return d
+}
+
+// retryable reports whether a failed attempt may succeed when repeated.
+// Only network trouble and server-side HTTP errors are; invalid requests,
+// blocked URLs and unreadable files fail the same way every time.
+func retryable(err error) bool {
+	var fetchErr *fetch.Error
+	if !errors.As(err, &fetchErr) {
+		return false
+	}
+	switch fetchErr.Kind {
+	case fetch.Timeout, fetch.Network:
+		return true
+	case fetch.Status:
+		return fetchErr.StatusCode == http.StatusTooManyRequests || fetchErr.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package jobs
+
+import (
+	"cognivaultServer/chunking"
+	"cognivaultServer/collections"
+	"cognivaultServer/fetch"
+	"cognivaultServer/ingest"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// handler stands in for Ingester.Run.
+type handler func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error)
+
+// succeed stores nothing and reports a data point named after the text of
+// the request.
+func succeed(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+	progress(1, 1)
+	return &ingest.Report{
+		Collection: &collections.Collection{ID: "c"},
+		Results:    []*ingest.Result{{DataPoints: []collections.DataPoint{{ID: req.Text}}}},
+	}, nil
+}
+
+// testOptions retry right away, so that tests do not wait for backoffs.
+var testOptions = Options{Workers: 2, MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, PollInterval: time.Millisecond}
+
+func newTestQueue(t *testing.T, opts Options, h handler) (*Queue, collections.Store) {
+	t.Helper()
+	store := collections.NewMemoryStore()
+	q := New(store, nil, opts)
+	q.handle = h
+	return q, store
+}
+
+// start starts the workers of q until the test ends.
+func start(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := q.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		q.Wait()
+	})
+}
+
+// waitFor polls the job id until it has status.
+func waitFor(t *testing.T, store collections.Store, id string, status collections.JobStatus) *collections.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.GetJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func submit(t *testing.T, q *Queue, text string) *collections.Job {
+	t.Helper()
+	job, err := q.Submit(context.Background(), ingest.Request{Collection: "c", Text: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestSubmitRejects(t *testing.T) {
+	q, store := newTestQueue(t, testOptions, succeed)
+	ctx := context.Background()
+	if _, err := q.Submit(ctx, ingest.Request{Collection: "c"}); !errors.Is(err, ingest.ErrNoSource) {
+		t.Errorf("Submit(no source) error = %v, want %v", err, ingest.ErrNoSource)
+	}
+	bad := &chunking.Options{Strategy: chunking.Fixed, Size: 10, Overlap: 10}
+	if _, err := q.Submit(ctx, ingest.Request{Collection: "c", Text: "a", Chunking: bad}); err == nil {
+		t.Error("Submit(overlap as long as the chunks) succeeded")
+	}
+	if jobs, _ := store.ListJobs(ctx, "", collections.Page{}); len(jobs) != 0 {
+		t.Errorf("rejected requests stored %d jobs", len(jobs))
+	}
+}
+
+func TestQueueRunsJobs(t *testing.T) {
+	q, store := newTestQueue(t, testOptions, succeed)
+	start(t, q)
+
+	job := waitFor(t, store, submit(t, q, "dp").ID, collections.JobSucceeded)
+	var result Result
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.CollectionID != "c" || len(result.DataPointIDs) != 1 || result.DataPointIDs[0] != "dp" {
+		t.Errorf("result = %+v", result)
+	}
+	if job.Attempts != 1 || job.Error != "" || job.FinishedAt == nil || job.Progress != (collections.JobProgress{Done: 1, Total: 1}) {
+		t.Errorf("job = %+v", job)
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	// Requests fail with the error named by their text until their third
+	// attempt.
+	q, store := newTestQueue(t, testOptions, func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+		mu.Lock()
+		attempts[req.Text]++
+		n := attempts[req.Text]
+		mu.Unlock()
+		if n == 3 {
+			return succeed(ctx, req, progress)
+		}
+		switch req.Text {
+		case "network":
+			return nil, &fetch.Error{Kind: fetch.Network, URL: "http://example.com", Err: errors.New("connection reset")}
+		case "unavailable":
+			return nil, fmt.Errorf("crawl: %w", &fetch.Error{Kind: fetch.Status, URL: "http://example.com", StatusCode: http.StatusServiceUnavailable})
+		case "not found":
+			return nil, &fetch.Error{Kind: fetch.Status, URL: "http://example.com", StatusCode: http.StatusNotFound}
+		}
+		return nil, errors.New("invalid request")
+	})
+	start(t, q)
+
+	for _, text := range []string{"network", "unavailable"} {
+		job := waitFor(t, store, submit(t, q, text).ID, collections.JobSucceeded)
+		if job.Attempts != 3 || job.Error != "" {
+			t.Errorf("retried job %s = %+v, want it to succeed on the third attempt", text, job)
+		}
+	}
+	for _, text := range []string{"not found", "invalid"} {
+		job := waitFor(t, store, submit(t, q, text).ID, collections.JobFailed)
+		if job.Attempts != 1 || job.Error == "" || job.FinishedAt == nil {
+			t.Errorf("permanently failing job %s = %+v, want it to fail on the first attempt", text, job)
+		}
+	}
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	opts := testOptions
+	opts.MaxAttempts = 2
+	q, store := newTestQueue(t, opts, func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+		return nil, &fetch.Error{Kind: fetch.Timeout, URL: "http://example.com", Err: context.DeadlineExceeded}
+	})
+	start(t, q)
+
+	job := waitFor(t, store, submit(t, q, "a").ID, collections.JobFailed)
+	if job.Attempts != 2 || job.Error == "" {
+		t.Errorf("job = %+v, want it to fail after 2 attempts", job)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fetch.Error{Kind: fetch.Timeout}, true},
+		{&fetch.Error{Kind: fetch.Network}, true},
+		{&fetch.Error{Kind: fetch.Status, StatusCode: http.StatusTooManyRequests}, true},
+		{&fetch.Error{Kind: fetch.Status, StatusCode: http.StatusBadGateway}, true},
+		{fmt.Errorf("page: %w", &fetch.Error{Kind: fetch.Network}), true},
+		{&fetch.Error{Kind: fetch.Status, StatusCode: http.StatusNotFound}, false},
+		{&fetch.Error{Kind: fetch.InvalidURL}, false},
+		{&fetch.Error{Kind: fetch.TooManyRedirects}, false},
+		{ingest.ErrEmpty, false},
+		{errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	q := New(collections.NewMemoryStore(), nil, Options{Backoff: 10 * time.Second, MaxBackoff: time.Minute})
+	for attempt, want := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		4:  time.Minute,
+		10: time.Minute,
+	} {
+		if got := q.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	q.opts.MaxBackoff = 0
+	if got := q.backoff(8); got != 1280*time.Second {
+		t.Errorf("backoff(8) without a maximum = %v, want %v", got, 1280*time.Second)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	q, store := newTestQueue(t, testOptions, succeed)
+	ctx := context.Background()
+	job := submit(t, q, "a")
+
+	canceled, err := q.Cancel(ctx, job.ID)
+	if err != nil || canceled.Status != collections.JobCanceled || canceled.FinishedAt == nil {
+		t.Fatalf("Cancel = %+v, %v", canceled, err)
+	}
+	if _, err := q.Cancel(ctx, job.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Cancel(canceled job) error = %v, want %v", err, ErrFinished)
+	}
+	if _, err := q.Cancel(ctx, "missing"); !errors.Is(err, collections.ErrNotFound) {
+		t.Errorf("Cancel(missing job) error = %v, want %v", err, collections.ErrNotFound)
+	}
+
+	// Canceled jobs are never run.
+	start(t, q)
+	waitFor(t, store, submit(t, q, "b").ID, collections.JobSucceeded)
+	if job, err := store.GetJob(ctx, job.ID); err != nil || job.Status != collections.JobCanceled || job.Attempts != 0 {
+		t.Errorf("canceled job = %+v, %v", job, err)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	q, store := newTestQueue(t, testOptions, func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	start(t, q)
+	job := submit(t, q, "a")
+	<-started
+
+	running, err := q.Cancel(context.Background(), job.ID)
+	if err != nil || running.Status != collections.JobRunning {
+		t.Fatalf("Cancel = %+v, %v, want the job still running", running, err)
+	}
+	job = waitFor(t, store, job.ID, collections.JobCanceled)
+	if job.Attempts != 1 || job.Error != "" || job.FinishedAt == nil {
+		t.Errorf("canceled job = %+v", job)
+	}
+}
+
+// TestCancelRace cancels jobs while the workers claim them: every job must
+// end canceled or succeeded, and succeed exactly when its run completed.
+func TestCancelRace(t *testing.T) {
+	var mu sync.Mutex
+	completed := map[string]bool{}
+	opts := testOptions
+	opts.Workers = 4
+	q, store := newTestQueue(t, opts, func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(len(req.Text)%3) * time.Millisecond):
+		}
+		mu.Lock()
+		completed[req.Text] = true
+		mu.Unlock()
+		return succeed(ctx, req, progress)
+	})
+	start(t, q)
+
+	ctx := context.Background()
+	var jobs []*collections.Job
+	for n := 0; n < 50; n++ {
+		job := submit(t, q, fmt.Sprint(n))
+		jobs = append(jobs, job)
+		if n%2 == 0 {
+			if _, err := q.Cancel(ctx, jobs[n/2].ID); err != nil && !errors.Is(err, ErrFinished) {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for n, job := range jobs {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stored, err := store.GetJob(ctx, job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status == collections.JobSucceeded || stored.Status == collections.JobCanceled {
+				mu.Lock()
+				done := completed[fmt.Sprint(n)]
+				mu.Unlock()
+				if done != (stored.Status == collections.JobSucceeded) {
+					t.Errorf("job %d is %s, but its run completed: %t", n, stored.Status, done)
+				}
+				break
+			}
+			if stored.Status != collections.JobQueued && stored.Status != collections.JobRunning || time.Now().After(deadline) {
+				t.Fatalf("job %d = %+v", n, stored)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestStartRequeuesInterruptedJobs(t *testing.T) {
+	q, store := newTestQueue(t, testOptions, succeed)
+	ctx := context.Background()
+
+	// Jobs left running by a previous process, one of them on its last
+	// attempt.
+	var interrupted []string
+	for _, maxAttempts := range []int{2, 1} {
+		job := &collections.Job{Status: collections.JobQueued, Request: json.RawMessage(`{"collection":"c","text":"a"}`), MaxAttempts: maxAttempts}
+		if err := store.CreateJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.ClaimJob(ctx); err != nil {
+			t.Fatal(err)
+		}
+		interrupted = append(interrupted, job.ID)
+	}
+
+	start(t, q)
+	if job := waitFor(t, store, interrupted[0], collections.JobSucceeded); job.Attempts != 2 {
+		t.Errorf("requeued job = %+v, want it run a second time", job)
+	}
+	if job := waitFor(t, store, interrupted[1], collections.JobFailed); job.Attempts != 1 || job.Error == "" {
+		t.Errorf("job interrupted on its last attempt = %+v", job)
+	}
+}
+
+func TestShutdownRequeuesRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	q, store := newTestQueue(t, testOptions, func(ctx context.Context, req ingest.Request, progress func(done, total int)) (*ingest.Report, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := q.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job := submit(t, q, "a")
+	<-started
+	cancel()
+	q.Wait()
+
+	// The interrupted attempt does not count.
+	job, err := store.GetJob(context.Background(), job.ID)
+	if err != nil || job.Status != collections.JobQueued || job.Attempts != 0 || job.FinishedAt != nil {
+		t.Errorf("job after shutdown = %+v, %v, want it queued again", job, err)
+	}
+}
@@ -2,9 +2,25 @@ package main
 
 import (
 	"cognivaultServer/api"
+	"cognivaultServer/collections"
+	"cognivaultServer/config"
 	"cognivaultServer/database"
+	"cognivaultServer/embeddings"
+	"cognivaultServer/fetch"
+	"cognivaultServer/files"
+	"cognivaultServer/ingest"
+	"cognivaultServer/jobs"
+	"cognivaultServer/recrawl"
+	"cognivaultServer/search"
+	"cognivaultServer/trash"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -12,33 +28,171 @@ import (
 )
 
 func main() {
-	// Connect to the SQLite database
+	// Run schema migrations on their own when asked to
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Connect to the SQLite database and apply pending migrations
 	db, err := database.ConnectDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	// Create tables for collections, tags, and data points
-	err = database.CreateTables()
+	cfg := config.FromEnv()
+	store := collections.NewSQLStore(db)
+
+	// Hash the content of data points stored before duplicates were tracked
+	hashed, err := store.HashDataPoints(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if hashed > 0 {
+		log.Printf("Hashed the content of %d data point(s)", hashed)
+	}
+
+	// Load the embedding index used by semantic search
+	embedder, err := newEmbedder(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	searcher := search.NewService(store, embedder)
+	if err := searcher.Load(context.Background()); err != nil {
+		log.Printf("Semantic search index is incomplete: %v", err)
+	}
+
+	// Restrict file ingestion to the configured import roots
+	sandbox, err := newSandbox(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	ingester := &ingest.Ingester{
+		Store:   store,
+		Search:  searcher,
+		Fetcher: newFetcher(cfg),
+		Files:   sandbox,
+
+		RecrawlInterval: cfg.RecrawlInterval,
+	}
+
+	// Run ingestion jobs in the background until the server is stopped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	queue := newQueue(cfg, store, ingester)
+	if err := queue.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Purge records that have been in the trash for longer than the retention
+	purger := newPurger(cfg, store, searcher)
+	purger.Start(ctx)
+
+	// Crawl fetched URLs again and poll feeds when they are due
+	scheduler := newScheduler(cfg, store, ingester)
+	scheduler.Start(ctx)
+
 	// Set up the chi router
 	r := chi.NewRouter()
+	r.Use(api.RequestID)
 	r.Use(middleware.Logger)
+	r.Use(api.Author)
 
 	// Set up the API routes
-	api.SetRoutes(r)
+	api.SetRoutes(r, &api.Handlers{
+		Store:  store,
+		Search: searcher,
+		Ingest: ingester,
+		Jobs:   queue,
+
+		MaxBodyBytes:   cfg.MaxBodyBytes,
+		TrashRetention: cfg.TrashRetention,
+		FeedInterval:   cfg.FeedInterval,
+	})
 
 	// Serve the Swagger UI for API documentation
 	r.Get("/swagger/*", api.SwaggerHandler())
 
 	// Start the server
+	server := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
 	log.Println("Starting server on :8080")
-	err = http.ListenAndServe(":8080", r)
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	// Let running jobs save their state before closing the database
+	queue.Wait()
+	purger.Wait()
+	scheduler.Wait()
+}
+
+// newEmbedder returns the embedding backend selected by cfg.
+func newEmbedder(cfg config.Config) (embeddings.Embedder, error) {
+	switch cfg.Embedder {
+	case "hash":
+		return embeddings.NewHashEmbedder(cfg.EmbeddingDimensions), nil
+	case "http":
+		return embeddings.NewHTTPEmbedder(cfg.EmbeddingsURL, cfg.EmbeddingsModel, cfg.EmbeddingsAPIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q", cfg.Embedder)
+	}
+}
+
+// newFetcher returns the Fetcher used to download URLs for ingestion.
+func newFetcher(cfg config.Config) *fetch.Fetcher {
+	opts := fetch.DefaultOptions
+	opts.Timeout = cfg.FetchTimeout
+	opts.MaxBodySize = cfg.FetchMaxBytes
+	opts.MaxRedirects = cfg.FetchMaxRedirects
+	opts.AllowPrivateNetworks = cfg.FetchAllowPrivate
+	opts.UserAgent = cfg.FetchUserAgent
+	if cfg.FetchContentTypes != nil {
+		opts.AllowedContentTypes = cfg.FetchContentTypes
+	}
+	return fetch.New(opts)
+}
+
+// newSandbox returns the Sandbox used to read files for ingestion.
+func newSandbox(cfg config.Config) (*files.Sandbox, error) {
+	opts := files.DefaultOptions
+	opts.Roots = cfg.ImportRoots
+	opts.MaxFileSize = cfg.ImportMaxBytes
+	opts.MaxFiles = cfg.ImportMaxFiles
+	if cfg.ImportContentTypes != nil {
+		opts.AllowedContentTypes = cfg.ImportContentTypes
+	}
+	return files.New(opts)
+}
+
+// newQueue returns the Queue running ingestion jobs.
+func newQueue(cfg config.Config, store collections.Store, ingester *ingest.Ingester) *jobs.Queue {
+	opts := jobs.DefaultOptions
+	opts.Workers = cfg.JobWorkers
+	opts.MaxAttempts = cfg.JobMaxAttempts
+	opts.Backoff = cfg.JobBackoff
+	return jobs.New(store, ingester, opts)
+}
+
+// newPurger returns the Purger emptying the trash.
+func newPurger(cfg config.Config, store collections.Store, searcher *search.Service) *trash.Purger {
+	opts := trash.DefaultOptions
+	opts.Retention = cfg.TrashRetention
+	opts.Interval = cfg.TrashPurgeInterval
+	return trash.New(store, searcher, opts)
+}
+
+// newScheduler returns the Scheduler crawling sources again and polling feeds.
+func newScheduler(cfg config.Config, store collections.Store, ingester *ingest.Ingester) *recrawl.Scheduler {
+	opts := recrawl.DefaultOptions
+	opts.PollInterval = cfg.RecrawlPollInterval
+	return recrawl.New(store, ingester, opts)
 }
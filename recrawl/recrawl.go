@@ -0,0 +1,145 @@
+// Package recrawl crawls the URL sources of a Store again when they are
+// due, so that the data points fetched from them follow the changes of the
+// pages, and polls its feeds for new entries.
+package recrawl
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/ingest"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Options configures a Scheduler.
+type Options struct {
+	// PollInterval is how often the Store is checked for due sources and
+	// feeds. Nothing is crawled if it is 0.
+	PollInterval time.Duration
+	// BatchSize is the number of due sources or feeds loaded at once.
+	BatchSize int
+}
+
+// DefaultOptions check for due sources and feeds every minute.
+var DefaultOptions = Options{
+	PollInterval: time.Minute,
+	BatchSize:    50,
+}
+
+// Scheduler periodically crawls the sources and polls the feeds whose next
+// fetch is due.
+type Scheduler struct {
+	store    collections.Store
+	ingester *ingest.Ingester
+	opts     Options
+	wg       sync.WaitGroup
+}
+
+// New returns a Scheduler crawling sources and polling feeds with ingester. It only runs once
+// started with Start.
+func New(store collections.Store, ingester *ingest.Ingester, opts Options) *Scheduler {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOptions.BatchSize
+	}
+	return &Scheduler{store: store, ingester: ingester, opts: opts}
+}
+
+// Start crawls the due sources and polls the due feeds right away and then
+// every PollInterval until ctx is done. It does nothing if PollInterval is 0.
+func (s *Scheduler) Start(ctx context.Context) {
+	if s.opts.PollInterval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.opts.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.Crawl(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to crawl sources: %v", err)
+			}
+			if _, err := s.PollFeeds(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to poll feeds: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until a started Scheduler has stopped.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Crawl crawls the sources that are due, one at a time, and returns how
+// many were crawled. Failed crawls are recorded with their sources and do
+// not stop the others.
+func (s *Scheduler) Crawl(ctx context.Context) (int, error) {
+	crawled := 0
+	for {
+		sources, err := s.store.ListDueSources(ctx, time.Now().UTC(), s.opts.BatchSize)
+		if err != nil {
+			return crawled, err
+		}
+		for _, src := range sources {
+			if err := ctx.Err(); err != nil {
+				return crawled, err
+			}
+			f, err := s.ingester.Recrawl(ctx, &src)
+			if err != nil {
+				return crawled, err
+			}
+			crawled++
+			if f.Status == collections.FetchFailed {
+				log.Printf("Failed to crawl %s: %s", src.URL, f.Error)
+			}
+		}
+		// Crawled sources are scheduled again, so a full batch may be
+		// followed by more due sources.
+		if len(sources) < s.opts.BatchSize {
+			return crawled, nil
+		}
+	}
+}
+
+// PollFeeds polls the feeds that are due, one at a time, and returns how
+// many were polled. Failed polls are recorded with their feeds and do not
+// stop the others.
+func (s *Scheduler) PollFeeds(ctx context.Context) (int, error) {
+	polled := 0
+	for {
+		feeds, err := s.store.ListDueFeeds(ctx, time.Now().UTC(), s.opts.BatchSize)
+		if err != nil {
+			return polled, err
+		}
+		for _, f := range feeds {
+			if err := ctx.Err(); err != nil {
+				return polled, err
+			}
+			poll, err := s.ingester.Poll(ctx, &f)
+			if err != nil {
+				return polled, err
+			}
+			polled++
+			if poll.Status == collections.FetchFailed {
+				log.Printf("Failed to poll %s: %s", f.URL, poll.Error)
+			} else if len(poll.Entries) > 0 {
+				log.Printf("Stored %d new entries of %s", len(poll.Entries), f.URL)
+			}
+		}
+		// Polled feeds are scheduled again, so a full batch may be followed
+		// by more due feeds.
+		if len(feeds) < s.opts.BatchSize {
+			return polled, nil
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package recrawl
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/embeddings"
+	"cognivaultServer/fetch"
+	"cognivaultServer/ingest"
+	"cognivaultServer/search"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server serves a page at every path, and an RSS feed at those starting
+// with /feed, counting the requests for each path.
+type server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newServer(t *testing.T) *server {
+	s := &server{requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		n := s.requests[r.URL.Path]
+		s.mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/feed") {
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprintf(w, `<rss version="2.0"><channel><title>Feed</title><item><guid>%d</guid><description>Story %s %d.</description></item></channel></rss>`, n, r.URL.Path, n)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "Page %s, version %d.", r.URL.Path, n)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *server) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func newTestScheduler(t *testing.T) (*Scheduler, *ingest.Ingester) {
+	t.Helper()
+	store := collections.NewMemoryStore()
+	opts := fetch.DefaultOptions
+	opts.AllowPrivateNetworks = true
+	i := &ingest.Ingester{
+		Store:           store,
+		Search:          search.NewService(store, embeddings.NewHashEmbedder(64)),
+		Fetcher:         fetch.New(opts),
+		RecrawlInterval: time.Hour,
+	}
+	// Batches of one make every crawl load the due sources again.
+	return New(store, i, Options{PollInterval: time.Minute, BatchSize: 1}), i
+}
+
+func TestCrawl(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	s, i := newTestScheduler(t)
+
+	never := collections.Duration(0)
+	sources := map[string]*collections.Source{}
+	for _, path := range []string{"/due", "/also-due", "/later", "/manual"} {
+		req := ingest.Request{Collection: "web", URL: srv.URL + path}
+		if path == "/manual" {
+			req.RecrawlInterval = &never
+		}
+		report, err := i.Run(ctx, req, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[path] = report.Results[0].Source
+	}
+	past := time.Now().UTC().Add(-time.Minute)
+	for _, path := range []string{"/due", "/also-due"} {
+		sources[path].NextFetchAt = &past
+		if err := i.Store.UpdateSource(ctx, sources[path]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := s.Crawl(ctx); err != nil || n != 2 {
+		t.Fatalf("Crawl = %d, %v, want the 2 due sources", n, err)
+	}
+	for path, want := range map[string]int{"/due": 2, "/also-due": 2, "/later": 1, "/manual": 1} {
+		if got := srv.count(path); got != want {
+			t.Errorf("%s fetched %d times, want %d", path, got, want)
+		}
+	}
+	src, err := i.Store.GetSource(ctx, sources["/due"].ID)
+	if err != nil || src.LastStatus != collections.FetchChanged || src.NextFetchAt == nil || !src.NextFetchAt.After(time.Now().Add(59*time.Minute)) {
+		t.Errorf("crawled source = %+v, %v, want it scheduled an hour later", src, err)
+	}
+
+	// Crawled sources are not due again until their interval passed.
+	if n, err := s.Crawl(ctx); err != nil || n != 0 {
+		t.Errorf("second Crawl = %d, %v, want 0", n, err)
+	}
+}
+
+func TestPollFeeds(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	s, i := newTestScheduler(t)
+	c := &collections.Collection{Name: "news"}
+	if err := i.Store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	feeds := map[string]*collections.Feed{}
+	for _, path := range []string{"/feed/due", "/feed/later", "/feed/manual"} {
+		f := &collections.Feed{CollectionID: c.ID, URL: srv.URL + path}
+		if path != "/feed/manual" {
+			f.Interval = collections.Duration(time.Hour)
+		}
+		if _, err := i.Subscribe(ctx, f, "news"); err != nil {
+			t.Fatal(err)
+		}
+		feeds[path] = f
+	}
+	past := time.Now().UTC().Add(-time.Minute)
+	feeds["/feed/due"].NextFetchAt = &past
+	if err := i.Store.UpdateFeed(ctx, feeds["/feed/due"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.PollFeeds(ctx); err != nil || n != 1 {
+		t.Fatalf("PollFeeds = %d, %v, want the due feed", n, err)
+	}
+	for path, want := range map[string]int{"/feed/due": 2, "/feed/later": 1, "/feed/manual": 1} {
+		if got := srv.count(path); got != want {
+			t.Errorf("%s fetched %d times, want %d", path, got, want)
+		}
+	}
+	entries, err := i.Store.ListFeedEntries(ctx, feeds["/feed/due"].ID, collections.Page{})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("entries of the polled feed = %+v, %v, want 2", entries, err)
+	}
+
+	if n, err := s.PollFeeds(ctx); err != nil || n != 0 {
+		t.Errorf("second PollFeeds = %d, %v, want 0", n, err)
+	}
+}
+
+func TestStartWithoutPollInterval(t *testing.T) {
+	srv := newServer(t)
+	s, i := newTestScheduler(t)
+	if _, err := i.Run(context.Background(), ingest.Request{Collection: "web", URL: srv.URL + "/page"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s.opts.PollInterval = 0
+	s.Start(context.Background())
+	s.Wait()
+	if got := srv.count("/page"); got != 1 {
+		t.Errorf("page fetched %d times, want only once by Run", got)
+	}
+}
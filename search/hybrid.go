@@ -0,0 +1,169 @@
+package search
+
+import (
+	"cognivaultServer/collections"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Fusion configures reciprocal rank fusion. A result ranked r by a retriever
+// with weight w gains w / (K + r) in the fused score.
+type Fusion struct {
+	KeywordWeight  float64
+	SemanticWeight float64
+	K              int
+}
+
+// DefaultFusion weighs both retrievers equally with the constant k = 60 from
+// the original RRF paper.
+var DefaultFusion = Fusion{KeywordWeight: 1, SemanticWeight: 1, K: 60}
+
+// Validate reports whether the fusion parameters can be used.
+func (f Fusion) Validate() error {
+	if f.KeywordWeight < 0 || f.SemanticWeight < 0 {
+		return errors.New("fusion weights must not be negative")
+	}
+	if f.KeywordWeight == 0 && f.SemanticWeight == 0 {
+		return errors.New("at least one fusion weight must be positive")
+	}
+	if f.K < 1 {
+		return errors.New("rrf_k must be at least 1")
+	}
+	return nil
+}
+
+// Settings returns the fusion parameters saved for a collection, or
+// DefaultFusion if none were saved.
+func (s *Service) Settings(ctx context.Context, collectionID string) (Fusion, error) {
+	settings, err := s.store.GetSearchSettings(ctx, collectionID)
+	if errors.Is(err, collections.ErrNotFound) {
+		return DefaultFusion, nil
+	}
+	if err != nil {
+		return Fusion{}, err
+	}
+
+	return Fusion{
+		KeywordWeight:  settings.KeywordWeight,
+		SemanticWeight: settings.SemanticWeight,
+		K:              settings.RRFK,
+	}, nil
+}
+
+// SaveSettings stores the fusion parameters used by default for a collection.
+func (s *Service) SaveSettings(ctx context.Context, collectionID string, f Fusion) (*collections.SearchSettings, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	settings := collections.SearchSettings{
+		CollectionID:   collectionID,
+		KeywordWeight:  f.KeywordWeight,
+		SemanticWeight: f.SemanticWeight,
+		RRFK:           f.K,
+	}
+	if err := s.store.SaveSearchSettings(ctx, &settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// Hybrid runs the keyword and semantic retrievers concurrently and merges
+// their rankings with reciprocal rank fusion. Every result carries a
+// breakdown of the rank, raw score and contribution of each retriever. A
+// retriever with weight 0 is not run.
+func (s *Service) Hybrid(ctx context.Context, collectionID, query string, filter *collections.MetadataFilter, limit int, f Fusion) ([]collections.SearchResult, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Fetch more candidates than requested so that results ranked low by one
+	// retriever but high by the other still make the cut.
+	candidates := limit * 4
+	if candidates < 50 {
+		candidates = 50
+	}
+
+	var wg sync.WaitGroup
+	var keyword, semantic []collections.SearchResult
+	var keywordErr, semanticErr error
+	if f.KeywordWeight > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keyword, keywordErr = s.Keyword(ctx, collectionID, query, filter, candidates)
+		}()
+	}
+	if f.SemanticWeight > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semantic, semanticErr = s.Semantic(ctx, collectionID, query, filter, candidates)
+		}()
+	}
+	wg.Wait()
+
+	if keywordErr != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", keywordErr)
+	}
+	if semanticErr != nil {
+		return nil, fmt.Errorf("semantic search failed: %w", semanticErr)
+	}
+
+	return fuse(keyword, semantic, f, limit), nil
+}
+
+// fuse merges the keyword and semantic rankings as described by f and
+// returns at most limit results. Results with the same fused score keep the
+// order of the keyword ranking, followed by those only the semantic
+// retriever found in its order.
+func fuse(keyword, semantic []collections.SearchResult, f Fusion, limit int) []collections.SearchResult {
+	fused := map[string]*collections.SearchResult{}
+	var order []string
+	add := func(retriever string, weight float64, results []collections.SearchResult) {
+		if weight == 0 {
+			return
+		}
+		for i, r := range results {
+			rank := i + 1
+			contribution := weight / float64(f.K+rank)
+
+			result, ok := fused[r.ID]
+			if !ok {
+				result = &collections.SearchResult{
+					DataPoint: r.DataPoint,
+					Snippet:   r.Snippet,
+					Breakdown: map[string]collections.RankScore{},
+				}
+				fused[r.ID] = result
+				order = append(order, r.ID)
+			}
+			result.Score += contribution
+			result.Breakdown[retriever] = collections.RankScore{
+				Rank:         rank,
+				Score:        r.Score,
+				Contribution: contribution,
+			}
+		}
+	}
+	// Keyword results go first so their highlighted snippets are kept.
+	add(string(ModeKeyword), f.KeywordWeight, keyword)
+	add(string(ModeSemantic), f.SemanticWeight, semantic)
+
+	results := make([]collections.SearchResult, 0, len(order))
+	for _, id := range order {
+		results = append(results, *fused[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"cognivaultServer/collections"
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// ranking returns results with the given IDs, scored from 1 down.
+func ranking(ids ...string) []collections.SearchResult {
+	results := make([]collections.SearchResult, len(ids))
+	for i, id := range ids {
+		results[i] = collections.SearchResult{
+			DataPoint: collections.DataPoint{ID: id, Value: id},
+			Score:     1 / float64(i+1),
+			Snippet:   id,
+		}
+	}
+	return results
+}
+
+func ids(results []collections.SearchResult) string {
+	s := make([]string, len(results))
+	for i, r := range results {
+		s[i] = r.ID
+	}
+	return strings.Join(s, ",")
+}
+
+func TestHybrid(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.add(t, "The cat sleeps.", nil)
+	f.add(t, "A cat and a dog.", nil)
+	f.add(t, "The dog barks.", nil)
+
+	results, err := f.Hybrid(ctx, f.collection, "dog", nil, 10, DefaultFusion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both retrievers find the data points with "dog"; the semantic one
+	// ranks the one with the cat lower and also returns the other one.
+	if values(results) != "The dog barks.|A cat and a dog.|The cat sleeps." {
+		t.Fatalf("results = %s", values(results))
+	}
+	top := results[0]
+	if top.Breakdown["keyword"].Rank == 0 || top.Breakdown["semantic"].Rank != 1 || !strings.Contains(top.Snippet, "<mark>") {
+		t.Errorf("top result = %+v, want it found by both retrievers with a highlighted snippet", top)
+	}
+	if last := results[2]; len(last.Breakdown) != 1 || last.Breakdown["semantic"].Rank != 3 {
+		t.Errorf("last result = %+v, want it found by the semantic retriever only", last)
+	}
+
+	// Retrievers with weight 0 are not run.
+	f.embedder.embedded = nil
+	results, err = f.Hybrid(ctx, f.collection, "dog", nil, 10, Fusion{KeywordWeight: 1, K: 60})
+	if err != nil || len(results) != 2 || len(f.embedder.embedded) != 0 {
+		t.Errorf("keyword-only results = %s, %v, embedded %q", values(results), err, f.embedder.embedded)
+	}
+
+	if _, err := f.Hybrid(ctx, f.collection, "dog", nil, 10, Fusion{K: 60}); err == nil {
+		t.Error("Hybrid without weights succeeded")
+	}
+}
+
+func TestFuse(t *testing.T) {
+	tests := []struct {
+		name              string
+		keyword, semantic []collections.SearchResult
+		fusion            Fusion
+		limit             int
+		want              string
+	}{
+		{"found by both first", ranking("a", "b", "c"), ranking("c", "a", "d"), DefaultFusion, 0, "a,c,b,d"},
+		{"limit", ranking("a", "b", "c"), ranking("c", "a", "d"), DefaultFusion, 2, "a,c"},
+		// c gains more from the semantic ranking than a from the keyword one.
+		{"semantic weight", ranking("a", "b", "c"), ranking("c", "a", "d"), Fusion{KeywordWeight: 1, SemanticWeight: 3, K: 60}, 0, "c,a,d,b"},
+		{"keyword weight", ranking("a", "b", "c"), ranking("c", "a", "d"), Fusion{KeywordWeight: 3, SemanticWeight: 1, K: 60}, 0, "a,c,b,d"},
+		{"zero weight", ranking("a", "b"), ranking("c", "b"), Fusion{KeywordWeight: 1, K: 60}, 0, "a,b"},
+		// A small k favours the top ranks over being found by both.
+		{"small k", ranking("a", "b"), ranking("s1", "s2", "b", "s4", "s5", "s6", "s7", "s8", "s9", "a"), Fusion{KeywordWeight: 1, SemanticWeight: 1, K: 1}, 2, "a,b"},
+		{"large k", ranking("a", "b"), ranking("s1", "s2", "b", "s4", "s5", "s6", "s7", "s8", "s9", "a"), DefaultFusion, 2, "b,a"},
+		// Ties keep the keyword ranking, then the semantic one.
+		{"tie across retrievers", ranking("a"), ranking("b"), DefaultFusion, 0, "a,b"},
+		{"tie across retrievers reversed", ranking("b"), ranking("a"), DefaultFusion, 0, "b,a"},
+		{"tie of results found by both", ranking("b", "a"), ranking("a", "b"), DefaultFusion, 0, "b,a"},
+		{"tie of semantic results", nil, ranking("b", "a"), Fusion{KeywordWeight: 1, SemanticWeight: 1, K: 60}, 0, "b,a"},
+		{"nothing found", nil, nil, DefaultFusion, 10, ""},
+	}
+	for _, tt := range tests {
+		if got := ids(fuse(tt.keyword, tt.semantic, tt.fusion, tt.limit)); got != tt.want {
+			t.Errorf("%s: fused = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFuseBreakdown(t *testing.T) {
+	f := Fusion{KeywordWeight: 0.5, SemanticWeight: 2, K: 10}
+	keyword := ranking("a", "b")
+	keyword[1].Snippet = "<mark>b</mark>"
+	results := fuse(keyword, ranking("b"), f, 0)
+	if ids(results) != "b,a" {
+		t.Fatalf("fused = %s", ids(results))
+	}
+
+	b := results[0]
+	want := map[string]collections.RankScore{
+		"keyword":  {Rank: 2, Score: 0.5, Contribution: 0.5 / 12},
+		"semantic": {Rank: 1, Score: 1, Contribution: 2.0 / 11},
+	}
+	for retriever, score := range want {
+		got := b.Breakdown[retriever]
+		if got.Rank != score.Rank || got.Score != score.Score || math.Abs(got.Contribution-score.Contribution) > 1e-12 {
+			t.Errorf("%s breakdown of b = %+v, want %+v", retriever, got, score)
+		}
+	}
+	if math.Abs(b.Score-(0.5/12+2.0/11)) > 1e-12 || b.Snippet != "<mark>b</mark>" {
+		t.Errorf("b = %+v, want the sum of the contributions and the keyword snippet", b)
+	}
+	if a := results[1]; len(a.Breakdown) != 1 || math.Abs(a.Score-0.5/11) > 1e-12 {
+		t.Errorf("a = %+v", a)
+	}
+}
+
+func TestFusionValidate(t *testing.T) {
+	tests := []struct {
+		fusion Fusion
+		valid  bool
+	}{
+		{DefaultFusion, true},
+		{Fusion{SemanticWeight: 0.1, K: 1}, true},
+		{Fusion{KeywordWeight: 1, SemanticWeight: -1, K: 60}, false},
+		{Fusion{K: 60}, false},
+		{Fusion{KeywordWeight: 1, SemanticWeight: 1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.fusion.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v.Validate() = %v, want valid %t", tt.fusion, err, tt.valid)
+		}
+	}
+}
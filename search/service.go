@@ -0,0 +1,204 @@
+// Package search answers keyword and semantic queries over the data points of
+// a collection.
+package search
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/embeddings"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Mode selects the retriever used by Service.Search.
+type Mode string
+
+const (
+	// ModeKeyword ranks data points by full-text relevance.
+	ModeKeyword Mode = "keyword"
+	// ModeSemantic ranks data points by embedding similarity to the query.
+	ModeSemantic Mode = "semantic"
+	// ModeHybrid fuses the keyword and semantic rankings.
+	ModeHybrid Mode = "hybrid"
+)
+
+// ParseMode validates a mode name. The empty string selects ModeKeyword.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeKeyword:
+		return ModeKeyword, nil
+	case ModeSemantic:
+		return ModeSemantic, nil
+	case ModeHybrid:
+		return ModeHybrid, nil
+	default:
+		return "", fmt.Errorf("unknown search mode %q", s)
+	}
+}
+
+// excerptWords is the length of the excerpt returned for semantic matches,
+// which have no highlighted terms.
+const excerptWords = 32
+
+// Service runs searches against a Store and keeps the embedding index of its
+// data points up to date.
+type Service struct {
+	store    collections.Store
+	embedder embeddings.Embedder
+	index    *embeddings.Index
+}
+
+// NewService returns a Service over store that embeds text with embedder.
+func NewService(store collections.Store, embedder embeddings.Embedder) *Service {
+	return &Service{
+		store:    store,
+		embedder: embedder,
+		index:    embeddings.NewIndex(),
+	}
+}
+
+// Load fills the in-memory index from stored embeddings and computes the
+// embeddings of data points that do not have one for the current model.
+func (s *Service) Load(ctx context.Context) error {
+	stored, err := s.store.ListEmbeddings(ctx, s.embedder.Model())
+	if err != nil {
+		return err
+	}
+	for _, e := range stored {
+		s.index.Add(e.DataPointID, e.Vector)
+	}
+
+	all, err := s.store.ListCollections(ctx, collections.Page{})
+	if err != nil {
+		return err
+	}
+
+	var missing []collections.DataPoint
+	for _, c := range all {
+		dataPoints, err := s.store.ListCollectionDataPoints(ctx, c.ID, nil, collections.Page{})
+		if err != nil {
+			return err
+		}
+		for _, dp := range dataPoints {
+			if !s.index.Has(dp.ID) {
+				missing = append(missing, dp)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		log.Printf("Computing %s embeddings for %d data point(s)", s.embedder.Model(), len(missing))
+	}
+	return s.Index(ctx, missing...)
+}
+
+// Index computes, stores and indexes the embeddings of the given data points.
+// It should be called whenever a data point is created or its value changes.
+func (s *Service) Index(ctx context.Context, dataPoints ...collections.DataPoint) error {
+	const batchSize = 32
+
+	for start := 0; start < len(dataPoints); start += batchSize {
+		end := start + batchSize
+		if end > len(dataPoints) {
+			end = len(dataPoints)
+		}
+		batch := dataPoints[start:end]
+
+		texts := make([]string, len(batch))
+		for i, dp := range batch {
+			texts[i] = dp.Value
+		}
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed data points: %w", err)
+		}
+
+		for i, dp := range batch {
+			e := collections.Embedding{
+				DataPointID: dp.ID,
+				Model:       s.embedder.Model(),
+				Vector:      vectors[i],
+			}
+			if err := s.store.SaveEmbedding(ctx, &e); err != nil {
+				return err
+			}
+			s.index.Add(dp.ID, vectors[i])
+		}
+	}
+
+	return nil
+}
+
+// Remove drops a deleted data point from the index.
+func (s *Service) Remove(ids ...string) {
+	for _, id := range ids {
+		s.index.Remove(id)
+	}
+}
+
+// Search returns at most limit data points of the collection matching query
+// and filter, which may be nil. fusion is only used by ModeHybrid.
+func (s *Service) Search(ctx context.Context, collectionID, query string, filter *collections.MetadataFilter, mode Mode, limit int, fusion Fusion) ([]collections.SearchResult, error) {
+	switch mode {
+	case ModeSemantic:
+		return s.Semantic(ctx, collectionID, query, filter, limit)
+	case ModeHybrid:
+		return s.Hybrid(ctx, collectionID, query, filter, limit, fusion)
+	default:
+		return s.Keyword(ctx, collectionID, query, filter, limit)
+	}
+}
+
+// Keyword runs a full-text search through the Store.
+func (s *Service) Keyword(ctx context.Context, collectionID, query string, filter *collections.MetadataFilter, limit int) ([]collections.SearchResult, error) {
+	return s.store.SearchDataPoints(ctx, collectionID, query, filter, limit)
+}
+
+// Semantic embeds the query and returns the data points of the collection with
+// the most similar embeddings. Scores are cosine similarities.
+func (s *Service) Semantic(ctx context.Context, collectionID, query string, filter *collections.MetadataFilter, limit int) ([]collections.SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return []collections.SearchResult{}, nil
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	dataPoints, err := s.store.ListCollectionDataPoints(ctx, collectionID, filter, collections.Page{})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]collections.DataPoint, len(dataPoints))
+	for _, dp := range dataPoints {
+		byID[dp.ID] = dp
+	}
+
+	matches := s.index.Search(vectors[0], limit, func(id string) bool {
+		_, ok := byID[id]
+		return ok
+	})
+
+	results := make([]collections.SearchResult, 0, len(matches))
+	for _, m := range matches {
+		dp := byID[m.ID]
+		results = append(results, collections.SearchResult{
+			DataPoint: dp,
+			Score:     m.Score,
+			Snippet:   excerpt(dp.Value),
+		})
+	}
+
+	return results, nil
+}
+
+// excerpt returns the first words of text.
+func excerpt(text string) string {
+	words := strings.Fields(text)
+	if len(words) <= excerptWords {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:excerptWords], " ") + "…"
+}
@@ -0,0 +1,203 @@
+package search
+
+import (
+	"cognivaultServer/collections"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// wordEmbedder counts the words of its vocabulary in a text, one dimension
+// per word, so that similarities are easy to work out. It records the texts
+// it embedded.
+type wordEmbedder struct {
+	mu       sync.Mutex
+	embedded []string
+}
+
+var vocabulary = []string{"cat", "dog", "fish"}
+
+func (e *wordEmbedder) Model() string {
+	return "words"
+}
+
+func (e *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.embedded = append(e.embedded, texts...)
+	e.mu.Unlock()
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(vocabulary))
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			for d, w := range vocabulary {
+				if strings.Trim(word, ".,?!") == w {
+					v[d]++
+				}
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// fixture holds a Service over a MemoryStore with one collection.
+type fixture struct {
+	*Service
+	store      collections.Store
+	embedder   *wordEmbedder
+	collection string
+	tag        string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	store := collections.NewMemoryStore()
+	c := &collections.Collection{Name: "pets"}
+	if err := store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	tag := &collections.Tag{CollectionID: c.ID, Name: "notes"}
+	if err := store.CreateTag(ctx, tag); err != nil {
+		t.Fatal(err)
+	}
+	embedder := &wordEmbedder{}
+	return &fixture{Service: NewService(store, embedder), store: store, embedder: embedder, collection: c.ID, tag: tag.ID}
+}
+
+// add stores and indexes a data point with value and metadata, which may
+// be nil.
+func (f *fixture) add(t *testing.T, value string, metadata map[string]interface{}) collections.DataPoint {
+	t.Helper()
+	dp := collections.DataPoint{TagID: f.tag, Value: value, Metadata: metadata}
+	if err := f.store.CreateDataPoint(context.Background(), &dp); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Index(context.Background(), dp); err != nil {
+		t.Fatal(err)
+	}
+	return dp
+}
+
+// values returns the values of results in order.
+func values(results []collections.SearchResult) string {
+	v := make([]string, len(results))
+	for i, r := range results {
+		v[i] = r.Value
+	}
+	return strings.Join(v, "|")
+}
+
+func TestSemanticRanking(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.add(t, "cat cat dog", map[string]interface{}{"kind": "long"})
+	f.add(t, "dog", nil)
+	f.add(t, "cat", map[string]interface{}{"kind": "short"})
+	f.add(t, "fish", nil)
+
+	results, err := f.Semantic(ctx, f.collection, "Cat?", nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values(results) != "cat|cat cat dog" {
+		t.Fatalf("results = %s, want the closest 2", values(results))
+	}
+	if math.Abs(results[0].Score-1) > 1e-6 || math.Abs(results[1].Score-2/math.Sqrt(5)) > 1e-6 {
+		t.Errorf("scores = %v, %v, want the cosine similarities", results[0].Score, results[1].Score)
+	}
+	if results[1].Snippet != "cat cat dog" || results[1].Breakdown != nil {
+		t.Errorf("result = %+v", results[1])
+	}
+
+	filter, err := collections.ParseMetadataFilter(`meta.kind = "long"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = f.Semantic(ctx, f.collection, "cat", filter, 1)
+	if err != nil || values(results) != "cat cat dog" {
+		t.Errorf("filtered results = %s, %v", values(results), err)
+	}
+
+	// Other collections are not searched.
+	other := &collections.Collection{Name: "other"}
+	if err := f.store.CreateCollection(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := f.Semantic(ctx, other.ID, "cat", nil, 10); err != nil || len(results) != 0 {
+		t.Errorf("results in another collection = %s, %v", values(results), err)
+	}
+	if results, err := f.Semantic(ctx, f.collection, "  ", nil, 10); err != nil || len(results) != 0 {
+		t.Errorf("results of a blank query = %s, %v", values(results), err)
+	}
+}
+
+func TestReindexing(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	cat := f.add(t, "cat", nil)
+	dog := f.add(t, "dog", nil)
+
+	// Updated data points are found by their new value once indexed again.
+	dog.Value = "cat cat"
+	if err := f.store.UpdateDataPoint(ctx, &dog); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Index(ctx, dog); err != nil {
+		t.Fatal(err)
+	}
+	results, err := f.Semantic(ctx, f.collection, "dog", nil, 1)
+	if err != nil || len(results) != 1 || results[0].Score != 0 {
+		t.Errorf("results for the old value = %+v, %v, want no similar one", results, err)
+	}
+	stored, err := f.store.ListEmbeddings(ctx, "words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range stored {
+		if e.DataPointID == dog.ID && (e.Vector[0] != 2 || e.Vector[1] != 0) {
+			t.Errorf("stored embedding of the updated data point = %v", e.Vector)
+		}
+	}
+
+	// Deleted data points leave the index.
+	if err := f.store.DeleteDataPoint(ctx, cat.ID); err != nil {
+		t.Fatal(err)
+	}
+	f.Remove(cat.ID)
+	if f.index.Has(cat.ID) || f.index.Len() != 1 {
+		t.Errorf("index has %d vectors after removing %s", f.index.Len(), cat.ID)
+	}
+	results, err = f.Semantic(ctx, f.collection, "cat", nil, 10)
+	if err != nil || values(results) != "cat cat" {
+		t.Errorf("results after the delete = %s, %v", values(results), err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.add(t, "cat", nil)
+	missing := collections.DataPoint{TagID: f.tag, Value: "dog"}
+	if err := f.store.CreateDataPoint(ctx, &missing); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new Service loads the stored embeddings and only computes the
+	// missing one.
+	embedder := &wordEmbedder{}
+	s := NewService(f.store, embedder)
+	if err := s.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(embedder.embedded, "|") != "dog" || s.index.Len() != 2 {
+		t.Errorf("Load embedded %q and indexed %d data points", embedder.embedded, s.index.Len())
+	}
+	results, err := s.Semantic(ctx, f.collection, "dog", nil, 1)
+	if err != nil || values(results) != "dog" {
+		t.Errorf("results = %s, %v", values(results), err)
+	}
+}
@@ -0,0 +1,92 @@
+// Package trash purges the collections, tags and data points that have been
+// in the trash of a Store for longer than the retention period, together
+// with their embeddings in the search index.
+package trash
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/search"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Options configures a Purger.
+type Options struct {
+	// Retention is how long deleted records are kept in the trash. Nothing
+	// is purged if it is 0.
+	Retention time.Duration
+	// Interval is how often the trash is checked for expired records.
+	Interval time.Duration
+}
+
+// DefaultOptions keep deleted records for 30 days and check hourly.
+var DefaultOptions = Options{
+	Retention: 30 * 24 * time.Hour,
+	Interval:  time.Hour,
+}
+
+// Purger periodically deletes expired records from the trash for good.
+type Purger struct {
+	store  collections.Store
+	search *search.Service
+	opts   Options
+	now    func() time.Time
+	wg     sync.WaitGroup
+}
+
+// New returns a Purger removing purged data points from searcher. It only
+// runs once started with Start.
+func New(store collections.Store, searcher *search.Service, opts Options) *Purger {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultOptions.Interval
+	}
+	return &Purger{store: store, search: searcher, opts: opts, now: time.Now}
+}
+
+// Start purges the trash right away and then every Interval until ctx is
+// done. It does nothing if Retention is 0.
+func (p *Purger) Start(ctx context.Context) {
+	if p.opts.Retention <= 0 {
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to purge trash: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until a started Purger has stopped.
+func (p *Purger) Wait() {
+	p.wg.Wait()
+}
+
+// Purge deletes the records deleted more than Retention ago and returns
+// the number of data points among them.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	ids, err := p.store.PurgeTrash(ctx, p.now().Add(-p.opts.Retention))
+	if err != nil {
+		return 0, err
+	}
+	p.search.Remove(ids...)
+	if len(ids) > 0 {
+		log.Printf("Purged %d data point(s) from the trash", len(ids))
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,80 @@
+package trash
+
+import (
+	"cognivaultServer/collections"
+	"cognivaultServer/embeddings"
+	"cognivaultServer/search"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	store := collections.NewMemoryStore()
+	c := &collections.Collection{Name: "notes"}
+	if err := store.CreateCollection(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	tag := &collections.Tag{CollectionID: c.ID, Name: "inbox"}
+	if err := store.CreateTag(ctx, tag); err != nil {
+		t.Fatal(err)
+	}
+	var dps []collections.DataPoint
+	for _, value := range []string{"old", "new", "kept"} {
+		dp := collections.DataPoint{TagID: tag.ID, Value: value}
+		if err := store.CreateDataPoint(ctx, &dp); err != nil {
+			t.Fatal(err)
+		}
+		dps = append(dps, dp)
+	}
+	old, recent, kept := dps[0], dps[1], dps[2]
+
+	searcher := search.NewService(store, embeddings.NewHashEmbedder(0))
+	if err := searcher.Index(ctx, dps...); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteDataPoint(ctx, old.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := store.DeleteDataPoint(ctx, recent.ID); err != nil {
+		t.Fatal(err)
+	}
+	items, err := store.ListTrash(ctx, collections.TrashDataPoint, "", collections.Page{})
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ListTrash = %+v, %v", items, err)
+	}
+	deletedAt := map[string]time.Time{}
+	for _, item := range items {
+		deletedAt[item.ID] = item.DeletedAt
+	}
+
+	// The cutoff falls on the deletion of the recent data point, which is
+	// kept like the one that is not in the trash.
+	p := New(store, searcher, Options{Retention: time.Hour})
+	p.now = func() time.Time { return deletedAt[recent.ID].Add(time.Hour) }
+	if n, err := p.Purge(ctx); err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v, want the old data point", n, err)
+	}
+	if _, err := store.RestoreDataPoint(ctx, old.ID); !errors.Is(err, collections.ErrNotFound) {
+		t.Errorf("RestoreDataPoint(purged) error = %v", err)
+	}
+	if _, err := store.GetDataPoint(ctx, kept.ID); err != nil {
+		t.Errorf("GetDataPoint(kept) error = %v", err)
+	}
+	items, err = store.ListTrash(ctx, "", "", collections.Page{})
+	if err != nil || len(items) != 1 || items[0].ID != recent.ID {
+		t.Fatalf("trash after the purge = %+v, %v", items, err)
+	}
+
+	// Once past the cutoff, the recent data point goes too.
+	p.now = func() time.Time { return deletedAt[recent.ID].Add(time.Hour + time.Nanosecond) }
+	if n, err := p.Purge(ctx); err != nil || n != 1 {
+		t.Fatalf("second Purge = %d, %v", n, err)
+	}
+	if n, err := p.Purge(ctx); err != nil || n != 0 {
+		t.Errorf("Purge of an empty trash = %d, %v", n, err)
+	}
+}
@@ -1,38 +0,0 @@
-package utils
-
-import (
-	"io"
-	"net/http"
-	"os"
-)
-
-// ReadFile reads a file from the given path and returns its contents as a string.
-func ReadFile(path string) (string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return "", err
-	}
-
-	return string(bytes), nil
-}
-
-func FetchURL(url string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(body), nil
-}
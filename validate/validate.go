@@ -0,0 +1,252 @@
+// Package validate checks request payloads against rules declared in
+// `validate` struct tags, such as
+//
+//	Name string `json:"name" validate:"required,max=200,pattern=name"`
+//
+// Rules are separated by commas and checked in order; the first rule a
+// field fails is reported. Fields are named by their JSON names, joined by
+// dots for nested structs.
+//
+//   - required: the field is set. Strings must not be blank, pointers,
+//     slices and maps must not be nil or empty, numbers must not be 0.
+//   - notblank: a string, if given, is not only white space.
+//   - min=N, max=N: the length of a string in characters, the number of
+//     items of a slice or map, or the value of a number is within bounds.
+//   - pattern=P: a string matches the named pattern P (name, path, ulid).
+//   - enum=a|b|c: a string is one of the listed values.
+//   - each: the rules after it apply to every item of a slice, which is
+//     named by its index, as in tags[2].
+//
+// Rules other than required do not apply to nil pointers and to empty
+// strings other than those pointed to, so optional fields are only checked
+// when given. Nested structs are checked recursively, and afterwards
+// validated with their Validate method if they have one. The fields of
+// embedded structs are named like those of the outer struct, as in JSON.
+package validate
+
+import (
+	"cognivaultServer/apperr"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by types that check themselves, such as
+// chunking.Options.
+type Validator interface {
+	Validate() error
+}
+
+// pattern is a named regular expression for the pattern rule.
+type pattern struct {
+	re      *regexp.Regexp
+	message string
+}
+
+var patterns = map[string]pattern{
+	// name is a single URL path segment, such as a collection name.
+	"name": {regexp.MustCompile(`^[^/\p{Cc}]+$`), "must not contain slashes or control characters"},
+	// path is names separated by single slashes, such as a tag name.
+	"path": {regexp.MustCompile(`^[^/\p{Cc}]+(/[^/\p{Cc}]+)*$`), "must be names separated by single slashes, without control characters"},
+	"ulid": {regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`), "must be a ULID"},
+}
+
+// Struct checks the rules of the struct v, or the struct v points to, and
+// returns the fields that fail them.
+func Struct(v interface{}) []apperr.FieldError {
+	var errs []apperr.FieldError
+	check(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+// check appends the fields of the struct v that fail their rules to errs.
+func check(v reflect.Value, path string, errs *[]apperr.FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			check(v.Field(i), path, errs)
+			continue
+		}
+		name := jsonName(f)
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		value := v.Field(i)
+		tag, itemTag := splitEach(f.Tag.Get("validate"))
+		if msg := rules(tag, value); msg != "" {
+			*errs = append(*errs, apperr.FieldError{Field: name, Message: msg})
+			continue
+		}
+		if itemTag != "" && value.Kind() == reflect.Slice {
+			for j := 0; j < value.Len(); j++ {
+				if msg := rules(itemTag, value.Index(j)); msg != "" {
+					*errs = append(*errs, apperr.FieldError{Field: fmt.Sprintf("%s[%d]", name, j), Message: msg})
+				}
+			}
+		}
+
+		n := len(*errs)
+		check(value, name, errs)
+		if len(*errs) > n || (value.Kind() == reflect.Ptr && value.IsNil()) || !value.CanInterface() {
+			continue
+		}
+		if validator, ok := value.Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				*errs = append(*errs, apperr.FieldError{Field: name, Message: err.Error()})
+			}
+		}
+	}
+}
+
+// splitEach splits the rules of tag into those of the field and those after
+// "each" for its items.
+func splitEach(tag string) (field, items string) {
+	if tag == "each" || strings.HasPrefix(tag, "each,") {
+		return "", strings.TrimPrefix(strings.TrimPrefix(tag, "each"), ",")
+	}
+	field, items, _ = strings.Cut(tag, ",each,")
+	field = strings.TrimSuffix(field, ",each")
+	return field, items
+}
+
+// jsonName returns the name of f in JSON.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// rules returns why v fails the rules of tag, or "" if it passes them.
+func rules(tag string, v reflect.Value) string {
+	if tag == "" {
+		return ""
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			if blank(v) {
+				return "is required"
+			}
+			continue
+		}
+
+		v := v
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		} else if v.Kind() == reflect.String && v.Len() == 0 {
+			return ""
+		}
+
+		switch name {
+		case "notblank":
+			if strings.TrimSpace(v.String()) == "" {
+				return "must not be blank"
+			}
+		case "min", "max":
+			if msg := bound(name, arg, v); msg != "" {
+				return msg
+			}
+		case "pattern":
+			p, ok := patterns[arg]
+			if !ok {
+				panic("validate: unknown pattern " + arg)
+			}
+			if !p.re.MatchString(v.String()) {
+				return p.message
+			}
+		case "enum":
+			values := strings.Split(arg, "|")
+			if !contains(values, v.String()) {
+				return "must be one of " + strings.Join(values, ", ")
+			}
+		default:
+			panic("validate: unknown rule " + name)
+		}
+	}
+	return ""
+}
+
+// blank reports whether v is not set for the required rule.
+func blank(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil() || blank(v.Elem())
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// bound checks the min or max rule with the limit arg.
+func bound(rule, arg string, v reflect.Value) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic("validate: invalid " + rule + " " + arg)
+	}
+
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		panic("validate: " + rule + " does not apply to " + v.Kind().String())
+	}
+
+	switch {
+	case rule == "min" && n < limit:
+		if unit == "" {
+			return "must be at least " + arg
+		}
+		return fmt.Sprintf("must have at least %s%s", arg, unit)
+	case rule == "max" && n > limit:
+		if unit == "" {
+			return "must be at most " + arg
+		}
+		return fmt.Sprintf("must have at most %s%s", arg, unit)
+	}
+	return ""
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package validate
+
+import (
+	"cognivaultServer/apperr"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rule checks a single rule of tag against value, which is stored in a
+// field of the type of value.
+func rule(tag string, value interface{}) string {
+	return rules(tag, reflect.ValueOf(value))
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestRules(t *testing.T) {
+	type policy string
+	tests := []struct {
+		tag   string
+		value interface{}
+		want  string
+	}{
+		{"required", "x", ""},
+		{"required", "", "is required"},
+		{"required", " \t\n", "is required"},
+		{"required", 0, "is required"},
+		{"required", 0.5, ""},
+		{"required", false, "is required"},
+		{"required", []string{}, "is required"},
+		{"required", map[string]int{}, "is required"},
+		{"required", []string{""}, ""},
+		{"required", (*string)(nil), "is required"},
+		{"required", ptr(" "), "is required"},
+		{"required", ptr("x"), ""},
+
+		{"notblank", "", ""},
+		{"notblank", " ", "must not be blank"},
+		{"notblank", (*string)(nil), ""},
+		// Strings given through pointers are checked even if empty.
+		{"notblank", ptr(""), "must not be blank"},
+		{"notblank", ptr("x"), ""},
+
+		{"min=2", "ab", ""},
+		{"min=2", "a", "must have at least 2 characters"},
+		{"min=2", "", ""},
+		{"max=3", "äöü", ""},
+		{"max=3", "äöüß", "must have at most 3 characters"},
+		{"max=1", []int{1, 2}, "must have at most 1 items"},
+		{"max=1", map[string]int{"a": 1, "b": 2}, "must have at most 1 items"},
+		{"min=1", -1, "must be at least 1"},
+		{"min=0", 0.0, ""},
+		{"min=0", -0.5, "must be at least 0"},
+		{"max=10", uint8(11), "must be at most 10"},
+		{"max=2.5", float32(2.5), ""},
+		{"max=10", ptr("12345678901"), "must have at most 10 characters"},
+
+		{"enum=reject|skip", "skip", ""},
+		{"enum=reject|skip", policy("skip"), ""},
+		{"enum=reject|skip", "merge", "must be one of reject, skip"},
+		{"enum=reject|skip", "Skip", "must be one of reject, skip"},
+		{"enum=reject|skip", "", ""},
+
+		{"pattern=name", "My notes", ""},
+		{"pattern=name", "a/b", "must not contain slashes or control characters"},
+		{"pattern=name", "a\x00b", "must not contain slashes or control characters"},
+		{"pattern=name", "tab\there", "must not contain slashes or control characters"},
+		{"pattern=path", "infra", ""},
+		{"pattern=path", "infra/k8s/dns", ""},
+		{"pattern=path", "Ünïcode/名前", ""},
+		{"pattern=ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAV", ""},
+		{"pattern=ulid", "01arz3ndektsv4rrffq69g5fav", "must be a ULID"},
+		{"pattern=ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAU0", "must be a ULID"},
+		{"pattern=ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAI", "must be a ULID"},
+
+		// The first failed rule is reported.
+		{"required,max=3,pattern=name", "", "is required"},
+		{"required,max=3,pattern=name", "a/bcd", "must have at most 3 characters"},
+		{"required,max=3,pattern=name", "a/b", "must not contain slashes or control characters"},
+		{"", "anything", ""},
+	}
+	for _, tt := range tests {
+		if got := rule(tt.tag, tt.value); got != tt.want {
+			t.Errorf("rules(%q, %#v) = %q, want %q", tt.tag, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	for path, valid := range map[string]bool{
+		"a":          true,
+		"a/b":        true,
+		"a b/c d":    true,
+		"a.b/c-d/e_": true,
+		"/a":         false,
+		"a/":         false,
+		"a//b":       false,
+		"/":          false,
+		"a/b\n":      false,
+		"a/\x7fb":    false,
+	} {
+		if got := rule("pattern=path", path) == ""; got != valid {
+			t.Errorf("pattern=path on %q passes: %t, want %t", path, got, valid)
+		}
+	}
+}
+
+func TestSplitEach(t *testing.T) {
+	tests := []struct {
+		tag, field, items string
+	}{
+		{"", "", ""},
+		{"required,max=5", "required,max=5", ""},
+		{"each,required", "", "required"},
+		{"each", "", ""},
+		{"required,max=100,each,required,max=200,pattern=path", "required,max=100", "required,max=200,pattern=path"},
+		{"required,each", "required", ""},
+		{"enum=each|every", "enum=each|every", ""},
+	}
+	for _, tt := range tests {
+		if field, items := splitEach(tt.tag); field != tt.field || items != tt.items {
+			t.Errorf("splitEach(%q) = %q, %q, want %q, %q", tt.tag, field, items, tt.field, tt.items)
+		}
+	}
+}
+
+func TestUnknownRulesPanic(t *testing.T) {
+	for _, tag := range []string{"requird", "pattern=email", "max=ten", "min=1"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("rules(%q) did not panic", tag)
+				}
+			}()
+			rule(tag, struct{}{})
+		}()
+	}
+}
+
+type options struct {
+	Size int `json:"size"`
+}
+
+func (o options) Validate() error {
+	if o.Size%2 != 0 {
+		return errors.New("size must be even")
+	}
+	return nil
+}
+
+type Paging struct {
+	Limit int `json:"limit" validate:"max=100"`
+}
+
+type request struct {
+	Name     string   `json:"name" validate:"required,max=10,pattern=name"`
+	Tags     []string `json:"tags,omitempty" validate:"max=2,each,required,pattern=path"`
+	NoJSON   string   `validate:"required"`
+	Skipped  string   `json:"-" validate:"required"`
+	Options  *options `json:"options,omitempty"`
+	Settings struct {
+		Mode  string  `json:"mode" validate:"enum=fast|slow"`
+		Inner options `json:"inner"`
+	} `json:"settings"`
+	Paging
+	unexported string `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	var r request
+	r.Tags = []string{"ok", "a//b", ""}
+	r.Options = &options{Size: 3}
+	r.Settings.Mode = "medium"
+	r.Settings.Inner.Size = 1
+	r.Paging.Limit = 500
+
+	want := []apperr.FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "tags", Message: "must have at most 2 items"},
+		{Field: "NoJSON", Message: "is required"},
+		{Field: "options", Message: "size must be even"},
+		{Field: "settings.mode", Message: "must be one of fast, slow"},
+		{Field: "settings.inner", Message: "size must be even"},
+		{Field: "limit", Message: "must be at most 100"},
+	}
+	if got := Struct(&r); !reflect.DeepEqual(got, want) {
+		t.Errorf("Struct =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Items are only checked if the slice itself passes, and are named by
+	// their index.
+	r.Tags = r.Tags[1:]
+	got := Struct(r)
+	var tagErrors []string
+	for _, e := range got {
+		if strings.HasPrefix(e.Field, "tags") {
+			tagErrors = append(tagErrors, e.Field+": "+e.Message)
+		}
+	}
+	if strings.Join(tagErrors, "\n") != "tags[0]: must be names separated by single slashes, without control characters\ntags[1]: is required" {
+		t.Errorf("errors of the tags =\n%s", strings.Join(tagErrors, "\n"))
+	}
+
+	// The fields of nested structs are named by their path.
+	type outer struct {
+		Inner *request `json:"inner"`
+	}
+	fields := Struct(outer{Inner: &request{Name: "n", NoJSON: "x", Options: &options{Size: 1}, Settings: r.Settings}})
+	if len(fields) != 3 || fields[0].Field != "inner.options" || fields[1].Field != "inner.settings.mode" || fields[2].Field != "inner.settings.inner" {
+		t.Errorf("errors of a nested struct = %+v", fields)
+	}
+
+	valid := request{Name: "notes", NoJSON: "x", Tags: []string{"a/b"}}
+	if fields := Struct(&valid); len(fields) != 0 {
+		t.Errorf("Struct(valid) = %+v", fields)
+	}
+	if fields := Struct((*request)(nil)); len(fields) != 0 {
+		t.Errorf("Struct(nil) = %+v", fields)
+	}
+}